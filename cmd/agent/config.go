@@ -0,0 +1,190 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"github.com/g0194776/lightningmonkey/pkg/entities"
+	"github.com/g0194776/lightningmonkey/pkg/utils"
+	flag "github.com/spf13/pflag"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+var (
+	//serverTransport is used by the shared client of API servers, it's configured by the TLS material and token of agent configuration.
+	serverTransport http.RoundTripper = http.DefaultTransport
+)
+
+//agentFlags holds the command line flags, only the explicitly given ones override the configuration file.
+type agentFlags struct {
+	server                 *string
+	address                *string
+	usedEthernetInterface  *string
+	clusterId              *string
+	nodeLabels             *string
+	nodePool               *string
+	isETCDRole             *bool
+	isMasterRole           *bool
+	isMinionRole           *bool
+	isHARole               *bool
+	listenPort             *int
+	containerRuntime       *string
+	healthFailureThreshold *int
+	containerdAddress      *string
+	logLevel               *string
+	id                     *string
+	certDir                *string
+}
+
+func loadAgentConfig(path string) (*entities.AgentConfig, error) {
+	if path == "" {
+		return utils.NewDefaultAgentConfig(), nil
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read agent configuration file %s, error: %s", path, err.Error())
+	}
+	return utils.LoadAgentConfig(data)
+}
+
+func (f *agentFlags) applyTo(c *entities.AgentConfig) error {
+	if flag.CommandLine.Changed("server") {
+		c.Servers = strings.Split(*f.server, ",")
+	}
+	if flag.CommandLine.Changed("address") {
+		c.Address = *f.address
+	}
+	if flag.CommandLine.Changed("nc") {
+		c.Interface = *f.usedEthernetInterface
+	}
+	if flag.CommandLine.Changed("cluster") {
+		c.ClusterId = *f.clusterId
+	}
+	if flag.CommandLine.Changed("labels") {
+		labels, err := utils.ParseAgentLabels(*f.nodeLabels)
+		if err != nil {
+			return fmt.Errorf("Illegal \"--labels\" flag, error: %s", err.Error())
+		}
+		c.Labels = labels
+	}
+	if flag.CommandLine.Changed("node-pool") {
+		c.NodePool = *f.nodePool
+	}
+	roleFlags := map[string]*bool{
+		entities.AgentRole_ETCD:   f.isETCDRole,
+		entities.AgentRole_Master: f.isMasterRole,
+		entities.AgentRole_Minion: f.isMinionRole,
+		entities.AgentRole_HA:     f.isHARole,
+	}
+	for _, role := range []string{entities.AgentRole_ETCD, entities.AgentRole_Master, entities.AgentRole_Minion, entities.AgentRole_HA} {
+		if !flag.CommandLine.Changed(role) {
+			continue
+		}
+		roles := []string{}
+		for i := 0; i < len(c.Roles); i++ {
+			if c.Roles[i] != role {
+				roles = append(roles, c.Roles[i])
+			}
+		}
+		if *roleFlags[role] {
+			roles = append(roles, role)
+		}
+		c.Roles = roles
+	}
+	if flag.CommandLine.Changed("port") {
+		c.Port = *f.listenPort
+	}
+	if flag.CommandLine.Changed("container-runtime") {
+		c.ContainerRuntime = *f.containerRuntime
+	}
+	if flag.CommandLine.Changed("health-failure-threshold") {
+		c.HealthFailureThreshold = *f.healthFailureThreshold
+	}
+	if flag.CommandLine.Changed("containerd-address") {
+		c.ContainerdAddress = *f.containerdAddress
+	}
+	if flag.CommandLine.Changed("log-level") {
+		c.LogLevel = *f.logLevel
+	}
+	if flag.CommandLine.Changed("id") {
+		c.AgentId = *f.id
+	}
+	if flag.CommandLine.Changed("cert-dir") && *f.certDir != "" {
+		c.Paths.Certificates = *f.certDir
+	}
+	return nil
+}
+
+//newAgentArgs converts the validated configuration.
+func newAgentArgs(c *entities.AgentConfig) AgentArgs {
+	isETCDRole := utils.HasAgentRole(c, entities.AgentRole_ETCD)
+	isMasterRole := utils.HasAgentRole(c, entities.AgentRole_Master)
+	isMinionRole := utils.HasAgentRole(c, entities.AgentRole_Minion)
+	isHARole := utils.HasAgentRole(c, entities.AgentRole_HA)
+	nodeLabels := utils.FormatAgentLabels(c.Labels)
+	return AgentArgs{
+		AgentId:                c.AgentId,
+		Servers:                c.Servers,
+		ClusterId:              &c.ClusterId,
+		Address:                &c.Address,
+		NodeLabels:             &nodeLabels,
+		NodePool:               &c.NodePool,
+		UsedEthernetInterface:  &c.Interface,
+		IsETCDRole:             &isETCDRole,
+		IsMasterRole:           &isMasterRole,
+		IsMinionRole:           &isMinionRole,
+		IsHARole:               &isHARole,
+		ListenPort:             &c.Port,
+		ContainerRuntime:       &c.ContainerRuntime,
+		ContainerdAddress:      &c.ContainerdAddress,
+		HealthFailureThreshold: &c.HealthFailureThreshold,
+		RegisterTimeout:        c.Timeouts.Register.Duration,
+		RequestTimeout:         c.Timeouts.Request.Duration,
+	}
+}
+
+func newServerTransport(c *entities.AgentConfig) (http.RoundTripper, error) {
+	var transport http.RoundTripper = http.DefaultTransport
+	if c.TLS != nil {
+		t := http.DefaultTransport.(*http.Transport).Clone()
+		t.TLSClientConfig = &tls.Config{InsecureSkipVerify: c.TLS.InsecureSkipVerify}
+		if c.TLS.CAFile != "" {
+			ca, err := ioutil.ReadFile(c.TLS.CAFile)
+			if err != nil {
+				return nil, fmt.Errorf("Failed to read CA file of API servers, error: %s", err.Error())
+			}
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM(ca) {
+				return nil, errors.New("Failed to parse CA file of API servers: " + c.TLS.CAFile)
+			}
+			t.TLSClientConfig.RootCAs = pool
+		}
+		if c.TLS.CertFile != "" {
+			cert, err := tls.LoadX509KeyPair(c.TLS.CertFile, c.TLS.KeyFile)
+			if err != nil {
+				return nil, fmt.Errorf("Failed to load client certificate for API servers, error: %s", err.Error())
+			}
+			t.TLSClientConfig.Certificates = []tls.Certificate{cert}
+		}
+		transport = t
+	}
+	if c.Token != "" {
+		transport = &tokenTransport{token: c.Token, next: transport}
+	}
+	return transport, nil
+}
+
+//tokenTransport authenticates the agent by the bearer token.
+type tokenTransport struct {
+	token string
+	next  http.RoundTripper
+}
+
+func (t *tokenTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	r := req.Clone(req.Context())
+	r.Header.Set("Authorization", "Bearer "+t.token)
+	return t.next.RoundTrip(r)
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/g0194776/lightningmonkey/pkg/certs"
+	"github.com/g0194776/lightningmonkey/pkg/entities"
+	"github.com/g0194776/lightningmonkey/pkg/utils"
+	"github.com/kataras/iris/context"
+	"github.com/sirupsen/logrus"
+	"github.com/vishvananda/netlink"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+//GetDiagnostics returns a redacted tar.gz archive which contains the generated configurations and the runtime status of this host.
+func (a *LightningMonkeyAgent) GetDiagnostics(ctx context.Context) {
+	if !a.authorize(ctx) {
+		return
+	}
+	buffer := bytes.Buffer{}
+	err := a.buildDiagnosticsArchive(&buffer)
+	if err != nil {
+		logrus.Errorf("Failed to build diagnostics archive, error: %s", err.Error())
+		writeErrorWithStatusCode(ctx, http.StatusInternalServerError, entities.Response{ErrorId: entities.OperationFailed, Reason: err.Error()})
+		return
+	}
+	ctx.ContentType("application/gzip")
+	ctx.StatusCode(http.StatusOK)
+	_, _ = ctx.Write(buffer.Bytes())
+}
+
+//buildDiagnosticsArchive never fails because of a single item, failures are recorded in "errors.txt" of the archive.
+func (a *LightningMonkeyAgent) buildDiagnosticsArchive(buffer *bytes.Buffer) error {
+	da := utils.NewDiagnosticsArchive(buffer)
+	failures := []string{}
+	addFailure := func(item string, err error) {
+		failures = append(failures, fmt.Sprintf("%s: %s", item, err.Error()))
+	}
+	files := map[string]string{
+		"recovery.json":                RECOVERY_FILE_PATH,
+		"configs/kubelet_settings.yml": filepath.Join(CERTIFICATE_STORAGE_PATH, "kubelet_settings.yml"),
+		"configs/haproxy.cfg":          haProxyConfigPath,
+		"configs/keepalived.conf":      keepAlivedConfigPath,
+	}
+	manifests, _ := filepath.Glob(filepath.Join(certs.GetManifestDirectory(CERTIFICATE_STORAGE_PATH), "*.yaml"))
+	for i := 0; i < len(manifests); i++ {
+		files["manifests/"+filepath.Base(manifests[i])] = manifests[i]
+	}
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		data, err := ioutil.ReadFile(files[name])
+		if err != nil {
+			//a file only exists on the hosts which have the relevant role.
+			if !os.IsNotExist(err) {
+				addFailure(name, err)
+			}
+			continue
+		}
+		if err = da.AddFile(name, data); err != nil {
+			return err
+		}
+	}
+	cs, err := a.containerRuntime.ListContainers()
+	if err != nil {
+		addFailure("containers.json", err)
+	} else if err = da.AddJSON("containers.json", cs); err != nil {
+		return err
+	}
+	routes := entities.GetSystemRoutesResponse{}
+	routes.ManagedRoutes, err = loadManagedSystemRoutes()
+	if err != nil {
+		addFailure("routes.json", err)
+	}
+	link, err := netlink.LinkByName(*a.arg.UsedEthernetInterface)
+	if err == nil {
+		routes.ActualRoutes, err = listManagedSystemRoutes(link)
+	}
+	if err != nil {
+		addFailure("routes.json", err)
+	}
+	if err = da.AddJSON("routes.json", routes); err != nil {
+		return err
+	}
+	hi, err := a.collectHostInformation()
+	if err != nil {
+		addFailure("host.json", err)
+	} else if err = da.AddJSON("host.json", hi); err != nil {
+		return err
+	}
+	if err = da.AddJSON("probes.json", a.getComponentProbes()); err != nil {
+		return err
+	}
+	if len(failures) > 0 {
+		if err = da.AddFile("errors.txt", []byte(strings.Join(failures, "\n")+"\n")); err != nil {
+			return err
+		}
+	}
+	return da.Close()
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"github.com/g0194776/lightningmonkey/pkg/containers"
+	"github.com/g0194776/lightningmonkey/pkg/entities"
+	"github.com/g0194776/lightningmonkey/pkg/utils"
+	"github.com/sirupsen/logrus"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	configDriftDetectionInterval = time.Minute
+)
+
+//detectConfigDriftPeriodically compares the expected state of installed components with the actual one,
+//the drifted components are re-applied only if the remediation policy of cluster allows.
+func (a *LightningMonkeyAgent) detectConfigDriftPeriodically() {
+	for {
+		time.Sleep(configDriftDetectionInterval)
+		if atomic.LoadInt32(&a.hasRegistered) != 1 {
+			continue
+		}
+		if status := a.checkConfigDrift(); status != nil {
+			a.setConfigDrift(status)
+		}
+	}
+}
+
+//checkConfigDrift holds the job lock so that none of jobs could be performed during remediation,
+//it returns nil if the configurations are being changed by a job.
+func (a *LightningMonkeyAgent) checkConfigDrift() *entities.ConfigDriftStatus {
+	a.jobLock.Lock()
+	defer a.jobLock.Unlock()
+	if atomic.LoadInt32(&a.isTrackingJob) == 1 {
+		return nil
+	}
+	status := &entities.ConfigDriftStatus{Drifts: a.detectConfigDrift(), LastCheckTime: time.Now()}
+	if len(status.Drifts) > 0 {
+		logrus.Warnf("Detected configuration drift: %#v", status.Drifts)
+		if remediated, err := a.remediateConfigDrift(status.Drifts); remediated {
+			now := time.Now()
+			status.LastRemediationTime = &now
+			if err != nil {
+				status.RemediationError = err.Error()
+			}
+			status.Drifts = a.detectConfigDrift()
+			status.LastCheckTime = time.Now()
+		}
+	}
+	return status
+}
+
+func (a *LightningMonkeyAgent) detectConfigDrift() []entities.ConfigDrift {
+	cs, err := a.containerRuntime.ListContainers()
+	if err != nil {
+		logrus.Errorf("Failed to retrieve all containers information, error: %s", err.Error())
+	}
+	drifts := []entities.ConfigDrift{}
+	for _, c := range recoverableComponents {
+		if rc := a.getRecoveryComponent(c.name); rc != nil {
+			drifts = append(drifts, a.detectComponentDrift(c, rc, cs)...)
+		}
+	}
+	return drifts
+}
+
+//detectComponentDrift prefers the re-rendered configurations, the recorded checksums are used for the ones generated by kubeadm.
+func (a *LightningMonkeyAgent) detectComponentDrift(c recoverableComponent, rc *RecoveryComponent, cs []containers.Container) []entities.ConfigDrift {
+	expected := make(map[string]string, len(rc.Checksums))
+	for p, checksum := range rc.Checksums {
+		expected[p] = checksum
+	}
+	if c.render != nil {
+		files, err := c.render(a, rc.Arguments)
+		if err != nil {
+			logrus.Errorf("Failed to render expected configurations of component %s, error: %s", c.name, err.Error())
+		}
+		for p, content := range files {
+			expected[p] = utils.ContentChecksum([]byte(content))
+		}
+	}
+	drifts := utils.DetectFileDrifts(c.name, expected)
+	if c.container != "" && cs != nil {
+		ct := containers.FindContainer(cs, func(ct containers.Container) bool { return ct.Name == c.container })
+		if ct == nil {
+			drifts = append(drifts, entities.ConfigDrift{Component: c.name, Target: c.container, Reason: "container missing"})
+		} else if !ct.IsRunning {
+			drifts = append(drifts, entities.ConfigDrift{Component: c.name, Target: c.container, Reason: "container not running"})
+		}
+	}
+	if c.isInSync != nil {
+		inSync, err := c.isInSync(a, rc.Arguments)
+		if err != nil {
+			logrus.Errorf("Failed to verify component %s, error: %s", c.name, err.Error())
+		} else if !inSync {
+			drifts = append(drifts, entities.ConfigDrift{Component: c.name, Target: c.name, Reason: "out of sync"})
+		}
+	}
+	return drifts
+}
+
+//remediateConfigDrift re-applies the drifted components which are allowed by the remediation policy,
+//it returns false if none of them has been re-applied.
+func (a *LightningMonkeyAgent) remediateConfigDrift(drifts []entities.ConfigDrift) (bool, error) {
+	policy := a.getDriftRemediation()
+	drifted := make(map[string]bool, len(drifts))
+	for i := 0; i < len(drifts); i++ {
+		drifted[drifts[i].Component] = true
+	}
+	var lastErr error
+	remediated := false
+	for _, c := range recoverableComponents {
+		if !drifted[c.name] || !utils.ShouldRemediateDrift(policy, c.name) {
+			continue
+		}
+		rc := a.getRecoveryComponent(c.name)
+		if rc == nil {
+			continue
+		}
+		remediated = true
+		logrus.Warnf("Remediating configuration drift of component %s...", c.name)
+		if err := c.reapply(a, rc.Arguments); err != nil {
+			logrus.Errorf("Failed to remediate configuration drift of component %s, error: %s", c.name, err.Error())
+			lastErr = err
+			continue
+		}
+		a.recordComponent(c.name, rc.Arguments)
+	}
+	return remediated, lastErr
+}
+
+func (a *LightningMonkeyAgent) setConfigDrift(status *entities.ConfigDriftStatus) {
+	a.statusLock.Lock()
+	defer a.statusLock.Unlock()
+	a.configDrift = status
+}
+
+func (a *LightningMonkeyAgent) getConfigDrift() *entities.ConfigDriftStatus {
+	a.statusLock.RLock()
+	defer a.statusLock.RUnlock()
+	if a.configDrift == nil {
+		return nil
+	}
+	s := *a.configDrift
+	return &s
+}
+
+func (a *LightningMonkeyAgent) setDriftRemediation(policy *entities.DriftRemediationSettings) {
+	a.statusLock.Lock()
+	defer a.statusLock.Unlock()
+	a.driftRemediation = policy
+}
+
+func (a *LightningMonkeyAgent) getDriftRemediation() *entities.DriftRemediationSettings {
+	a.statusLock.RLock()
+	defer a.statusLock.RUnlock()
+	return a.driftRemediation
+}
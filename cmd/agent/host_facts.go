@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/g0194776/lightningmonkey/pkg/entities"
+	"github.com/matishsiao/goInfo"
+	"github.com/shirou/gopsutil/cpu"
+	"github.com/shirou/gopsutil/mem"
+	"github.com/sirupsen/logrus"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	hostFactsRefreshInterval = time.Minute * 10
+)
+
+//collectHostInformation gathers the facts which are used for choosing nodes for ETCD or ES data nodes.
+func (a *LightningMonkeyAgent) collectHostInformation() (entities.HostInformation, error) {
+	hi := entities.HostInformation{UpdateTime: time.Now()}
+	ci, err := cpu.InfoWithContext(context.Background())
+	if err != nil {
+		return hi, fmt.Errorf("Failed to obtain host CPU information, error: %s", err.Error())
+	}
+	memory, err := mem.VirtualMemory()
+	if err != nil {
+		return hi, fmt.Errorf("Failed to obtain host Memory information, error: %s", err.Error())
+	}
+	gi := goInfo.GetInfo()
+	hi.CPUCores = ci[0].Cores
+	hi.CPUMhz = ci[0].Mhz
+	hi.MemoryTotalMB = memory.Total / 1024 / 1024
+	hi.OS = gi.GoOS
+	hi.Kernel = fmt.Sprintf("%s %s", gi.Kernel, gi.Core)
+	hi.LogicalCPUs, err = cpu.Counts(true)
+	if err != nil {
+		return hi, fmt.Errorf("Failed to obtain count of logical CPUs, error: %s", err.Error())
+	}
+	hi.Disks = getDisks()
+	hi.NICs = getNICs()
+	hi.CgroupVersion = getCgroupVersion()
+	hi.MachineId = getMachineId()
+	hi.ContainerRuntime = a.containerRuntime.GetName()
+	//an unavailable runtime is reported by preflight checks.
+	hi.ContainerRuntimeVersion, _ = a.containerRuntime.GetVersion()
+	return hi, nil
+}
+
+//refreshHostInformation returns the newest host facts if the refresh interval has elapsed, nil otherwise.
+func (a *LightningMonkeyAgent) refreshHostInformation() *entities.HostInformation {
+	if time.Since(a.getLastHostFactsTime()) < hostFactsRefreshInterval {
+		return nil
+	}
+	hi, err := a.collectHostInformation()
+	if err != nil {
+		logrus.Errorf("Failed to refresh host information, error: %s", err.Error())
+		return nil
+	}
+	a.setLastHostFactsTime(hi.UpdateTime)
+	return &hi
+}
+
+func (a *LightningMonkeyAgent) setLastHostFactsTime(t time.Time) {
+	a.statusLock.Lock()
+	defer a.statusLock.Unlock()
+	a.lastHostFactsTime = t
+}
+
+func (a *LightningMonkeyAgent) getLastHostFactsTime() time.Time {
+	a.statusLock.RLock()
+	defer a.statusLock.RUnlock()
+	return a.lastHostFactsTime
+}
+
+//getDisks lists block devices in "/sys/block" except the virtual ones, mount points of partitions belong to their disks.
+func getDisks() []entities.DiskInformation {
+	files, err := ioutil.ReadDir("/sys/block")
+	if err != nil {
+		return nil
+	}
+	mounts := getMountPoints()
+	disks := []entities.DiskInformation{}
+	for i := 0; i < len(files); i++ {
+		name := files[i].Name()
+		if strings.HasPrefix(name, "loop") || strings.HasPrefix(name, "ram") || strings.HasPrefix(name, "sr") {
+			continue
+		}
+		d := entities.DiskInformation{Name: name}
+		//size is always counted in 512-byte sectors.
+		if sectors, err := strconv.ParseUint(readSysFile(filepath.Join("/sys/block", name, "size")), 10, 64); err == nil {
+			d.SizeMB = sectors * 512 / 1024 / 1024
+		}
+		d.IsRotational = readSysFile(filepath.Join("/sys/block", name, "queue", "rotational")) == "1"
+		for dev, mps := range mounts {
+			if dev == name {
+				d.MountPoints = append(d.MountPoints, mps...)
+			} else if _, err := os.Stat(filepath.Join("/sys/block", name, dev)); err == nil {
+				d.MountPoints = append(d.MountPoints, mps...)
+			}
+		}
+		sort.Strings(d.MountPoints)
+		disks = append(disks, d)
+	}
+	return disks
+}
+
+//getMountPoints parses "/proc/mounts", it returns mount points by the device names, e.g: "sda1" -> ["/", "/var/lib/docker"].
+func getMountPoints() map[string][]string {
+	data, err := ioutil.ReadFile("/proc/mounts")
+	if err != nil {
+		return nil
+	}
+	mounts := map[string][]string{}
+	lines := strings.Split(string(data), "\n")
+	for i := 0; i < len(lines); i++ {
+		fields := strings.Fields(lines[i])
+		if len(fields) < 2 || !strings.HasPrefix(fields[0], "/dev/") {
+			continue
+		}
+		dev := filepath.Base(fields[0])
+		mounts[dev] = append(mounts[dev], fields[1])
+	}
+	return mounts
+}
+
+func getNICs() []entities.NICInformation {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil
+	}
+	nics := []entities.NICInformation{}
+	for i := 0; i < len(ifaces); i++ {
+		if ifaces[i].Flags&net.FlagLoopback != 0 {
+			continue
+		}
+		nic := entities.NICInformation{Name: ifaces[i].Name, MAC: ifaces[i].HardwareAddr.String()}
+		addrs, err := ifaces[i].Addrs()
+		if err == nil {
+			for j := 0; j < len(addrs); j++ {
+				nic.Addresses = append(nic.Addresses, addrs[j].String())
+			}
+		}
+		//reading speed of a virtual or down NIC fails or returns -1.
+		if speed, err := strconv.Atoi(readSysFile(filepath.Join("/sys/class/net", ifaces[i].Name, "speed"))); err == nil && speed > 0 {
+			nic.SpeedMbps = speed
+		}
+		nics = append(nics, nic)
+	}
+	return nics
+}
+
+//getCgroupVersion returns 2 if the unified hierarchy is mounted on "/sys/fs/cgroup".
+func getCgroupVersion() int {
+	if _, err := os.Stat("/sys/fs/cgroup/cgroup.controllers"); err == nil {
+		return 2
+	}
+	return 1
+}
+
+func getMachineId() string {
+	if id := readSysFile("/etc/machine-id"); id != "" {
+		return id
+	}
+	return readSysFile("/var/lib/dbus/machine-id")
+}
+
+func readSysFile(filePath string) string {
+	data, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
@@ -4,12 +4,13 @@ import (
 	"encoding/json"
 	"fmt"
 	"github.com/g0194776/lightningmonkey/pkg/entities"
+	"github.com/g0194776/lightningmonkey/pkg/utils"
 	"github.com/kataras/iris"
 	"github.com/kataras/iris/context"
 	"github.com/sirupsen/logrus"
 	"github.com/vishvananda/netlink"
 	"io/ioutil"
-	"net"
+	"net/http"
 	"sync/atomic"
 )
 
@@ -19,8 +20,11 @@ type HelloResponse struct {
 func (a *LightningMonkeyAgent) InitializeWebServer() {
 	app := iris.New()
 	app.Get("/hello", HealthCheck)
+	app.Get("/system/routes", a.GetSystemRoutingRules)
 	app.Post("/system/routes", a.GenerateSystemRoutingRules)
 	app.Post("/registration/change", a.RegistrationDataChange)
+	app.Get("/logs", a.GetLogs)
+	app.Get("/diagnostics", a.GetDiagnostics)
 	logrus.Infof("Starting Web Server...")
 	app.Run(iris.Addr(fmt.Sprintf("0.0.0.0:%d", *a.arg.ListenPort)))
 }
@@ -41,35 +45,14 @@ func (a *LightningMonkeyAgent) GenerateSystemRoutingRules(ctx context.Context) {
 		ctx.Next()
 		return
 	}
-	logrus.Infof("Setting system routing rules: %#v", req)
-	link, err := netlink.LinkByName(*a.arg.UsedEthernetInterface)
+	logrus.Debugf("Setting system routing rules: %#v", req)
+	err = a.reconcileSystemRoutes(req.Nodes)
 	if err != nil {
-		logrus.Errorf("Failed to get link information for device %s, error: %s", *a.arg.UsedEthernetInterface, err.Error())
-	} else {
-		if req.Nodes != nil && len(req.Nodes) > 0 {
-			addedRulesCount := 0
-			var rr netlink.Route
-			//TODO(g0194776): considering add more check logic for routing rules replacement.
-			for i := 0; i < len(req.Nodes); i++ {
-				_, cidr, err := net.ParseCIDR(req.Nodes[i].PodCIDR)
-				if err != nil {
-					logrus.Errorf("Failed to parse pod CIDR: %s, error: %s", req.Nodes[i].PodCIDR, err.Error())
-					continue
-				}
-				rr = netlink.Route{
-					Gw:        net.ParseIP(req.Nodes[i].NodeIP),
-					Dst:       cidr,
-					LinkIndex: link.Attrs().Index,
-				}
-				err = netlink.RouteAdd(&rr)
-				if err != nil {
-					logrus.Errorf("Failed to add system routing rule, error: %s", err.Error())
-				} else {
-					addedRulesCount++
-				}
-			}
-			logrus.Warnf("Successfully added system routing rules count: %d", addedRulesCount)
-		}
+		logrus.Errorf("Failed to reconcile system routing rules, error: %s", err.Error())
+		rsp := entities.Response{ErrorId: entities.OperationFailed, Reason: err.Error()}
+		ctx.JSON(&rsp)
+		ctx.Next()
+		return
 	}
 	rsp := entities.Response{ErrorId: 0}
 	_, _ = ctx.JSON(rsp)
@@ -77,6 +60,31 @@ func (a *LightningMonkeyAgent) GenerateSystemRoutingRules(ctx context.Context) {
 	return
 }
 
+func (a *LightningMonkeyAgent) GetSystemRoutingRules(ctx context.Context) {
+	managed, err := loadManagedSystemRoutes()
+	if err != nil {
+		rsp := entities.Response{ErrorId: entities.OperationFailed, Reason: err.Error()}
+		ctx.JSON(&rsp)
+		ctx.Next()
+		return
+	}
+	var actual []entities.SystemRoute
+	link, err := netlink.LinkByName(*a.arg.UsedEthernetInterface)
+	if err == nil {
+		actual, err = listManagedSystemRoutes(link)
+	}
+	if err != nil {
+		rsp := entities.Response{ErrorId: entities.OperationFailed, Reason: err.Error()}
+		ctx.JSON(&rsp)
+		ctx.Next()
+		return
+	}
+	rsp := entities.GetSystemRoutesResponse{Response: entities.Response{ErrorId: 0}, ManagedRoutes: managed, ActualRoutes: actual}
+	_, _ = ctx.JSON(rsp)
+	ctx.Next()
+	return
+}
+
 func (a *LightningMonkeyAgent) RegistrationDataChange(ctx context.Context) {
 	req := entities.ChangeClusterAndRolesRequest{}
 	httpData, err := ioutil.ReadAll(ctx.Request().Body)
@@ -100,12 +108,19 @@ func (a *LightningMonkeyAgent) RegistrationDataChange(ctx context.Context) {
 	a.arg.IsMasterRole = &req.IsMasterRole
 	a.arg.IsMinionRole = &req.IsMinionRole
 	a.arg.IsHARole = &req.IsHARole
+	//keeps the node pool given by "--node-pool" or recovery file if the request does not assign a new one.
+	if req.NodePool != nil {
+		a.arg.NodePool = req.NodePool
+	}
 	if a.rr != nil {
 		a.rr.ClusterID = req.NewClusterId
 		a.rr.IsETCDRole = req.IsETCDRole
 		a.rr.IsMasterRole = req.IsMasterRole
 		a.rr.IsMinionRole = req.IsMinionRole
 		a.rr.IsHARole = req.IsHARole
+		if req.NodePool != nil {
+			a.rr.NodePool = req.NodePool
+		}
 		err = a.saveRecoveryFile()
 		if err != nil {
 			logrus.Errorf("Failed to save recovery file by updating agent's cluster-id and roles, error: %s", err.Error())
@@ -120,6 +135,21 @@ func (a *LightningMonkeyAgent) RegistrationDataChange(ctx context.Context) {
 	return
 }
 
+//authorize checks the bearer token against the access token which is given to API server during registering, it responds HTTP 401 if failed.
+func (a *LightningMonkeyAgent) authorize(ctx context.Context) bool {
+	if utils.IsAgentAccessTokenMatched(ctx.GetHeader("Authorization"), a.accessToken) {
+		return true
+	}
+	writeErrorWithStatusCode(ctx, http.StatusUnauthorized, entities.Response{ErrorId: entities.ParameterError, Reason: "Illegal access token."})
+	return false
+}
+
+func writeErrorWithStatusCode(ctx context.Context, statusCode int, rsp entities.Response) {
+	ctx.StatusCode(statusCode)
+	_, _ = ctx.JSON(&rsp)
+	ctx.Next()
+}
+
 func HealthCheck(ctx iris.Context) {
 	_, _ = ctx.JSON(HelloResponse{})
 	ctx.Next()
@@ -2,17 +2,13 @@ package main
 
 import (
 	"bytes"
-	"context"
-	"crypto/md5"
-	"encoding/hex"
 	"fmt"
-	"github.com/docker/engine-api/types"
 	"github.com/g0194776/lightningmonkey/pkg/common"
 	"github.com/g0194776/lightningmonkey/pkg/entities"
+	"github.com/g0194776/lightningmonkey/pkg/utils"
 	"github.com/sirupsen/logrus"
 	"golang.org/x/xerrors"
 	"html/template"
-	"io"
 	"strings"
 )
 
@@ -32,7 +28,7 @@ etcd:
         - "127.0.0.1"
         extraArgs:
             initial-cluster: {{.SERVERS}}
-            initial-cluster-state: new
+            initial-cluster-state: {{.STATE}}
             name: {{.NAME}}
             listen-peer-urls: https://{{.ADDR}}:2380
             listen-client-urls: https://{{.ADDR}}:2379
@@ -63,12 +59,18 @@ func HandleDeployETCD(job *entities.AgentJob, a *LightningMonkeyAgent) (bool, er
 		return false, xerrors.Errorf("Failed to parse ETCD configuration template, error: %s %w", err.Error(), crashError)
 	}
 	logrus.Infof("SERVER ADDR: %s", *a.arg.Address)
+	//joining an existing ETCD cluster requires that the member has been added.
+	state := "new"
+	if job.Arguments["state"] == "existing" {
+		state = "existing"
+	}
 	args := map[string]string{
+		"STATE":   state,
 		"NAME":    generateETCDName(a, *a.arg.Address),
 		"HOST":    *a.arg.Address,
 		"SERVERS": serversConnection,
 		"IMAGE":   a.basicImages.Images["etcd"].ImageName,
-		"DATADIR": "/data/etcd",
+		"DATADIR": etcdDataDir,
 		"ADDR":    *a.arg.Address, //"0.0.0.0",
 	}
 	buffer := bytes.Buffer{}
@@ -84,80 +86,105 @@ func HandleDeployETCD(job *entities.AgentJob, a *LightningMonkeyAgent) (bool, er
 }
 
 func CheckETCDHealth(job *entities.AgentJob, a *LightningMonkeyAgent) (bool, error) {
-	var err error
-	var containers []types.Container
-	containers, err = a.dockerClient.ContainerList(context.Background(), types.ContainerListOptions{All: true})
-	if err != nil {
-		logrus.Errorf("Failed to retrieve all containers information, error: %s", err.Error())
-		return false, err
-	}
-	if containers == nil || len(containers) == 0 {
+	if !a.probeComponent(Component_ETCD, a.probeETCD) {
+		a.setETCDMembers(nil)
 		return false, nil
 	}
-	var destContainerId string
-	for i := 0; i < len(containers); i++ {
-		logrus.Infof("container status: %s, names: %#v", containers[i].Status, containers[i].Names)
-		if strings.Contains(containers[i].Names[0], "k8s_etcd") &&
-			strings.Contains(containers[i].Names[0], "kube-system") &&
-			strings.Contains(containers[i].Status, "Up") {
-			destContainerId = containers[i].ID
-			break
-		}
-	}
-	//unhealthy or not expected status.
-	if destContainerId == "" {
+	//the members are listed by "etcdctl" inside of the ETCD container.
+	destContainerId, err := a.getETCDContainerId()
+	if err != nil {
+		logrus.Errorf("Failed to list ETCD members, error: %s", err.Error())
 		return false, nil
 	}
 	logrus.Debugf("Try performing ETCD health check with container-id: %s", destContainerId)
 	result, err := getETCDClusterInfo(a, destContainerId)
 	if err != nil {
 		logrus.Errorf("Failed to perform ETCD health check, error: %s", err.Error())
+		a.setETCDMembers(nil)
 		return false, nil
 	}
 	logrus.Debugf("ETCD health check result: \n%s", result)
-	//return healthy status util expected count of ETCD nodes are ready.
-	if strings.Count(string(result), "started") >= a.expectedETCDNodeCount {
-		return true, nil
+	members := parseETCDMembers(result)
+	a.setETCDMembers(members)
+	var isStarted bool
+	startedCount := 0
+	for i := 0; i < len(members); i++ {
+		if !members[i].IsStarted {
+			continue
+		}
+		startedCount++
+		if members[i].Name == generateETCDName(a, *a.arg.Address) {
+			isStarted = true
+		}
+	}
+	//return healthy status util expected count of ETCD nodes are ready, the cluster might have been shrunk afterwards.
+	expectedCount := a.expectedETCDNodeCount
+	if len(members) < expectedCount {
+		expectedCount = len(members)
 	}
-	return false, nil
+	return isStarted && startedCount >= expectedCount, nil
 }
 
 func generateETCDName(a *LightningMonkeyAgent, addr string) string {
-	hasher := md5.New()
-	hasher.Write([]byte([]byte(addr)))
-	return hex.EncodeToString(hasher.Sum(nil))
+	return utils.GetETCDMemberName(addr)
 }
 
 func getETCDClusterInfo(a *LightningMonkeyAgent, containerId string) (string, error) {
+	return execETCDCtl(a, containerId, "member list")
+}
+
+func execETCDCtl(a *LightningMonkeyAgent, containerId string, command string) (string, error) {
 	//docker exec 01f sh -c  "export ETCDCTL_API=3 && /usr/local/bin/etcdctl --endpoints=https://[192.168.33.11]:2379 --cacert=/etc/kubernetes/pki/etcd/ca.crt --cert=/etc/kubernetes/pki/etcd/healthcheck-client.crt --key=/etc/kubernetes/pki/etcd/healthcheck-client.key member list"
-	cmdStr := fmt.Sprintf("export ETCDCTL_API=3 && /usr/local/bin/etcdctl --endpoints=https://[%s]:2379 --cacert=/etc/kubernetes/pki/etcd/ca.crt --cert=/etc/kubernetes/pki/etcd/healthcheck-client.crt --key=/etc/kubernetes/pki/etcd/healthcheck-client.key member list", *a.arg.Address)
-	config := types.ExecConfig{
-		AttachStdout: true,
-		AttachStderr: true,
-		Cmd:          []string{"/bin/sh", "-c", cmdStr},
-	}
-	execID, err := a.dockerClient.ContainerExecCreate(context.TODO(), containerId, config)
+	cmdStr := fmt.Sprintf("export ETCDCTL_API=3 && /usr/local/bin/etcdctl --endpoints=https://[%s]:2379 --cacert=/etc/kubernetes/pki/etcd/ca.crt --cert=/etc/kubernetes/pki/etcd/healthcheck-client.crt --key=/etc/kubernetes/pki/etcd/healthcheck-client.key %s", *a.arg.Address, command)
+	result, err := execInContainer(a, containerId, cmdStr)
 	if err != nil {
-		return "", err
+		return "", fmt.Errorf("etcdctl %s", err.Error())
 	}
-	res, err := a.dockerClient.ContainerExecAttach(context.TODO(), execID.ID, types.ExecConfig{})
+	return result, nil
+}
+
+//execInContainer runs a shell command inside of the given container, it returns error if the command exited with non-zero code.
+func execInContainer(a *LightningMonkeyAgent, containerId string, cmdStr string) (string, error) {
+	stdout, stderr, exitCode, err := a.containerRuntime.Exec(containerId, []string{"/bin/sh", "-c", cmdStr})
 	if err != nil {
 		return "", err
 	}
-	err = a.dockerClient.ContainerExecStart(context.TODO(), execID.ID, types.ExecStartCheck{})
-	if err != nil {
-		return "", err
+	if exitCode != 0 {
+		return "", fmt.Errorf("exited with code: %d, error: %s", exitCode, strings.TrimSpace(stderr))
 	}
-	sb := strings.Builder{}
-	for {
-		content, _, err := res.Reader.ReadLine()
-		if err != nil {
-			if err != io.EOF {
-				return "", err
-			}
-			break
+	return stdout, nil
+}
+
+//parseETCDMembers parses the output of "member list", e.g: "8e9e05c52164694d, started, infra1, https://10.0.0.1:2380, https://10.0.0.1:2379".
+func parseETCDMembers(result string) []entities.ETCDMember {
+	members := []entities.ETCDMember{}
+	lines := strings.Split(result, "\n")
+	for i := 0; i < len(lines); i++ {
+		fields := strings.Split(lines[i], ",")
+		if len(fields) < 4 {
+			continue
 		}
-		sb.WriteString(string(content) + "\n")
-	}
-	return sb.String(), nil
+		for j := 0; j < len(fields); j++ {
+			fields[j] = strings.TrimSpace(fields[j])
+		}
+		members = append(members, entities.ETCDMember{
+			Id:        fields[0],
+			IsStarted: fields[1] == "started",
+			Name:      fields[2],
+			PeerURL:   fields[3],
+		})
+	}
+	return members
+}
+
+func (a *LightningMonkeyAgent) setETCDMembers(members []entities.ETCDMember) {
+	a.statusLock.Lock()
+	defer a.statusLock.Unlock()
+	a.etcdMembers = members
+}
+
+func (a *LightningMonkeyAgent) getETCDMembers() []entities.ETCDMember {
+	a.statusLock.RLock()
+	defer a.statusLock.RUnlock()
+	return a.etcdMembers
 }
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/g0194776/lightningmonkey/pkg/containers"
+	"github.com/g0194776/lightningmonkey/pkg/entities"
+	"github.com/sirupsen/logrus"
+)
+
+//HandleETCDMember adds or removes an ETCD member through the local ETCD member,
+//failures never crash agent because the scheduler will dispatch the job again.
+func HandleETCDMember(job *entities.AgentJob, a *LightningMonkeyAgent) (bool, error) {
+	if job.Arguments == nil || job.Arguments["operation"] == "" {
+		return false, errors.New("Illegal ETCD membership job, required arguments are missed")
+	}
+	containerId, err := a.getETCDContainerId()
+	if err != nil {
+		return false, err
+	}
+	var command string
+	switch job.Arguments["operation"] {
+	case entities.ETCDMemberOperation_Add:
+		if job.Arguments["name"] == "" || job.Arguments["peer-url"] == "" {
+			return false, errors.New("Illegal ETCD membership job, \"name\" and \"peer-url\" are required for adding member")
+		}
+		command = fmt.Sprintf("member add %s --peer-urls=%s", job.Arguments["name"], job.Arguments["peer-url"])
+	case entities.ETCDMemberOperation_Remove:
+		if job.Arguments["member-id"] == "" {
+			return false, errors.New("Illegal ETCD membership job, \"member-id\" is required for removing member")
+		}
+		command = fmt.Sprintf("member remove %s", job.Arguments["member-id"])
+	default:
+		return false, fmt.Errorf("Unsupported ETCD membership operation: %s", job.Arguments["operation"])
+	}
+	logrus.Infof("Changing ETCD membership: %s...", command)
+	_, err = execETCDCtl(a, containerId, command)
+	if err != nil {
+		return false, fmt.Errorf("Failed to change ETCD membership, error: %s", err.Error())
+	}
+	return true, nil
+}
+
+func CheckETCDMemberHealth(job *entities.AgentJob, a *LightningMonkeyAgent) (bool, error) {
+	if job == nil || job.Arguments == nil {
+		return false, nil
+	}
+	containerId, err := a.getETCDContainerId()
+	if err != nil {
+		return false, err
+	}
+	result, err := getETCDClusterInfo(a, containerId)
+	if err != nil {
+		return false, err
+	}
+	members := parseETCDMembers(result)
+	a.setETCDMembers(members)
+	for i := 0; i < len(members); i++ {
+		if job.Arguments["operation"] == entities.ETCDMemberOperation_Add && members[i].PeerURL == job.Arguments["peer-url"] {
+			return true, nil
+		}
+		if job.Arguments["operation"] == entities.ETCDMemberOperation_Remove && members[i].Id == job.Arguments["member-id"] {
+			return false, nil
+		}
+	}
+	return job.Arguments["operation"] == entities.ETCDMemberOperation_Remove, nil
+}
+
+func (a *LightningMonkeyAgent) getETCDContainerId() (string, error) {
+	cs, err := a.containerRuntime.ListContainers()
+	if err != nil {
+		return "", fmt.Errorf("Failed to retrieve all containers information, error: %s", err.Error())
+	}
+	for i := 0; i < len(cs); i++ {
+		if containers.IsKubernetesContainer(cs[i], "etcd", "kube-system") && cs[i].IsRunning {
+			return cs[i].Id, nil
+		}
+	}
+	return "", errors.New("No any running ETCD container being found on this node!")
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/g0194776/lightningmonkey/pkg/certs"
+	"github.com/g0194776/lightningmonkey/pkg/containers"
+	"github.com/g0194776/lightningmonkey/pkg/entities"
+	"github.com/sirupsen/logrus"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	etcdRestoreContainerName = "etcd-restore"
+	etcdStopTimeout          = time.Minute * 2
+	etcdRestoreTimeout       = time.Minute * 10
+)
+
+//HandleETCDRestore replaces the data of local ETCD member with the given snapshot,
+//the original data directory is kept aside for manual recovery.
+func HandleETCDRestore(job *entities.AgentJob, a *LightningMonkeyAgent) (bool, error) {
+	if job.Arguments == nil || job.Arguments["restore-id"] == "" || job.Arguments["name"] == "" || job.Arguments["location"] == "" || job.Arguments["addresses"] == "" {
+		return false, errors.New("Illegal ETCD restore job, required arguments are missed")
+	}
+	restoreId := job.Arguments["restore-id"]
+	a.setRestoreStatus(restoreId, entities.ETCDRestoreNodeStatus_Restoring, "")
+	err := a.restoreETCD(job)
+	if err != nil {
+		a.setRestoreStatus(restoreId, entities.ETCDRestoreNodeStatus_Failed, err.Error())
+		return false, fmt.Errorf("Failed to restore ETCD snapshot %s, error: %s", job.Arguments["name"], err.Error())
+	}
+	a.setRestoreStatus(restoreId, entities.ETCDRestoreNodeStatus_Succeed, "")
+	return true, nil
+}
+
+func CheckETCDRestoreHealth(job *entities.AgentJob, a *LightningMonkeyAgent) (bool, error) {
+	if job == nil || job.Arguments == nil {
+		return false, nil
+	}
+	s := a.getRestoreStatus()
+	if s == nil || s.RestoreId != job.Arguments["restore-id"] {
+		return false, nil
+	}
+	return s.Status == entities.ETCDRestoreNodeStatus_Succeed, nil
+}
+
+func (a *LightningMonkeyAgent) restoreETCD(job *entities.AgentJob) error {
+	restoreId := job.Arguments["restore-id"]
+	suffix := restoreId
+	if len(suffix) > 8 {
+		suffix = suffix[:8]
+	}
+	snapshotFile, isDownloaded, err := a.prepareETCDSnapshotFile(job, suffix)
+	if err != nil {
+		return err
+	}
+	if isDownloaded {
+		defer os.Remove(snapshotFile)
+	}
+	//stop ETCD by moving its static pod manifest out of the manifest directory of kubelet.
+	manifest := filepath.Join(certs.GetManifestDirectory(CERTIFICATE_STORAGE_PATH), "etcd.yaml")
+	movedManifest := filepath.Join(CERTIFICATE_STORAGE_PATH, "etcd.yaml.restoring")
+	if _, err = os.Stat(manifest); err == nil {
+		if err = os.Rename(manifest, movedManifest); err != nil {
+			return fmt.Errorf("Failed to stop ETCD, error: %s", err.Error())
+		}
+	} else if _, err = os.Stat(movedManifest); err != nil {
+		return fmt.Errorf("ETCD manifest not found: %s", manifest)
+	}
+	hasSwapped := false
+	defer func() {
+		//ETCD is always started again, with the original data if restoring failed.
+		if err := os.Rename(movedManifest, manifest); err != nil {
+			logrus.Errorf("Failed to restore ETCD manifest, error: %s", err.Error())
+		}
+		if !hasSwapped {
+			logrus.Warnf("ETCD is started with its original data.")
+		}
+	}()
+	err = a.waitETCDStopped()
+	if err != nil {
+		return err
+	}
+	restoredDir := fmt.Sprintf("%s-restored-%s", etcdDataDir, suffix)
+	_ = os.RemoveAll(restoredDir)
+	err = a.runETCDRestoreContainer(snapshotFile, restoredDir, strings.Split(job.Arguments["addresses"], ","))
+	if err != nil {
+		return err
+	}
+	backupDir := fmt.Sprintf("%s-backup-%s", etcdDataDir, suffix)
+	if err = os.Rename(etcdDataDir, backupDir); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("Failed to move original ETCD data aside, error: %s", err.Error())
+	}
+	if err = os.Rename(restoredDir, etcdDataDir); err != nil {
+		_ = os.Rename(backupDir, etcdDataDir)
+		return fmt.Errorf("Failed to replace ETCD data, error: %s", err.Error())
+	}
+	hasSwapped = true
+	logrus.Infof("ETCD data has been restored from snapshot %s, original data is kept in %s", job.Arguments["name"], backupDir)
+	return nil
+}
+
+//prepareETCDSnapshotFile returns true if the snapshot has been downloaded from API server.
+func (a *LightningMonkeyAgent) prepareETCDSnapshotFile(job *entities.AgentJob, suffix string) (string, bool, error) {
+	location := job.Arguments["location"]
+	switch job.Arguments["destination"] {
+	case entities.ETCDSnapshotDestination_Local:
+		if _, err := os.Stat(location); err != nil {
+			return "", false, fmt.Errorf("ETCD snapshot %s not found on this node, error: %s", location, err.Error())
+		}
+		return location, false, nil
+	case entities.ETCDSnapshotDestination_APIServer:
+		file := fmt.Sprintf("%s-restore-%s.db", etcdDataDir, suffix)
+		err := a.downloadETCDSnapshot(job.Arguments["api-server"], location, job.Arguments["token"], file)
+		if err != nil {
+			_ = os.Remove(file)
+			return "", false, err
+		}
+		return file, true, nil
+	default:
+		return "", false, fmt.Errorf("Unsupported ETCD snapshot destination: %s", job.Arguments["destination"])
+	}
+}
+
+//downloadETCDSnapshot downloads the snapshot from the API server which holds it, any of API servers is tried if
+//the holder has not been recorded.
+func (a *LightningMonkeyAgent) downloadETCDSnapshot(server, location, token, file string) error {
+	var rsp *http.Response
+	var err error
+	path := fmt.Sprintf("/apis/v1/snapshots/%s?token=%s", location, token)
+	if server != "" {
+		rsp, err = a.servers.DoOn(server, "GET", path, nil, snapshotUploadTimeout)
+	} else {
+		rsp, err = a.servers.Do("GET", path, nil, snapshotUploadTimeout)
+	}
+	if err != nil {
+		return fmt.Errorf("Failed to download snapshot from API server, error: %s", err.Error())
+	}
+	defer rsp.Body.Close()
+	if rsp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Failed to download snapshot from API server, status code: %d", rsp.StatusCode)
+	}
+	f, err := os.OpenFile(file, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, rsp.Body)
+	closeErr := f.Close()
+	if err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func (a *LightningMonkeyAgent) waitETCDStopped() error {
+	deadline := time.Now().Add(etcdStopTimeout)
+	for time.Now().Before(deadline) {
+		if _, err := a.getETCDContainerId(); err != nil {
+			return nil
+		}
+		time.Sleep(time.Second * 2)
+	}
+	return errors.New("Timed out waiting for ETCD to stop.")
+}
+
+//runETCDRestoreContainer restores the snapshot into a new data directory with the ETCD image of current cluster.
+func (a *LightningMonkeyAgent) runETCDRestoreContainer(snapshotFile, dataDir string, addresses []string) error {
+	var sb strings.Builder
+	for i := 0; i < len(addresses); i++ {
+		sb.WriteString(fmt.Sprintf("%s=https://%s:2380", generateETCDName(a, addresses[i]), addresses[i]))
+		if i != len(addresses)-1 {
+			sb.WriteString(",")
+		}
+	}
+	cmdStr := fmt.Sprintf("export ETCDCTL_API=3 && /usr/local/bin/etcdctl snapshot restore %s --name=%s --initial-cluster=%s --initial-advertise-peer-urls=https://%s:2380 --data-dir=%s",
+		snapshotFile, generateETCDName(a, *a.arg.Address), sb.String(), *a.arg.Address, dataDir)
+	err := a.removeContainer(etcdRestoreContainerName)
+	if err != nil {
+		return err
+	}
+	binds := []string{
+		fmt.Sprintf("%s:%s", filepath.Dir(etcdDataDir), filepath.Dir(etcdDataDir)),
+	}
+	if dir := filepath.Dir(snapshotFile); dir != filepath.Dir(etcdDataDir) {
+		binds = append(binds, fmt.Sprintf("%s:%s:ro", dir, dir))
+	}
+	id, err := a.containerRuntime.RunContainer(containers.ContainerSpec{
+		Name:        etcdRestoreContainerName,
+		Image:       a.basicImages.Images["etcd"].ImageName,
+		Cmd:         []string{"/bin/sh", "-c", cmdStr},
+		Binds:       binds,
+		HostNetwork: true,
+	})
+	if err != nil {
+		return err
+	}
+	defer a.containerRuntime.RemoveContainer(id)
+	code, err := a.containerRuntime.WaitContainer(id, etcdRestoreTimeout)
+	if err != nil {
+		return fmt.Errorf("Failed to wait for restoring ETCD snapshot, error: %s", err.Error())
+	}
+	if code != 0 {
+		return fmt.Errorf("Restoring ETCD snapshot exited with code: %d", code)
+	}
+	return nil
+}
+
+func (a *LightningMonkeyAgent) setRestoreStatus(restoreId, status, reason string) {
+	a.statusLock.Lock()
+	defer a.statusLock.Unlock()
+	a.restoreStatus = &entities.AgentRestoreStatus{
+		RestoreId:  restoreId,
+		Status:     status,
+		Reason:     reason,
+		UpdateTime: time.Now(),
+	}
+}
+
+func (a *LightningMonkeyAgent) getRestoreStatus() *entities.AgentRestoreStatus {
+	a.statusLock.RLock()
+	defer a.statusLock.RUnlock()
+	if a.restoreStatus == nil {
+		return nil
+	}
+	s := *a.restoreStatus
+	return &s
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/g0194776/lightningmonkey/pkg/entities"
+	"github.com/g0194776/lightningmonkey/pkg/utils"
+	"github.com/sirupsen/logrus"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	etcdDataDir           = "/data/etcd"
+	snapshotUploadTimeout = time.Minute * 10
+)
+
+//etcdSnapshotStatus is the output of "etcdctl snapshot status -w json".
+type etcdSnapshotStatus struct {
+	Hash      int64 `json:"hash"`
+	Revision  int64 `json:"revision"`
+	TotalKey  int64 `json:"totalKey"`
+	TotalSize int64 `json:"totalSize"`
+}
+
+//HandleETCDSnapshot takes a snapshot through the local ETCD member, the result is reported along with agent status.
+func HandleETCDSnapshot(job *entities.AgentJob, a *LightningMonkeyAgent) (bool, error) {
+	if job.Arguments == nil || job.Arguments["name"] == "" || job.Arguments["destination"] == "" {
+		return false, errors.New("Illegal ETCD snapshot job, required arguments are missed")
+	}
+	snapshot, err := a.takeETCDSnapshot(job)
+	if err != nil {
+		a.setETCDSnapshot(&entities.ETCDSnapshot{
+			Name:        job.Arguments["name"],
+			Destination: job.Arguments["destination"],
+			Status:      entities.ETCDSnapshotStatus_Failed,
+			Reason:      err.Error(),
+			CreateTime:  time.Now(),
+		})
+		return false, fmt.Errorf("Failed to take ETCD snapshot %s, error: %s", job.Arguments["name"], err.Error())
+	}
+	logrus.Infof("ETCD snapshot %s has been saved to %s, revision: %d", snapshot.Name, snapshot.Location, snapshot.Revision)
+	a.setETCDSnapshot(snapshot)
+	return true, nil
+}
+
+func CheckETCDSnapshotHealth(job *entities.AgentJob, a *LightningMonkeyAgent) (bool, error) {
+	if job == nil || job.Arguments == nil {
+		return false, nil
+	}
+	s := a.getETCDSnapshot()
+	return s != nil && s.Name == job.Arguments["name"], nil
+}
+
+func (a *LightningMonkeyAgent) takeETCDSnapshot(job *entities.AgentJob) (*entities.ETCDSnapshot, error) {
+	containerId, err := a.getETCDContainerId()
+	if err != nil {
+		return nil, err
+	}
+	name := job.Arguments["name"]
+	//data directory of ETCD is mounted with the same path inside of ETCD container.
+	tmpFile := filepath.Join(etcdDataDir, name+".db")
+	defer os.Remove(tmpFile)
+	_, err = execETCDCtl(a, containerId, fmt.Sprintf("snapshot save %s", tmpFile))
+	if err != nil {
+		return nil, err
+	}
+	//verify integrity of the snapshot before saving it.
+	result, err := execETCDCtl(a, containerId, fmt.Sprintf("snapshot status %s -w json", tmpFile))
+	if err != nil {
+		return nil, fmt.Errorf("Failed to verify snapshot, error: %s", err.Error())
+	}
+	status := etcdSnapshotStatus{}
+	err = json.Unmarshal([]byte(strings.TrimSpace(result)), &status)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse snapshot status: %s, error: %s", result, err.Error())
+	}
+	if status.Revision <= 0 {
+		return nil, fmt.Errorf("Illegal snapshot revision: %d", status.Revision)
+	}
+	fi, err := os.Stat(tmpFile)
+	if err != nil {
+		return nil, err
+	}
+	snapshot := &entities.ETCDSnapshot{
+		Name:        name,
+		Destination: job.Arguments["destination"],
+		Revision:    status.Revision,
+		Hash:        status.Hash,
+		TotalKeys:   status.TotalKey,
+		Size:        fi.Size(),
+		Status:      entities.ETCDSnapshotStatus_Succeed,
+		CreateTime:  time.Now(),
+	}
+	switch snapshot.Destination {
+	case entities.ETCDSnapshotDestination_Local:
+		dir := job.Arguments["directory"]
+		if dir == "" {
+			return nil, errors.New("Illegal ETCD snapshot job, \"directory\" is required for local destination")
+		}
+		err = os.MkdirAll(dir, 0700)
+		if err != nil {
+			return nil, err
+		}
+		snapshot.Location = filepath.Join(dir, name+".db")
+		err = moveFile(tmpFile, snapshot.Location)
+		if err != nil {
+			return nil, err
+		}
+		retention, _ := strconv.Atoi(job.Arguments["retention"])
+		pruneLocalSnapshots(dir, retention)
+	case entities.ETCDSnapshotDestination_APIServer:
+		snapshot.Location = utils.GetETCDSnapshotLocation(*a.arg.ClusterId, name)
+		snapshot.APIServer, err = a.uploadETCDSnapshot(tmpFile, snapshot.Location, job.Arguments["token"])
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("Unsupported ETCD snapshot destination: %s", snapshot.Destination)
+	}
+	return snapshot, nil
+}
+
+//uploadETCDSnapshot returns the address of API server which holds the uploaded snapshot, the snapshot is only
+//saved on the local disk of that API server.
+func (a *LightningMonkeyAgent) uploadETCDSnapshot(file, location, token string) (string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	rsp, err := a.servers.Do("PUT", fmt.Sprintf("/apis/v1/snapshots/%s?token=%s", location, token), f, snapshotUploadTimeout)
+	if err != nil {
+		return "", fmt.Errorf("Failed to upload snapshot to API server, error: %s", err.Error())
+	}
+	defer rsp.Body.Close()
+	if rsp.StatusCode != http.StatusOK {
+		data, _ := ioutil.ReadAll(rsp.Body)
+		return "", fmt.Errorf("Failed to upload snapshot to API server, status code: %d, error: %s", rsp.StatusCode, string(data))
+	}
+	return fmt.Sprintf("%s://%s", rsp.Request.URL.Scheme, rsp.Request.URL.Host), nil
+}
+
+//pruneLocalSnapshots removes the oldest snapshots in given directory, snapshot names are sortable by their creation time.
+func pruneLocalSnapshots(dir string, retention int) {
+	if retention <= 0 {
+		return
+	}
+	fis, err := ioutil.ReadDir(dir)
+	if err != nil {
+		logrus.Warnf("Failed to list ETCD snapshots in directory: %s, error: %s", dir, err.Error())
+		return
+	}
+	names := []string{}
+	for i := 0; i < len(fis); i++ {
+		if !fis[i].IsDir() && strings.HasPrefix(fis[i].Name(), "snapshot-") && strings.HasSuffix(fis[i].Name(), ".db") {
+			names = append(names, fis[i].Name())
+		}
+	}
+	sort.Strings(names)
+	for i := 0; i < len(names)-retention; i++ {
+		logrus.Infof("Removing expired ETCD snapshot: %s...", names[i])
+		if err = os.Remove(filepath.Join(dir, names[i])); err != nil {
+			logrus.Warnf("Failed to remove expired ETCD snapshot: %s, error: %s", names[i], err.Error())
+		}
+	}
+}
+
+//moveFile falls back to copying when source and destination are on different file systems.
+func moveFile(src, dst string) error {
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, in)
+	closeErr := out.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(dst)
+		return err
+	}
+	return os.Remove(src)
+}
+
+func (a *LightningMonkeyAgent) setETCDSnapshot(s *entities.ETCDSnapshot) {
+	a.statusLock.Lock()
+	defer a.statusLock.Unlock()
+	a.etcdSnapshot = s
+}
+
+func (a *LightningMonkeyAgent) getETCDSnapshot() *entities.ETCDSnapshot {
+	a.statusLock.RLock()
+	defer a.statusLock.RUnlock()
+	if a.etcdSnapshot == nil {
+		return nil
+	}
+	s := *a.etcdSnapshot
+	return &s
+}
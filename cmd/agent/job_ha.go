@@ -2,16 +2,12 @@ package main
 
 import (
 	"bytes"
-	"context"
 	"fmt"
-	"github.com/docker/engine-api/types"
-	"github.com/docker/engine-api/types/container"
-	"github.com/docker/engine-api/types/network"
+	"github.com/g0194776/lightningmonkey/pkg/containers"
 	"github.com/g0194776/lightningmonkey/pkg/entities"
 	"github.com/sirupsen/logrus"
 	"golang.org/x/xerrors"
 	"html/template"
-	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
@@ -100,6 +96,8 @@ vrrp_instance VI_1 {
 }`
 	keepAlivedConfigPath = "/etc/lightning-monkey/keepalived.conf"
 	haProxyConfigPath    = "/etc/lightning-monkey/haproxy.cfg"
+	//path of HAProxy configuration file inside of "ha" container.
+	haProxyContainerConfigPath = "/usr/local/etc/haproxy/haproxy.cfg"
 )
 
 func HandleDeployHA(job *entities.AgentJob, a *LightningMonkeyAgent) (bool, error) {
@@ -118,35 +116,40 @@ func HandleDeployHA(job *entities.AgentJob, a *LightningMonkeyAgent) (bool, erro
 		return result, err
 	}
 	//STEP 2, start up docker container.
-	resp, err := a.dockerClient.ContainerCreate(context.Background(), &container.Config{
+	_, err = a.containerRuntime.RunContainer(containers.ContainerSpec{
+		Name:     "ha",
 		Hostname: *a.arg.Address,
 		Image:    a.basicImages.Images["ha"].ImageName,
-		Tty:      false,
-		Volumes:  map[string]struct{}{},
-	}, &container.HostConfig{
 		Binds: []string{
-			fmt.Sprintf("%s:/usr/local/etc/haproxy/haproxy.cfg", haProxyConfigPath),
+			//bind the directory rather than the file, so that replacing the file by renaming is visible in the container.
+			fmt.Sprintf("%s:%s", filepath.Dir(haProxyConfigPath), filepath.Dir(haProxyContainerConfigPath)),
 			fmt.Sprintf("%s:/etc/keepalived/keepalived.conf", keepAlivedConfigPath),
 		},
 		Privileged:    true,
-		NetworkMode:   "host",
-		RestartPolicy: container.RestartPolicy{Name: "unless-stopped"},
-	}, &network.NetworkingConfig{}, "ha")
+		HostNetwork:   true,
+		AlwaysRestart: true,
+	})
 	if err != nil {
-		return false, xerrors.Errorf("Failed to create HA container, error: %s %w", err.Error(), crashError)
+		return false, xerrors.Errorf("Failed to run HA container, error: %s %w", err.Error(), crashError)
 	}
-	if err = a.dockerClient.ContainerStart(context.Background(), resp.ID, types.ContainerStartOptions{}); err != nil {
-		return false, xerrors.Errorf("Failed to start HA container, error: %s %w", err.Error(), crashError)
+	return true, nil
+}
+
+func writeKeepAlivedConfigFile(haIPs []string, job *entities.AgentJob, a *LightningMonkeyAgent) (bool, error) {
+	conf, err := renderKeepAlivedConfig(haIPs, job.Arguments, a)
+	if err != nil {
+		return false, err
 	}
-	out, err := a.dockerClient.ContainerLogs(context.Background(), resp.ID, types.ContainerLogsOptions{ShowStdout: true})
+	_ = os.Remove(keepAlivedConfigPath)
+	_ = os.MkdirAll(filepath.Dir(keepAlivedConfigPath), 0644) //"rw-r-r"
+	err = ioutil.WriteFile(keepAlivedConfigPath, []byte(conf), 0644)
 	if err != nil {
-		return false, xerrors.Errorf("Failed to retrieve HA container logs, error: %s %w", err.Error(), crashError)
+		return false, fmt.Errorf("Failed to write KeepAlived configuration file, error: %s", err.Error())
 	}
-	_, _ = io.Copy(os.Stdout, out)
 	return true, nil
 }
 
-func writeKeepAlivedConfigFile(haIPs []string, job *entities.AgentJob, a *LightningMonkeyAgent) (bool, error) {
+func renderKeepAlivedConfig(haIPs []string, jobArgs map[string]string, a *LightningMonkeyAgent) (string, error) {
 	sb := strings.Builder{}
 	for i := 0; i < len(haIPs); i++ {
 		if haIPs[i] != *a.arg.Address {
@@ -158,33 +161,40 @@ func writeKeepAlivedConfigFile(haIPs []string, job *entities.AgentJob, a *Lightn
 	}
 	tpl, err := template.New("kt").Parse(keepalived_payload)
 	if err != nil {
-		return false, fmt.Errorf("Failed to parse KeepAlived template, error: %s", err.Error())
+		return "", fmt.Errorf("Failed to parse KeepAlived template, error: %s", err.Error())
 	}
 	args := map[string]string{
-		"STATE":     job.Arguments["state"],
+		"STATE":     jobArgs["state"],
 		"ETH":       *a.arg.UsedEthernetInterface,
-		"ROUTERID":  job.Arguments["router-id"],
+		"ROUTERID":  jobArgs["router-id"],
 		"LOCALIP":   *a.arg.Address,
 		"MASTERIPS": sb.String(),
-		"VIP":       job.Arguments["vip"], //"0.0.0.0",
-		"PRIORITY":  job.Arguments["priority"],
+		"VIP":       jobArgs["vip"], //"0.0.0.0",
+		"PRIORITY":  jobArgs["priority"],
 	}
 	buffer := bytes.Buffer{}
 	err = tpl.Execute(&buffer, args)
 	if err != nil {
-		return false, xerrors.Errorf("Failed to execute KeepAlived configuration template, error: %s %w", err.Error(), crashError)
+		return "", xerrors.Errorf("Failed to execute KeepAlived configuration template, error: %s %w", err.Error(), crashError)
 	}
-	conf := buffer.String()
-	_ = os.Remove(keepAlivedConfigPath)
-	_ = os.MkdirAll(filepath.Dir(keepAlivedConfigPath), 0644) //"rw-r-r"
-	err = ioutil.WriteFile(keepAlivedConfigPath, []byte(conf), 0644)
+	return buffer.String(), nil
+}
+
+func writeHAProxyConfigFile(masterIPs []string, job *entities.AgentJob, a *LightningMonkeyAgent) (bool, error) {
+	conf, err := renderHAProxyConfig(masterIPs)
 	if err != nil {
-		return false, fmt.Errorf("Failed to write KeepAlived configuration file, error: %s", err.Error())
+		return false, xerrors.Errorf("%s %w", err.Error(), crashError)
+	}
+	_ = os.Remove(haProxyConfigPath)
+	_ = os.MkdirAll(filepath.Dir(haProxyConfigPath), 0644) //"rw-r-r"
+	err = ioutil.WriteFile(haProxyConfigPath, []byte(conf), 0644)
+	if err != nil {
+		return false, fmt.Errorf("Failed to write HAProxy configuration file, error: %s", err.Error())
 	}
 	return true, nil
 }
 
-func writeHAProxyConfigFile(masterIPs []string, job *entities.AgentJob, a *LightningMonkeyAgent) (bool, error) {
+func renderHAProxyConfig(masterIPs []string) (string, error) {
 	sb := strings.Builder{}
 	for i := 0; i < len(masterIPs); i++ {
 		sb.WriteString(fmt.Sprintf("    server  master-%d %s:6443 check inter 10s rise 3 fall 3", i, masterIPs[i]))
@@ -194,7 +204,7 @@ func writeHAProxyConfigFile(masterIPs []string, job *entities.AgentJob, a *Light
 	}
 	tpl, err := template.New("hat").Parse(haproxy_payload)
 	if err != nil {
-		return false, fmt.Errorf("Failed to parse HAProxy template, error: %s", err.Error())
+		return "", fmt.Errorf("Failed to parse HAProxy template, error: %s", err.Error())
 	}
 	args := map[string]string{
 		"MASTERS": sb.String(),
@@ -202,33 +212,25 @@ func writeHAProxyConfigFile(masterIPs []string, job *entities.AgentJob, a *Light
 	buffer := bytes.Buffer{}
 	err = tpl.Execute(&buffer, args)
 	if err != nil {
-		return false, xerrors.Errorf("Failed to execute HAProxy configuration template, error: %s %w", err.Error(), crashError)
-	}
-	conf := buffer.String()
-	_ = os.Remove(haProxyConfigPath)
-	_ = os.MkdirAll(filepath.Dir(haProxyConfigPath), 0644) //"rw-r-r"
-	err = ioutil.WriteFile(haProxyConfigPath, []byte(conf), 0644)
-	if err != nil {
-		return false, fmt.Errorf("Failed to write HAProxy configuration file, error: %s", err.Error())
+		return "", fmt.Errorf("Failed to execute HAProxy configuration template, error: %s", err.Error())
 	}
-	return true, nil
+	return buffer.String(), nil
 }
 
 func CheckHAHealth(job *entities.AgentJob, a *LightningMonkeyAgent) (bool, error) {
 	var err error
-	var containers []types.Container
-	containers, err = a.dockerClient.ContainerList(context.Background(), types.ContainerListOptions{All: true})
+	var cs []containers.Container
+	cs, err = a.containerRuntime.ListContainers()
 	if err != nil {
 		logrus.Errorf("Failed to retrieve all containers information, error: %s", err.Error())
 		return false, err
 	}
-	if containers == nil || len(containers) == 0 {
+	if cs == nil || len(cs) == 0 {
 		return false, nil
 	}
-	for i := 0; i < len(containers); i++ {
-		logrus.Debugf("container status: %s, names: %#v", containers[i].Status, containers[i].Names)
-		if containers[i].Names[0] == "/ha" &&
-			strings.Contains(containers[i].Status, "Up") {
+	for i := 0; i < len(cs); i++ {
+		logrus.Debugf("container running: %t, name: %s", cs[i].IsRunning, cs[i].Name)
+		if cs[i].Name == "ha" && cs[i].IsRunning {
 			return true, nil
 		}
 	}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/g0194776/lightningmonkey/pkg/containers"
+	"github.com/g0194776/lightningmonkey/pkg/entities"
+	"github.com/sirupsen/logrus"
+	"io/ioutil"
+	"net"
+	"os"
+	"sort"
+	"strings"
+)
+
+//HandleHABackends rewrites the backends of HAProxy and reloads it gracefully,
+//the old HAProxy processes keep serving established connections until they finish.
+func HandleHABackends(job *entities.AgentJob, a *LightningMonkeyAgent) (bool, error) {
+	if job.Arguments == nil || job.Arguments["master-addresses"] == "" {
+		return false, errors.New("Illegal HAProxy backends job, required arguments are missed")
+	}
+	containerId, err := a.getHAContainerId()
+	if err != nil {
+		return false, err
+	}
+	conf, err := renderHAProxyConfig(strings.Split(job.Arguments["master-addresses"], ","))
+	if err != nil {
+		return false, err
+	}
+	previous, err := ioutil.ReadFile(haProxyConfigPath)
+	if err != nil {
+		return false, fmt.Errorf("Failed to read HAProxy configuration file, error: %s", err.Error())
+	}
+	inPlace, err := a.isHAProxyConfigFileBound(containerId)
+	if err != nil {
+		return false, err
+	}
+	err = replaceHAProxyConfigFile([]byte(conf), inPlace)
+	if err != nil {
+		return false, err
+	}
+	logrus.Infof("Reloading HAProxy with backends: %s...", job.Arguments["master-addresses"])
+	_, err = execInContainer(a, containerId, fmt.Sprintf("haproxy -c -q -f %s && haproxy -f %s -sf $(pidof haproxy)", haProxyContainerConfigPath, haProxyContainerConfigPath))
+	if err != nil {
+		//the previous configuration is still being served.
+		if rollbackErr := replaceHAProxyConfigFile(previous, inPlace); rollbackErr != nil {
+			logrus.Errorf("Failed to roll back HAProxy configuration file, error: %s", rollbackErr.Error())
+		}
+		return false, fmt.Errorf("Failed to reload HAProxy, error: %s", err.Error())
+	}
+	return true, nil
+}
+
+func CheckHABackendsHealth(job *entities.AgentJob, a *LightningMonkeyAgent) (bool, error) {
+	if job == nil || job.Arguments == nil {
+		return false, nil
+	}
+	desired := strings.Split(job.Arguments["master-addresses"], ",")
+	sort.Strings(desired)
+	actual := getHAProxyBackends()
+	if len(desired) != len(actual) {
+		return false, nil
+	}
+	for i := 0; i < len(desired); i++ {
+		if desired[i] != actual[i] {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+//getHAProxyBackends returns sorted master addresses in the HAProxy configuration file, nil if HAProxy has not been deployed.
+func getHAProxyBackends() []string {
+	data, err := ioutil.ReadFile(haProxyConfigPath)
+	if err != nil {
+		return nil
+	}
+	backends := []string{}
+	lines := strings.Split(string(data), "\n")
+	for i := 0; i < len(lines); i++ {
+		fields := strings.Fields(lines[i])
+		if len(fields) < 3 || fields[0] != "server" {
+			continue
+		}
+		host, _, err := net.SplitHostPort(fields[2])
+		if err != nil {
+			continue
+		}
+		backends = append(backends, host)
+	}
+	sort.Strings(backends)
+	return backends
+}
+
+//replaceHAProxyConfigFile writes a temporary file and renames it, containers created by the previous versions
+//bind the configuration file itself, the file must be overwritten in place for them.
+func replaceHAProxyConfigFile(conf []byte, inPlace bool) error {
+	if inPlace {
+		err := ioutil.WriteFile(haProxyConfigPath, conf, 0644)
+		if err != nil {
+			return fmt.Errorf("Failed to write HAProxy configuration file, error: %s", err.Error())
+		}
+		return nil
+	}
+	tmpPath := haProxyConfigPath + ".tmp"
+	f, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("Failed to create HAProxy configuration file, error: %s", err.Error())
+	}
+	_, err = f.Write(conf)
+	if err == nil {
+		err = f.Sync()
+	}
+	closeErr := f.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmpPath, haProxyConfigPath)
+	}
+	if err != nil {
+		_ = os.Remove(tmpPath)
+		return fmt.Errorf("Failed to write HAProxy configuration file, error: %s", err.Error())
+	}
+	return nil
+}
+
+func (a *LightningMonkeyAgent) isHAProxyConfigFileBound(containerId string) (bool, error) {
+	cs, err := a.containerRuntime.ListContainers()
+	if err != nil {
+		return false, fmt.Errorf("Failed to inspect HA container, error: %s", err.Error())
+	}
+	c := containers.FindContainer(cs, func(c containers.Container) bool { return c.Id == containerId })
+	if c == nil {
+		return false, nil
+	}
+	for i := 0; i < len(c.Mounts); i++ {
+		if c.Mounts[i].Source == haProxyConfigPath && c.Mounts[i].Destination == haProxyContainerConfigPath {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (a *LightningMonkeyAgent) getHAContainerId() (string, error) {
+	cs, err := a.containerRuntime.ListContainers()
+	if err != nil {
+		return "", fmt.Errorf("Failed to retrieve all containers information, error: %s", err.Error())
+	}
+	for i := 0; i < len(cs); i++ {
+		if cs[i].Name == "ha" && cs[i].IsRunning {
+			return cs[i].Id, nil
+		}
+	}
+	return "", errors.New("No any running HA container being found on this node!")
+}
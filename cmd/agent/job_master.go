@@ -1,13 +1,9 @@
 package main
 
 import (
-	"context"
-	"github.com/docker/engine-api/types"
 	"github.com/g0194776/lightningmonkey/pkg/common"
 	"github.com/g0194776/lightningmonkey/pkg/entities"
-	"github.com/sirupsen/logrus"
 	"golang.org/x/xerrors"
-	"strings"
 )
 
 func HandleDeployMaster(job *entities.AgentJob, a *LightningMonkeyAgent) (bool, error) {
@@ -18,52 +14,10 @@ func HandleDeployMaster(job *entities.AgentJob, a *LightningMonkeyAgent) (bool,
 	return true, nil
 }
 
+//CheckMasterHealth probes all of master components, so that each of them has the latest result.
 func CheckMasterHealth(job *entities.AgentJob, a *LightningMonkeyAgent) (bool, error) {
-	var err error
-	var containers []types.Container
-	containers, err = a.dockerClient.ContainerList(context.Background(), types.ContainerListOptions{All: true})
-	if err != nil {
-		logrus.Errorf("Failed to retrieve all containers information, error: %s", err.Error())
-		return false, err
-	}
-	if containers == nil || len(containers) == 0 {
-		return false, nil
-	}
-	return (hasAPIServerStarted(containers) && hasControllerManagerStarted(containers) && hasSchedulerStarted(containers)), nil
-}
-
-func hasAPIServerStarted(containers []types.Container) bool {
-	for i := 0; i < len(containers); i++ {
-		logrus.Infof("container status: %s, names: %#v", containers[i].Status, containers[i].Names)
-		if strings.Contains(containers[i].Names[0], "k8s_kube-apiserver") &&
-			strings.Contains(containers[i].Names[0], "kube-system") &&
-			strings.Contains(containers[i].Status, "Up") {
-			return true
-		}
-	}
-	return false
-}
-
-func hasControllerManagerStarted(containers []types.Container) bool {
-	for i := 0; i < len(containers); i++ {
-		logrus.Infof("container status: %s, names: %#v", containers[i].Status, containers[i].Names)
-		if strings.Contains(containers[i].Names[0], "k8s_kube-controller-manager") &&
-			strings.Contains(containers[i].Names[0], "kube-system") &&
-			strings.Contains(containers[i].Status, "Up") {
-			return true
-		}
-	}
-	return false
-}
-
-func hasSchedulerStarted(containers []types.Container) bool {
-	for i := 0; i < len(containers); i++ {
-		logrus.Infof("container status: %s, names: %#v", containers[i].Status, containers[i].Names)
-		if strings.Contains(containers[i].Names[0], "k8s_kube-scheduler") &&
-			strings.Contains(containers[i].Names[0], "kube-system") &&
-			strings.Contains(containers[i].Status, "Up") {
-			return true
-		}
-	}
-	return false
+	isAPIServerHealthy := a.probeComponent(Component_APIServer, a.probeAPIServer)
+	isControllerManagerHealthy := a.probeComponent(Component_ControllerManager, func() error { return probeLocalHealthz(controllerManagerHealthzPort) })
+	isSchedulerHealthy := a.probeComponent(Component_Scheduler, func() error { return probeLocalHealthz(schedulerHealthzPort) })
+	return isAPIServerHealthy && isControllerManagerHealthy && isSchedulerHealthy, nil
 }
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/g0194776/lightningmonkey/pkg/certs"
+	"github.com/g0194776/lightningmonkey/pkg/entities"
+	uuid "github.com/satori/go.uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/vishvananda/netlink"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+var (
+	//data directories which are only removed when "wipe-data" argument is set.
+	resetDataDirs = []string{etcdDataDir, "/data/elasticsearch"}
+	//certificates and configurations which are downloaded or generated by the agent into the certificates directory.
+	resetCertificateFiles = []string{
+		"ca.crt", "ca.key", "front-proxy-ca.crt", "front-proxy-ca.key", "sa.pub", "sa.key",
+		"apiserver.crt", "apiserver.key", "apiserver-etcd-client.crt", "apiserver-etcd-client.key",
+		"apiserver-kubelet-client.crt", "apiserver-kubelet-client.key", "front-proxy-client.crt", "front-proxy-client.key",
+		"etcd/ca.crt", "etcd/ca.key", "etcd/server.crt", "etcd/server.key", "etcd/peer.crt", "etcd/peer.key",
+		"etcd/healthcheck-client.crt", "etcd/healthcheck-client.key",
+		"etcd_config.yml", "kubelet_settings.yml", "kubelet.conf", "bootstrap-kubelet.conf",
+	}
+	//static pod manifests which are generated by kubeadm.
+	resetManifestFiles = []string{"etcd.yaml", "kube-apiserver.yaml", "kube-controller-manager.yaml", "kube-scheduler.yaml"}
+	//kubeconfig files which are generated by kubeadm next to the manifests directory.
+	resetKubeConfigFiles = []string{"controller-manager.conf", "scheduler.conf"}
+	//network interfaces which are owned by kube-router, routes owned by the agent are flushed separately.
+	resetNetworkLinks = []string{"kube-bridge", "kube-dummy-if"}
+)
+
+//HandleReset wipes everything which was provisioned by Lightning Monkey on this host,
+//the agent is returned to the resource pool by API server after it reports the reset-id.
+func HandleReset(job *entities.AgentJob, a *LightningMonkeyAgent) (bool, error) {
+	if job.Arguments == nil || job.Arguments["reset-id"] == "" {
+		return false, errors.New("Illegal reset job, required arguments are missed")
+	}
+	//STEP 1, stops static pods from being restarted.
+	manifestDir := certs.GetManifestDirectory(CERTIFICATE_STORAGE_PATH)
+	err := removeFiles(manifestDir, resetManifestFiles)
+	if err != nil {
+		return false, err
+	}
+	//STEP 2, removes kubelet, HA and all of pod containers.
+	_, err = HandleTeardown(job, a)
+	if err != nil {
+		return false, err
+	}
+	//STEP 3, removes generated certificates, configurations and optional data.
+	if err = removeFiles(CERTIFICATE_STORAGE_PATH, resetCertificateFiles); err != nil {
+		return false, err
+	}
+	if err = removeFiles(filepath.Dir(manifestDir), resetKubeConfigFiles); err != nil {
+		return false, err
+	}
+	if err = removeFiles("", []string{keepAlivedConfigPath, haProxyConfigPath}); err != nil {
+		return false, err
+	}
+	if job.Arguments["wipe-data"] == "true" {
+		for i := 0; i < len(resetDataDirs); i++ {
+			logrus.Infof("Cleaning up directory: %s...", resetDataDirs[i])
+			if err = removeDirContents(resetDataDirs[i]); err != nil {
+				return false, err
+			}
+		}
+	}
+	//STEP 4, flushes network state.
+	err = a.flushSystemRoutes()
+	if err != nil {
+		return false, err
+	}
+	for i := 0; i < len(resetNetworkLinks); i++ {
+		link, err := netlink.LinkByName(resetNetworkLinks[i])
+		if err != nil {
+			continue
+		}
+		logrus.Infof("Deleting network interface: %s...", resetNetworkLinks[i])
+		if err = netlink.LinkDel(link); err != nil {
+			return false, fmt.Errorf("Failed to delete network interface %s, error: %s", resetNetworkLinks[i], err.Error())
+		}
+	}
+	//STEP 5, forgets all of local states.
+	err = a.resetLocalState()
+	if err != nil {
+		return false, err
+	}
+	a.setResetId(job.Arguments["reset-id"])
+	return true, nil
+}
+
+func CheckResetHealth(job *entities.AgentJob, a *LightningMonkeyAgent) (bool, error) {
+	if job == nil || job.Arguments == nil {
+		return false, nil
+	}
+	return a.getResetId() == job.Arguments["reset-id"], nil
+}
+
+//flushSystemRoutes deletes all of routes which were generated by the agent.
+func (a *LightningMonkeyAgent) flushSystemRoutes() error {
+	a.routesLock.Lock()
+	defer a.routesLock.Unlock()
+	link, err := netlink.LinkByName(*a.arg.UsedEthernetInterface)
+	if err != nil {
+		return fmt.Errorf("Failed to get link information for device %s, error: %s", *a.arg.UsedEthernetInterface, err.Error())
+	}
+	routes, err := listManagedSystemRoutes(link)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < len(routes); i++ {
+		if err = netlink.RouteDel(newManagedRoute(link, routes[i])); err != nil && err != syscall.ESRCH {
+			return fmt.Errorf("Failed to delete system routing rule %s via %s, error: %s", routes[i].Destination, routes[i].Gateway, err.Error())
+		}
+	}
+	if err = os.Remove(ROUTES_FILE_PATH); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("Failed to remove managed system routes file, error: %s", err.Error())
+	}
+	return nil
+}
+
+func (a *LightningMonkeyAgent) resetLocalState() error {
+	a.recoveryLock.Lock()
+	err := os.Remove(RECOVERY_FILE_PATH)
+	if err != nil && !os.IsNotExist(err) {
+		a.recoveryLock.Unlock()
+		return fmt.Errorf("Failed to remove recovery file, error: %s", err.Error())
+	}
+	a.rr = &RecoveryRecord{ClusterID: uuid.Nil.String()}
+	a.recoveryLock.Unlock()
+	a.statusLock.Lock()
+	defer a.statusLock.Unlock()
+	a.ItemsStatus = make(map[string]entities.LightningMonkeyAgentReportStatusItem)
+	a.upgradeStatus = nil
+	a.etcdMembers = nil
+	a.etcdSnapshot = nil
+	a.restoreStatus = nil
+	a.probes = nil
+	return nil
+}
+
+//removeFiles removes the given files under the directory, the files which are not existed are ignored.
+func removeFiles(dir string, files []string) error {
+	for i := 0; i < len(files); i++ {
+		path := filepath.Join(dir, files[i])
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("Failed to remove %s, error: %s", path, err.Error())
+		}
+	}
+	return nil
+}
+
+//removeDirContents keeps the directory itself, it might be mounted into the other containers.
+func removeDirContents(dir string) error {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("Failed to read directory %s, error: %s", dir, err.Error())
+	}
+	for i := 0; i < len(files); i++ {
+		if err = os.RemoveAll(filepath.Join(dir, files[i].Name())); err != nil {
+			return fmt.Errorf("Failed to remove %s, error: %s", filepath.Join(dir, files[i].Name()), err.Error())
+		}
+	}
+	return nil
+}
+
+func (a *LightningMonkeyAgent) setResetId(resetId string) {
+	a.statusLock.Lock()
+	defer a.statusLock.Unlock()
+	a.resetId = resetId
+}
+
+func (a *LightningMonkeyAgent) getResetId() string {
+	a.statusLock.RLock()
+	defer a.statusLock.RUnlock()
+	return a.resetId
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"github.com/g0194776/lightningmonkey/pkg/containers"
+	"github.com/g0194776/lightningmonkey/pkg/entities"
+	"github.com/sirupsen/logrus"
+	"strings"
+)
+
+//HandleTeardown removes kubelet, HA and all of pod containers before the host leaves the cluster.
+func HandleTeardown(job *entities.AgentJob, a *LightningMonkeyAgent) (bool, error) {
+	cs, err := a.getTeardownContainers()
+	if err != nil {
+		return false, err
+	}
+	for i := 0; i < len(cs); i++ {
+		logrus.Infof("Tearing down container: %s(%s)...", cs[i].Name, cs[i].Id)
+		err = a.containerRuntime.RemoveContainer(cs[i].Id)
+		if err != nil {
+			return false, fmt.Errorf("Failed to remove container: %s, error: %s", cs[i].Name, err.Error())
+		}
+	}
+	return true, nil
+}
+
+func CheckTeardownHealth(job *entities.AgentJob, a *LightningMonkeyAgent) (bool, error) {
+	cs, err := a.getTeardownContainers()
+	if err != nil {
+		return false, err
+	}
+	return len(cs) == 0, nil
+}
+
+func (a *LightningMonkeyAgent) getTeardownContainers() ([]containers.Container, error) {
+	cs, err := a.containerRuntime.ListContainers()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to retrieve all containers information, error: %s", err.Error())
+	}
+	result := []containers.Container{}
+	for i := 0; i < len(cs); i++ {
+		name := cs[i].Name
+		if name == "kubelet" || name == "ha" || strings.HasPrefix(name, "k8s_") {
+			result = append(result, cs[i])
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/g0194776/lightningmonkey/pkg/containers"
+	"github.com/g0194776/lightningmonkey/pkg/entities"
+	"github.com/g0194776/lightningmonkey/pkg/managers"
+	"github.com/sirupsen/logrus"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	upgradeTimeout = time.Minute * 10
+)
+
+//HandleUpgrade upgrades one of local components, the failure never crashes agent and it will be reported to pause the upgrade.
+func HandleUpgrade(job *entities.AgentJob, a *LightningMonkeyAgent) (bool, error) {
+	version, stage, attempt, images, err := parseUpgradeJob(job)
+	if err != nil {
+		return false, err
+	}
+	a.setUpgradeStatus(version, stage, attempt, entities.UpgradeNodeStatus_Upgrading, "")
+	err = a.upgradeComponent(job, stage, images)
+	if err != nil {
+		a.setUpgradeStatus(version, stage, attempt, entities.UpgradeNodeStatus_Failed, err.Error())
+		return false, fmt.Errorf("Failed to upgrade %s to version %s, error: %s", stage, version, err.Error())
+	}
+	//images have been loaded synchronously, nothing to wait.
+	if stage == entities.UpgradeStage_Images {
+		a.setUpgradeStatus(version, stage, attempt, entities.UpgradeNodeStatus_Succeed, "")
+	}
+	return true, nil
+}
+
+//CheckUpgradeHealth waits until upgraded component becomes healthy with the new image.
+func CheckUpgradeHealth(job *entities.AgentJob, a *LightningMonkeyAgent) (bool, error) {
+	if job == nil {
+		return false, nil
+	}
+	version, stage, attempt, images, err := parseUpgradeJob(job)
+	if err != nil {
+		return false, err
+	}
+	s := a.getUpgradeStatus()
+	if s == nil || s.Version != version || s.Stage != stage || s.Attempt != attempt {
+		return false, nil
+	}
+	if s.Status != entities.UpgradeNodeStatus_Upgrading {
+		return s.Status == entities.UpgradeNodeStatus_Succeed, nil
+	}
+	healthy, err := a.isUpgradedComponentHealthy(stage, images)
+	if err != nil {
+		return false, err
+	}
+	if healthy {
+		a.setUpgradeStatus(version, stage, attempt, entities.UpgradeNodeStatus_Succeed, "")
+		return true, nil
+	}
+	if time.Since(s.UpdateTime) > upgradeTimeout {
+		a.setUpgradeStatus(version, stage, attempt, entities.UpgradeNodeStatus_Failed, fmt.Sprintf("Timed out waiting for %s to become healthy.", stage))
+		//stop tracing this job, the failure has been reported.
+		job.HadDone = true
+	}
+	return false, nil
+}
+
+func parseUpgradeJob(job *entities.AgentJob) (string, string, int, *entities.DockerImageCollection, error) {
+	if job.Arguments == nil || job.Arguments["version"] == "" || job.Arguments["stage"] == "" || job.Arguments["images"] == "" {
+		return "", "", 0, nil, errors.New("Illegal upgrade job, required arguments are missed")
+	}
+	attempt, err := strconv.Atoi(job.Arguments["attempt"])
+	if err != nil {
+		return "", "", 0, nil, fmt.Errorf("Illegal upgrade job, attempt: %s", job.Arguments["attempt"])
+	}
+	images := entities.DockerImageCollection{}
+	err = json.Unmarshal([]byte(job.Arguments["images"]), &images)
+	if err != nil {
+		return "", "", 0, nil, fmt.Errorf("Illegal upgrade job, failed to unmarshal images, error: %s", err.Error())
+	}
+	return job.Arguments["version"], job.Arguments["stage"], attempt, &images, nil
+}
+
+func (a *LightningMonkeyAgent) upgradeComponent(job *entities.AgentJob, stage string, images *entities.DockerImageCollection) error {
+	if stage == entities.UpgradeStage_Images {
+		im, err := managers.NewDockerImageManager(a.servers.Active(), a.servers.client, a.containerRuntime, images)
+		if err != nil {
+			return err
+		}
+		return im.Ready()
+	}
+	//all of subsequent deployments use the new images.
+	oldImages := a.basicImages
+	a.basicImages = images
+	//the control-plane flags are rendered for the new version as well, the settings are kept once the stage succeeded.
+	oldSettings := a.masterSettings
+	if stage == entities.UpgradeStage_Master {
+		a.masterSettings = withKubernetesVersion(a.masterSettings, job.Arguments["version"])
+	}
+	var err error
+	switch stage {
+	case entities.UpgradeStage_ETCD:
+		//regenerates static pod manifest, kubelet will restart it with the new image.
+		_, err = HandleDeployETCD(job, a)
+	case entities.UpgradeStage_Master:
+		_, err = HandleDeployMaster(job, a)
+	case entities.UpgradeStage_HA:
+		err = a.removeContainer("ha")
+		if err == nil {
+			_, err = HandleDeployHA(job, a)
+		}
+	case entities.UpgradeStage_Kubelet:
+		err = a.removeContainer("kubelet")
+		if err == nil {
+			masterIP := *a.arg.Address
+			if *a.arg.IsMinionRole {
+				masterIP = getMasterIP(job)
+			}
+			err = a.runKubeletContainer(masterIP, job.Arguments["bootstrap_token"], job.Arguments["node_pool"])
+		}
+	default:
+		err = fmt.Errorf("Unsupported upgrade stage: %s", stage)
+	}
+	if err != nil {
+		a.basicImages = oldImages
+		a.masterSettings = oldSettings
+	}
+	return err
+}
+
+//withKubernetesVersion returns a copy of master settings with the given Kubernetes version.
+func withKubernetesVersion(masterSettings map[string]string, version string) map[string]string {
+	settings := make(map[string]string, len(masterSettings)+1)
+	for k, v := range masterSettings {
+		settings[k] = v
+	}
+	settings[entities.MasterSettings_KubernetesVersion] = version
+	return settings
+}
+
+func (a *LightningMonkeyAgent) isUpgradedComponentHealthy(stage string, images *entities.DockerImageCollection) (bool, error) {
+	var healthy bool
+	var err error
+	switch stage {
+	case entities.UpgradeStage_Images:
+		return true, nil
+	case entities.UpgradeStage_ETCD:
+		healthy, err = CheckETCDHealth(nil, a)
+	case entities.UpgradeStage_Master:
+		healthy, err = CheckMasterHealth(nil, a)
+	case entities.UpgradeStage_HA:
+		healthy, err = CheckHAHealth(nil, a)
+	case entities.UpgradeStage_Kubelet:
+		healthy = true
+	default:
+		return false, fmt.Errorf("Unsupported upgrade stage: %s", stage)
+	}
+	if err != nil || !healthy {
+		return false, err
+	}
+	cs, err := a.containerRuntime.ListContainers()
+	if err != nil {
+		return false, err
+	}
+	//ensures that old containers have been replaced.
+	switch stage {
+	case entities.UpgradeStage_ETCD:
+		return hasContainerRunningWithImage(cs, "k8s_etcd", images.Images["etcd"].ImageName), nil
+	case entities.UpgradeStage_Master:
+		img := images.Images["k8s"].ImageName
+		return hasContainerRunningWithImage(cs, "k8s_kube-apiserver", img) &&
+			hasContainerRunningWithImage(cs, "k8s_kube-controller-manager", img) &&
+			hasContainerRunningWithImage(cs, "k8s_kube-scheduler", img), nil
+	case entities.UpgradeStage_HA:
+		return hasContainerRunningWithImage(cs, "ha", images.Images["ha"].ImageName), nil
+	default:
+		return hasContainerRunningWithImage(cs, "kubelet", images.Images["k8s"].ImageName), nil
+	}
+}
+
+//hasContainerRunningWithImage matches the container by its exact name or the prefix of name generated by kubelet.
+func hasContainerRunningWithImage(cs []containers.Container, name, image string) bool {
+	for i := 0; i < len(cs); i++ {
+		if (cs[i].Name == name || strings.HasPrefix(cs[i].Name, name+"_")) &&
+			cs[i].IsRunning &&
+			containers.IsSameImage(cs[i].Image, image) {
+			return true
+		}
+	}
+	return false
+}
+
+func (a *LightningMonkeyAgent) removeContainer(name string) error {
+	cs, err := a.containerRuntime.ListContainers()
+	if err != nil {
+		return fmt.Errorf("Failed to retrieve all containers information, error: %s", err.Error())
+	}
+	for i := 0; i < len(cs); i++ {
+		if cs[i].Name == name {
+			logrus.Infof("Removing container: %s(%s)...", name, cs[i].Id)
+			return a.containerRuntime.RemoveContainer(cs[i].Id)
+		}
+	}
+	return nil
+}
+
+func (a *LightningMonkeyAgent) setUpgradeStatus(version, stage string, attempt int, status, reason string) {
+	a.statusLock.Lock()
+	defer a.statusLock.Unlock()
+	a.upgradeStatus = &entities.AgentUpgradeStatus{
+		Version:    version,
+		Stage:      stage,
+		Attempt:    attempt,
+		Status:     status,
+		Reason:     reason,
+		UpdateTime: time.Now(),
+	}
+}
+
+func (a *LightningMonkeyAgent) getUpgradeStatus() *entities.AgentUpgradeStatus {
+	a.statusLock.RLock()
+	defer a.statusLock.RUnlock()
+	if a.upgradeStatus == nil {
+		return nil
+	}
+	s := *a.upgradeStatus
+	return &s
+}
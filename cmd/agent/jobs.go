@@ -26,6 +26,14 @@ func (hf *AgentJobHandlerFactory) Initialize(c chan<- LightningMonkeyAgentReport
 	for k, v := range hf.handlers {
 		go hf.healthCheck(c, ma, k, v[1])
 	}
+	//one-off jobs have no periodic health check, upgrade progress is reported along with agent status.
+	hf.handlers[entities.AgentJob_Upgrade] = []AgentJobHandler{HandleUpgrade, CheckUpgradeHealth}
+	hf.handlers[entities.AgentJob_Teardown] = []AgentJobHandler{HandleTeardown, CheckTeardownHealth}
+	hf.handlers[entities.AgentJob_Reset] = []AgentJobHandler{HandleReset, CheckResetHealth}
+	hf.handlers[entities.AgentJob_ETCD_Member] = []AgentJobHandler{HandleETCDMember, CheckETCDMemberHealth}
+	hf.handlers[entities.AgentJob_ETCD_Snapshot] = []AgentJobHandler{HandleETCDSnapshot, CheckETCDSnapshotHealth}
+	hf.handlers[entities.AgentJob_ETCD_Restore] = []AgentJobHandler{HandleETCDRestore, CheckETCDRestoreHealth}
+	hf.handlers[entities.AgentJob_HA_Backends] = []AgentJobHandler{HandleHABackends, CheckHABackendsHealth}
 }
 
 //do health check for each of supported Lightning Monkey components.
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/g0194776/lightningmonkey/pkg/containers"
+	"github.com/g0194776/lightningmonkey/pkg/entities"
+	"github.com/g0194776/lightningmonkey/pkg/utils"
+	"github.com/kataras/iris/context"
+	"github.com/sirupsen/logrus"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	Component_Agent = "agent"
+	Component_HA    = "ha"
+	//number of agent's own log lines which are kept in memory.
+	maxAgentLogLines = 10000
+)
+
+var (
+	agentLogs = &logBuffer{}
+)
+
+//logBuffer is a logrus hook which keeps the newest log lines of agent, each line is prefixed by its RFC3339 timestamp.
+type logBuffer struct {
+	lock  sync.Mutex
+	lines []string
+}
+
+func (b *logBuffer) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (b *logBuffer) Fire(entry *logrus.Entry) error {
+	line, err := entry.String()
+	if err != nil {
+		return err
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.lines = append(b.lines, entry.Time.Format(time.RFC3339Nano)+" "+strings.TrimRight(line, "\n"))
+	if len(b.lines) > maxAgentLogLines {
+		b.lines = b.lines[len(b.lines)-maxAgentLogLines:]
+	}
+	return nil
+}
+
+func (b *logBuffer) Open(opts containers.LogOptions) (io.ReadCloser, error) {
+	b.lock.Lock()
+	data := strings.Join(b.lines, "\n")
+	b.lock.Unlock()
+	filtered, err := containers.FilterLogLines(strings.NewReader(data), opts)
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.NopCloser(bytes.NewReader(filtered)), nil
+}
+
+//GetLogs streams the logs of agent itself or one of components which it manages.
+func (a *LightningMonkeyAgent) GetLogs(ctx context.Context) {
+	if !a.authorize(ctx) {
+		return
+	}
+	opts := containers.LogOptions{}
+	var err error
+	if tail := ctx.URLParam("tail"); tail != "" {
+		if opts.Tail, err = strconv.Atoi(tail); err != nil || opts.Tail < 0 {
+			writeErrorWithStatusCode(ctx, http.StatusBadRequest, entities.Response{ErrorId: entities.ParameterError, Reason: "\"tail\" parameter must be a non-negative integer."})
+			return
+		}
+	}
+	if opts.Since, err = utils.ParseLogSince(ctx.URLParam("since"), time.Now()); err != nil {
+		writeErrorWithStatusCode(ctx, http.StatusBadRequest, entities.Response{ErrorId: entities.ParameterError, Reason: err.Error()})
+		return
+	}
+	component := ctx.URLParam("component")
+	var reader io.ReadCloser
+	if component == Component_Agent {
+		reader, err = agentLogs.Open(opts)
+	} else {
+		reader, err = a.openComponentLogs(component, opts)
+	}
+	if err != nil {
+		writeErrorWithStatusCode(ctx, http.StatusNotFound, entities.Response{ErrorId: entities.OperationFailed, Reason: err.Error()})
+		return
+	}
+	defer reader.Close()
+	ctx.ContentType("text/plain")
+	ctx.StatusCode(http.StatusOK)
+	_, err = io.Copy(ctx.ResponseWriter(), reader)
+	if err != nil {
+		logrus.Warnf("Failed to stream logs of component: %s, error: %s", component, err.Error())
+	}
+}
+
+//openComponentLogs reads the logs of the newest container of component, the running one is preferred.
+func (a *LightningMonkeyAgent) openComponentLogs(component string, opts containers.LogOptions) (io.ReadCloser, error) {
+	var isOwner func(c containers.Container) bool
+	switch component {
+	case Component_Kubelet, Component_HA:
+		isOwner = func(c containers.Container) bool { return c.Name == component }
+	case Component_ETCD, Component_APIServer, Component_ControllerManager, Component_Scheduler:
+		isOwner = func(c containers.Container) bool {
+			return containers.IsKubernetesContainer(c, component, "kube-system")
+		}
+	default:
+		return nil, fmt.Errorf("Unsupported component: %s", component)
+	}
+	cs, err := a.containerRuntime.ListContainers()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to retrieve all containers information, error: %s", err.Error())
+	}
+	c := containers.FindContainer(cs, func(c containers.Container) bool { return c.IsRunning && isOwner(c) })
+	if c == nil {
+		c = containers.FindContainer(cs, isOwner)
+	}
+	if c == nil {
+		return nil, fmt.Errorf("Container of component %s not found", component)
+	}
+	return a.containerRuntime.ContainerLogs(c.Id, opts)
+}
@@ -1,8 +1,11 @@
 package main
 
 import (
+	"fmt"
 	"github.com/g0194776/lightningmonkey/pkg/certs"
 	"github.com/g0194776/lightningmonkey/pkg/common"
+	"github.com/g0194776/lightningmonkey/pkg/containers"
+	"github.com/g0194776/lightningmonkey/pkg/utils"
 	uuid "github.com/satori/go.uuid"
 	"github.com/sirupsen/logrus"
 	flag "github.com/spf13/pflag"
@@ -10,12 +13,67 @@ import (
 	"os"
 	"os/exec"
 	"runtime"
+	"sigs.k8s.io/yaml"
+	"time"
 )
 
 func main() {
+	configFile := flag.String("config", "", "The path of YAML configuration file, the flags which are explicitly given override it.")
+	printConfig := flag.Bool("print-config", false, "Print the effective configuration and exit.")
+	flags := agentFlags{}
+	flags.server = flag.String("server", "", "api addresses, multiple addresses are separated by comma")
+	flags.address = flag.String("address", "", "local node address")
+	flags.usedEthernetInterface = flag.String("nc", "", "used ethernet interface name")
+	flags.clusterId = flag.String("cluster", uuid.Nil.String(), "cluster id, leave it to blank will set to the resource pool mode")
+	flags.nodeLabels = flag.String("labels", "", "Labels to add when registering the node in the cluster. Labels must be key=value pairs separated by ','. Labels in the 'kubernetes.io' namespace must begin with an allowed prefix (kubelet.kubernetes.io, node.kubernetes.io) or be in the specifically allowed set (beta.kubernetes.io/arch, beta.kubernetes.io/instance-type, beta.kubernetes.io/os, failure-domain.beta.kubernetes.io/region, failure-domain.beta.kubernetes.io/zone, failure-domain.kubernetes.io/region, failure-domain.kubernetes.io/zone, kubernetes.io/arch, kubernetes.io/hostname, kubernetes.io/instance-type, kubernetes.io/os)")
+	flags.nodePool = flag.String("node-pool", "", "The node pool of minion, it must be defined in the cluster settings.")
+	flags.isETCDRole = flag.Bool("etcd", false, "")
+	flags.isMasterRole = flag.Bool("master", false, "")
+	flags.isMinionRole = flag.Bool("minion", false, "")
+	flags.isHARole = flag.Bool("ha", false, "")
+	flags.listenPort = flag.Int("port", 6060, "The port used for listening API call.")
+	flags.containerRuntime = flag.String("container-runtime", containers.Runtime_Docker, "The container runtime used for running Kubernetes components, \"docker\" or \"containerd\".")
+	flags.healthFailureThreshold = flag.Int("health-failure-threshold", 3, "The number of consecutive failures of health probe before a component is considered unhealthy.")
+	flags.containerdAddress = flag.String("containerd-address", "", "The socket path of containerd, leave it to blank for the default one.")
+	flags.logLevel = flag.String("log-level", "info", "The log level, e.g: debug, info, warning and error.")
+	flags.id = flag.String("id", "", "Specify the fixed ID for current agent instance, that's available only for debugging.")
+	flags.certDir = flag.String("cert-dir", "", "")
+	showVersion := flag.Bool("version", false, "Print the version of agent and exit.")
+	flag.Parse()
+	if *showVersion {
+		fmt.Println(AGENT_VERSION)
+		return
+	}
+	config, err := loadAgentConfig(*configFile)
+	if err != nil {
+		logrus.Fatalf("%s", err.Error())
+	}
+	if err = flags.applyTo(config); err != nil {
+		logrus.Fatalf("%s", err.Error())
+	}
+	if err = utils.ValidateAgentConfig(config); err != nil {
+		logrus.Fatalf("%s", err.Error())
+	}
+	if *printConfig {
+		data, err := yaml.Marshal(config)
+		if err != nil {
+			logrus.Fatalf("Failed to print agent configuration, error: %s", err.Error())
+		}
+		fmt.Print(string(utils.RedactSecrets(data)))
+		return
+	}
+	level, _ := logrus.ParseLevel(config.LogLevel)
+	logrus.SetLevel(level)
+	logrus.AddHook(agentLogs)
+	serverTransport, err = newServerTransport(config)
+	if err != nil {
+		logrus.Fatalf("%s", err.Error())
+	}
+	CERTIFICATE_STORAGE_PATH = config.Paths.Certificates
+	RECOVERY_FILE_PATH = config.Paths.RecoveryFile
 	if runtime.GOOS == "linux" {
 		logrus.Infof("Copying depended CNI binary files...")
-		cmd := exec.Command("/bin/sh", "-c", "cp -rf /tmp/cni/* /opt/cni/bin/")
+		cmd := exec.Command("/bin/sh", "-c", fmt.Sprintf("cp -rf %s/* /opt/cni/bin/", config.Paths.CNIBinaries))
 		cmd.Stdout = os.Stdout
 		err := cmd.Run()
 		if err != nil {
@@ -24,59 +82,35 @@ func main() {
 		}
 		createCgroupsSubDirectories()
 	}
-	arg := AgentArgs{}
-	arg.Server = flag.String("server", "", "api address")
-	arg.Address = flag.String("address", "", "local node address")
-	arg.UsedEthernetInterface = flag.String("nc", "", "used ethernet interface name")
-	arg.ClusterId = flag.String("cluster", uuid.Nil.String(), "cluster id, leave it to blank will set to the resource pool mode")
-	arg.NodeLabels = flag.String("labels", "", "Labels to add when registering the node in the cluster. Labels must be key=value pairs separated by ','. Labels in the 'kubernetes.io' namespace must begin with an allowed prefix (kubelet.kubernetes.io, node.kubernetes.io) or be in the specifically allowed set (beta.kubernetes.io/arch, beta.kubernetes.io/instance-type, beta.kubernetes.io/os, failure-domain.beta.kubernetes.io/region, failure-domain.beta.kubernetes.io/zone, failure-domain.kubernetes.io/region, failure-domain.kubernetes.io/zone, kubernetes.io/arch, kubernetes.io/hostname, kubernetes.io/instance-type, kubernetes.io/os)")
-	arg.IsETCDRole = flag.Bool("etcd", false, "")
-	arg.IsMasterRole = flag.Bool("master", false, "")
-	arg.IsMinionRole = flag.Bool("minion", false, "")
-	arg.IsHARole = flag.Bool("ha", false, "")
-	arg.ListenPort = flag.Int("port", 6060, "The port used for listening API call.")
-	id := flag.String("id", "", "Specify the fixed ID for current agent instance, that's available only for debugging.")
-	certdir := flag.String("cert-dir", "", "")
-	flag.Parse()
-	if id != nil && *id != "" {
-		arg.AgentId = *id
-	}
-	if certdir != nil && *certdir != "" {
-		CERTIFICATE_STORAGE_PATH = *certdir
-	}
-	if arg.Server == nil || *arg.Server == "" {
-		logrus.Fatalf("\"--server\" argument is required for initializing lightning-monkey agent.")
-	}
-	if arg.UsedEthernetInterface == nil || *arg.UsedEthernetInterface == "" {
-		logrus.Fatalf("\"--nc\" argument is required for initializing lightning-monkey agent.")
-	}
-	if arg.ClusterId == nil || *arg.ClusterId == "" {
-		logrus.Fatalf("\"--cluster\" argument is required for initializing lightning-monkey agent.")
-	}
-	if arg.Address == nil || *arg.Address == "" {
-		ip := GetLocalIP()
-		arg.Address = &ip
+	if config.Address == "" {
+		config.Address = GetLocalIP()
 	}
 	common.CertManager = &certs.CertificateManagerImple{}
 	agent := LightningMonkeyAgent{}
-	agent.Initialize(arg)
+	agent.Initialize(newAgentArgs(config))
 	go agent.Start()
 	agent.InitializeWebServer()
 }
 
 type AgentArgs struct {
-	AgentId               string
-	Server                *string
-	ClusterId             *string
-	Address               *string
-	NodeLabels            *string
-	UsedEthernetInterface *string
-	LeaseId               int64
-	IsETCDRole            *bool
-	IsMasterRole          *bool
-	IsMinionRole          *bool
-	IsHARole              *bool
-	ListenPort            *int
+	AgentId                string
+	Servers                []string
+	ClusterId              *string
+	Address                *string
+	NodeLabels             *string
+	NodePool               *string
+	UsedEthernetInterface  *string
+	LeaseId                int64
+	IsETCDRole             *bool
+	IsMasterRole           *bool
+	IsMinionRole           *bool
+	IsHARole               *bool
+	ListenPort             *int
+	ContainerRuntime       *string
+	ContainerdAddress      *string
+	HealthFailureThreshold *int
+	RegisterTimeout        time.Duration
+	RequestTimeout         time.Duration
 }
 
 // GetLocalIP returns the non loopback local IP of the host
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/g0194776/lightningmonkey/pkg/containers"
+	"github.com/g0194776/lightningmonkey/pkg/entities"
+	"github.com/g0194776/lightningmonkey/pkg/utils"
+	"github.com/sirupsen/logrus"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	preflightMaxClockSkew   = time.Second * 10
+	preflightMinFreeDiskMB  = 10 * 1024
+	minDockerVersion        = "1.13.1"
+	minContainerdVersion    = "1.3.0" //"ctr run --log-uri" is required.
+	preflightRequestTimeout = time.Second * 5
+	//host changes after registering(e.g: swap is enabled, disk is filled up) are reported in this interval.
+	preflightRefreshInterval = time.Minute * 5
+)
+
+var (
+	//kubelet runs on each of these roles.
+	kubeletRoles = []string{entities.AgentRole_ETCD, entities.AgentRole_Master, entities.AgentRole_Minion}
+	allRoles     = []string{entities.AgentRole_ETCD, entities.AgentRole_Master, entities.AgentRole_Minion, entities.AgentRole_HA}
+	networkRoles = []string{entities.AgentRole_Master, entities.AgentRole_Minion}
+)
+
+//preflightPort is a port which must be free before deploying the component,
+//the check is skipped if the component which listens on it has been running.
+type preflightPort struct {
+	port    int
+	roles   []string
+	isOwner func(c containers.Container) bool
+}
+
+//runPreflightChecks checks whether the host is capable of running Kubernetes, failures never block registering.
+//The checks are performed during registering and then periodically, results are reported along with agent status.
+func (a *LightningMonkeyAgent) runPreflightChecks() []entities.PreflightCheck {
+	checks := []entities.PreflightCheck{
+		newPreflightCheck("swap", checkSwapDisabled(), kubeletRoles...),
+		newPreflightCheck("kernel-modules", checkKernelModules("br_netfilter"), networkRoles...),
+		newPreflightCheck("sysctls", checkSysctls(map[string]string{
+			"net/ipv4/ip_forward":                "1",
+			"net/bridge/bridge-nf-call-iptables": "1",
+		}), networkRoles...),
+		newPreflightCheck("cgroups", checkCgroupControllers("cpu", "cpuacct", "cpuset", "devices", "freezer", "memory"), kubeletRoles...),
+		newPreflightCheck("disk", checkFreeDisk(a.containerRuntime.GetRootDir(), preflightMinFreeDiskMB), allRoles...),
+		newPreflightCheck("runtime-version", a.checkContainerRuntimeVersion(), allRoles...),
+		newPreflightCheck("clock-skew", a.checkClockSkew(), allRoles...),
+	}
+	ports := []preflightPort{
+		{port: 2379, roles: []string{entities.AgentRole_ETCD}, isOwner: isETCDContainer},
+		{port: 2380, roles: []string{entities.AgentRole_ETCD}, isOwner: isETCDContainer},
+		{port: 6443, roles: []string{entities.AgentRole_Master, entities.AgentRole_HA}, isOwner: func(c containers.Container) bool {
+			return c.Name == "ha" || containers.IsKubernetesContainer(c, "kube-apiserver", "kube-system")
+		}},
+		{port: 10250, roles: kubeletRoles, isOwner: func(c containers.Container) bool { return c.Name == "kubelet" }},
+	}
+	cs, err := a.containerRuntime.ListContainers()
+	for i := 0; i < len(ports); i++ {
+		name := fmt.Sprintf("port-%d", ports[i].port)
+		if err != nil {
+			checks = append(checks, newPreflightCheck(name, fmt.Errorf("Failed to retrieve all containers information, error: %s", err.Error()), ports[i].roles...))
+			continue
+		}
+		if containers.FindContainer(cs, func(c containers.Container) bool { return c.IsRunning && ports[i].isOwner(c) }) != nil {
+			checks = append(checks, newPreflightCheck(name, nil, ports[i].roles...))
+			continue
+		}
+		checks = append(checks, newPreflightCheck(name, checkPortFree(ports[i].port), ports[i].roles...))
+	}
+	for i := 0; i < len(checks); i++ {
+		if !checks[i].IsPassed {
+			logrus.Warnf("Preflight check %s failed, roles: %v, reason: %s", checks[i].Name, checks[i].Roles, checks[i].Reason)
+		}
+	}
+	return checks
+}
+
+//refreshPreflightChecks re-runs the checks if the refresh interval has elapsed, nil otherwise.
+func (a *LightningMonkeyAgent) refreshPreflightChecks() []entities.PreflightCheck {
+	if time.Since(a.getLastPreflightTime()) < preflightRefreshInterval {
+		return nil
+	}
+	checks := a.runPreflightChecks()
+	a.setLastPreflightTime(time.Now())
+	return checks
+}
+
+func (a *LightningMonkeyAgent) setLastPreflightTime(t time.Time) {
+	a.statusLock.Lock()
+	defer a.statusLock.Unlock()
+	a.lastPreflightTime = t
+}
+
+func (a *LightningMonkeyAgent) getLastPreflightTime() time.Time {
+	a.statusLock.RLock()
+	defer a.statusLock.RUnlock()
+	return a.lastPreflightTime
+}
+
+func newPreflightCheck(name string, err error, roles ...string) entities.PreflightCheck {
+	c := entities.PreflightCheck{Name: name, IsPassed: err == nil, Roles: roles}
+	if err != nil {
+		c.Reason = err.Error()
+	}
+	return c
+}
+
+func isETCDContainer(c containers.Container) bool {
+	return containers.IsKubernetesContainer(c, "etcd", "kube-system")
+}
+
+//checkSwapDisabled reads "/proc/swaps" which has only the header line if none of swap devices is enabled.
+func checkSwapDisabled() error {
+	data, err := ioutil.ReadFile("/proc/swaps")
+	if err != nil {
+		return fmt.Errorf("Failed to read swap information, error: %s", err.Error())
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) > 1 {
+		return errors.New("Swap is enabled, disable it by \"swapoff -a\"")
+	}
+	return nil
+}
+
+//checkKernelModules accepts both loaded and built-in modules, both of them appear in "/sys/module".
+func checkKernelModules(modules ...string) error {
+	missed := []string{}
+	for i := 0; i < len(modules); i++ {
+		if _, err := os.Stat("/sys/module/" + modules[i]); err != nil {
+			missed = append(missed, modules[i])
+		}
+	}
+	if len(missed) > 0 {
+		return fmt.Errorf("Kernel modules are not loaded: %s", strings.Join(missed, ","))
+	}
+	return nil
+}
+
+func checkSysctls(expected map[string]string) error {
+	mismatched := []string{}
+	for k, v := range expected {
+		data, err := ioutil.ReadFile("/proc/sys/" + k)
+		actual := strings.TrimSpace(string(data))
+		if err != nil {
+			actual = "<missing>"
+		}
+		if actual != v {
+			mismatched = append(mismatched, fmt.Sprintf("%s=%s(expected: %s)", strings.Replace(k, "/", ".", -1), actual, v))
+		}
+	}
+	if len(mismatched) > 0 {
+		return fmt.Errorf("Sysctls mismatched: %s", strings.Join(mismatched, ","))
+	}
+	return nil
+}
+
+//checkCgroupControllers parses "/proc/cgroups", e.g: "memory  4  110  1", the last column indicates whether it's enabled.
+func checkCgroupControllers(controllers ...string) error {
+	data, err := ioutil.ReadFile("/proc/cgroups")
+	if err != nil {
+		return fmt.Errorf("Failed to read cgroups information, error: %s", err.Error())
+	}
+	enabled := map[string]bool{}
+	lines := strings.Split(string(data), "\n")
+	for i := 0; i < len(lines); i++ {
+		fields := strings.Fields(lines[i])
+		if len(fields) < 4 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		enabled[fields[0]] = fields[3] == "1"
+	}
+	missed := []string{}
+	for i := 0; i < len(controllers); i++ {
+		if !enabled[controllers[i]] {
+			missed = append(missed, controllers[i])
+		}
+	}
+	if len(missed) > 0 {
+		return fmt.Errorf("Cgroup controllers are not enabled: %s", strings.Join(missed, ","))
+	}
+	return nil
+}
+
+func checkFreeDisk(dir string, minFreeMB uint64) error {
+	stat := syscall.Statfs_t{}
+	if err := syscall.Statfs(dir, &stat); err != nil {
+		return fmt.Errorf("Failed to retrieve disk usage of %s, error: %s", dir, err.Error())
+	}
+	freeMB := stat.Bavail * uint64(stat.Bsize) / 1024 / 1024
+	if freeMB < minFreeMB {
+		return fmt.Errorf("Free disk space of %s is %dMB, at least %dMB is required", dir, freeMB, minFreeMB)
+	}
+	return nil
+}
+
+func checkPortFree(port int) error {
+	l, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+	if err != nil {
+		return fmt.Errorf("Port %d is in use, error: %s", port, err.Error())
+	}
+	_ = l.Close()
+	return nil
+}
+
+func (a *LightningMonkeyAgent) checkContainerRuntimeVersion() error {
+	version, err := a.containerRuntime.GetVersion()
+	if err != nil {
+		return fmt.Errorf("Failed to retrieve version of %s, error: %s", a.containerRuntime.GetName(), err.Error())
+	}
+	minVersion := minDockerVersion
+	if a.containerRuntime.GetName() == containers.Runtime_Containerd {
+		minVersion = minContainerdVersion
+	}
+	if utils.CompareVersions(version, minVersion) < 0 {
+		return fmt.Errorf("Version of %s is %s, at least %s is required", a.containerRuntime.GetName(), version, minVersion)
+	}
+	return nil
+}
+
+//checkClockSkew compares the time of API server with the local time at the middle of the round trip.
+func (a *LightningMonkeyAgent) checkClockSkew() error {
+	start := time.Now()
+	rsp, err := a.servers.Do("GET", "/apis/v1/agent/time", nil, preflightRequestTimeout)
+	if err != nil {
+		return fmt.Errorf("Failed to retrieve time of API server, error: %s", err.Error())
+	}
+	defer rsp.Body.Close()
+	end := time.Now()
+	if rsp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Failed to retrieve time of API server, remote API server returned HTTP status code: %d", rsp.StatusCode)
+	}
+	rspObj := entities.GetServerTimeResponse{}
+	if err = json.NewDecoder(rsp.Body).Decode(&rspObj); err != nil {
+		return fmt.Errorf("Failed to retrieve time of API server, error: %s", err.Error())
+	}
+	skew := rspObj.Time.Sub(start.Add(end.Sub(start) / 2))
+	if skew < 0 {
+		skew = -skew
+	}
+	if skew > preflightMaxClockSkew {
+		return fmt.Errorf("Clock skew against API server is %s, it must be less than %s", skew.String(), preflightMaxClockSkew.String())
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/g0194776/lightningmonkey/pkg/entities"
+	"github.com/g0194776/lightningmonkey/pkg/utils"
+	"github.com/sirupsen/logrus"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	Component_ETCD              = "etcd"
+	Component_APIServer         = "kube-apiserver"
+	Component_ControllerManager = "kube-controller-manager"
+	Component_Scheduler         = "kube-scheduler"
+	Component_Kubelet           = "kubelet"
+	probeTimeout                = time.Second * 2
+	//insecure healthz ports of the components which are deployed by kubeadm.
+	controllerManagerHealthzPort = 10252
+	schedulerHealthzPort         = 10251
+	kubeletHealthzPort           = 10248
+)
+
+//probeComponent calls the probe and records its result, it returns the health status after applying the failure threshold.
+func (a *LightningMonkeyAgent) probeComponent(name string, probe func() error) bool {
+	start := time.Now()
+	err := probe()
+	latency := time.Since(start)
+	threshold := 1
+	if a.arg.HealthFailureThreshold != nil {
+		threshold = *a.arg.HealthFailureThreshold
+	}
+	a.statusLock.Lock()
+	defer a.statusLock.Unlock()
+	if a.probes == nil {
+		a.probes = map[string]*entities.ComponentProbe{}
+	}
+	p, isOK := a.probes[name]
+	if !isOK {
+		p = &entities.ComponentProbe{Name: name}
+		a.probes[name] = p
+	}
+	wasHealthy := p.IsHealthy
+	utils.UpdateComponentProbe(p, err, latency, threshold)
+	if wasHealthy != p.IsHealthy {
+		logrus.Warnf("Component %s turned to healthy: %t, reason: %s", name, p.IsHealthy, p.Reason)
+	}
+	return p.IsHealthy
+}
+
+func (a *LightningMonkeyAgent) getComponentProbes() []entities.ComponentProbe {
+	a.statusLock.RLock()
+	defer a.statusLock.RUnlock()
+	if len(a.probes) == 0 {
+		return nil
+	}
+	result := make([]entities.ComponentProbe, 0, len(a.probes))
+	for _, p := range a.probes {
+		result = append(result, *p)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result
+}
+
+func (a *LightningMonkeyAgent) probeAPIServer() error {
+	client, err := utils.NewProbeClient(utils.GetAPIServerProbeCertificates(CERTIFICATE_STORAGE_PATH), probeTimeout)
+	if err != nil {
+		return err
+	}
+	return probeHealthz(client, fmt.Sprintf("https://%s:6443/healthz", *a.arg.Address))
+}
+
+func (a *LightningMonkeyAgent) probeETCD() error {
+	client, err := utils.NewProbeClient(utils.GetETCDProbeCertificates(CERTIFICATE_STORAGE_PATH), probeTimeout)
+	if err != nil {
+		return err
+	}
+	body, err := probeHTTPEndpoint(client, fmt.Sprintf("https://%s:2379/health", *a.arg.Address))
+	if err != nil {
+		return err
+	}
+	//e.g: {"health":"true"}
+	result := struct {
+		Health string `json:"health"`
+	}{}
+	if err = json.Unmarshal(body, &result); err != nil {
+		return fmt.Errorf("Illegal ETCD health response: %s", string(body))
+	}
+	if result.Health != "true" {
+		return fmt.Errorf("ETCD reported unhealthy status: %s", string(body))
+	}
+	return nil
+}
+
+func probeLocalHealthz(port int) error {
+	client, err := utils.NewProbeClient(utils.ProbeCertificates{}, probeTimeout)
+	if err != nil {
+		return err
+	}
+	return probeHealthz(client, fmt.Sprintf("http://127.0.0.1:%d/healthz", port))
+}
+
+//probeHealthz expects that the endpoint returns "ok".
+func probeHealthz(client *http.Client, url string) error {
+	body, err := probeHTTPEndpoint(client, url)
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(string(body)) != "ok" {
+		return fmt.Errorf("Unexpected healthz response: %s", string(body))
+	}
+	return nil
+}
+
+func probeHTTPEndpoint(client *http.Client, url string) ([]byte, error) {
+	rsp, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer rsp.Body.Close()
+	body, err := ioutil.ReadAll(rsp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if rsp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s returned HTTP status code: %d, body: %s", url, rsp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return body, nil
+}
@@ -3,14 +3,124 @@ package main
 import (
 	"encoding/json"
 	"fmt"
+	"github.com/g0194776/lightningmonkey/pkg/certs"
+	"github.com/g0194776/lightningmonkey/pkg/containers"
 	"github.com/g0194776/lightningmonkey/pkg/entities"
+	"github.com/g0194776/lightningmonkey/pkg/k8s"
+	"github.com/g0194776/lightningmonkey/pkg/utils"
 	"github.com/sirupsen/logrus"
 	"io/ioutil"
 	"os"
 	"path"
+	"path/filepath"
+	"strings"
 	"time"
 )
 
+const (
+	//Component_Routes stands for the system routing rules which are managed by the agent.
+	Component_Routes = entities.ConfigDriftComponent_Routes
+)
+
+//recoverableComponent describes how a recorded component is verified and re-applied after reboot or drifted.
+type recoverableComponent struct {
+	name           string
+	requiresImages bool
+	//container must be running, the static pods are excluded since they are restarted by kubelet.
+	container string
+	//render is optional, it re-renders the expected configuration files keyed by path from the recorded arguments.
+	render func(a *LightningMonkeyAgent, args map[string]string) (map[string]string, error)
+	//isInSync is optional, it verifies the state which is not covered by configuration files.
+	isInSync func(a *LightningMonkeyAgent, args map[string]string) (bool, error)
+	reapply  func(a *LightningMonkeyAgent, args map[string]string) error
+}
+
+//recoverableComponents are re-applied in order, the kubelet runs the static pods of ETCD and master components.
+var recoverableComponents = []recoverableComponent{
+	{
+		name:           entities.AgentJob_Deploy_ETCD,
+		requiresImages: true,
+		reapply: func(a *LightningMonkeyAgent, args map[string]string) error {
+			_, err := HandleDeployETCD(&entities.AgentJob{Name: entities.AgentJob_Deploy_ETCD, Arguments: args}, a)
+			return err
+		},
+	},
+	{
+		name:           entities.AgentJob_Deploy_Master,
+		requiresImages: true,
+		reapply: func(a *LightningMonkeyAgent, args map[string]string) error {
+			_, err := HandleDeployMaster(&entities.AgentJob{Name: entities.AgentJob_Deploy_Master, Arguments: args}, a)
+			return err
+		},
+	},
+	{
+		name:           entities.AgentJob_Deploy_HA,
+		requiresImages: true,
+		container:      "ha",
+		render: func(a *LightningMonkeyAgent, args map[string]string) (map[string]string, error) {
+			keepAlived, err := renderKeepAlivedConfig(strings.Split(args["ha-addresses"], ","), args, a)
+			if err != nil {
+				return nil, err
+			}
+			haProxy, err := renderHAProxyConfig(strings.Split(args["master-addresses"], ","))
+			if err != nil {
+				return nil, err
+			}
+			return map[string]string{keepAlivedConfigPath: keepAlived, haProxyConfigPath: haProxy}, nil
+		},
+		reapply: func(a *LightningMonkeyAgent, args map[string]string) error {
+			if err := a.removeContainer("ha"); err != nil {
+				return err
+			}
+			_, err := HandleDeployHA(&entities.AgentJob{Name: entities.AgentJob_Deploy_HA, Arguments: args}, a)
+			return err
+		},
+	},
+	{
+		name:           Component_Kubelet,
+		requiresImages: true,
+		container:      "kubelet",
+		render: func(a *LightningMonkeyAgent, args map[string]string) (map[string]string, error) {
+			pool, err := utils.ParseNodePool(args["node-pool"])
+			if err != nil {
+				return nil, err
+			}
+			slots, err := utils.ApplyNodePool(a.masterSettings, pool)
+			if err != nil {
+				return nil, err
+			}
+			settings, err := k8s.RenderKubeletSettings(slots)
+			if err != nil {
+				return nil, err
+			}
+			return map[string]string{filepath.Join(CERTIFICATE_STORAGE_PATH, "kubelet_settings.yml"): settings}, nil
+		},
+		reapply: func(a *LightningMonkeyAgent, args map[string]string) error {
+			if err := a.removeContainer("kubelet"); err != nil {
+				return err
+			}
+			return a.runKubeletContainer(args["master-ip"], args["bootstrap-token"], args["node-pool"])
+		},
+	},
+	{
+		name: Component_Routes,
+		isInSync: func(a *LightningMonkeyAgent, args map[string]string) (bool, error) {
+			routes, err := decodeRecordedRoutes(args)
+			if err != nil {
+				return false, err
+			}
+			return a.isSystemRoutesInSync(routes)
+		},
+		reapply: func(a *LightningMonkeyAgent, args map[string]string) error {
+			routes, err := decodeRecordedRoutes(args)
+			if err != nil {
+				return err
+			}
+			return a.applySystemRoutes(routes)
+		},
+	},
+}
+
 func (a *LightningMonkeyAgent) recover() error {
 	var err error
 	if _, err = os.Stat(RECOVERY_FILE_PATH); err != nil {
@@ -34,9 +144,9 @@ func (a *LightningMonkeyAgent) recover() error {
 		a.arg.ClusterId = &a.rr.ClusterID
 	}
 	logrus.Warn("Entering recovery mode...")
-	//wait until all of installed components becomes healthy.
+	//re-apply the recorded configurations, then wait until all of installed components becomes healthy.
 	for {
-		if a.checkHealthy() {
+		if a.reapplyComponents() && a.checkHealthy() {
 			break
 		}
 		time.Sleep(time.Second * 3)
@@ -68,15 +178,187 @@ func (a *LightningMonkeyAgent) checkHealthy() bool {
 			return false
 		}
 	}
+	if a.rr.HasInstalledHA {
+		if rs, isOK = a.ItemsStatus[entities.AgentJob_Deploy_HA]; !isOK || !rs.HasProvisioned {
+			logrus.Debugf("[RECOVERY MODE] Waiting...HAProxy & KeepAlived still not healthy!")
+			return false
+		}
+	}
 	return true
 }
 
+//reapplyComponents verifies the recorded components in order, the ones whose configurations have been lost or changed
+//are installed again with the recorded arguments. It returns false if any of them is still out of sync.
+func (a *LightningMonkeyAgent) reapplyComponents() bool {
+	//the agent has not registered yet, uses the images and settings which had been used for installing.
+	if a.basicImages == nil {
+		a.basicImages = a.rr.BasicImages
+	}
+	if a.masterSettings == nil {
+		a.masterSettings = a.rr.MasterSettings
+	}
+	inSync := true
+	//the containers are verified same as detecting the drift, unchanged configurations do not mean they are running.
+	cs, err := a.containerRuntime.ListContainers()
+	if err != nil {
+		logrus.Errorf("[RECOVERY MODE] Failed to retrieve all containers information, error: %s", err.Error())
+		cs = nil
+	}
+	for _, c := range recoverableComponents {
+		rc := a.getRecoveryComponent(c.name)
+		if rc == nil {
+			continue
+		}
+		changed := utils.ChangedFiles(rc.Checksums)
+		isInSync := len(changed) == 0
+		if isInSync && c.container != "" && cs != nil {
+			ct := containers.FindContainer(cs, func(ct containers.Container) bool { return ct.Name == c.container })
+			if ct == nil || !ct.IsRunning {
+				logrus.Warnf("[RECOVERY MODE] Container %s of component %s is missing or not running", c.container, c.name)
+				isInSync = false
+			}
+		}
+		if isInSync && c.isInSync != nil {
+			var err error
+			if isInSync, err = c.isInSync(a, rc.Arguments); err != nil {
+				logrus.Errorf("[RECOVERY MODE] Failed to verify component %s, error: %s", c.name, err.Error())
+			}
+		}
+		if isInSync {
+			continue
+		}
+		if c.requiresImages && a.basicImages == nil {
+			logrus.Errorf("[RECOVERY MODE] Unable to re-apply component %s, the used images have not been recorded", c.name)
+			inSync = false
+			continue
+		}
+		logrus.Warnf("[RECOVERY MODE] Re-applying component %s, changed configurations: %v", c.name, changed)
+		if err := c.reapply(a, rc.Arguments); err != nil {
+			logrus.Errorf("[RECOVERY MODE] Failed to re-apply component %s, error: %s", c.name, err.Error())
+			inSync = false
+			continue
+		}
+		if changed = utils.ChangedFiles(rc.Checksums); len(changed) > 0 {
+			logrus.Warnf("[RECOVERY MODE] Re-applied configurations of component %s are different from the recorded ones: %v", c.name, changed)
+		}
+		a.recordComponent(c.name, rc.Arguments)
+	}
+	return inSync
+}
+
+//recordJob keeps the recovery record in step with the job which has been performed successfully.
+func (a *LightningMonkeyAgent) recordJob(job *entities.AgentJob) {
+	switch job.Name {
+	case entities.AgentJob_Deploy_ETCD, entities.AgentJob_Deploy_Master, entities.AgentJob_Deploy_HA, entities.AgentJob_Deploy_Minion:
+		a.recordComponent(job.Name, job.Arguments)
+	case entities.AgentJob_HA_Backends:
+		rc := a.getRecoveryComponent(entities.AgentJob_Deploy_HA)
+		if rc == nil {
+			return
+		}
+		args := make(map[string]string, len(rc.Arguments))
+		for k, v := range rc.Arguments {
+			args[k] = v
+		}
+		args["master-addresses"] = job.Arguments["master-addresses"]
+		a.recordComponent(entities.AgentJob_Deploy_HA, args)
+	default:
+		//configurations might have been regenerated by the job, e.g. upgrading or restoring ETCD.
+		for _, c := range recoverableComponents {
+			if rc := a.getRecoveryComponent(c.name); rc != nil {
+				a.recordComponent(c.name, rc.Arguments)
+			}
+		}
+	}
+}
+
+//recordComponent saves the arguments and the checksums of generated configuration files of an installed component,
+//the recovery file is only written when any of them has been changed.
+func (a *LightningMonkeyAgent) recordComponent(name string, args map[string]string) {
+	if a.rr == nil {
+		return
+	}
+	rc := &RecoveryComponent{
+		Arguments:  args,
+		Checksums:  utils.ChecksumFiles(componentConfigPaths(name, args)),
+		UpdateTime: time.Now(),
+	}
+	a.recoveryLock.Lock()
+	if previous, isOK := a.rr.Components[name]; isOK && isSameStringMap(previous.Arguments, rc.Arguments) && isSameStringMap(previous.Checksums, rc.Checksums) {
+		a.recoveryLock.Unlock()
+		return
+	}
+	if a.rr.Components == nil {
+		a.rr.Components = make(map[string]*RecoveryComponent)
+	}
+	a.rr.Components[name] = rc
+	if a.basicImages != nil {
+		a.rr.BasicImages = a.basicImages
+	}
+	if a.masterSettings != nil {
+		a.rr.MasterSettings = a.masterSettings
+	}
+	a.recoveryLock.Unlock()
+	if err := a.saveRecoveryFile(); err != nil {
+		logrus.Errorf("Failed to record component %s into recovery file, error: %s", name, err.Error())
+	}
+}
+
+func (a *LightningMonkeyAgent) getRecoveryComponent(name string) *RecoveryComponent {
+	a.recoveryLock.Lock()
+	defer a.recoveryLock.Unlock()
+	if a.rr == nil {
+		return nil
+	}
+	return a.rr.Components[name]
+}
+
+//componentConfigPaths returns the configuration files which are generated during installing the component.
+//The kubeconfig of kubelet is excluded, its client certificate is re-generated every time.
+func componentConfigPaths(name string, args map[string]string) []string {
+	switch name {
+	case entities.AgentJob_Deploy_ETCD:
+		return certs.GetETCDConfigurationPaths(CERTIFICATE_STORAGE_PATH)
+	case entities.AgentJob_Deploy_Master:
+		return certs.GetMasterManifestPaths(CERTIFICATE_STORAGE_PATH)
+	case entities.AgentJob_Deploy_HA:
+		return []string{keepAlivedConfigPath, haProxyConfigPath}
+	case Component_Kubelet:
+		paths := []string{filepath.Join(CERTIFICATE_STORAGE_PATH, "kubelet_settings.yml")}
+		if args["bootstrap-token"] != "" {
+			paths = append(paths, filepath.Join(CERTIFICATE_STORAGE_PATH, "bootstrap-kubelet.conf"))
+		}
+		return paths
+	default:
+		return nil
+	}
+}
+
+func isSameStringMap(m1, m2 map[string]string) bool {
+	if len(m1) != len(m2) {
+		return false
+	}
+	for k, v := range m1 {
+		if v2, isOK := m2[k]; !isOK || v2 != v {
+			return false
+		}
+	}
+	return true
+}
+
+func decodeRecordedRoutes(args map[string]string) ([]entities.SystemRoute, error) {
+	routes := []entities.SystemRoute{}
+	if err := json.Unmarshal([]byte(args["routes"]), &routes); err != nil {
+		return nil, fmt.Errorf("Failed to decode recorded system routes, error: %s", err.Error())
+	}
+	return routes, nil
+}
+
 func (a *LightningMonkeyAgent) saveRecoveryFile() error {
 	a.recoveryLock.Lock()
 	defer a.recoveryLock.Unlock()
-	_ = os.Remove(RECOVERY_FILE_PATH)
 	//create path.
-	err := os.MkdirAll(path.Dir(RECOVERY_FILE_PATH), 0644) //rw-r--r--
+	err := os.MkdirAll(path.Dir(RECOVERY_FILE_PATH), 0755) //rwxr-xr-x
 	if err != nil {
 		return fmt.Errorf("Failed to create path to save recovery file, error: %s", err.Error())
 	}
@@ -84,8 +366,17 @@ func (a *LightningMonkeyAgent) saveRecoveryFile() error {
 	if err != nil {
 		return fmt.Errorf("Failed to marshal recovery object structure to JSON data, error: %s", err.Error())
 	}
-	err = ioutil.WriteFile(RECOVERY_FILE_PATH, data, 0644) //rw-r--r--
+	//the arguments of jobs might contain bootstrap tokens.
+	//writes to a temporary file then renames it, the previous recovery file is kept if the agent crashes in the meantime.
+	tmpPath := RECOVERY_FILE_PATH + ".tmp"
+	err = ioutil.WriteFile(tmpPath, data, 0600) //rw-------
+	if err != nil {
+		_ = os.Remove(tmpPath)
+		return fmt.Errorf("Failed to save recovery file, error: %s", err.Error())
+	}
+	err = os.Rename(tmpPath, RECOVERY_FILE_PATH)
 	if err != nil {
+		_ = os.Remove(tmpPath)
 		return fmt.Errorf("Failed to save recovery file, error: %s", err.Error())
 	}
 	return nil
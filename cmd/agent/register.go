@@ -2,31 +2,23 @@ package main
 
 import (
 	"bytes"
-	"context"
 	"errors"
 	"fmt"
-	"github.com/matishsiao/goInfo"
 	uuid "github.com/satori/go.uuid"
-	"io"
 	"io/ioutil"
 	"net/http"
 	"os"
 	"path/filepath"
 	"strconv"
-	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 
-	"github.com/docker/engine-api/client"
-	"github.com/docker/engine-api/types"
-	"github.com/docker/engine-api/types/container"
-	"github.com/docker/engine-api/types/network"
+	"github.com/g0194776/lightningmonkey/pkg/containers"
 	"github.com/g0194776/lightningmonkey/pkg/entities"
 	"github.com/g0194776/lightningmonkey/pkg/k8s"
 	"github.com/g0194776/lightningmonkey/pkg/managers"
-	"github.com/shirou/gopsutil/cpu"
-	"github.com/shirou/gopsutil/mem"
+	"github.com/g0194776/lightningmonkey/pkg/utils"
 	"github.com/sirupsen/logrus"
 	"golang.org/x/xerrors"
 	"k8s.io/apimachinery/pkg/util/json"
@@ -58,60 +50,49 @@ func (a *LightningMonkeyAgent) Register() (err error) {
 	}()
 	hostname, _ := os.Hostname()
 	agentObj := entities.LightningMonkeyAgent{
-		HasETCDRole:   *a.arg.IsETCDRole,
-		HasMasterRole: *a.arg.IsMasterRole,
-		HasMinionRole: *a.arg.IsMinionRole,
-		HasHARole:     *a.arg.IsHARole,
-		ClusterId:     *a.arg.ClusterId,
-		Hostname:      hostname,
-		ListenPort:    *a.arg.ListenPort,
-		Id:            a.arg.AgentId,
+		HasETCDRole:     *a.arg.IsETCDRole,
+		HasMasterRole:   *a.arg.IsMasterRole,
+		HasMinionRole:   *a.arg.IsMinionRole,
+		HasHARole:       *a.arg.IsHARole,
+		ClusterId:       *a.arg.ClusterId,
+		Hostname:        hostname,
+		ListenPort:      *a.arg.ListenPort,
+		Id:              a.arg.AgentId,
+		Version:         AGENT_VERSION,
+		IsContainerized: isRunningInContainer(),
+		AccessToken:     a.accessToken,
+		NodePool:        *a.arg.NodePool,
 	}
 	//obtains host information.
-	ci, err := cpu.InfoWithContext(context.Background())
+	agentObj.HostInformation, err = a.collectHostInformation()
 	if err != nil {
-		logrus.Fatalf("Failed to obtain host CPU information, error: %s", err.Error())
+		logrus.Fatalf("%s", err.Error())
 		return
 	}
-	memory, err := mem.VirtualMemory()
-	if err != nil {
-		logrus.Fatalf("Failed to obtain host Memory information, error: %s", err.Error())
-		return
-	}
-	gi := goInfo.GetInfo()
-	agentObj.HostInformation.CPUCores = ci[0].Cores
-	agentObj.HostInformation.CPUMhz = ci[0].Mhz
-	agentObj.HostInformation.MemoryTotalMB = memory.Total / 1024 / 1024
-	agentObj.HostInformation.OS = gi.GoOS
-	agentObj.HostInformation.Kernel = fmt.Sprintf("%s %s", gi.Kernel, gi.Core)
+	a.setLastHostFactsTime(agentObj.HostInformation.UpdateTime)
+	agentObj.Preflight = a.runPreflightChecks()
+	a.setLastPreflightTime(time.Now())
 	bodyData, err := json.Marshal(agentObj)
 	if err != nil {
 		return xerrors.Errorf("%s %w", err.Error(), crashError)
 	}
-	client := http.Client{
-		Timeout:   time.Second * 120,
-		Transport: http.DefaultTransport,
-	}
-	req, err := http.NewRequest("POST", fmt.Sprintf("%s/apis/v1/agent/register", *a.arg.Server), bytes.NewReader(bodyData))
+	//network errors are transient, they are retried by the main loop.
+	rsp, err := a.servers.Do("POST", "/apis/v1/agent/register", bytes.NewReader(bodyData), a.arg.RegisterTimeout)
 	if err != nil {
-		return xerrors.Errorf("%s %w", err.Error(), crashError)
-	}
-	rsp, err := client.Do(req)
-	if err != nil {
-		return xerrors.Errorf("%s %w", err.Error(), crashError)
+		return err
 	}
 	defer rsp.Body.Close()
 	if rsp.StatusCode != http.StatusOK {
-		return xerrors.Errorf("Remote API server returned a non-zero HTTP status code: %d %w", rsp.StatusCode, crashError)
+		return fmt.Errorf("Remote API server returned a non-zero HTTP status code: %d", rsp.StatusCode)
 	}
 	httpRspBodyDate, err := ioutil.ReadAll(rsp.Body)
 	if err != nil {
-		return xerrors.Errorf("%s %w", err.Error(), crashError)
+		return err
 	}
 	rspObj := entities.RegisterAgentResponse{}
 	err = json.Unmarshal(httpRspBodyDate, &rspObj)
 	if err != nil {
-		return xerrors.Errorf("%s %w", err.Error(), crashError)
+		return err
 	}
 	if rspObj.NeedCrash {
 		return xerrors.Errorf("Remote API server returned an unrecoverable error: %s %w", rspObj.Reason, crashError)
@@ -121,13 +102,14 @@ func (a *LightningMonkeyAgent) Register() (err error) {
 	}
 	a.arg.AgentId = rspObj.AgentId
 	a.arg.LeaseId = rspObj.LeaseId
+	a.setPendingAgentSoftware(rspObj.AgentSoftware)
 	if *a.arg.ClusterId == uuid.Nil.String() || !a.hasInitializedRoles() {
 		logrus.Warn("Currently, agent has not belong to any cluster or has no any initialized roles, it's waiting for the remote call...")
 		return errNotInitialized
 	}
 	a.basicImages = &rspObj.BasicImages
 	a.masterSettings = rspObj.MasterSettings
-	a.dockerImageManager, err = managers.NewDockerImageManager(*a.arg.Server, a.dockerClient, &rspObj.BasicImages)
+	a.dockerImageManager, err = managers.NewDockerImageManager(a.servers.Active(), a.servers.client, a.containerRuntime, &rspObj.BasicImages)
 	if err != nil {
 		return xerrors.Errorf("Failed to create new docker image manager: %s %w", err.Error(), crashError)
 	}
@@ -145,7 +127,7 @@ func (a *LightningMonkeyAgent) Register() (err error) {
 	}
 	a.expectedETCDNodeCount, err = strconv.Atoi(rspObj.MasterSettings[entities.MasterSettings_ExpectedETCDNodeCount])
 	if err != nil {
-		logrus.Fatal("Illegal number of expected ETCD count: %s", rspObj.MasterSettings[entities.MasterSettings_ExpectedETCDNodeCount])
+		logrus.Fatalf("Illegal number of expected ETCD count: %s", rspObj.MasterSettings[entities.MasterSettings_ExpectedETCDNodeCount])
 		return
 	}
 	logrus.Debugf("API file server readonly token: %s", rspObj.BasicImages.HTTPDownloadToken)
@@ -153,15 +135,15 @@ func (a *LightningMonkeyAgent) Register() (err error) {
 	logrus.Info("Preparing downloading certificates & loading docker images...")
 	err = a.downloadCertificates()
 	if err != nil {
-		return xerrors.Errorf("%s %w", err.Error(), crashError)
+		return err
 	}
 	err = a.dockerImageManager.Ready()
 	if err != nil {
-		return xerrors.Errorf("%s %w", err.Error(), crashError)
+		return err
 	}
 	//directly start kubelet up when it has not Minion role.
 	if !*a.arg.IsMinionRole {
-		return a.runKubeletContainer(*a.arg.Address)
+		return a.runKubeletContainer(*a.arg.Address, "", "")
 	}
 	//otherwise, wait until all of depended components has been started.
 	return nil
@@ -172,7 +154,6 @@ func (a *LightningMonkeyAgent) downloadCertificates() error {
 	if err != nil {
 		return xerrors.Errorf("Failed to create certificate storage path: %s %w", err.Error(), crashError)
 	}
-	//TODO: does not all of base certificates are needed during minion node initialization.
 	neededCerts := []string{
 		"ca.crt",
 		"ca.key",
@@ -183,41 +164,37 @@ func (a *LightningMonkeyAgent) downloadCertificates() error {
 		"etcd/ca.crt",
 		"etcd/ca.key",
 	}
+	//minion-only node joins cluster through kubelet TLS bootstrap, the CA private keys are never needed.
+	if !a.needsCAPrivateKeys() {
+		neededCerts = []string{"ca.crt"}
+	}
 	for i := 0; i < len(neededCerts); i++ {
 		logrus.Infof("Downloading certificate: \"%s\"...", neededCerts[i])
 		err = a.saveRemoteCertificate(neededCerts[i], CERTIFICATE_STORAGE_PATH)
 		if err != nil {
-			return xerrors.Errorf("Failed to save remote certificate data to local disk file, error: %s %w", err.Error(), crashError)
+			return xerrors.Errorf("Failed to save remote certificate data to local disk file, error: %w", err)
 		}
 	}
 	return nil
 }
 
 func (a *LightningMonkeyAgent) saveRemoteCertificate(certName, path string) error {
-	client := http.Client{
-		Timeout:   time.Second * 5,
-		Transport: http.DefaultTransport,
-	}
-	req, err := http.NewRequest("GET", fmt.Sprintf("%s/apis/v1/certs/get?cluster=%s&cert=%s", *a.arg.Server, *a.arg.ClusterId, certName), nil)
+	rsp, err := a.servers.Do("GET", fmt.Sprintf("/apis/v1/certs/get?cluster=%s&agent-id=%s&cert=%s", *a.arg.ClusterId, a.arg.AgentId, certName), nil, a.arg.RequestTimeout)
 	if err != nil {
-		return xerrors.Errorf("%s %w", err.Error(), crashError)
-	}
-	rsp, err := client.Do(req)
-	if err != nil {
-		return xerrors.Errorf("%s %w", err.Error(), crashError)
+		return err
 	}
 	defer rsp.Body.Close()
 	if rsp.StatusCode != http.StatusOK {
-		return xerrors.Errorf("Remote API server returned a non-zero HTTP status code: %d %w", rsp.StatusCode, crashError)
+		return fmt.Errorf("Remote API server returned a non-zero HTTP status code: %d", rsp.StatusCode)
 	}
 	rspObj := entities.GetCertificateResponse{}
 	rspData, err := ioutil.ReadAll(rsp.Body)
 	if err != nil {
-		return xerrors.Errorf("%s %w", err.Error(), crashError)
+		return err
 	}
 	err = json.Unmarshal(rspData, &rspObj)
 	if err != nil {
-		return xerrors.Errorf("%s %w", err.Error(), crashError)
+		return err
 	}
 	if rspObj.ErrorId != entities.Succeed {
 		return fmt.Errorf("Remote API server returned a non-zero biz code: %d %w", rspObj.ErrorId, crashError)
@@ -262,6 +239,12 @@ func (a *LightningMonkeyAgent) Initialize(arg AgentArgs) {
 	if a.recoveryLock == nil {
 		a.recoveryLock = &sync.Mutex{}
 	}
+	if a.routesLock == nil {
+		a.routesLock = &sync.Mutex{}
+	}
+	if a.jobLock == nil {
+		a.jobLock = &sync.Mutex{}
+	}
 	if a.handlerFactory == nil {
 		a.handlerFactory = &AgentJobHandlerFactory{}
 		a.handlerFactory.Initialize(a.c, a)
@@ -269,12 +252,19 @@ func (a *LightningMonkeyAgent) Initialize(arg AgentArgs) {
 	if a.workQueue == nil {
 		a.workQueue = make(chan *entities.AgentJob, 1)
 	}
-	c, err := client.NewEnvClient()
+	cr, err := containers.NewContainerRuntime(*a.arg.ContainerRuntime, *a.arg.ContainerdAddress)
 	if err != nil {
-		logrus.Fatalf("Failed to initialize docker client, error: %s %w", err.Error(), crashError)
+		logrus.Fatalf("Failed to initialize container runtime, error: %s", err.Error())
 		return
 	}
-	a.dockerClient = c
+	a.containerRuntime = cr
+	if a.accessToken == "" {
+		a.accessToken = uuid.NewV4().String()
+	}
+	if a.servers == nil {
+		a.servers = newServerPool(a.arg.Servers, serverTransport, a.accessToken)
+		go a.servers.startHealthChecking()
+	}
 }
 
 func (a *LightningMonkeyAgent) startStatusTracing() {
@@ -340,6 +330,16 @@ func (a *LightningMonkeyAgent) updateRecoveryFile(rs LightningMonkeyAgentReportS
 				logrus.Error(err.Error())
 			}
 		}
+	case entities.AgentJob_Deploy_HA:
+		if !a.rr.HasInstalledHA && rs.Item.HasProvisioned {
+			a.rr.HasInstalledHA = true
+			a.rr.HADeploymentType = COMPONENT_DEPLOYMENT_INTEGRATION
+			a.rr.InstallHATime = rs.Item.LastSeenTime
+			logrus.Debugf("Try writing recovery file with HAProxy & KeepAlived deployment status...")
+			if err = a.saveRecoveryFile(); err != nil {
+				logrus.Error(err.Error())
+			}
+		}
 	default:
 		//NOP
 	}
@@ -359,6 +359,9 @@ func (a *LightningMonkeyAgent) Start() {
 		a.arg.IsMasterRole = &a.rr.IsMasterRole
 		a.arg.IsMinionRole = &a.rr.IsMinionRole
 		a.arg.IsHARole = &a.rr.IsHARole
+		if a.rr.NodePool != nil {
+			a.arg.NodePool = a.rr.NodePool
+		}
 	} else {
 		//create new recovery record when it's the first time to boot up.
 		a.rr = &RecoveryRecord{ClusterID: *a.arg.ClusterId}
@@ -367,9 +370,15 @@ func (a *LightningMonkeyAgent) Start() {
 	go a.reportStatus()
 	//start new go-routine for performing jobs.
 	go a.performJob()
+	//start new go-routine for detecting configuration drift of installed components.
+	go a.detectConfigDriftPeriodically()
+	//transient failures of API servers are retried with an increasing delay.
+	bo := utils.Backoff{Base: time.Second * 5, Max: time.Minute * 2}
 	//main loop start here.
 	for {
 		time.Sleep(time.Second * 5)
+		//it's safe to replace itself here, none of jobs is being performed.
+		a.tryUpgradeSelf()
 		//try to register itself.
 		err = a.Register()
 		if err != nil {
@@ -381,6 +390,7 @@ func (a *LightningMonkeyAgent) Start() {
 			if xerrors.Is(err, crashError) {
 				os.Exit(1)
 			}
+			time.Sleep(bo.Next())
 			continue
 		}
 		job, err := a.queryJob()
@@ -389,8 +399,10 @@ func (a *LightningMonkeyAgent) Start() {
 			if xerrors.Is(err, crashError) {
 				os.Exit(1)
 			}
+			time.Sleep(bo.Next())
 			continue
 		}
+		bo.Reset()
 		if job == nil {
 			continue
 		}
@@ -398,6 +410,7 @@ func (a *LightningMonkeyAgent) Start() {
 			logrus.Info(job.Reason)
 			continue
 		}
+		atomic.StoreInt32(&a.isTrackingJob, 1)
 		//do block when it's busy performing previous job.
 		a.workQueue <- job
 		//start tracing current executing job progressing.
@@ -425,11 +438,13 @@ func (a *LightningMonkeyAgent) Start() {
 			}
 			logrus.Debugf("Waiting for job execution finish...Job: %s, Args: %#v", a.currentJob.Name, a.currentJob.Arguments)
 		}
+		atomic.StoreInt32(&a.isTrackingJob, 0)
 	}
 }
 
 func (a *LightningMonkeyAgent) reportStatus() {
 	var err error
+	bo := utils.Backoff{Base: time.Second * 3, Max: time.Minute}
 	for {
 		time.Sleep(time.Second * 3)
 		if atomic.LoadInt32(&a.hasRegistered) == 0 {
@@ -441,41 +456,48 @@ func (a *LightningMonkeyAgent) reportStatus() {
 			if xerrors.Is(err, crashError) {
 				os.Exit(1)
 			}
+			time.Sleep(bo.Next())
+			continue
 		}
+		bo.Reset()
 	}
 }
 
 func (a *LightningMonkeyAgent) reportStatusInternal() error {
-	client := http.Client{
-		Timeout:   time.Second * 5,
-		Transport: http.DefaultTransport,
-	}
 	status := entities.LightningMonkeyAgentReportStatus{
-		IP:      *a.arg.Address,
-		LeaseId: a.arg.LeaseId,
-		Items:   a.cloneStatusMap(),
+		IP:          *a.arg.Address,
+		LeaseId:     a.arg.LeaseId,
+		Items:       a.cloneStatusMap(),
+		Upgrade:     a.getUpgradeStatus(),
+		ETCDMembers: a.getETCDMembers(),
+		Snapshot:    a.getETCDSnapshot(),
+		Restore:     a.getRestoreStatus(),
+		ResetId:     a.getResetId(),
+		Probes:      a.getComponentProbes(),
+		Host:        a.refreshHostInformation(),
+		ConfigDrift: a.getConfigDrift(),
+		Preflight:   a.refreshPreflightChecks(),
+	}
+	if *a.arg.IsHARole {
+		status.HABackends = getHAProxyBackends()
 	}
 	bodyData, err := json.Marshal(status)
 	if err != nil {
 		return xerrors.Errorf("%s %w", err.Error(), crashError)
 	}
-	req, err := http.NewRequest("PUT", fmt.Sprintf("%s/apis/v1/agent/status?agent-id=%s&cluster-id=%s", *a.arg.Server, a.arg.AgentId, *a.arg.ClusterId), bytes.NewBuffer(bodyData))
+	rsp, err := a.servers.Do("PUT", fmt.Sprintf("/apis/v1/agent/status?agent-id=%s&cluster-id=%s", a.arg.AgentId, *a.arg.ClusterId), bytes.NewReader(bodyData), a.arg.RequestTimeout)
 	if err != nil {
-		return xerrors.Errorf("%s %w", err.Error(), crashError)
-	}
-	rsp, err := client.Do(req)
-	if err != nil {
-		return xerrors.Errorf("%s %w", err.Error(), crashError)
+		return err
 	}
 	defer rsp.Body.Close()
 	obj := entities.AgentReportStatusResponse{}
 	rspData, err := ioutil.ReadAll(rsp.Body)
 	if err != nil {
-		return xerrors.Errorf("%s %w", err.Error(), crashError)
+		return err
 	}
 	err = json.Unmarshal(rspData, &obj)
 	if err != nil {
-		return xerrors.Errorf("%s %w", err.Error(), crashError)
+		return err
 	}
 	if obj.ErrorId != entities.Succeed {
 		internalErr := fmt.Errorf("Failed to report status remote API server, biz code: %d, error: %s", obj.ErrorId, obj.Reason)
@@ -486,6 +508,8 @@ func (a *LightningMonkeyAgent) reportStatusInternal() error {
 	}
 	//reset lease-id for avoiding lease not working problems. (re-connecting after over allowed maximum heart-beat interval)
 	a.arg.LeaseId = obj.LeaseId
+	a.setPendingAgentSoftware(obj.AgentSoftware)
+	a.setDriftRemediation(obj.DriftRemediation)
 	return nil
 }
 
@@ -500,15 +524,7 @@ func (a *LightningMonkeyAgent) cloneStatusMap() map[string]entities.LightningMon
 }
 
 func (a *LightningMonkeyAgent) queryJob() (*entities.AgentJob, error) {
-	client := http.Client{
-		Timeout:   time.Second * 5,
-		Transport: http.DefaultTransport,
-	}
-	req, err := http.NewRequest("GET", fmt.Sprintf("%s/apis/v1/agent/query?agent-id=%s&cluster-id=%s", *a.arg.Server, a.arg.AgentId, *a.arg.ClusterId), nil)
-	if err != nil {
-		return nil, err
-	}
-	rsp, err := client.Do(req)
+	rsp, err := a.servers.Do("GET", fmt.Sprintf("/apis/v1/agent/query?agent-id=%s&cluster-id=%s", a.arg.AgentId, *a.arg.ClusterId), nil, a.arg.RequestTimeout)
 	if err != nil {
 		return nil, err
 	}
@@ -542,7 +558,9 @@ func (a *LightningMonkeyAgent) performJob() {
 			job.HadDone = true
 			return
 		}
+		a.jobLock.Lock()
 		err = a.handleJob(job)
+		a.jobLock.Unlock()
 		if err != nil {
 			logrus.Error(err)
 		}
@@ -583,33 +601,42 @@ func (a *LightningMonkeyAgent) handleJob(job *entities.AgentJob) error {
 		job.HadDone = true
 		return fmt.Errorf("Failed to process job: %#v, which returned an un-successful status!", job)
 	}
+	a.recordJob(job)
 	return nil
 }
 
-func (a *LightningMonkeyAgent) runKubeletContainer(masterIP string) error {
+//runKubeletContainer starts kubelet up, the node pool is given by the minion job in JSON format.
+func (a *LightningMonkeyAgent) runKubeletContainer(masterIP, bootstrapToken, nodePool string) error {
 	var err error
-	var cs []types.Container
-	cs, err = a.dockerClient.ContainerList(context.Background(), types.ContainerListOptions{})
+	var cs []containers.Container
+	cs, err = a.containerRuntime.ListContainers()
 	if err != nil {
 		return xerrors.Errorf("Failed to get running container list, error: %s %w", err.Error(), crashError)
 	}
 	//check whether a container named "kubelet" has been started.
-	if cs != nil && len(cs) > 0 {
-		for i := 0; i < len(cs); i++ {
-			if cs[i].Names[0] == "/kubelet" {
-				if strings.Contains(cs[i].Status, "Up") {
-					//kubelet has been started successfully, skip other actions.
-					return nil
-				} else {
-					return xerrors.Errorf("\"kubelet\" has been started but with unhealthy container status %w", crashError)
-				}
-			}
+	if c := containers.FindContainer(cs, func(c containers.Container) bool { return c.Name == "kubelet" }); c != nil {
+		if c.IsRunning {
+			//kubelet has been started successfully, skip other actions.
+			return nil
 		}
+		return xerrors.Errorf("\"kubelet\" has been started but with unhealthy container status %w", crashError)
 	}
 	if masterIP == "" {
-		err = k8s.GenerateKubeletConfig(CERTIFICATE_STORAGE_PATH, *a.arg.Address, a.masterSettings)
+		masterIP = *a.arg.Address
+	}
+	pool, err := utils.ParseNodePool(nodePool)
+	if err != nil {
+		return xerrors.Errorf("%s %w", err.Error(), crashError)
+	}
+	//resource reservation and kubelet settings of the node pool override the cluster's ones.
+	settings, err := utils.ApplyNodePool(a.masterSettings, pool)
+	if err != nil {
+		return xerrors.Errorf("Failed to apply node pool settings, error: %s %w", err.Error(), crashError)
+	}
+	if bootstrapToken != "" {
+		err = k8s.GenerateKubeletBootstrapConfig(CERTIFICATE_STORAGE_PATH, masterIP, bootstrapToken, settings)
 	} else {
-		err = k8s.GenerateKubeletConfig(CERTIFICATE_STORAGE_PATH, masterIP, a.masterSettings)
+		err = k8s.GenerateKubeletConfig(CERTIFICATE_STORAGE_PATH, masterIP, settings)
 	}
 	if err != nil {
 		return xerrors.Errorf("Failed to generate kube-config, master-ip: %s, error: %s %w", masterIP, err.Error(), crashError)
@@ -619,10 +646,10 @@ func (a *LightningMonkeyAgent) runKubeletContainer(masterIP string) error {
 	//resource reservation calculation.
 	var isOK bool
 	var kubeReserve, systemReserve string
-	if kubeReserve, isOK = a.masterSettings[entities.MasterSettings_ResourceReservation_Kube]; !isOK {
+	if kubeReserve, isOK = settings[entities.MasterSettings_ResourceReservation_Kube]; !isOK {
 		kubeReserve = "cpu=1,memory=1Gi"
 	}
-	if systemReserve, isOK = a.masterSettings[entities.MasterSettings_ResourceReservation_System]; !isOK {
+	if systemReserve, isOK = settings[entities.MasterSettings_ResourceReservation_System]; !isOK {
 		systemReserve = "cpu=1,memory=1Gi"
 	}
 	//--volume=/:/rootfs:ro
@@ -634,25 +661,42 @@ func (a *LightningMonkeyAgent) runKubeletContainer(masterIP string) error {
 	cmd := []string{
 		"kubelet",
 		fmt.Sprintf("--config=%s", filepath.Join(CERTIFICATE_STORAGE_PATH, "kubelet_settings.yml")),
-		//fmt.Sprintf("--bootstrap-kubeconfig=%s", filepath.Join(CERTIFICATE_STORAGE_PATH, "bootstrap-kubelet.conf")),
 		fmt.Sprintf("--kubeconfig=%s", filepath.Join(CERTIFICATE_STORAGE_PATH, "kubelet.conf")),
 		fmt.Sprintf("--pod-infra-container-image=%s", infraContainer),
 		fmt.Sprintf("--register-node=%t", *a.arg.IsMinionRole),
 		fmt.Sprintf("--hostname-override=%s", *a.arg.Address),
 		fmt.Sprintf("--kube-reserved=%s", kubeReserve),
 		fmt.Sprintf("--system-reserved=%s", systemReserve),
-		"--cgroup-driver=cgroupfs",
 		//"--cgroups-per-qos=false",
 		"--enforce-node-allocatable=pods,system-reserved,kube-reserved",
 		"--kube-reserved-cgroup=/kube-reserved",
 		"--system-reserved-cgroup=/system-reserved",
 		"--allow-privileged=true",
 		"--network-plugin=cni",
-		"--serialize-image-pulls=false",
 		//"--address=0.0.0.0",
 	}
-	if a.arg.NodeLabels != nil && *a.arg.NodeLabels != "" {
-		cmd = append(cmd, fmt.Sprintf("--node-labels=%s", *a.arg.NodeLabels))
+	cmd = append(cmd, a.containerRuntime.GetKubeletFlags()...)
+	if bootstrapToken != "" {
+		cmd = append(cmd, fmt.Sprintf("--bootstrap-kubeconfig=%s", filepath.Join(CERTIFICATE_STORAGE_PATH, "bootstrap-kubelet.conf")))
+	}
+	//the agent's own labels take precedence over the node pool's ones.
+	nodeLabels, err := utils.MergeNodeLabels(pool, *a.arg.NodeLabels)
+	if err != nil {
+		return xerrors.Errorf("%s %w", err.Error(), crashError)
+	}
+	if nodeLabels != "" {
+		cmd = append(cmd, fmt.Sprintf("--node-labels=%s", nodeLabels))
+	}
+	if pool != nil && len(pool.Taints) > 0 {
+		cmd = append(cmd, fmt.Sprintf("--register-with-taints=%s", utils.FormatNodeTaints(pool.Taints)))
+	}
+	//cgroup driver and the other fields of KubeletConfiguration are given by "kubelet_settings.yml".
+	kubeletSettings, err := utils.ParseKubeletSettings(settings)
+	if err != nil {
+		return xerrors.Errorf("%s %w", err.Error(), crashError)
+	}
+	if kubeletSettings != nil {
+		cmd = utils.MergeKubeletFlags(cmd, kubeletSettings.ExtraFlags)
 	}
 	binds := []string{
 		//"/:/rootfs:ro",
@@ -660,40 +704,37 @@ func (a *LightningMonkeyAgent) runKubeletContainer(masterIP string) error {
 		//"/dev:/dev",
 		"/etc:/etc",
 		"/var/run:/var/run:rw",
-		"/var/lib/docker:/var/lib/docker:rw",
 		"/var/lib/kubelet:/var/lib/kubelet:rshared",
 		"/opt/cni/bin:/opt/cni/bin",
 	}
+	binds = append(binds, a.containerRuntime.GetKubeletBinds()...)
 	if v, isOK := a.masterSettings[entities.MasterSettings_DockerExtraGraphPath]; isOK && v != "" {
 		binds = append(binds, fmt.Sprintf("%s:%s:rw", v, v))
 	}
-	resp, err := a.dockerClient.ContainerCreate(context.Background(), &container.Config{
-		Hostname: *a.arg.Address,
-		Image:    img,
-		Tty:      false,
-		Cmd:      cmd,
-		Volumes:  map[string]struct{}{},
-	}, &container.HostConfig{
+	_, err = a.containerRuntime.RunContainer(containers.ContainerSpec{
+		Name:          "kubelet",
+		Hostname:      *a.arg.Address,
+		Image:         img,
+		Cmd:           cmd,
 		Binds:         binds,
 		Privileged:    true,
-		NetworkMode:   "host",
-		PidMode:       "host",
-		RestartPolicy: container.RestartPolicy{Name: "unless-stopped"},
-	}, &network.NetworkingConfig{}, "kubelet")
-	if err != nil {
-		return xerrors.Errorf("Failed to create container, error: %s %w", err.Error(), crashError)
-	}
-	if err = a.dockerClient.ContainerStart(context.Background(), resp.ID, types.ContainerStartOptions{}); err != nil {
-		return xerrors.Errorf("Failed to start container, error: %s %w", err.Error(), crashError)
-	}
-	out, err := a.dockerClient.ContainerLogs(context.Background(), resp.ID, types.ContainerLogsOptions{ShowStdout: true})
+		HostNetwork:   true,
+		HostPID:       true,
+		AlwaysRestart: true,
+	})
 	if err != nil {
-		return xerrors.Errorf("Failed to retrieve container logs, error: %s %w", err.Error(), crashError)
+		return xerrors.Errorf("%s %w", err.Error(), crashError)
 	}
-	_, _ = io.Copy(os.Stdout, out)
+	a.recordComponent(Component_Kubelet, map[string]string{"master-ip": masterIP, "bootstrap-token": bootstrapToken, "node-pool": nodePool})
 	return nil
 }
 
+//needsCAPrivateKeys returns true when current agent has any role which requires CA private keys,
+//API server refuses to give the private keys to the other agents.
+func (a *LightningMonkeyAgent) needsCAPrivateKeys() bool {
+	return *a.arg.IsETCDRole || *a.arg.IsMasterRole
+}
+
 //hasInitializedRoles returns false when it is waiting for the remote call.
 func (a *LightningMonkeyAgent) hasInitializedRoles() bool {
 	return *a.arg.IsETCDRole || *a.arg.IsMasterRole || *a.arg.IsMinionRole || *a.arg.IsHARole
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/g0194776/lightningmonkey/pkg/entities"
+	"github.com/g0194776/lightningmonkey/pkg/utils"
+	"github.com/sirupsen/logrus"
+	"github.com/vishvananda/netlink"
+	"io/ioutil"
+	"net"
+	"os"
+	"path"
+	"syscall"
+	"time"
+)
+
+const (
+	//all of routes which are generated by the agent are labelled with this protocol number,
+	//it makes them distinguishable from the routes which are managed by others.
+	managedRouteProtocol = 77
+)
+
+var (
+	ROUTES_FILE_PATH = "/opt/lightning-monkey/routes"
+)
+
+//reconcileSystemRoutes makes managed routes on the used ethernet interface exactly same as the desired routes.
+func (a *LightningMonkeyAgent) reconcileSystemRoutes(nodes []entities.KubernetesNodeInfo) error {
+	desired := []entities.SystemRoute{}
+	for i := 0; i < len(nodes); i++ {
+		_, cidr, err := net.ParseCIDR(nodes[i].PodCIDR)
+		if err != nil {
+			logrus.Errorf("Failed to parse pod CIDR: %s, error: %s", nodes[i].PodCIDR, err.Error())
+			continue
+		}
+		//never route the pod CIDR of itself.
+		if nodes[i].NodeIP == *a.arg.Address || net.ParseIP(nodes[i].NodeIP) == nil {
+			continue
+		}
+		desired = append(desired, entities.SystemRoute{Destination: cidr.String(), Gateway: nodes[i].NodeIP})
+	}
+	return a.applySystemRoutes(desired)
+}
+
+//applySystemRoutes replaces the managed routes with the desired ones, the desired routes are recorded for recovery.
+func (a *LightningMonkeyAgent) applySystemRoutes(desired []entities.SystemRoute) error {
+	a.routesLock.Lock()
+	defer a.routesLock.Unlock()
+	link, err := netlink.LinkByName(*a.arg.UsedEthernetInterface)
+	if err != nil {
+		return fmt.Errorf("Failed to get link information for device %s, error: %s", *a.arg.UsedEthernetInterface, err.Error())
+	}
+	actual, err := listManagedSystemRoutes(link)
+	if err != nil {
+		return err
+	}
+	//routes which had been recorded but lost their labels are still owned by the agent.
+	managed, err := loadManagedSystemRoutes()
+	if err != nil {
+		logrus.Errorf("Failed to load managed system routes, error: %s", err.Error())
+	} else if managed != nil {
+		actual = mergeSystemRoutes(actual, managed.Routes)
+	}
+	added, changed, removed := utils.DiffSystemRoutes(desired, actual)
+	failed := 0
+	for _, r := range append(added, changed...) {
+		//replacing also takes over the unlabelled routes which have the same destination.
+		if err = netlink.RouteReplace(newManagedRoute(link, r)); err != nil {
+			logrus.Errorf("Failed to replace system routing rule %s via %s, error: %s", r.Destination, r.Gateway, err.Error())
+			failed++
+		}
+	}
+	for _, r := range removed {
+		if err = netlink.RouteDel(newManagedRoute(link, r)); err != nil && err != syscall.ESRCH {
+			logrus.Errorf("Failed to delete system routing rule %s via %s, error: %s", r.Destination, r.Gateway, err.Error())
+			failed++
+		}
+	}
+	if len(added)+len(changed)+len(removed) > 0 {
+		logrus.Infof("System routing rules reconciled, added: %d, replaced: %d, deleted: %d, failed: %d", len(added), len(changed), len(removed), failed)
+	}
+	err = saveManagedSystemRoutes(&entities.ManagedSystemRoutes{Routes: desired, UpdateTime: time.Now()})
+	if err != nil {
+		return err
+	}
+	if data, err := json.Marshal(desired); err == nil {
+		a.recordComponent(Component_Routes, map[string]string{"routes": string(data)})
+	}
+	if failed > 0 {
+		return fmt.Errorf("Failed to reconcile %d system routing rules", failed)
+	}
+	return nil
+}
+
+//isSystemRoutesInSync returns true when the managed routes on the used ethernet interface are same as the given ones.
+func (a *LightningMonkeyAgent) isSystemRoutesInSync(desired []entities.SystemRoute) (bool, error) {
+	a.routesLock.Lock()
+	defer a.routesLock.Unlock()
+	link, err := netlink.LinkByName(*a.arg.UsedEthernetInterface)
+	if err != nil {
+		return false, fmt.Errorf("Failed to get link information for device %s, error: %s", *a.arg.UsedEthernetInterface, err.Error())
+	}
+	actual, err := listManagedSystemRoutes(link)
+	if err != nil {
+		return false, err
+	}
+	added, changed, removed := utils.DiffSystemRoutes(desired, actual)
+	return len(added)+len(changed)+len(removed) == 0, nil
+}
+
+func newManagedRoute(link netlink.Link, r entities.SystemRoute) *netlink.Route {
+	_, cidr, _ := net.ParseCIDR(r.Destination)
+	return &netlink.Route{
+		Gw:        net.ParseIP(r.Gateway),
+		Dst:       cidr,
+		LinkIndex: link.Attrs().Index,
+		Protocol:  managedRouteProtocol,
+	}
+}
+
+func listManagedSystemRoutes(link netlink.Link) ([]entities.SystemRoute, error) {
+	rs, err := netlink.RouteListFiltered(netlink.FAMILY_V4, &netlink.Route{LinkIndex: link.Attrs().Index, Protocol: managedRouteProtocol}, netlink.RT_FILTER_OIF|netlink.RT_FILTER_PROTOCOL)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to list system routing rules, error: %s", err.Error())
+	}
+	routes := []entities.SystemRoute{}
+	for i := 0; i < len(rs); i++ {
+		if rs[i].Dst == nil || rs[i].Gw == nil {
+			continue
+		}
+		routes = append(routes, entities.SystemRoute{Destination: rs[i].Dst.String(), Gateway: rs[i].Gw.String()})
+	}
+	return routes, nil
+}
+
+func mergeSystemRoutes(actual, recorded []entities.SystemRoute) []entities.SystemRoute {
+	existed := make(map[string]bool, len(actual))
+	for i := 0; i < len(actual); i++ {
+		existed[actual[i].Destination] = true
+	}
+	for i := 0; i < len(recorded); i++ {
+		if !existed[recorded[i].Destination] {
+			actual = append(actual, recorded[i])
+		}
+	}
+	return actual
+}
+
+//loadManagedSystemRoutes returns nil if the agent has never generated any routes.
+func loadManagedSystemRoutes() (*entities.ManagedSystemRoutes, error) {
+	data, err := ioutil.ReadFile(ROUTES_FILE_PATH)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var routes entities.ManagedSystemRoutes
+	err = json.Unmarshal(data, &routes)
+	if err != nil {
+		return nil, err
+	}
+	return &routes, nil
+}
+
+func saveManagedSystemRoutes(routes *entities.ManagedSystemRoutes) error {
+	data, err := json.Marshal(routes)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(path.Dir(ROUTES_FILE_PATH), 0755) //rwxr-xr-x
+	if err != nil {
+		return fmt.Errorf("Failed to create directory of managed system routes file, error: %s", err.Error())
+	}
+	tmpPath := ROUTES_FILE_PATH + ".tmp"
+	err = ioutil.WriteFile(tmpPath, data, 0644)
+	if err != nil {
+		return fmt.Errorf("Failed to save managed system routes file, error: %s", err.Error())
+	}
+	return os.Rename(tmpPath, ROUTES_FILE_PATH)
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/g0194776/lightningmonkey/pkg/entities"
+	"github.com/sirupsen/logrus"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+)
+
+//tryUpgradeSelf replaces the running binary with the version published by API server,
+//it must be called when none of jobs is being performed.
+func (a *LightningMonkeyAgent) tryUpgradeSelf() {
+	s := a.getPendingAgentSoftware()
+	if s == nil || AGENT_VERSION == "" || s.Version == AGENT_VERSION || s.Checksum == a.failedSoftware {
+		return
+	}
+	//the replaced binary would be lost once the container is recreated from the original image,
+	//API server never publishes the rollout to containerized agents, this only guards against the misreported ones.
+	if isRunningInContainer() {
+		a.failedSoftware = s.Checksum
+		logrus.Errorf("Refused upgrading agent to %s, the agent runs in a container, please upgrade it by replacing the image.", s.Version)
+		return
+	}
+	logrus.Warnf("Upgrading agent from %s to %s...", AGENT_VERSION, s.Version)
+	err := a.upgradeSelf(s)
+	if err != nil {
+		//never retry the same software again until API server publishes another one.
+		a.failedSoftware = s.Checksum
+		logrus.Errorf("Failed to upgrade agent to %s, error: %s", s.Version, err.Error())
+	}
+}
+
+func (a *LightningMonkeyAgent) upgradeSelf(s *entities.AgentSoftware) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("Failed to locate agent binary file, error: %s", err.Error())
+	}
+	exe, err = filepath.EvalSymlinks(exe)
+	if err != nil {
+		return fmt.Errorf("Failed to locate agent binary file, error: %s", err.Error())
+	}
+	//download to the same directory for renaming atomically.
+	newPath := exe + ".new"
+	backupPath := exe + ".old"
+	defer os.Remove(newPath)
+	err = a.downloadAgentSoftware(s, newPath)
+	if err != nil {
+		return err
+	}
+	output, err := exec.Command(newPath, "--version").Output()
+	if err != nil {
+		return fmt.Errorf("Failed to run downloaded agent binary file, error: %s", err.Error())
+	}
+	if strings.TrimSpace(string(output)) != s.Version {
+		return fmt.Errorf("Downloaded agent binary file reports unexpected version: %s", strings.TrimSpace(string(output)))
+	}
+	//keep the recovery file up to date, the new process recovers from it.
+	if a.rr != nil {
+		if err = a.saveRecoveryFile(); err != nil {
+			return err
+		}
+	}
+	_ = os.Remove(backupPath)
+	if err = os.Link(exe, backupPath); err != nil {
+		return fmt.Errorf("Failed to back up agent binary file, error: %s", err.Error())
+	}
+	if err = os.Rename(newPath, exe); err != nil {
+		return fmt.Errorf("Failed to replace agent binary file, error: %s", err.Error())
+	}
+	logrus.Warnf("Agent binary file has been replaced, restarting with version %s...", s.Version)
+	err = syscall.Exec(exe, os.Args, os.Environ())
+	//only returns on failure, rolls back to the running version.
+	if rollbackErr := os.Rename(backupPath, exe); rollbackErr != nil {
+		logrus.Errorf("Failed to roll back agent binary file, error: %s", rollbackErr.Error())
+	}
+	return fmt.Errorf("Failed to restart agent, error: %s", err.Error())
+}
+
+//isRunningInContainer detects the container runtimes by their marker files and the cgroup of init process.
+func isRunningInContainer() bool {
+	for _, f := range []string{"/.dockerenv", "/run/.containerenv"} {
+		if _, err := os.Stat(f); err == nil {
+			return true
+		}
+	}
+	data, err := ioutil.ReadFile("/proc/1/cgroup")
+	if err != nil {
+		return false
+	}
+	for _, keyword := range []string{"docker", "kubepods", "containerd", "lxc"} {
+		if strings.Contains(string(data), keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+func (a *LightningMonkeyAgent) downloadAgentSoftware(s *entities.AgentSoftware, filePath string) error {
+	rsp, err := a.servers.Do("GET", fmt.Sprintf("/apis/v1/%s?token=%s", s.Location, s.Token), nil, time.Minute*5)
+	if err != nil {
+		return fmt.Errorf("Failed to download agent software, error: %s", err.Error())
+	}
+	defer rsp.Body.Close()
+	if rsp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Failed to download agent software, remote API server returned HTTP status code: %d", rsp.StatusCode)
+	}
+	f, err := os.OpenFile(filePath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0755)
+	if err != nil {
+		return fmt.Errorf("Failed to create agent binary file, error: %s", err.Error())
+	}
+	hasher := sha256.New()
+	_, err = io.Copy(io.MultiWriter(f, hasher), rsp.Body)
+	if err == nil {
+		err = f.Sync()
+	}
+	closeErr := f.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("Failed to save agent binary file, error: %s", err.Error())
+	}
+	checksum := hex.EncodeToString(hasher.Sum(nil))
+	if checksum != s.Checksum {
+		return fmt.Errorf("Checksum mismatched, expected: %s, actual: %s", s.Checksum, checksum)
+	}
+	return nil
+}
+
+func (a *LightningMonkeyAgent) setPendingAgentSoftware(s *entities.AgentSoftware) {
+	a.statusLock.Lock()
+	defer a.statusLock.Unlock()
+	a.pendingSoftware = s
+}
+
+func (a *LightningMonkeyAgent) getPendingAgentSoftware() *entities.AgentSoftware {
+	a.statusLock.RLock()
+	defer a.statusLock.RUnlock()
+	return a.pendingSoftware
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/g0194776/lightningmonkey/pkg/entities"
+	"github.com/g0194776/lightningmonkey/pkg/utils"
+	"github.com/sirupsen/logrus"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	serverHealthCheckInterval = time.Second * 10
+	serverHealthCheckTimeout  = time.Second * 5
+)
+
+//serverPool sends requests to the active API server, it fails over to the other endpoints once the active one is
+//unreachable and fails back to the preferred one when it becomes healthy again.
+type serverPool struct {
+	lock      *sync.RWMutex
+	endpoints []string
+	healthy   []bool
+	active    int
+	//client is shared by all of calls to API servers, the timeout is given per request.
+	client *http.Client
+	//token is the access token of agent, API servers authenticate the agent by it.
+	token string
+}
+
+func newServerPool(endpoints []string, transport http.RoundTripper, token string) *serverPool {
+	p := &serverPool{
+		lock:      &sync.RWMutex{},
+		endpoints: endpoints,
+		healthy:   make([]bool, len(endpoints)),
+		client:    &http.Client{Transport: transport},
+		token:     token,
+	}
+	//considers all of endpoints are healthy until the first failure.
+	for i := 0; i < len(p.healthy); i++ {
+		p.healthy[i] = true
+	}
+	return p
+}
+
+//Active returns the address of API server which is currently used.
+func (p *serverPool) Active() string {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	return p.endpoints[p.active]
+}
+
+//Do sends the request to the active API server, it tries the other endpoints in order when a transport error occurred
+//or the API server returned a 5xx status code. The body is rewound before every attempt.
+func (p *serverPool) Do(method, path string, body io.ReadSeeker, timeout time.Duration) (*http.Response, error) {
+	var lastErr error
+	for _, idx := range p.attemptOrder() {
+		rsp, err := p.doOnce(p.endpoints[idx], method, path, body, timeout)
+		if err == nil && rsp.StatusCode < http.StatusInternalServerError {
+			p.setHealthy(idx, true)
+			p.activate(idx)
+			return rsp, nil
+		}
+		if err == nil {
+			rsp.Body.Close()
+			err = fmt.Errorf("Remote API server returned HTTP status code: %d", rsp.StatusCode)
+		}
+		lastErr = fmt.Errorf("Failed to call API server %s, error: %s", p.endpoints[idx], err.Error())
+		logrus.Warn(lastErr.Error())
+		p.setHealthy(idx, false)
+	}
+	return nil, lastErr
+}
+
+//DoOn sends the request to the given API server without failing over, it's used for accessing the resources
+//which are only held by that API server.
+func (p *serverPool) DoOn(endpoint, method, path string, body io.ReadSeeker, timeout time.Duration) (*http.Response, error) {
+	rsp, err := p.doOnce(endpoint, method, path, body, timeout)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to call API server %s, error: %s", endpoint, err.Error())
+	}
+	return rsp, nil
+}
+
+//attemptOrder returns the active endpoint first, followed by the healthy ones and then the unhealthy ones.
+func (p *serverPool) attemptOrder() []int {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	order := []int{p.active}
+	for _, healthy := range []bool{true, false} {
+		for i := 0; i < len(p.endpoints); i++ {
+			if i != p.active && p.healthy[i] == healthy {
+				order = append(order, i)
+			}
+		}
+	}
+	return order
+}
+
+func (p *serverPool) doOnce(endpoint, method, path string, body io.ReadSeeker, timeout time.Duration) (*http.Response, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	req, err := http.NewRequestWithContext(ctx, method, endpoint+path, nil)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	if body != nil {
+		//the body is never closed by HTTP client, it's going to be sent again to other endpoints.
+		if req.ContentLength, err = body.Seek(0, io.SeekEnd); err == nil {
+			_, err = body.Seek(0, io.SeekStart)
+		}
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+		req.Body = ioutil.NopCloser(body)
+	}
+	if p.token != "" {
+		req.Header.Set(entities.AgentTokenHeader, p.token)
+	}
+	rsp, err := p.client.Do(req)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	rsp.Body = &cancelOnClose{ReadCloser: rsp.Body, cancel: cancel}
+	return rsp, nil
+}
+
+func (p *serverPool) setHealthy(idx int, healthy bool) {
+	p.lock.Lock()
+	p.healthy[idx] = healthy
+	p.lock.Unlock()
+}
+
+func (p *serverPool) activate(idx int) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.active == idx {
+		return
+	}
+	logrus.Warnf("Switching API server from %s to %s", p.endpoints[p.active], p.endpoints[idx])
+	p.active = idx
+}
+
+//startHealthChecking checks all of API servers periodically, the preferred healthy one is activated.
+func (p *serverPool) startHealthChecking() {
+	if len(p.endpoints) < 2 {
+		return
+	}
+	for {
+		time.Sleep(serverHealthCheckInterval)
+		healthy := make([]bool, len(p.endpoints))
+		for i := 0; i < len(p.endpoints); i++ {
+			healthy[i] = p.checkHealth(p.endpoints[i]) == nil
+		}
+		p.lock.Lock()
+		p.healthy = healthy
+		current := p.active
+		p.lock.Unlock()
+		p.activate(utils.SelectServerEndpoint(healthy, current))
+	}
+}
+
+func (p *serverPool) checkHealth(endpoint string) error {
+	rsp, err := p.doOnce(endpoint, "GET", "/apis/v1/agent/time", nil, serverHealthCheckTimeout)
+	if err != nil {
+		return err
+	}
+	defer rsp.Body.Close()
+	if rsp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Remote API server returned HTTP status code: %d", rsp.StatusCode)
+	}
+	return nil
+}
+
+//cancelOnClose releases the context of request after the response body has been consumed.
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c *cancelOnClose) Close() error {
+	err := c.ReadCloser.Close()
+	c.cancel()
+	return err
+}
@@ -1,7 +1,7 @@
 package main
 
 import (
-	"github.com/docker/engine-api/client"
+	"github.com/g0194776/lightningmonkey/pkg/containers"
 	"github.com/g0194776/lightningmonkey/pkg/entities"
 	"github.com/g0194776/lightningmonkey/pkg/managers"
 	"github.com/pkg/errors"
@@ -13,6 +13,8 @@ var (
 	CERTIFICATE_STORAGE_PATH = "/etc/kubernetes"
 	RECOVERY_FILE_PATH       = "/opt/lightning-monkey/recovery"
 	crashError               = errors.New("CRASH ERROR")
+	//AGENT_VERSION is set by -ldflags "-X main.AGENT_VERSION=x.y.z", development builds never upgrade themselves.
+	AGENT_VERSION = ""
 )
 
 const (
@@ -28,10 +30,14 @@ type LightningMonkeyAgentReportStatus struct {
 type LightningMonkeyAgent struct {
 	c                     chan LightningMonkeyAgentReportStatus
 	currentJob            *entities.AgentJob
+	isTrackingJob         int32       //set while the current job is being performed or its health is being waited for.
+	jobLock               *sync.Mutex //serializes performing jobs and remediating configuration drift.
 	statusLock            *sync.RWMutex
 	recoveryLock          *sync.Mutex
+	routesLock            *sync.Mutex
 	arg                   *AgentArgs
-	dockerClient          *client.Client
+	servers               *serverPool
+	containerRuntime      containers.ContainerRuntime
 	dockerImageManager    managers.DockerImageManager
 	lastRegisteredTime    time.Time
 	lastReportTime        time.Time
@@ -43,6 +49,19 @@ type LightningMonkeyAgent struct {
 	ItemsStatus           map[string]entities.LightningMonkeyAgentReportStatusItem
 	expectedETCDNodeCount int
 	rr                    *RecoveryRecord
+	upgradeStatus         *entities.AgentUpgradeStatus
+	etcdMembers           []entities.ETCDMember
+	etcdSnapshot          *entities.ETCDSnapshot
+	restoreStatus         *entities.AgentRestoreStatus
+	resetId               string
+	pendingSoftware       *entities.AgentSoftware
+	failedSoftware        string //checksum of the software which failed to upgrade to.
+	probes                map[string]*entities.ComponentProbe
+	lastHostFactsTime     time.Time
+	lastPreflightTime     time.Time
+	accessToken           string //authenticates the calls from API server to the agent.
+	configDrift           *entities.ConfigDriftStatus
+	driftRemediation      *entities.DriftRemediationSettings
 }
 
 type RecoveryRecord struct {
@@ -59,5 +78,24 @@ type RecoveryRecord struct {
 	MasterDeploymentType string    `json:"master_deployment_type"`
 	ETCDDeploymentType   string    `json:"etcd_deployment_type"`
 	MinionDeploymentType string    `json:"minion_deployment_type"`
+	HasInstalledHA       bool      `json:"has_installed_ha"`
+	InstallHATime        time.Time `json:"install_ha_time"`
+	HADeploymentType     string    `json:"ha_deployment_type"`
 	ClusterID            string    `json:"cluster_id"`
+	//NodePool is assigned by API server along with the cluster-id, nil means the configured one is used.
+	NodePool *string `json:"node_pool,omitempty"`
+	//Components records how each of installed components was configured, keyed by the component name.
+	Components map[string]*RecoveryComponent `json:"components,omitempty"`
+	//BasicImages and MasterSettings are used for re-applying the components before registering to API server.
+	BasicImages    *entities.DockerImageCollection `json:"basic_images,omitempty"`
+	MasterSettings map[string]string               `json:"master_settings,omitempty"`
+}
+
+//RecoveryComponent is the configuration which is used to install a component, it's re-applied after reboot when
+//any of generated configuration files has been lost or changed.
+type RecoveryComponent struct {
+	Arguments map[string]string `json:"arguments,omitempty"`
+	//Checksums holds the SHA256 checksum of each generated configuration file, keyed by the file path.
+	Checksums  map[string]string `json:"checksums,omitempty"`
+	UpdateTime time.Time         `json:"update_time"`
 }
@@ -3,9 +3,11 @@ package agents
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/g0194776/lightningmonkey/pkg/cache"
 	uuid "github.com/satori/go.uuid"
+	"io"
 	"io/ioutil"
 	"strconv"
 	"sync"
@@ -14,6 +16,7 @@ import (
 	"github.com/g0194776/lightningmonkey/pkg/common"
 	"github.com/g0194776/lightningmonkey/pkg/entities"
 	"github.com/g0194776/lightningmonkey/pkg/managers"
+	"github.com/g0194776/lightningmonkey/pkg/utils"
 	"github.com/kataras/iris"
 	"github.com/sirupsen/logrus"
 )
@@ -80,9 +83,76 @@ func Register(app *iris.Application) error {
 	app.Put("/apis/v1/agent/change", ChangeAgentClusterAndRoles)
 	app.Delete("/apis/v1/agent/change", CancelChangeAgentClusterAndRoles)
 	app.Get("/apis/v1/agents/list", ListAgentsByClusterId)
+	app.Post("/apis/v1/agent/decommission", DecommissionAgent)
+	app.Get("/apis/v1/agent/decommission", GetAgentDecommission)
+	app.Post("/apis/v1/agent/reset", ResetAgent)
+	app.Get("/apis/v1/agent/time", GetServerTime)
+	app.Get("/apis/v1/agent/logs", GetAgentLogs)
 	return nil
 }
 
+//GetServerTime is called by agents' preflight checks to measure their clock skew.
+func GetServerTime(ctx iris.Context) {
+	rsp := entities.GetServerTimeResponse{
+		Response: entities.Response{ErrorId: entities.Succeed, Reason: ""},
+		Time:     time.Now(),
+	}
+	ctx.JSON(&rsp)
+	ctx.Values().Set(entities.RESPONSEINFO, &rsp)
+	ctx.Next()
+}
+
+//GetAgentLogs streams the logs of the agent itself or one of components which it manages,
+//"tail" is the number of lines from the end, "since" is either a duration like "10m" or a RFC3339 timestamp.
+func GetAgentLogs(ctx iris.Context) {
+	agentId := ctx.URLParam("agent-id")
+	if agentId == "" {
+		rsp := entities.Response{ErrorId: entities.ParameterError, Reason: "\"agent-id\" parameter is required."}
+		ctx.JSON(&rsp)
+		ctx.Values().Set(entities.RESPONSEINFO, &rsp)
+		ctx.Next()
+		return
+	}
+	component := ctx.URLParam("component")
+	if component == "" {
+		rsp := entities.Response{ErrorId: entities.ParameterError, Reason: "\"component\" parameter is required."}
+		ctx.JSON(&rsp)
+		ctx.Values().Set(entities.RESPONSEINFO, &rsp)
+		ctx.Next()
+		return
+	}
+	tail, err := parseUintURLParam(ctx, "tail")
+	if err != nil {
+		rsp := entities.Response{ErrorId: entities.ParameterError, Reason: err.Error()}
+		ctx.JSON(&rsp)
+		ctx.Values().Set(entities.RESPONSEINFO, &rsp)
+		ctx.Next()
+		return
+	}
+	since := ctx.URLParam("since")
+	if _, err = utils.ParseLogSince(since, time.Now()); err != nil {
+		rsp := entities.Response{ErrorId: entities.ParameterError, Reason: err.Error()}
+		ctx.JSON(&rsp)
+		ctx.Values().Set(entities.RESPONSEINFO, &rsp)
+		ctx.Next()
+		return
+	}
+	reader, err := managers.OpenAgentLogs(ctx.URLParam("cluster-id"), agentId, component, int(tail), since)
+	if err != nil {
+		rsp := entities.Response{ErrorId: entities.OperationFailed, Reason: err.Error()}
+		ctx.JSON(&rsp)
+		ctx.Values().Set(entities.RESPONSEINFO, &rsp)
+		ctx.Next()
+		return
+	}
+	defer reader.Close()
+	ctx.ContentType("text/plain")
+	_, err = io.Copy(ctx.ResponseWriter(), reader)
+	if err != nil {
+		logrus.Warnf("Failed to stream logs of agent: %s, component: %s, error: %s", agentId, component, err.Error())
+	}
+}
+
 func RegisterAgent(ctx iris.Context) {
 	var rsp interface{}
 	agent := entities.LightningMonkeyAgent{}
@@ -140,7 +210,12 @@ func RegisterAgent(ctx iris.Context) {
 		r.MasterSettings[entities.MasterSettings_ResourceReservation_Kube] = settings.ResourceReservation.Kube
 		r.MasterSettings[entities.MasterSettings_ResourceReservation_System] = settings.ResourceReservation.System
 	}
+	if settings.Kubelet != nil {
+		data, _ := json.Marshal(settings.Kubelet)
+		r.MasterSettings[entities.MasterSettings_KubeletSettings] = string(data)
+	}
 	r.BasicImages.HTTPDownloadToken = entities.HTTPDockerImageDownloadToken
+	r.AgentSoftware = managers.GetAgentSoftware(*settings, agentId, agent.Version, agent.IsContainerized)
 	rsp = r
 	_, _ = ctx.JSON(rsp)
 	ctx.Values().Set(entities.RESPONSEINFO, &rsp)
@@ -225,7 +300,12 @@ func ReportStatus(ctx iris.Context) {
 		ctx.Next()
 		return
 	}
-	rsp := entities.AgentReportStatusResponse{Response: entities.Response{ErrorId: entities.Succeed}, LeaseId: leaseId}
+	rsp := entities.AgentReportStatusResponse{
+		Response:         entities.Response{ErrorId: entities.Succeed},
+		LeaseId:          leaseId,
+		AgentSoftware:    managers.GetAgentSoftwareByAgentId(clusterId, agentId),
+		DriftRemediation: managers.GetDriftRemediation(clusterId),
+	}
 	ctx.JSON(&rsp)
 	ctx.Values().Set(entities.RESPONSEINFO, &rsp)
 	ctx.Next()
@@ -290,10 +370,33 @@ func ChangeAgentClusterAndRoles(ctx iris.Context) {
 	isMasterRole := ctx.URLParamInt32Default("master", 0) == 1
 	isMinionRole := ctx.URLParamInt32Default("minion", 0) == 1
 	isHARole := ctx.URLParamInt32Default("ha", 0) == 1
+	//the agent keeps its current node pool if "node-pool" parameter is absent, an empty one makes it leave.
+	var nodePool *string
+	if ctx.URLParamExists("node-pool") {
+		p := ctx.URLParam("node-pool")
+		nodePool = &p
+	}
+	if nodePool != nil && *nodePool != "" {
+		newCluster, err := common.ClusterManager.GetClusterById(newClusterId)
+		if err != nil {
+			rsp := entities.Response{ErrorId: entities.InternalError, Reason: fmt.Sprintf("Failed to retrieve cluster information from cache, error: %s", err.Error())}
+			ctx.JSON(&rsp)
+			ctx.Values().Set(entities.RESPONSEINFO, &rsp)
+			ctx.Next()
+			return
+		}
+		if newCluster == nil || newCluster.GetSettings().GetNodePool(*nodePool) == nil {
+			rsp := entities.Response{ErrorId: entities.ParameterError, Reason: fmt.Sprintf("Node pool: %s not found in cluster: %s!", *nodePool, newClusterId)}
+			ctx.JSON(&rsp)
+			ctx.Values().Set(entities.RESPONSEINFO, &rsp)
+			ctx.Next()
+			return
+		}
+	}
 	waitTimeSecs := ctx.URLParamInt32Default("wait", 0)
 	if waitTimeSecs > 0 {
 		var innerRsp entities.Response
-		err := addPendingTask(agentId, waitTimeSecs, newClusterId, oldClusterId, agent, isETCDRole, isMasterRole, isMinionRole, isHARole)
+		err := addPendingTask(agentId, waitTimeSecs, newClusterId, oldClusterId, agent, isETCDRole, isMasterRole, isMinionRole, isHARole, nodePool)
 		if err != nil {
 			innerRsp = entities.Response{ErrorId: entities.OperationFailed, Reason: err.Error()}
 		} else {
@@ -311,7 +414,8 @@ func ChangeAgentClusterAndRoles(ctx iris.Context) {
 		isETCDRole,
 		isMasterRole,
 		isMinionRole,
-		isHARole)
+		isHARole,
+		nodePool)
 	if err != nil {
 		rsp := entities.Response{ErrorId: entities.InternalError, Reason: err.Error()}
 		ctx.JSON(&rsp)
@@ -365,7 +469,7 @@ func CancelChangeAgentClusterAndRoles(ctx iris.Context) {
 	ctx.Next()
 }
 
-func addPendingTask(agentId string, waitTimeSecs int32, newClusterId, oldClusterId string, agent *entities.LightningMonkeyAgent, isETCDRole, isMasterRole, isMinionRole, isHARole bool) error {
+func addPendingTask(agentId string, waitTimeSecs int32, newClusterId, oldClusterId string, agent *entities.LightningMonkeyAgent, isETCDRole, isMasterRole, isMinionRole, isHARole bool, nodePool *string) error {
 	pendingTaskLock.Lock()
 	defer pendingTaskLock.Unlock()
 
@@ -386,7 +490,8 @@ func addPendingTask(agentId string, waitTimeSecs int32, newClusterId, oldCluster
 			isETCDRole,
 			isMasterRole,
 			isMinionRole,
-			isHARole)
+			isHARole,
+			nodePool)
 		if err != nil {
 			if agent.State != nil {
 				msg := fmt.Sprintf("Failed to change agent %s to cluster %s, error: %s", agentId, newClusterId, err.Error())
@@ -398,6 +503,10 @@ func addPendingTask(agentId string, waitTimeSecs int32, newClusterId, oldCluster
 	if pendingTaskCollection == nil {
 		pendingTaskCollection = make(map[string] /*agent id*/ *PendingTask)
 	}
+	pool := agent.NodePool
+	if nodePool != nil {
+		pool = *nodePool
+	}
 	t.briefInformation = entities.LightningMonkeyAgentBriefInformation{
 		Id:              agent.Id,
 		HasETCDRole:     isETCDRole,
@@ -408,6 +517,9 @@ func addPendingTask(agentId string, waitTimeSecs int32, newClusterId, oldCluster
 		HostInformation: agent.HostInformation,
 		DeploymentPhase: entities.AgentDeploymentPhase_Pending,
 		State:           agent.State,
+		Version:         agent.Version,
+		Preflight:       agent.Preflight,
+		NodePool:        pool,
 	}
 	pendingTaskCollection[agentId] = t
 	pendingTasks[newClusterId] = pendingTaskCollection
@@ -421,6 +533,112 @@ func NewPendingTask(agentId, clusterId string, waitTime int, workProc func()) *P
 	return &PendingTask{agentId: agentId, clusterId: clusterId, ct: ct, cancel: cf, workProc: workProc}
 }
 
+//DecommissionAgent drains the node and returns it to the pool, "delete=1" marks it as deleted instead.
+//It returns immediately, the progress could be queried by GetAgentDecommission.
+func DecommissionAgent(ctx iris.Context) {
+	agentId := ctx.URLParam("agent-id")
+	if agentId == "" {
+		rsp := entities.Response{ErrorId: entities.ParameterError, Reason: "\"agent-id\" parameter is required."}
+		ctx.JSON(&rsp)
+		ctx.Values().Set(entities.RESPONSEINFO, &rsp)
+		ctx.Next()
+		return
+	}
+	clusterId := ctx.URLParam("cluster-id")
+	if clusterId == "" {
+		rsp := entities.Response{ErrorId: entities.ParameterError, Reason: "\"cluster-id\" parameter is required."}
+		ctx.JSON(&rsp)
+		ctx.Values().Set(entities.RESPONSEINFO, &rsp)
+		ctx.Next()
+		return
+	}
+	timeoutSecs := ctx.URLParamInt32Default("timeout", 300)
+	if timeoutSecs <= 0 {
+		rsp := entities.Response{ErrorId: entities.ParameterError, Reason: "\"timeout\" parameter must be greater than zero."}
+		ctx.JSON(&rsp)
+		ctx.Values().Set(entities.RESPONSEINFO, &rsp)
+		ctx.Next()
+		return
+	}
+	isDelete := ctx.URLParamInt32Default("delete", 0) == 1
+	d, err := managers.DecommissionAgent(clusterId, agentId, time.Duration(timeoutSecs)*time.Second, isDelete)
+	handleAgentDecommission(ctx, d, err)
+}
+
+func GetAgentDecommission(ctx iris.Context) {
+	agentId := ctx.URLParam("agent-id")
+	clusterId := ctx.URLParam("cluster-id")
+	if agentId == "" || clusterId == "" {
+		rsp := entities.Response{ErrorId: entities.ParameterError, Reason: "\"agent-id\" and \"cluster-id\" parameters are required."}
+		ctx.JSON(&rsp)
+		ctx.Values().Set(entities.RESPONSEINFO, &rsp)
+		ctx.Next()
+		return
+	}
+	d, err := managers.GetAgentDecommission(clusterId, agentId)
+	handleAgentDecommission(ctx, d, err)
+}
+
+func handleAgentDecommission(ctx iris.Context, d *entities.AgentDecommission, err error) {
+	if err != nil {
+		rsp := entities.Response{ErrorId: entities.OperationFailed, Reason: err.Error()}
+		ctx.JSON(&rsp)
+		ctx.Values().Set(entities.RESPONSEINFO, &rsp)
+		ctx.Next()
+		return
+	}
+	rsp := entities.GetAgentDecommissionResponse{
+		Response:     entities.Response{ErrorId: entities.Succeed},
+		Decommission: d,
+	}
+	ctx.JSON(&rsp)
+	ctx.Values().Set(entities.RESPONSEINFO, &rsp)
+	ctx.Next()
+}
+
+//ResetAgent wipes the host and returns it to the pool, "wipe-data=1" also removes ETCD & Elasticsearch data,
+//"force=1" is required for ETCD and Kubernetes master nodes.
+func ResetAgent(ctx iris.Context) {
+	agentId := ctx.URLParam("agent-id")
+	if agentId == "" {
+		rsp := entities.Response{ErrorId: entities.ParameterError, Reason: "\"agent-id\" parameter is required."}
+		ctx.JSON(&rsp)
+		ctx.Values().Set(entities.RESPONSEINFO, &rsp)
+		ctx.Next()
+		return
+	}
+	clusterId := ctx.URLParam("cluster-id")
+	if clusterId == "" {
+		rsp := entities.Response{ErrorId: entities.ParameterError, Reason: "\"cluster-id\" parameter is required."}
+		ctx.JSON(&rsp)
+		ctx.Values().Set(entities.RESPONSEINFO, &rsp)
+		ctx.Next()
+		return
+	}
+	timeoutSecs := ctx.URLParamInt32Default("timeout", 300)
+	if timeoutSecs <= 0 {
+		rsp := entities.Response{ErrorId: entities.ParameterError, Reason: "\"timeout\" parameter must be greater than zero."}
+		ctx.JSON(&rsp)
+		ctx.Values().Set(entities.RESPONSEINFO, &rsp)
+		ctx.Next()
+		return
+	}
+	wipeData := ctx.URLParamInt32Default("wipe-data", 0) == 1
+	isForced := ctx.URLParamInt32Default("force", 0) == 1
+	err := managers.ResetAgent(clusterId, agentId, wipeData, isForced, time.Duration(timeoutSecs)*time.Second)
+	if err != nil {
+		rsp := entities.Response{ErrorId: entities.OperationFailed, Reason: err.Error()}
+		ctx.JSON(&rsp)
+		ctx.Values().Set(entities.RESPONSEINFO, &rsp)
+		ctx.Next()
+		return
+	}
+	rsp := entities.Response{ErrorId: entities.Succeed}
+	ctx.JSON(&rsp)
+	ctx.Values().Set(entities.RESPONSEINFO, &rsp)
+	ctx.Next()
+}
+
 func ListAgentsByClusterId(ctx iris.Context) {
 	clusterId := ctx.URLParam("cluster-id")
 	if clusterId == "" {
@@ -445,6 +663,14 @@ func ListAgentsByClusterId(ctx iris.Context) {
 		ctx.Next()
 		return
 	}
+	filter, err := parseAgentListFilter(ctx)
+	if err != nil {
+		rsp := entities.Response{ErrorId: entities.ParameterError, Reason: err.Error()}
+		ctx.JSON(&rsp)
+		ctx.Values().Set(entities.RESPONSEINFO, &rsp)
+		ctx.Next()
+		return
+	}
 	agents, err := cluster.GetAgentList(false)
 	if err != nil {
 		rsp := entities.Response{ErrorId: entities.InternalError, Reason: fmt.Sprintf("Failed to list agents from cluster(%s), error: %s", clusterId, err.Error())}
@@ -461,6 +687,7 @@ func ListAgentsByClusterId(ctx iris.Context) {
 			agents = filterClusterHosts(cluster, agents)
 		}
 		pendingTaskLock.RUnlock()
+		agents = utils.FilterAgents(agents, filter)
 	}
 	rsp := entities.GetAgentListResponse{
 		Response: entities.Response{ErrorId: entities.Succeed},
@@ -471,6 +698,49 @@ func ListAgentsByClusterId(ctx iris.Context) {
 	ctx.Next()
 }
 
+//parseAgentListFilter reads the optional host facts conditions from URL parameters.
+func parseAgentListFilter(ctx iris.Context) (entities.AgentListFilter, error) {
+	filter := entities.AgentListFilter{
+		DiskType:         ctx.URLParam("disk-type"),
+		ContainerRuntime: ctx.URLParam("container-runtime"),
+	}
+	cpus, err := parseUintURLParam(ctx, "min-cpus")
+	if err != nil {
+		return filter, err
+	}
+	filter.MinLogicalCPUs = int(cpus)
+	cgroupVersion, err := parseUintURLParam(ctx, "cgroup-version")
+	if err != nil {
+		return filter, err
+	}
+	if cgroupVersion > 2 {
+		return filter, errors.New("\"cgroup-version\" parameter must be 1 or 2.")
+	}
+	filter.CgroupVersion = int(cgroupVersion)
+	if filter.MinMemoryMB, err = parseUintURLParam(ctx, "min-memory-mb"); err != nil {
+		return filter, err
+	}
+	if filter.MinDiskSizeGB, err = parseUintURLParam(ctx, "min-disk-size-gb"); err != nil {
+		return filter, err
+	}
+	if filter.DiskType != "" && filter.DiskType != entities.DiskType_SSD && filter.DiskType != entities.DiskType_HDD {
+		return filter, fmt.Errorf("\"disk-type\" parameter must be %s or %s.", entities.DiskType_SSD, entities.DiskType_HDD)
+	}
+	return filter, nil
+}
+
+func parseUintURLParam(ctx iris.Context, name string) (uint64, error) {
+	v := ctx.URLParam(name)
+	if v == "" {
+		return 0, nil
+	}
+	i, err := strconv.ParseUint(v, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("\"%s\" parameter must be a non-negative integer.", name)
+	}
+	return i, nil
+}
+
 func filterPoolingHosts(agents []entities.LightningMonkeyAgentBriefInformation) []entities.LightningMonkeyAgentBriefInformation {
 	if pendingTasks == nil || len(pendingTasks) == 0 {
 		return agents
@@ -1,18 +1,22 @@
 package certs
 
 import (
+	"encoding/json"
 	"fmt"
 	"github.com/g0194776/lightningmonkey/pkg/common"
 	"github.com/g0194776/lightningmonkey/pkg/entities"
 	"github.com/g0194776/lightningmonkey/pkg/managers"
 	"github.com/kataras/iris"
 	"github.com/sirupsen/logrus"
+	"io/ioutil"
+	"net/http"
 )
 
 func Register(app *iris.Application) error {
 	logrus.Infof("    Registering Cluster Certificate Mgmt APIs...")
 	app.Get("/apis/v1/certs/get", DownloadCerts)
 	app.Get("/apis/v1/certs/admin/get", DownloadAdminCert)
+	app.Post("/apis/v1/certs/ca/upload", UploadSignedCA)
 	return nil
 }
 
@@ -33,8 +37,28 @@ func DownloadCerts(ctx iris.Context) {
 		ctx.Next()
 		return
 	}
-	//TODO: do more secruity checks.
-	certs, err := managers.GetClusterCertificates(cluster)
+	agentId := ctx.URLParam("agent-id")
+	if agentId == "" {
+		rsp := entities.Response{ErrorId: entities.ParameterError, Reason: "\"agent-id\" parameter is required."}
+		ctx.JSON(&rsp)
+		ctx.Values().Set(entities.RESPONSEINFO, &rsp)
+		ctx.Next()
+		return
+	}
+	//the agent is authenticated by its access token which was given during registering.
+	content, err := managers.GetAgentCertificate(cluster, agentId, ctx.GetHeader(entities.AgentTokenHeader), certName)
+	if err == managers.ErrAgentUnauthorized || err == managers.ErrCertificateForbidden {
+		statusCode := http.StatusUnauthorized
+		if err == managers.ErrCertificateForbidden {
+			statusCode = http.StatusForbidden
+		}
+		rsp := entities.Response{ErrorId: entities.AuthError, Reason: err.Error()}
+		ctx.StatusCode(statusCode)
+		ctx.JSON(&rsp)
+		ctx.Values().Set(entities.RESPONSEINFO, &rsp)
+		ctx.Next()
+		return
+	}
 	if err != nil {
 		rsp := entities.Response{ErrorId: entities.InternalError, Reason: err.Error()}
 		ctx.JSON(&rsp)
@@ -42,8 +66,7 @@ func DownloadCerts(ctx iris.Context) {
 		ctx.Next()
 		return
 	}
-	var content string
-	if content = certs.GetCertificateContent(certName); content == "" {
+	if content == "" {
 		rsp := entities.Response{ErrorId: entities.ParameterError, Reason: fmt.Sprintf("certificate: %s not found.", certName)}
 		ctx.JSON(&rsp)
 		ctx.Values().Set(entities.RESPONSEINFO, &rsp)
@@ -99,3 +122,42 @@ func DownloadAdminCert(ctx iris.Context) {
 	ctx.Values().Set(entities.RESPONSEINFO, &rsp)
 	ctx.Next()
 }
+
+func UploadSignedCA(ctx iris.Context) {
+	req := entities.UploadSignedCARequest{}
+	httpData, err := ioutil.ReadAll(ctx.Request().Body)
+	if err != nil {
+		rsp := entities.Response{ErrorId: entities.DeserializeError, Reason: err.Error()}
+		ctx.JSON(&rsp)
+		ctx.Values().Set(entities.RESPONSEINFO, &rsp)
+		ctx.Next()
+		return
+	}
+	err = json.Unmarshal(httpData, &req)
+	if err != nil {
+		rsp := entities.Response{ErrorId: entities.DeserializeError, Reason: err.Error()}
+		ctx.JSON(&rsp)
+		ctx.Values().Set(entities.RESPONSEINFO, &rsp)
+		ctx.Next()
+		return
+	}
+	if req.ClusterId == "" || req.Name == "" || req.Certificate == "" {
+		rsp := entities.Response{ErrorId: entities.ParameterError, Reason: "\"cluster_id\", \"name\" and \"certificate\" fields are required."}
+		ctx.JSON(&rsp)
+		ctx.Values().Set(entities.RESPONSEINFO, &rsp)
+		ctx.Next()
+		return
+	}
+	err = managers.UploadSignedCA(req.ClusterId, req.Name, req.Certificate)
+	if err != nil {
+		rsp := entities.Response{ErrorId: entities.OperationFailed, Reason: err.Error()}
+		ctx.JSON(&rsp)
+		ctx.Values().Set(entities.RESPONSEINFO, &rsp)
+		ctx.Next()
+		return
+	}
+	rsp := entities.Response{ErrorId: entities.Succeed, Reason: ""}
+	ctx.JSON(&rsp)
+	ctx.Values().Set(entities.RESPONSEINFO, &rsp)
+	ctx.Next()
+}
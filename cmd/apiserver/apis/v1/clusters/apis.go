@@ -9,6 +9,7 @@ import (
 	"github.com/kataras/iris"
 	"github.com/sirupsen/logrus"
 	"io/ioutil"
+	"time"
 )
 
 func Register(app *iris.Application) error {
@@ -17,6 +18,19 @@ func Register(app *iris.Application) error {
 	app.Post("/apis/v1/cluster/create", NewCluster)
 	app.Put("/apis/v1/cluster/update", UpdateCluster)
 	app.Get("/apis/v1/cluster/status", GetClusterComponentStatus)
+	app.Get("/apis/v1/cluster/ha/backends", GetHABackends)
+	app.Post("/apis/v1/cluster/diagnostics", CollectClusterDiagnostics)
+	app.Get("/apis/v1/cluster/upgrade", GetClusterUpgrade)
+	app.Post("/apis/v1/cluster/upgrade", UpgradeCluster)
+	app.Post("/apis/v1/cluster/upgrade/resume", ResumeClusterUpgrade)
+	app.Post("/apis/v1/cluster/upgrade/rollback", RollbackClusterUpgrade)
+	app.Get("/apis/v1/cluster/snapshots", GetClusterSnapshots)
+	app.Post("/apis/v1/cluster/snapshot", TriggerClusterSnapshot)
+	app.Post("/apis/v1/cluster/snapshot/restore", RestoreClusterSnapshot)
+	app.Get("/apis/v1/cluster/agent/rollout", GetAgentRollout)
+	app.Put("/apis/v1/cluster/agent/rollout", SetAgentRollout)
+	app.Put("/apis/v1/cluster/drift/remediation", SetDriftRemediation)
+	app.Put("/apis/v1/cluster/etcd/count", SetExpectedETCDCount)
 	return nil
 }
 
@@ -93,13 +107,402 @@ func GetClusterComponentStatus(ctx iris.Context) {
 	}
 	wps := c.GetWachPoints()
 	rsp := entities.GetClusterComponentStatusResponse{
-		Response:    entities.Response{ErrorId: entities.Succeed, Reason: ""},
-		WatchPoints: wps,
+		Response:     entities.Response{ErrorId: entities.Succeed, Reason: ""},
+		WatchPoints:  wps,
+		Reconcilers:  c.GetReconcileResults(),
+		MasterQuorum: c.GetMasterQuorum(),
 	}
 	_, _ = ctx.JSON(rsp)
 	ctx.Values().Set(entities.RESPONSEINFO, &rsp)
 	ctx.Next()
 }
 
-func UpdateCluster(ctx iris.Context)  {}
-func GetClusterList(ctx iris.Context) {}
+//CollectClusterDiagnostics returns a tar.gz archive which contains the redacted diagnostics of all of online agents and API server's view of the cluster.
+func CollectClusterDiagnostics(ctx iris.Context) {
+	clusterId := ctx.URLParam("cluster-id")
+	if clusterId == "" {
+		rsp := entities.Response{ErrorId: entities.ParameterError, Reason: "\"cluster-id\" parameter is required."}
+		ctx.JSON(&rsp)
+		ctx.Values().Set(entities.RESPONSEINFO, &rsp)
+		ctx.Next()
+		return
+	}
+	data, err := managers.CollectClusterDiagnostics(clusterId)
+	if err != nil {
+		rsp := entities.Response{ErrorId: entities.OperationFailed, Reason: err.Error()}
+		ctx.JSON(&rsp)
+		ctx.Values().Set(entities.RESPONSEINFO, &rsp)
+		ctx.Next()
+		return
+	}
+	ctx.ContentType("application/gzip")
+	ctx.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"diagnostics-%s-%s.tar.gz\"", clusterId, time.Now().Format("20060102150405")))
+	_, _ = ctx.Write(data)
+}
+
+func GetHABackends(ctx iris.Context) {
+	clusterId := ctx.URLParam("cluster-id")
+	if clusterId == "" {
+		rsp := entities.Response{ErrorId: entities.ParameterError, Reason: "\"cluster-id\" parameter is required."}
+		ctx.JSON(&rsp)
+		ctx.Values().Set(entities.RESPONSEINFO, &rsp)
+		ctx.Next()
+		return
+	}
+	backends, err := managers.GetHABackends(clusterId)
+	if err != nil {
+		rsp := entities.Response{ErrorId: entities.OperationFailed, Reason: err.Error()}
+		ctx.JSON(&rsp)
+		ctx.Values().Set(entities.RESPONSEINFO, &rsp)
+		ctx.Next()
+		return
+	}
+	rsp := entities.GetHABackendsResponse{
+		Response: entities.Response{ErrorId: entities.Succeed, Reason: ""},
+		Backends: backends,
+	}
+	_, _ = ctx.JSON(rsp)
+	ctx.Values().Set(entities.RESPONSEINFO, &rsp)
+	ctx.Next()
+}
+
+func UpdateCluster(ctx iris.Context) {}
+
+func GetClusterList(ctx iris.Context) {
+	clusters := common.ClusterManager.GetClusters()
+	result := []entities.ClusterBriefInformation{}
+	for i := 0; i < len(clusters); i++ {
+		settings := clusters[i].GetSettings()
+		result = append(result, entities.ClusterBriefInformation{
+			Id:                settings.Id,
+			Name:              settings.Name,
+			CreateTime:        settings.CreateTime,
+			KubernetesVersion: settings.KubernetesVersion,
+			Status:            clusters[i].GetStatus(),
+			MasterQuorum:      clusters[i].GetMasterQuorum(),
+		})
+	}
+	rsp := entities.GetClusterListResponse{
+		Response: entities.Response{ErrorId: entities.Succeed, Reason: ""},
+		Clusters: result,
+	}
+	_, _ = ctx.JSON(rsp)
+	ctx.Values().Set(entities.RESPONSEINFO, &rsp)
+	ctx.Next()
+}
+
+func GetClusterUpgrade(ctx iris.Context) {
+	clusterId := ctx.URLParam("cluster-id")
+	if clusterId == "" {
+		rsp := entities.Response{ErrorId: entities.ParameterError, Reason: "\"cluster-id\" parameter is required."}
+		ctx.JSON(&rsp)
+		ctx.Values().Set(entities.RESPONSEINFO, &rsp)
+		ctx.Next()
+		return
+	}
+	u, err := managers.GetClusterUpgrade(clusterId)
+	if err != nil {
+		rsp := entities.Response{ErrorId: entities.InternalError, Reason: err.Error()}
+		ctx.JSON(&rsp)
+		ctx.Values().Set(entities.RESPONSEINFO, &rsp)
+		ctx.Next()
+		return
+	}
+	rsp := entities.GetClusterUpgradeResponse{
+		Response: entities.Response{ErrorId: entities.Succeed, Reason: ""},
+		Upgrade:  u,
+	}
+	_, _ = ctx.JSON(rsp)
+	ctx.Values().Set(entities.RESPONSEINFO, &rsp)
+	ctx.Next()
+}
+
+func UpgradeCluster(ctx iris.Context) {
+	req := entities.UpgradeClusterRequest{}
+	httpData, err := ioutil.ReadAll(ctx.Request().Body)
+	if err != nil {
+		rsp := entities.Response{ErrorId: entities.DeserializeError, Reason: err.Error()}
+		ctx.JSON(&rsp)
+		ctx.Values().Set(entities.RESPONSEINFO, &rsp)
+		ctx.Next()
+		return
+	}
+	err = json.Unmarshal(httpData, &req)
+	if err != nil {
+		rsp := entities.Response{ErrorId: entities.DeserializeError, Reason: err.Error()}
+		ctx.JSON(&rsp)
+		ctx.Values().Set(entities.RESPONSEINFO, &rsp)
+		ctx.Next()
+		return
+	}
+	if req.ClusterId == "" || req.Version == "" {
+		rsp := entities.Response{ErrorId: entities.ParameterError, Reason: "\"cluster_id\" and \"version\" fields are required."}
+		ctx.JSON(&rsp)
+		ctx.Values().Set(entities.RESPONSEINFO, &rsp)
+		ctx.Next()
+		return
+	}
+	u, err := managers.UpgradeCluster(req.ClusterId, req.Version, req.KubeletBatchSize)
+	handleClusterUpgradeOperation(ctx, u, err)
+}
+
+func ResumeClusterUpgrade(ctx iris.Context) {
+	clusterId := ctx.URLParam("cluster-id")
+	if clusterId == "" {
+		rsp := entities.Response{ErrorId: entities.ParameterError, Reason: "\"cluster-id\" parameter is required."}
+		ctx.JSON(&rsp)
+		ctx.Values().Set(entities.RESPONSEINFO, &rsp)
+		ctx.Next()
+		return
+	}
+	u, err := managers.ResumeClusterUpgrade(clusterId)
+	handleClusterUpgradeOperation(ctx, u, err)
+}
+
+func RollbackClusterUpgrade(ctx iris.Context) {
+	clusterId := ctx.URLParam("cluster-id")
+	if clusterId == "" {
+		rsp := entities.Response{ErrorId: entities.ParameterError, Reason: "\"cluster-id\" parameter is required."}
+		ctx.JSON(&rsp)
+		ctx.Values().Set(entities.RESPONSEINFO, &rsp)
+		ctx.Next()
+		return
+	}
+	u, err := managers.RollbackClusterUpgrade(clusterId)
+	handleClusterUpgradeOperation(ctx, u, err)
+}
+
+func handleClusterUpgradeOperation(ctx iris.Context, u *entities.ClusterUpgrade, err error) {
+	if err != nil {
+		rsp := entities.Response{ErrorId: entities.OperationFailed, Reason: err.Error()}
+		ctx.JSON(&rsp)
+		ctx.Values().Set(entities.RESPONSEINFO, &rsp)
+		ctx.Next()
+		return
+	}
+	rsp := entities.GetClusterUpgradeResponse{
+		Response: entities.Response{ErrorId: entities.Succeed, Reason: ""},
+		Upgrade:  u,
+	}
+	_, _ = ctx.JSON(rsp)
+	ctx.Values().Set(entities.RESPONSEINFO, &rsp)
+	ctx.Next()
+}
+
+func GetClusterSnapshots(ctx iris.Context) {
+	clusterId := ctx.URLParam("cluster-id")
+	if clusterId == "" {
+		rsp := entities.Response{ErrorId: entities.ParameterError, Reason: "\"cluster-id\" parameter is required."}
+		ctx.JSON(&rsp)
+		ctx.Values().Set(entities.RESPONSEINFO, &rsp)
+		ctx.Next()
+		return
+	}
+	s, err := managers.GetETCDSnapshots(clusterId)
+	if err != nil {
+		rsp := entities.Response{ErrorId: entities.InternalError, Reason: err.Error()}
+		ctx.JSON(&rsp)
+		ctx.Values().Set(entities.RESPONSEINFO, &rsp)
+		ctx.Next()
+		return
+	}
+	rsp := entities.GetClusterSnapshotsResponse{
+		Response:  entities.Response{ErrorId: entities.Succeed, Reason: ""},
+		Snapshots: s,
+	}
+	_, _ = ctx.JSON(rsp)
+	ctx.Values().Set(entities.RESPONSEINFO, &rsp)
+	ctx.Next()
+}
+
+func TriggerClusterSnapshot(ctx iris.Context) {
+	clusterId := ctx.URLParam("cluster-id")
+	if clusterId == "" {
+		rsp := entities.Response{ErrorId: entities.ParameterError, Reason: "\"cluster-id\" parameter is required."}
+		ctx.JSON(&rsp)
+		ctx.Values().Set(entities.RESPONSEINFO, &rsp)
+		ctx.Next()
+		return
+	}
+	s, err := managers.TriggerETCDSnapshot(clusterId)
+	handleClusterSnapshotOperation(ctx, s, err)
+}
+
+func RestoreClusterSnapshot(ctx iris.Context) {
+	clusterId := ctx.URLParam("cluster-id")
+	name := ctx.URLParam("name")
+	if clusterId == "" || name == "" {
+		rsp := entities.Response{ErrorId: entities.ParameterError, Reason: "\"cluster-id\" and \"name\" parameters are required."}
+		ctx.JSON(&rsp)
+		ctx.Values().Set(entities.RESPONSEINFO, &rsp)
+		ctx.Next()
+		return
+	}
+	s, err := managers.RestoreETCDSnapshot(clusterId, name)
+	handleClusterSnapshotOperation(ctx, s, err)
+}
+
+func handleClusterSnapshotOperation(ctx iris.Context, s *entities.ClusterSnapshots, err error) {
+	if err != nil {
+		rsp := entities.Response{ErrorId: entities.OperationFailed, Reason: err.Error()}
+		ctx.JSON(&rsp)
+		ctx.Values().Set(entities.RESPONSEINFO, &rsp)
+		ctx.Next()
+		return
+	}
+	rsp := entities.GetClusterSnapshotsResponse{
+		Response:  entities.Response{ErrorId: entities.Succeed, Reason: ""},
+		Snapshots: s,
+	}
+	_, _ = ctx.JSON(rsp)
+	ctx.Values().Set(entities.RESPONSEINFO, &rsp)
+	ctx.Next()
+}
+
+func GetAgentRollout(ctx iris.Context) {
+	clusterId := ctx.URLParam("cluster-id")
+	if clusterId == "" {
+		rsp := entities.Response{ErrorId: entities.ParameterError, Reason: "\"cluster-id\" parameter is required."}
+		ctx.JSON(&rsp)
+		ctx.Values().Set(entities.RESPONSEINFO, &rsp)
+		ctx.Next()
+		return
+	}
+	rollout, versions, containerized, err := managers.GetAgentRollout(clusterId)
+	if err != nil {
+		rsp := entities.Response{ErrorId: entities.InternalError, Reason: err.Error()}
+		ctx.JSON(&rsp)
+		ctx.Values().Set(entities.RESPONSEINFO, &rsp)
+		ctx.Next()
+		return
+	}
+	rsp := entities.GetAgentRolloutResponse{
+		Response:      entities.Response{ErrorId: entities.Succeed, Reason: ""},
+		Rollout:       rollout,
+		Versions:      versions,
+		Containerized: containerized,
+	}
+	_, _ = ctx.JSON(rsp)
+	ctx.Values().Set(entities.RESPONSEINFO, &rsp)
+	ctx.Next()
+}
+
+//SetAgentRollout publishes the desired agent version, an empty version stops the rollout.
+func SetAgentRollout(ctx iris.Context) {
+	req := entities.SetAgentRolloutRequest{}
+	httpData, err := ioutil.ReadAll(ctx.Request().Body)
+	if err != nil {
+		rsp := entities.Response{ErrorId: entities.DeserializeError, Reason: err.Error()}
+		ctx.JSON(&rsp)
+		ctx.Values().Set(entities.RESPONSEINFO, &rsp)
+		ctx.Next()
+		return
+	}
+	err = json.Unmarshal(httpData, &req)
+	if err != nil {
+		rsp := entities.Response{ErrorId: entities.DeserializeError, Reason: err.Error()}
+		ctx.JSON(&rsp)
+		ctx.Values().Set(entities.RESPONSEINFO, &rsp)
+		ctx.Next()
+		return
+	}
+	if req.ClusterId == "" {
+		rsp := entities.Response{ErrorId: entities.ParameterError, Reason: "\"cluster_id\" field is required."}
+		ctx.JSON(&rsp)
+		ctx.Values().Set(entities.RESPONSEINFO, &rsp)
+		ctx.Next()
+		return
+	}
+	err = managers.SetAgentRollout(req.ClusterId, req.Version, req.CanaryPercentage)
+	if err != nil {
+		rsp := entities.Response{ErrorId: entities.OperationFailed, Reason: err.Error()}
+		ctx.JSON(&rsp)
+		ctx.Values().Set(entities.RESPONSEINFO, &rsp)
+		ctx.Next()
+		return
+	}
+	rsp := entities.Response{ErrorId: entities.Succeed}
+	ctx.JSON(&rsp)
+	ctx.Values().Set(entities.RESPONSEINFO, &rsp)
+	ctx.Next()
+}
+
+//SetDriftRemediation replaces the configuration drift remediation policy, a null policy makes agents only report the drift.
+func SetDriftRemediation(ctx iris.Context) {
+	req := entities.SetDriftRemediationRequest{}
+	httpData, err := ioutil.ReadAll(ctx.Request().Body)
+	if err != nil {
+		rsp := entities.Response{ErrorId: entities.DeserializeError, Reason: err.Error()}
+		ctx.JSON(&rsp)
+		ctx.Values().Set(entities.RESPONSEINFO, &rsp)
+		ctx.Next()
+		return
+	}
+	err = json.Unmarshal(httpData, &req)
+	if err != nil {
+		rsp := entities.Response{ErrorId: entities.DeserializeError, Reason: err.Error()}
+		ctx.JSON(&rsp)
+		ctx.Values().Set(entities.RESPONSEINFO, &rsp)
+		ctx.Next()
+		return
+	}
+	if req.ClusterId == "" {
+		rsp := entities.Response{ErrorId: entities.ParameterError, Reason: "\"cluster_id\" field is required."}
+		ctx.JSON(&rsp)
+		ctx.Values().Set(entities.RESPONSEINFO, &rsp)
+		ctx.Next()
+		return
+	}
+	err = managers.SetDriftRemediation(req.ClusterId, req.DriftRemediation)
+	if err != nil {
+		rsp := entities.Response{ErrorId: entities.OperationFailed, Reason: err.Error()}
+		ctx.JSON(&rsp)
+		ctx.Values().Set(entities.RESPONSEINFO, &rsp)
+		ctx.Next()
+		return
+	}
+	rsp := entities.Response{ErrorId: entities.Succeed}
+	ctx.JSON(&rsp)
+	ctx.Values().Set(entities.RESPONSEINFO, &rsp)
+	ctx.Next()
+}
+
+//SetExpectedETCDCount grows or shrinks the ETCD cluster, the membership is changed one member at a time.
+func SetExpectedETCDCount(ctx iris.Context) {
+	req := entities.SetExpectedETCDCountRequest{}
+	httpData, err := ioutil.ReadAll(ctx.Request().Body)
+	if err != nil {
+		rsp := entities.Response{ErrorId: entities.DeserializeError, Reason: err.Error()}
+		ctx.JSON(&rsp)
+		ctx.Values().Set(entities.RESPONSEINFO, &rsp)
+		ctx.Next()
+		return
+	}
+	err = json.Unmarshal(httpData, &req)
+	if err != nil {
+		rsp := entities.Response{ErrorId: entities.DeserializeError, Reason: err.Error()}
+		ctx.JSON(&rsp)
+		ctx.Values().Set(entities.RESPONSEINFO, &rsp)
+		ctx.Next()
+		return
+	}
+	if req.ClusterId == "" {
+		rsp := entities.Response{ErrorId: entities.ParameterError, Reason: "\"cluster_id\" field is required."}
+		ctx.JSON(&rsp)
+		ctx.Values().Set(entities.RESPONSEINFO, &rsp)
+		ctx.Next()
+		return
+	}
+	err = managers.SetExpectedETCDCount(req.ClusterId, req.ExpectedETCDCount)
+	if err != nil {
+		rsp := entities.Response{ErrorId: entities.OperationFailed, Reason: err.Error()}
+		ctx.JSON(&rsp)
+		ctx.Values().Set(entities.RESPONSEINFO, &rsp)
+		ctx.Next()
+		return
+	}
+	rsp := entities.Response{ErrorId: entities.Succeed}
+	ctx.JSON(&rsp)
+	ctx.Values().Set(entities.RESPONSEINFO, &rsp)
+	ctx.Next()
+}
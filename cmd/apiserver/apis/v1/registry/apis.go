@@ -4,8 +4,10 @@ import (
 	"net/http"
 	"os"
 	"path"
+	"strings"
 
 	"github.com/g0194776/lightningmonkey/pkg/entities"
+	"github.com/g0194776/lightningmonkey/pkg/managers"
 	"github.com/kataras/iris"
 	"github.com/sirupsen/logrus"
 )
@@ -15,6 +17,8 @@ func Register(app *iris.Application) error {
 	app.Get("/apis/v1/registry/1.12.5/*", downloadFile)
 	app.Get("/apis/v1/registry/1.13.12/*", downloadFile)
 	app.Get("/apis/v1/registry/software/*", downloadFile)
+	app.Get("/apis/v1/snapshots/{cluster}/{name}", downloadSnapshot)
+	app.Put("/apis/v1/snapshots/{cluster}/{name}", uploadSnapshot)
 	app.Get("/bootstrap/*", downloadFile2)
 	return nil
 }
@@ -28,6 +32,50 @@ func downloadFile(ctx iris.Context) {
 	http.StripPrefix("/apis/v1/", http.FileServer(http.Dir(path.Dir(os.Args[0])))).ServeHTTP(ctx.ResponseWriter(), ctx.Request())
 }
 
+//uploadSnapshot receives the ETCD snapshot which is taken by the elected ETCD agent, the token is issued to that agent only.
+func uploadSnapshot(ctx iris.Context) {
+	defer ctx.Request().Body.Close()
+	err := managers.SaveETCDSnapshotFile(ctx.Params().Get("cluster"), strings.TrimSuffix(ctx.Params().Get("name"), ".db"), ctx.URLParam("token"), ctx.Request().Body)
+	if err == managers.ErrSnapshotUnauthorized {
+		ctx.StatusCode(http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		logrus.Errorf("Failed to save uploaded ETCD snapshot, error: %s", err.Error())
+		ctx.StatusCode(http.StatusBadRequest)
+		_, _ = ctx.WriteString(err.Error())
+		return
+	}
+	ctx.StatusCode(http.StatusOK)
+}
+
+//downloadSnapshot serves the ETCD snapshot which is being restored, the token is issued along with the restore.
+func downloadSnapshot(ctx iris.Context) {
+	name := strings.TrimSuffix(ctx.Params().Get("name"), ".db")
+	f, err := managers.OpenETCDSnapshotFile(ctx.Params().Get("cluster"), name, ctx.URLParam("token"))
+	if err == managers.ErrSnapshotUnauthorized {
+		ctx.StatusCode(http.StatusUnauthorized)
+		return
+	}
+	if os.IsNotExist(err) {
+		ctx.StatusCode(http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		logrus.Errorf("Failed to open ETCD snapshot, error: %s", err.Error())
+		ctx.StatusCode(http.StatusBadRequest)
+		_, _ = ctx.WriteString(err.Error())
+		return
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		ctx.StatusCode(http.StatusInternalServerError)
+		return
+	}
+	http.ServeContent(ctx.ResponseWriter(), ctx.Request(), name+".db", fi.ModTime(), f)
+}
+
 func downloadFile2(ctx iris.Context) {
 	http.StripPrefix("/bootstrap/", http.FileServer(http.Dir(path.Dir(os.Args[0])))).ServeHTTP(ctx.ResponseWriter(), ctx.Request())
 }
@@ -14,6 +14,8 @@ import (
 	"github.com/sirupsen/logrus"
 	"math/rand"
 	"os"
+	"path"
+	"path/filepath"
 	"strings"
 )
 
@@ -29,6 +31,14 @@ func main() {
 		return
 	}
 	entities.HTTPDockerImageDownloadToken = os.Getenv("GET_TOKEN")
+	if dir := os.Getenv("SNAPSHOT_STORAGE_DIR"); dir != "" {
+		entities.ETCDSnapshotStorageDirectory = dir
+	}
+	//payloads under the directory of binary are served without authentication.
+	if isSubDirectory(path.Dir(os.Args[0]), entities.ETCDSnapshotStorageDirectory) {
+		logrus.Fatalf("Snapshot storage directory %s must not be placed under the directory of API server: %s", entities.ETCDSnapshotStorageDirectory, path.Dir(os.Args[0]))
+		return
+	}
 	logrus.Infof("Creating backend storage driver...")
 	driverType := os.Getenv("BACKEND_STORAGE_TYPE")
 	if driverType == "" {
@@ -108,3 +118,17 @@ func main() {
 	logrus.Infof("Starting Web Engine...")
 	app.Run(iris.Addr("0.0.0.0:8080"))
 }
+
+//isSubDirectory returns true if dir is the same as parent or is placed under it.
+func isSubDirectory(parent, dir string) bool {
+	parent, err := filepath.Abs(parent)
+	if err != nil {
+		return true
+	}
+	dir, err = filepath.Abs(dir)
+	if err != nil {
+		return true
+	}
+	rel, err := filepath.Rel(parent, dir)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
@@ -82,6 +82,31 @@ func (ac *AgentCache) Offline(agent entities.LightningMonkeyAgent) {
 	logrus.Infof("Agent %s(%s) offline..., etcd-role: %t, master-role: %t, minion-role: %t, ha-role: %t", agent.Id, agent.ClusterId, agent.HasETCDRole, agent.HasMasterRole, agent.HasMinionRole, agent.HasHARole)
 }
 
+//Dump returns copies of all of cached agents including their states by role, agents of resource pool are keyed by "pool".
+func (ac *AgentCache) Dump() map[string][]entities.CachedAgent {
+	ac.Lock()
+	defer ac.Unlock()
+	collections := map[string]map[string]*entities.LightningMonkeyAgent{
+		entities.AgentRole_ETCD:   ac.etcd,
+		entities.AgentRole_Master: ac.k8sMaster,
+		entities.AgentRole_Minion: ac.k8sMinion,
+		entities.AgentRole_HA:     ac.ha,
+		"pool":                    ac.pool,
+	}
+	result := map[string][]entities.CachedAgent{}
+	for role, m := range collections {
+		agents := []entities.CachedAgent{}
+		for _, agent := range m {
+			agents = append(agents, entities.CachedAgent{LightningMonkeyAgent: *agent, State: agent.State})
+		}
+		sort.Slice(agents, func(i, j int) bool {
+			return agents[i].Id < agents[j].Id
+		})
+		result[role] = agents
+	}
+	return result
+}
+
 func (ac *AgentCache) GetTotalCountByRole(role string) int {
 	ac.Lock()
 	defer ac.Unlock()
@@ -140,6 +165,30 @@ func (ac *AgentCache) GetTotalProvisionedCountByRole(role string) int {
 func (ac *AgentCache) GetAgentsAddress(role string, mustStatusFlag entities.AgentStatusFlag) []string {
 	ac.Lock()
 	defer ac.Unlock()
+	ips := []string{}
+	for _, as := range ac.filterAgents(role, mustStatusFlag) {
+		ips = append(ips, as.State.LastReportIP)
+	}
+	sort.Strings(ips)
+	return ips
+}
+
+//GetAgents returns copies of matched agents which are sorted by agent's identity.
+func (ac *AgentCache) GetAgents(role string, mustStatusFlag entities.AgentStatusFlag) []entities.LightningMonkeyAgent {
+	ac.Lock()
+	defer ac.Unlock()
+	agents := []entities.LightningMonkeyAgent{}
+	for _, as := range ac.filterAgents(role, mustStatusFlag) {
+		agents = append(agents, *as)
+	}
+	sort.Slice(agents, func(i, j int) bool {
+		return agents[i].Id < agents[j].Id
+	})
+	return agents
+}
+
+//filterAgents must be called with lock held.
+func (ac *AgentCache) filterAgents(role string, mustStatusFlag entities.AgentStatusFlag) []*entities.LightningMonkeyAgent {
 	var expFunc agentExp
 	var targetCollection map[string]*entities.LightningMonkeyAgent
 	switch role {
@@ -201,14 +250,13 @@ func (ac *AgentCache) GetAgentsAddress(role string, mustStatusFlag entities.Agen
 		logrus.Fatalf("Illegal type of role name: %s", role)
 		return nil
 	}
-	ips := []string{}
+	agents := []*entities.LightningMonkeyAgent{}
 	for _, as := range targetCollection {
 		if expFunc(as) {
-			ips = append(ips, as.State.LastReportIP)
+			agents = append(agents, as)
 		}
 	}
-	sort.Strings(ips)
-	return ips
+	return agents
 }
 
 func (ac *AgentCache) GetETCDCount() int {
@@ -264,3 +312,37 @@ func (ac *AgentCache) GetAdminConfFromMasterAgents() string {
 	}
 	return ""
 }
+
+//GetMasterQuorum calculates Kubernetes master quorum health with the given expected count.
+func (ac *AgentCache) GetMasterQuorum(expectedCount int) entities.MasterQuorum {
+	q := entities.MasterQuorum{
+		Expected:    expectedCount,
+		Registered:  ac.GetTotalCountByRole(entities.AgentRole_Master),
+		Provisioned: ac.GetTotalProvisionedCountByRole(entities.AgentRole_Master),
+		Online:      len(ac.GetAgentsAddress(entities.AgentRole_Master, entities.AgentStatusFlag_Provisioned)),
+	}
+	q.HasQuorum = q.Online >= expectedCount/2+1
+	q.IsCompleted = q.Provisioned >= expectedCount
+	return q
+}
+
+//GetETCDMembers returns the ETCD member list which is reported by the latest online & provisioned ETCD agent.
+func (ac *AgentCache) GetETCDMembers() []entities.ETCDMember {
+	ac.Lock()
+	defer ac.Unlock()
+	var latest *entities.LightningMonkeyAgent
+	for _, as := range ac.filterAgents(entities.AgentRole_ETCD, entities.AgentStatusFlag_Provisioned) {
+		if len(as.State.ETCDMembers) == 0 {
+			continue
+		}
+		if latest == nil || as.State.LastReportTime.After(latest.State.LastReportTime) {
+			latest = as
+		}
+	}
+	if latest == nil {
+		return nil
+	}
+	members := make([]entities.ETCDMember, len(latest.State.ETCDMembers))
+	copy(members, latest.State.ETCDMembers)
+	return members
+}
@@ -24,6 +24,7 @@ import (
 	"strings"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	agg_v1beta "k8s.io/kube-aggregator/pkg/client/clientset_generated/clientset/typed/apiregistration/v1beta1"
 )
@@ -57,10 +58,31 @@ type ClusterController interface {
 	GetDNSController() controllers.DeploymentController
 	GetExtensionDeploymentController() controllers.DeploymentController
 	GetWachPoints() []entities.WatchPoint
+	DumpAgentCache() map[string][]entities.CachedAgent
 	GetRandomAdminConfFromMasterAgents() (string, error)
 	GetNodesInformation() ([]entities.KubernetesNodeInfo, error)
 	EnableMonitors()
 	GetAgentList(onlineOnly bool) ([]entities.LightningMonkeyAgentBriefInformation, error)
+	RequestKubeletBootstrapToken(agent entities.LightningMonkeyAgent) string
+	GetReconcileResults() []entities.ReconcileResult
+	GetMasterQuorum() entities.MasterQuorum
+	GetHABackends() entities.HABackends
+	GetUpgrade() *entities.ClusterUpgrade
+	OnUpgradeChanged(value []byte, isDeleted bool) error
+	GetSnapshots() *entities.ClusterSnapshots
+	OnSnapshotsChanged(value []byte, isDeleted bool) error
+	OnBootstrapTokenChanged(agentId string, value []byte, isDeleted bool) error
+	GetDecommission(agentId string) *entities.AgentDecommission
+	OnDecommissionChanged(agentId string, value []byte, isDeleted bool) error
+	EnqueueAgentJob(agentId string, job entities.AgentJob)
+	HasPendingAgentJob(agentId string) bool
+	RemoveAgentJob(agentId string)
+	DecommissionKubernetesNode(nodeName string, timeout time.Duration) error
+}
+
+type kubeletBootstrapToken struct {
+	Token      string    `json:"token"`
+	ExpireTime time.Time `json:"expire_time"`
 }
 
 type ClusterControllerImple struct {
@@ -80,6 +102,19 @@ type ClusterControllerImple struct {
 	settings             entities.LightningMonkeyClusterSettings
 	synchronizedRevision int64
 	sd                   storage.LightningMonkeyStorageDriver
+	bootstrapLockObj     *sync.Mutex
+	bootstrapTokens      map[string]kubeletBootstrapToken
+	hasBootstrapRBAC     bool
+	worker               *clusterReconcileWorker
+	upgradeLockObj       *sync.RWMutex
+	upgrade              *entities.ClusterUpgrade
+	snapshotLockObj      *sync.RWMutex
+	snapshots            *entities.ClusterSnapshots
+	pendingJobLockObj    *sync.Mutex
+	pendingJobs          map[string]*pendingAgentJob
+	decommissionLockObj  *sync.RWMutex
+	decommissions        map[string]*entities.AgentDecommission
+	drainingAgents       map[string]bool
 }
 
 func (cc *ClusterControllerImple) GetSettings() entities.LightningMonkeyClusterSettings {
@@ -103,6 +138,25 @@ func (cc *ClusterControllerImple) Initialize(sd storage.LightningMonkeyStorageDr
 	if cc.monitorLockObj == nil {
 		cc.monitorLockObj = &sync.Mutex{}
 	}
+	if cc.bootstrapLockObj == nil {
+		cc.bootstrapLockObj = &sync.Mutex{}
+	}
+	if cc.upgradeLockObj == nil {
+		cc.upgradeLockObj = &sync.RWMutex{}
+	}
+	if cc.snapshotLockObj == nil {
+		cc.snapshotLockObj = &sync.RWMutex{}
+	}
+	if cc.pendingJobLockObj == nil {
+		cc.pendingJobLockObj = &sync.Mutex{}
+	}
+	if cc.decommissionLockObj == nil {
+		cc.decommissionLockObj = &sync.RWMutex{}
+	}
+	cc.bootstrapTokens = make(map[string]kubeletBootstrapToken)
+	cc.pendingJobs = make(map[string]*pendingAgentJob)
+	cc.decommissions = make(map[string]*entities.AgentDecommission)
+	cc.drainingAgents = make(map[string]bool)
 	cc.sd = sd
 	cc.certs = make(map[string]string)
 	cc.cache = &AgentCache{}
@@ -114,6 +168,12 @@ func (cc *ClusterControllerImple) Initialize(sd storage.LightningMonkeyStorageDr
 		logrus.Fatalf("Failed to full-sync cluster %s data, error: %s", cc.settings.Id, err.Error())
 		os.Exit(1)
 	}
+	cc.worker, err = newClusterReconcileWorker(cc)
+	if err != nil {
+		logrus.Fatalf("Failed to initialize cluster %s reconcile worker, error: %s", cc.settings.Id, err.Error())
+		os.Exit(1)
+	}
+	cc.worker.Start()
 }
 
 func (cc *ClusterControllerImple) fullSync(sd storage.LightningMonkeyStorageDriver) error {
@@ -144,6 +204,30 @@ func (cc *ClusterControllerImple) fullSync(sd storage.LightningMonkeyStorageDriv
 				continue
 			}
 		}
+		if isUpgradeChanged(subKeys) {
+			err = cc.OnUpgradeChanged(rsp.Kvs[i].Value, false)
+			if err != nil {
+				logrus.Errorf("Failed to update hot cache with upgrade progress, cluster: %s, error: %s", cc.GetClusterId(), err.Error())
+			}
+		}
+		if isSnapshotsChanged(subKeys) {
+			err = cc.OnSnapshotsChanged(rsp.Kvs[i].Value, false)
+			if err != nil {
+				logrus.Errorf("Failed to update hot cache with snapshots, cluster: %s, error: %s", cc.GetClusterId(), err.Error())
+			}
+		}
+		if agentId, isChanged := isBootstrapTokenChanged(subKeys); isChanged {
+			err = cc.OnBootstrapTokenChanged(agentId, rsp.Kvs[i].Value, false)
+			if err != nil {
+				logrus.Errorf("Failed to update hot cache with kubelet bootstrap token, cluster: %s, error: %s", cc.GetClusterId(), err.Error())
+			}
+		}
+		if agentId, isChanged := isDecommissionChanged(subKeys); isChanged {
+			err = cc.OnDecommissionChanged(agentId, rsp.Kvs[i].Value, false)
+			if err != nil {
+				logrus.Errorf("Failed to update hot cache with decommission progress, cluster: %s, error: %s", cc.GetClusterId(), err.Error())
+			}
+		}
 	}
 	return nil
 }
@@ -154,13 +238,19 @@ func (cc *ClusterControllerImple) Dispose() {
 			logrus.Errorf("Occurred an unhandled exception during disposing cluster controller, cluster-id: %s, error: %v", cc.settings.Id, err)
 		}
 	}()
-	atomic.StoreUint32(&cc.isDisposed, 1)
+	if atomic.SwapUint32(&cc.isDisposed, 1) == 0 && cc.worker != nil {
+		cc.worker.Stop()
+	}
 	if cc.cancellationFunc != nil {
 		cc.cancellationFunc()
 	}
 	cc.cache = nil
 }
 
+func (cc *ClusterControllerImple) isDisposedNow() bool {
+	return atomic.LoadUint32(&cc.isDisposed) == 1
+}
+
 func (cc *ClusterControllerImple) GetSynchronizedRevision() int64 {
 	return cc.synchronizedRevision
 }
@@ -184,6 +274,12 @@ func (cc *ClusterControllerImple) GetCertificates() entities.LightningMonkeyCert
 }
 
 func (cc *ClusterControllerImple) GetNextJob(agent entities.LightningMonkeyAgent, updateAgentDeploymentPhase func(int)) (entities.AgentJob, error) {
+	if job, isOK := cc.getDecommissionJob(agent); isOK {
+		return job, nil
+	}
+	if job, isOK := cc.fetchAgentJob(agent.Id); isOK {
+		return job, nil
+	}
 	return cc.jobScheduler.GetNextJob(cc, agent, cc.cache, updateAgentDeploymentPhase)
 }
 
@@ -207,10 +303,21 @@ func (cc *ClusterControllerImple) OnAgentChanged(agent entities.LightningMonkeyA
 	if atomic.LoadUint32(&cc.isDisposed) == 1 {
 		return fmt.Errorf("Cannot update cache to a disposed cluster controller, cluster-id: %s", cc.settings.Id)
 	}
-	if isDeleted || agent.State == nil {
+	//decommissioned agent never comes back.
+	if isDeleted || agent.State == nil || agent.IsDelete {
 		cc.cache.Offline(agent)
 	} else {
 		cc.cache.Online(agent)
+		//newly provisioned master makes cluster-level reconciliations possible.
+		if cc.worker != nil && agent.HasMasterRole && agent.State.HasProvisionedMasterComponents {
+			cc.worker.Enqueue(reconcileKey)
+		}
+		if cc.worker != nil && agent.State.Upgrade != nil {
+			cc.worker.Enqueue(upgradeKey)
+		}
+		if cc.worker != nil && (agent.State.Snapshot != nil || agent.State.Restore != nil) {
+			cc.worker.Enqueue(snapshotKey)
+		}
 	}
 	return nil
 }
@@ -351,6 +458,13 @@ func (cc *ClusterControllerImple) GetWachPoints() []entities.WatchPoint {
 	return wps
 }
 
+func (cc *ClusterControllerImple) DumpAgentCache() map[string][]entities.CachedAgent {
+	if atomic.LoadUint32(&cc.isDisposed) == 1 {
+		return nil
+	}
+	return cc.cache.Dump()
+}
+
 func (cc *ClusterControllerImple) EnableMonitors() {
 	cc.monitorLockObj.Lock()
 	defer cc.monitorLockObj.Unlock()
@@ -478,6 +592,10 @@ func (cc *ClusterControllerImple) GetAgentList(onlineOnly bool) ([]entities.Ligh
 				Hostname:        agent.Hostname,
 				HostInformation: agent.HostInformation,
 				DeploymentPhase: agent.DeploymentPhase,
+				Version:         agent.Version,
+				IsContainerized: agent.IsContainerized,
+				Preflight:       agent.Preflight,
+				NodePool:        agent.NodePool,
 			}
 			if agent.State != nil {
 				as := *agent.State
@@ -527,3 +645,131 @@ func (cc *ClusterControllerImple) GetAgentFromETCD(agentId string) (*entities.Li
 	agent.State = &state
 	return &agent, nil
 }
+
+//RequestKubeletBootstrapToken returns the cached bootstrap token of given agent.
+//It never talks to Kubernetes API synchronously, an empty value means the token is being created by the reconcile worker.
+func (cc *ClusterControllerImple) RequestKubeletBootstrapToken(agent entities.LightningMonkeyAgent) string {
+	cc.bootstrapLockObj.Lock()
+	t, isOK := cc.bootstrapTokens[agent.Id]
+	cc.bootstrapLockObj.Unlock()
+	if isOK && time.Now().Add(time.Hour).Before(t.ExpireTime) {
+		return t.Token
+	}
+	if cc.worker != nil {
+		cc.worker.Enqueue(bootstrapTokenKeyPrefix + agent.Id)
+	}
+	return ""
+}
+
+func (cc *ClusterControllerImple) GetMasterQuorum() entities.MasterQuorum {
+	return cc.cache.GetMasterQuorum(cc.settings.GetExpectedMasterCount())
+}
+
+//GetHABackends returns the desired HAProxy backends of this cluster along with the backends served by each of HA nodes.
+func (cc *ClusterControllerImple) GetHABackends() entities.HABackends {
+	backends := entities.HABackends{Desired: getDesiredHABackends(cc.cache), Nodes: []entities.HANodeBackend{}}
+	for _, agent := range cc.cache.GetAgents(entities.AgentRole_HA, entities.AgentStatusFlag_Whatever) {
+		n := entities.HANodeBackend{Id: agent.Id, Hostname: agent.Hostname}
+		if agent.State != nil {
+			n.Backends = agent.State.HABackends
+		}
+		n.IsSynchronized = isSameHABackends(backends.Desired, n.Backends)
+		backends.Nodes = append(backends.Nodes, n)
+	}
+	return backends
+}
+
+func (cc *ClusterControllerImple) GetReconcileResults() []entities.ReconcileResult {
+	if cc.worker == nil {
+		return nil
+	}
+	return cc.worker.GetResults()
+}
+
+//createKubeletBootstrapToken creates a per-agent bootstrap token in the target Kubernetes cluster, it's only called by reconcile worker.
+func (cc *ClusterControllerImple) createKubeletBootstrapToken(agentId string) error {
+	agent, err := cc.GetCachedAgent(agentId)
+	if err != nil {
+		return err
+	}
+	if agent == nil {
+		//agent has gone, nothing to do.
+		return nil
+	}
+	err = cc.InitializeKubernetesClient()
+	if err != nil {
+		return fmt.Errorf("Failed to initialize Kubernetes client, error: %s", err.Error())
+	}
+	cc.bootstrapLockObj.Lock()
+	defer cc.bootstrapLockObj.Unlock()
+	if t, isOK := cc.bootstrapTokens[agentId]; isOK && time.Now().Add(time.Hour).Before(t.ExpireTime) {
+		return nil
+	}
+	if !cc.hasBootstrapRBAC {
+		err = k8s.EnsureKubeletBootstrapRBAC(cc.cs)
+		if err != nil {
+			return err
+		}
+		cc.hasBootstrapRBAC = true
+	}
+	ttl := time.Hour * 24
+	token, err := k8s.CreateBootstrapToken(cc.cs, agent.Hostname, ttl)
+	if err != nil {
+		return err
+	}
+	t := kubeletBootstrapToken{Token: token, ExpireTime: time.Now().Add(ttl)}
+	//shares the token with other API servers, it's removed along with the lease once it has expired.
+	data, err := json.Marshal(&t)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), cc.sd.GetRequestTimeoutDuration())
+	defer cancel()
+	grantRsp, err := cc.sd.NewLease().Grant(ctx, int64(ttl/time.Second))
+	if err != nil {
+		return fmt.Errorf("Failed to grant lease for kubelet bootstrap token, error: %s", err.Error())
+	}
+	_, err = cc.sd.Put(ctx, fmt.Sprintf("/lightning-monkey/clusters/%s/bootstrap-tokens/%s", cc.GetClusterId(), agentId), string(data), clientv3.WithLease(grantRsp.ID))
+	if err != nil {
+		return fmt.Errorf("Failed to save kubelet bootstrap token, error: %s", err.Error())
+	}
+	cc.bootstrapTokens[agentId] = t
+	return nil
+}
+
+//OnBootstrapTokenChanged updates the cached bootstrap token which is created by the reconcile leader.
+func (cc *ClusterControllerImple) OnBootstrapTokenChanged(agentId string, value []byte, isDeleted bool) error {
+	if cc.isDisposedNow() {
+		return fmt.Errorf("Cannot update cache to a disposed cluster controller, cluster-id: %s", cc.settings.Id)
+	}
+	cc.bootstrapLockObj.Lock()
+	defer cc.bootstrapLockObj.Unlock()
+	if isDeleted {
+		delete(cc.bootstrapTokens, agentId)
+		return nil
+	}
+	t := kubeletBootstrapToken{}
+	err := json.Unmarshal(value, &t)
+	if err != nil {
+		return fmt.Errorf("Failed to unmarshal kubelet bootstrap token, error: %s", err.Error())
+	}
+	cc.bootstrapTokens[agentId] = t
+	return nil
+}
+
+//getPendingBootstrapTokenAgents returns the minion-only agents which are waiting for their kubelet bootstrap tokens.
+func (cc *ClusterControllerImple) getPendingBootstrapTokenAgents() []string {
+	agentIds := []string{}
+	cc.bootstrapLockObj.Lock()
+	defer cc.bootstrapLockObj.Unlock()
+	for _, agent := range cc.cache.GetAgents(entities.AgentRole_Minion, entities.AgentStatusFlag_Whatever) {
+		if agent.HasMasterRole || agent.HasETCDRole || (agent.State != nil && agent.State.HasProvisionedMinion) {
+			continue
+		}
+		if t, isOK := cc.bootstrapTokens[agent.Id]; isOK && time.Now().Add(time.Hour).Before(t.ExpireTime) {
+			continue
+		}
+		agentIds = append(agentIds, agent.Id)
+	}
+	return agentIds
+}
@@ -0,0 +1,258 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/g0194776/lightningmonkey/pkg/entities"
+	"github.com/g0194776/lightningmonkey/pkg/k8s"
+	"github.com/g0194776/lightningmonkey/pkg/storage"
+	uuid "github.com/satori/go.uuid"
+	"github.com/sirupsen/logrus"
+	"time"
+)
+
+const (
+	decommissionKeyPrefix = "decommission/"
+	teardownTimeout       = time.Minute * 2
+)
+
+type pendingAgentJob struct {
+	job        entities.AgentJob
+	hasFetched bool
+}
+
+//EnqueueAgentJob puts a one-off job for given agent, it takes precedence over any of scheduling strategies.
+//The agent only receives NOP after fetching it, until the job is removed.
+func (cc *ClusterControllerImple) EnqueueAgentJob(agentId string, job entities.AgentJob) {
+	cc.pendingJobLockObj.Lock()
+	defer cc.pendingJobLockObj.Unlock()
+	cc.pendingJobs[agentId] = &pendingAgentJob{job: job}
+}
+
+//HasPendingAgentJob returns true if the enqueued job of given agent has not been fetched yet.
+func (cc *ClusterControllerImple) HasPendingAgentJob(agentId string) bool {
+	cc.pendingJobLockObj.Lock()
+	defer cc.pendingJobLockObj.Unlock()
+	pj, isOK := cc.pendingJobs[agentId]
+	return isOK && !pj.hasFetched
+}
+
+//RemoveAgentJob gives the agent back to scheduling strategies.
+func (cc *ClusterControllerImple) RemoveAgentJob(agentId string) {
+	cc.pendingJobLockObj.Lock()
+	defer cc.pendingJobLockObj.Unlock()
+	delete(cc.pendingJobs, agentId)
+}
+
+func (cc *ClusterControllerImple) fetchAgentJob(agentId string) (entities.AgentJob, bool) {
+	cc.pendingJobLockObj.Lock()
+	defer cc.pendingJobLockObj.Unlock()
+	pj, isOK := cc.pendingJobs[agentId]
+	if !isOK {
+		return entities.AgentJob{}, false
+	}
+	if pj.hasFetched {
+		return entities.AgentJob{Name: entities.AgentJob_NOP, Reason: fmt.Sprintf("Waiting, job %s has been dispatched.", pj.job.Name)}, true
+	}
+	pj.hasFetched = true
+	return pj.job, true
+}
+
+//GetDecommission returns a copy of the decommission progress of given agent, nil means it has never been decommissioned.
+func (cc *ClusterControllerImple) GetDecommission(agentId string) *entities.AgentDecommission {
+	cc.decommissionLockObj.RLock()
+	defer cc.decommissionLockObj.RUnlock()
+	d, isOK := cc.decommissions[agentId]
+	if !isOK {
+		return nil
+	}
+	copied := *d
+	return &copied
+}
+
+func (cc *ClusterControllerImple) OnDecommissionChanged(agentId string, value []byte, isDeleted bool) error {
+	if cc.isDisposedNow() {
+		return fmt.Errorf("Cannot update cache to a disposed cluster controller, cluster-id: %s", cc.settings.Id)
+	}
+	var d *entities.AgentDecommission
+	if !isDeleted {
+		d = &entities.AgentDecommission{}
+		err := json.Unmarshal(value, d)
+		if err != nil {
+			return fmt.Errorf("Failed to unmarshal decommission progress of agent %s, error: %s", agentId, err.Error())
+		}
+	}
+	cc.decommissionLockObj.Lock()
+	if d == nil {
+		delete(cc.decommissions, agentId)
+	} else {
+		cc.decommissions[agentId] = d
+	}
+	cc.decommissionLockObj.Unlock()
+	if d != nil && !d.IsFinished() && cc.worker != nil {
+		cc.worker.Enqueue(decommissionKeyPrefix + agentId)
+	}
+	return nil
+}
+
+//getDecommissionJob returns the job of the agent which is being decommissioned, it's served by all of API servers.
+func (cc *ClusterControllerImple) getDecommissionJob(agent entities.LightningMonkeyAgent) (entities.AgentJob, bool) {
+	d := cc.GetDecommission(agent.Id)
+	if d == nil || d.IsFinished() {
+		return entities.AgentJob{}, false
+	}
+	if d.Status == entities.DecommissionStatus_Draining {
+		return entities.AgentJob{Name: entities.AgentJob_NOP, Reason: fmt.Sprintf("Waiting, Kubernetes node %s is being drained.", d.NodeName)}, true
+	}
+	if agent.State != nil && (agent.State.HasProvisionedMinion || agent.State.HasProvisionedHA) {
+		return entities.AgentJob{Name: entities.AgentJob_Teardown}, true
+	}
+	return entities.AgentJob{Name: entities.AgentJob_NOP, Reason: "Waiting, agent is being decommissioned."}, true
+}
+
+//getUnfinishedDecommissions returns the agents whose decommissions need to be moved forward by the reconcile leader.
+func (cc *ClusterControllerImple) getUnfinishedDecommissions() []string {
+	cc.decommissionLockObj.RLock()
+	defer cc.decommissionLockObj.RUnlock()
+	agentIds := []string{}
+	for agentId, d := range cc.decommissions {
+		if !d.IsFinished() {
+			agentIds = append(agentIds, agentId)
+		}
+	}
+	return agentIds
+}
+
+//reconcileDecommission moves the decommission of given agent forward, it's only called by reconcile worker.
+//Draining takes a long time, so it's performed in the background and the result is saved to the decommission progress.
+func (cc *ClusterControllerImple) reconcileDecommission(agentId string) error {
+	d := cc.GetDecommission(agentId)
+	if d == nil || d.IsFinished() {
+		return nil
+	}
+	if d.Status == entities.DecommissionStatus_Draining {
+		cc.decommissionLockObj.Lock()
+		defer cc.decommissionLockObj.Unlock()
+		if !cc.drainingAgents[agentId] {
+			cc.drainingAgents[agentId] = true
+			go cc.drainAgentNode(*d)
+		}
+		return nil
+	}
+	agent, err := cc.GetCachedAgent(agentId)
+	if err != nil {
+		return err
+	}
+	if agent == nil || agent.State == nil {
+		return cc.updateDecommission(agentId, entities.DecommissionStatus_Failed, "Agent went offline during tearing down.")
+	}
+	if agent.State.HasProvisionedMinion || agent.State.HasProvisionedHA {
+		if time.Since(d.TeardownTime) > teardownTimeout {
+			return cc.updateDecommission(agentId, entities.DecommissionStatus_Failed, "Timed out waiting for agent to tear down its components.")
+		}
+		return nil
+	}
+	if d.IsDelete {
+		agent.IsDelete = true
+		err = cc.saveAgentSettings(agent)
+		if err != nil {
+			return fmt.Errorf("Failed to mark agent: %s as deleted, error: %s", agentId, err.Error())
+		}
+	} else {
+		noPool := ""
+		err = transferAgentToCluster(cc.sd, cc.GetClusterId(), uuid.Nil.String(), agent, false, false, false, false, &noPool)
+		if err != nil {
+			return err
+		}
+	}
+	logrus.Infof("Agent %s(%s) has been decommissioned from cluster %s.", agentId, agent.Hostname, cc.GetClusterId())
+	return cc.updateDecommission(agentId, entities.DecommissionStatus_Completed, "")
+}
+
+//drainAgentNode cordons, drains & deletes the Kubernetes node of agent, the node is uncordoned if it fails.
+func (cc *ClusterControllerImple) drainAgentNode(d entities.AgentDecommission) {
+	defer func() {
+		cc.decommissionLockObj.Lock()
+		delete(cc.drainingAgents, d.AgentId)
+		cc.decommissionLockObj.Unlock()
+	}()
+	if d.NodeName != "" {
+		err := cc.DecommissionKubernetesNode(d.NodeName, time.Duration(d.DrainTimeoutSeconds)*time.Second)
+		if err != nil {
+			logrus.Errorf("Failed to decommission Kubernetes node %s of cluster %s, error: %s", d.NodeName, cc.GetClusterId(), err.Error())
+			if uncordonErr := k8s.UncordonNode(cc.cs, d.NodeName); uncordonErr != nil {
+				logrus.Errorf("Failed to uncordon Kubernetes node %s of cluster %s, error: %s", d.NodeName, cc.GetClusterId(), uncordonErr.Error())
+			}
+			err = cc.updateDecommission(d.AgentId, entities.DecommissionStatus_Failed, fmt.Sprintf("Failed to decommission Kubernetes node, error: %s", err.Error()))
+			if err != nil {
+				logrus.Errorf("Failed to save decommission progress of agent %s, error: %s", d.AgentId, err.Error())
+			}
+			return
+		}
+	}
+	logrus.Infof("Tearing down all of components on agent: %s(%s)...", d.AgentId, d.Hostname)
+	err := cc.updateDecommission(d.AgentId, entities.DecommissionStatus_TearingDown, "")
+	if err != nil {
+		logrus.Errorf("Failed to save decommission progress of agent %s, error: %s", d.AgentId, err.Error())
+	}
+}
+
+//updateDecommission changes the status of an unfinished decommission with CompareAndSwap.
+func (cc *ClusterControllerImple) updateDecommission(agentId, status, reason string) error {
+	return storage.CompareAndSwap(cc.sd, fmt.Sprintf("/lightning-monkey/clusters/%s/decommissions/%s", cc.GetClusterId(), agentId), func(value []byte) ([]byte, error) {
+		if value == nil {
+			return nil, nil
+		}
+		d := entities.AgentDecommission{}
+		err := json.Unmarshal(value, &d)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to unmarshal decommission progress of agent %s, error: %s", agentId, err.Error())
+		}
+		if d.IsFinished() {
+			return nil, nil
+		}
+		d.Status = status
+		d.Reason = reason
+		d.UpdateTime = time.Now()
+		if status == entities.DecommissionStatus_TearingDown {
+			d.TeardownTime = d.UpdateTime
+		}
+		return json.Marshal(&d)
+	})
+}
+
+func (cc *ClusterControllerImple) saveAgentSettings(agent *entities.LightningMonkeyAgent) error {
+	data, err := json.Marshal(agent)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), cc.sd.GetRequestTimeoutDuration())
+	defer cancel()
+	_, err = cc.sd.Put(ctx, fmt.Sprintf("/lightning-monkey/clusters/%s/agents/%s/settings", agent.ClusterId, agent.Id), string(data))
+	return err
+}
+
+//DecommissionKubernetesNode cordons, drains and deletes the given Kubernetes node, it's not an error if the node has already gone.
+func (cc *ClusterControllerImple) DecommissionKubernetesNode(nodeName string, timeout time.Duration) error {
+	err := cc.InitializeKubernetesClient()
+	if err != nil {
+		return err
+	}
+	exists, err := k8s.IsNodeExists(cc.cs, nodeName)
+	if err != nil || !exists {
+		return err
+	}
+	logrus.Infof("Cordoning Kubernetes node: %s on cluster: %s...", nodeName, cc.GetClusterId())
+	err = k8s.CordonNode(cc.cs, nodeName)
+	if err != nil {
+		return err
+	}
+	logrus.Infof("Draining Kubernetes node: %s on cluster: %s...", nodeName, cc.GetClusterId())
+	err = k8s.DrainNode(cc.cs, nodeName, timeout)
+	if err != nil {
+		return err
+	}
+	logrus.Infof("Deleting Kubernetes node: %s on cluster: %s...", nodeName, cc.GetClusterId())
+	return k8s.DeleteNode(cc.cs, nodeName)
+}
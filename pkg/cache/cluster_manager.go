@@ -9,17 +9,19 @@ import (
 	"github.com/g0194776/lightningmonkey/pkg/storage"
 	"github.com/sirupsen/logrus"
 	"go.etcd.io/etcd/clientv3"
+	"sort"
 	"strings"
 	"sync"
 )
 
 //go:generate mockgen -package=mock_lm -destination=../../mocks/mock_cluster_manager.go -source=cluster_manager.go ClusterManagerInterface
 type ClusterManagerInterface interface {
-	TransferAgentToCluster(oldClusterId string, newClusterId string, agent *entities.LightningMonkeyAgent, isETCDRole, isMasterRole, isMinionRole, isHARole bool) error
+	TransferAgentToCluster(oldClusterId string, newClusterId string, agent *entities.LightningMonkeyAgent, isETCDRole, isMasterRole, isMinionRole, isHARole bool, nodePool *string) error
 	Initialize(storageDriver storage.LightningMonkeyStorageDriver) error
 	GetClusterCertificateByName(clusterId string, certName string) (string, error)
 	GetClusterCertificates(clusterId string) (entities.LightningMonkeyCertificateCollection, error)
 	GetClusterById(clusterId string) (ClusterController, error)
+	GetClusters() []ClusterController
 	GetAgentFromETCD(clusterId, agentId string) (*entities.LightningMonkeyAgent, error)
 	Register(cc ClusterController) error
 	RemoveAgentFromETCD(clusterId string, agentId string) error
@@ -107,6 +109,20 @@ func (cm *ClusterManager) GetClusterById(clusterId string) (ClusterController, e
 	return cluster, nil
 }
 
+//GetClusters returns all of cached clusters which are ordered by cluster identity.
+func (cm *ClusterManager) GetClusters() []ClusterController {
+	cm.lockObj.Lock()
+	clusters := make([]ClusterController, 0, len(cm.clusters))
+	for _, cc := range cm.clusters {
+		clusters = append(clusters, cc)
+	}
+	cm.lockObj.Unlock()
+	sort.Slice(clusters, func(i, j int) bool {
+		return clusters[i].GetClusterId() < clusters[j].GetClusterId()
+	})
+	return clusters
+}
+
 func (cm *ClusterManager) GetAgentFromETCD(clusterId, agentId string) (*entities.LightningMonkeyAgent, error) {
 	if agentId == "" {
 		return nil, nil
@@ -271,6 +287,38 @@ func (cm *ClusterManager) watchChanges(ctx context.Context, wc clientv3.WatchCha
 						continue
 					}
 				}
+				//detect upgrade progress changes.
+				if isUpgradeChanged(subKeys) {
+					err = cc.OnUpgradeChanged(rsp.Events[i].Kv.Value, rsp.Events[i].Type == clientv3.EventTypeDelete)
+					if err != nil {
+						logrus.Errorf("Failed to update hot cache with upgrade progress, cluster: %s, error: %s", cc.GetClusterId(), err.Error())
+						continue
+					}
+				}
+				//detect snapshot history changes.
+				if isSnapshotsChanged(subKeys) {
+					err = cc.OnSnapshotsChanged(rsp.Events[i].Kv.Value, rsp.Events[i].Type == clientv3.EventTypeDelete)
+					if err != nil {
+						logrus.Errorf("Failed to update hot cache with snapshots, cluster: %s, error: %s", cc.GetClusterId(), err.Error())
+						continue
+					}
+				}
+				//detect kubelet bootstrap token changes.
+				if agentId, changed = isBootstrapTokenChanged(subKeys); changed {
+					err = cc.OnBootstrapTokenChanged(agentId, rsp.Events[i].Kv.Value, rsp.Events[i].Type == clientv3.EventTypeDelete)
+					if err != nil {
+						logrus.Errorf("Failed to update hot cache with kubelet bootstrap token, cluster: %s, error: %s", cc.GetClusterId(), err.Error())
+						continue
+					}
+				}
+				//detect decommission progress changes.
+				if agentId, changed = isDecommissionChanged(subKeys); changed {
+					err = cc.OnDecommissionChanged(agentId, rsp.Events[i].Kv.Value, rsp.Events[i].Type == clientv3.EventTypeDelete)
+					if err != nil {
+						logrus.Errorf("Failed to update hot cache with decommission progress, cluster: %s, error: %s", cc.GetClusterId(), err.Error())
+						continue
+					}
+				}
 			}
 		}
 	}
@@ -391,3 +439,25 @@ func isCertificatesChanged(subKeys []string) (string, bool) {
 	}
 	return "", false
 }
+
+func isUpgradeChanged(subKeys []string) bool {
+	return len(subKeys) == 4 && subKeys[0] == "lightning-monkey" && subKeys[1] == "clusters" && subKeys[3] == "upgrade"
+}
+
+func isSnapshotsChanged(subKeys []string) bool {
+	return len(subKeys) == 4 && subKeys[0] == "lightning-monkey" && subKeys[1] == "clusters" && subKeys[3] == "snapshots"
+}
+
+func isDecommissionChanged(subKeys []string) (string /*parsed agent id*/, bool) {
+	if len(subKeys) == 5 && subKeys[0] == "lightning-monkey" && subKeys[1] == "clusters" && subKeys[3] == "decommissions" {
+		return subKeys[4], true
+	}
+	return "", false
+}
+
+func isBootstrapTokenChanged(subKeys []string) (string /*parsed agent id*/, bool) {
+	if len(subKeys) == 5 && subKeys[0] == "lightning-monkey" && subKeys[1] == "clusters" && subKeys[3] == "bootstrap-tokens" {
+		return subKeys[4], true
+	}
+	return "", false
+}
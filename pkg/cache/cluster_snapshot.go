@@ -0,0 +1,238 @@
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/g0194776/lightningmonkey/pkg/entities"
+	"github.com/g0194776/lightningmonkey/pkg/storage"
+	uuid "github.com/satori/go.uuid"
+	"github.com/sirupsen/logrus"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+//GetSnapshots returns a copy of current cluster snapshot history, nil means there is no any snapshot has been taken.
+func (cc *ClusterControllerImple) GetSnapshots() *entities.ClusterSnapshots {
+	cc.snapshotLockObj.RLock()
+	defer cc.snapshotLockObj.RUnlock()
+	if cc.snapshots == nil {
+		return nil
+	}
+	s := *cc.snapshots
+	s.Snapshots = append([]entities.ETCDSnapshot{}, cc.snapshots.Snapshots...)
+	if cc.snapshots.Restore != nil {
+		r := *cc.snapshots.Restore
+		r.Nodes = make(map[string]*entities.ETCDRestoreNode, len(cc.snapshots.Restore.Nodes))
+		for k, v := range cc.snapshots.Restore.Nodes {
+			n := *v
+			r.Nodes[k] = &n
+		}
+		s.Restore = &r
+	}
+	return &s
+}
+
+func (cc *ClusterControllerImple) OnSnapshotsChanged(value []byte, isDeleted bool) error {
+	if cc.isDisposedNow() {
+		return fmt.Errorf("Cannot update cache to a disposed cluster controller, cluster-id: %s", cc.settings.Id)
+	}
+	var s *entities.ClusterSnapshots
+	if !isDeleted {
+		s = &entities.ClusterSnapshots{}
+		err := json.Unmarshal(value, s)
+		if err != nil {
+			return fmt.Errorf("Failed to unmarshal cluster snapshots, error: %s", err.Error())
+		}
+	}
+	cc.snapshotLockObj.Lock()
+	cc.snapshots = s
+	cc.snapshotLockObj.Unlock()
+	if s != nil {
+		pruneSnapshotFiles(cc.GetClusterId(), s)
+	}
+	if cc.worker != nil {
+		cc.worker.Enqueue(snapshotKey)
+	}
+	return nil
+}
+
+//reconcileSnapshots records snapshot & restore results reported by ETCD agents, it's only called by reconcile worker.
+//The history is changed on the stored copy with CompareAndSwap, so the restore or snapshot requested meanwhile is never overwritten.
+func (cc *ClusterControllerImple) reconcileSnapshots() error {
+	if !cc.needsReconcileSnapshots() {
+		return nil
+	}
+	return storage.CompareAndSwap(cc.sd, fmt.Sprintf("/lightning-monkey/clusters/%s/snapshots", cc.GetClusterId()), func(value []byte) ([]byte, error) {
+		s := &entities.ClusterSnapshots{}
+		if value != nil {
+			err := json.Unmarshal(value, s)
+			if err != nil {
+				return nil, fmt.Errorf("Failed to unmarshal snapshots of cluster %s, error: %s", cc.GetClusterId(), err.Error())
+			}
+		}
+		if !cc.progressSnapshots(s) {
+			return nil, nil
+		}
+		return json.Marshal(s)
+	})
+}
+
+//needsReconcileSnapshots checks the cached history, so that the stored one is only read when it needs to be changed.
+func (cc *ClusterControllerImple) needsReconcileSnapshots() bool {
+	s := cc.GetSnapshots()
+	if s == nil {
+		s = &entities.ClusterSnapshots{}
+	}
+	if s.Restore != nil && s.Restore.Status == entities.ETCDRestoreStatus_Running {
+		return true
+	}
+	for _, agent := range cc.cache.GetAgents(entities.AgentRole_ETCD, entities.AgentStatusFlag_Whatever) {
+		if agent.State != nil && agent.State.Snapshot != nil && agent.State.Snapshot.Name != "" && s.GetSnapshot(agent.State.Snapshot.Name) == nil {
+			return true
+		}
+	}
+	return cc.reconcileUploadCredential(s, false)
+}
+
+//progressSnapshots returns true if any of snapshot history, restore progress or upload credential has been changed.
+func (cc *ClusterControllerImple) progressSnapshots(s *entities.ClusterSnapshots) bool {
+	changed := false
+	hasUploaded := false
+	for _, agent := range cc.cache.GetAgents(entities.AgentRole_ETCD, entities.AgentStatusFlag_Whatever) {
+		if agent.State == nil || agent.State.Snapshot == nil || agent.State.Snapshot.Name == "" || s.GetSnapshot(agent.State.Snapshot.Name) != nil {
+			continue
+		}
+		snapshot := *agent.State.Snapshot
+		snapshot.AgentId = agent.Id
+		snapshot.Hostname = agent.Hostname
+		s.Snapshots = append(s.Snapshots, snapshot)
+		changed = true
+		hasUploaded = hasUploaded || snapshot.Destination == entities.ETCDSnapshotDestination_APIServer
+		logrus.Infof("ETCD snapshot %s of cluster %s has been recorded, status: %s", snapshot.Name, cc.GetClusterId(), snapshot.Status)
+	}
+	if changed {
+		sort.SliceStable(s.Snapshots, func(i, j int) bool {
+			return s.Snapshots[i].CreateTime.Before(s.Snapshots[j].CreateTime)
+		})
+		settings := cc.GetSettings()
+		retention := 0
+		if settings.BackupSettings != nil {
+			retention = settings.BackupSettings.Retention
+		}
+		s.Snapshots = applySnapshotRetention(s.Snapshots, retention)
+	}
+	if cc.reconcileRestore(s.Restore) {
+		changed = true
+	}
+	if cc.reconcileUploadCredential(s, hasUploaded) {
+		changed = true
+	}
+	return changed
+}
+
+//applySnapshotRetention keeps the latest succeed snapshots and failures which happened after them,
+//files of removed snapshots are deleted by every API server which holds them once the history has been saved.
+func applySnapshotRetention(snapshots []entities.ETCDSnapshot, retention int) []entities.ETCDSnapshot {
+	kept := []entities.ETCDSnapshot{}
+	succeedCount := 0
+	for i := len(snapshots) - 1; i >= 0; i-- {
+		isSucceed := snapshots[i].Status == entities.ETCDSnapshotStatus_Succeed
+		if (isSucceed && (retention <= 0 || succeedCount < retention)) || (!isSucceed && succeedCount == 0) {
+			if isSucceed {
+				succeedCount++
+			}
+			kept = append([]entities.ETCDSnapshot{snapshots[i]}, kept...)
+		}
+	}
+	return kept
+}
+
+//reconcileUploadCredential issues a new upload token to the elected ETCD agent, the token is replaced after every
+//uploaded snapshot. It returns true if the credential has been changed.
+func (cc *ClusterControllerImple) reconcileUploadCredential(s *entities.ClusterSnapshots, hasUploaded bool) bool {
+	bs := cc.GetSettings().BackupSettings
+	agents := cc.cache.GetAgents(entities.AgentRole_ETCD, entities.AgentStatusFlag_Provisioned)
+	if bs == nil || bs.Destination != entities.ETCDSnapshotDestination_APIServer || len(agents) == 0 {
+		if s.Upload == nil {
+			return false
+		}
+		s.Upload = nil
+		return true
+	}
+	if s.Upload != nil && s.Upload.AgentId == agents[0].Id && !hasUploaded {
+		return false
+	}
+	s.Upload = &entities.ETCDSnapshotUploadCredential{AgentId: agents[0].Id, Token: uuid.NewV4().String()}
+	return true
+}
+
+//pruneSnapshotFiles removes the files held by this API server which are no longer recorded, the files newer than
+//the oldest recorded snapshot are kept since they might have not been recorded yet.
+func pruneSnapshotFiles(clusterId string, s *entities.ClusterSnapshots) {
+	if len(s.Snapshots) == 0 {
+		return
+	}
+	dir := path.Join(entities.ETCDSnapshotStorageDirectory, clusterId)
+	fis, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	recorded := map[string]bool{}
+	for i := 0; i < len(s.Snapshots); i++ {
+		recorded[s.Snapshots[i].Name+".db"] = true
+	}
+	oldest := s.Snapshots[0].Name + ".db"
+	for i := 0; i < len(fis); i++ {
+		name := fis[i].Name()
+		if fis[i].IsDir() || !strings.HasPrefix(name, "snapshot-") || !strings.HasSuffix(name, ".db") || recorded[name] || name >= oldest {
+			continue
+		}
+		logrus.Infof("Removing expired ETCD snapshot %s of cluster %s...", name, clusterId)
+		if err = os.Remove(path.Join(dir, name)); err != nil && !os.IsNotExist(err) {
+			logrus.Warnf("Failed to remove expired ETCD snapshot %s of cluster %s, error: %s", name, clusterId, err.Error())
+		}
+	}
+}
+
+//reconcileRestore returns true if any of restore progress has been changed.
+func (cc *ClusterControllerImple) reconcileRestore(r *entities.ETCDRestore) bool {
+	if r == nil || r.Status != entities.ETCDRestoreStatus_Running {
+		return false
+	}
+	changed := false
+	hasCompleted := true
+	for id, n := range r.Nodes {
+		agent, err := cc.GetCachedAgent(id)
+		if err == nil && agent != nil && agent.State != nil && agent.State.Restore != nil && agent.State.Restore.RestoreId == r.Id {
+			rs := agent.State.Restore
+			if n.Status != rs.Status || n.Reason != rs.Reason {
+				n.Status = rs.Status
+				n.Reason = rs.Reason
+				n.UpdateTime = time.Now()
+				changed = true
+			}
+		}
+		if n.Status == entities.ETCDRestoreNodeStatus_Failed {
+			r.Status = entities.ETCDRestoreStatus_Failed
+			r.Reason = fmt.Sprintf("Node %s failed to restore ETCD snapshot %s, error: %s", n.Hostname, r.SnapshotName, n.Reason)
+			logrus.Warnf("Restore of cluster %s has been failed, %s", cc.GetClusterId(), r.Reason)
+		}
+		if n.Status != entities.ETCDRestoreNodeStatus_Succeed {
+			hasCompleted = false
+		}
+	}
+	if r.Status == entities.ETCDRestoreStatus_Running && hasCompleted {
+		logrus.Infof("Cluster %s has restored ETCD snapshot: %s", cc.GetClusterId(), r.SnapshotName)
+		r.Status = entities.ETCDRestoreStatus_Completed
+		r.Reason = ""
+	}
+	if changed || r.Status != entities.ETCDRestoreStatus_Running {
+		r.UpdateTime = time.Now()
+		return true
+	}
+	return false
+}
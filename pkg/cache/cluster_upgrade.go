@@ -0,0 +1,143 @@
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/g0194776/lightningmonkey/pkg/entities"
+	"github.com/g0194776/lightningmonkey/pkg/storage"
+	"github.com/sirupsen/logrus"
+	"time"
+)
+
+//GetUpgrade returns a copy of current cluster upgrade progress, nil means there is no any upgrade has been requested.
+func (cc *ClusterControllerImple) GetUpgrade() *entities.ClusterUpgrade {
+	cc.upgradeLockObj.RLock()
+	defer cc.upgradeLockObj.RUnlock()
+	if cc.upgrade == nil {
+		return nil
+	}
+	u := *cc.upgrade
+	u.Stages = append([]string{}, cc.upgrade.Stages...)
+	u.Nodes = make(map[string]*entities.ClusterUpgradeNode, len(cc.upgrade.Nodes))
+	for k, v := range cc.upgrade.Nodes {
+		n := *v
+		u.Nodes[k] = &n
+	}
+	return &u
+}
+
+func (cc *ClusterControllerImple) OnUpgradeChanged(value []byte, isDeleted bool) error {
+	if cc.isDisposedNow() {
+		return fmt.Errorf("Cannot update cache to a disposed cluster controller, cluster-id: %s", cc.settings.Id)
+	}
+	var u *entities.ClusterUpgrade
+	if !isDeleted {
+		u = &entities.ClusterUpgrade{}
+		err := json.Unmarshal(value, u)
+		if err != nil {
+			return fmt.Errorf("Failed to unmarshal cluster upgrade progress, error: %s", err.Error())
+		}
+	}
+	cc.upgradeLockObj.Lock()
+	cc.upgrade = u
+	cc.upgradeLockObj.Unlock()
+	if cc.worker != nil {
+		cc.worker.Enqueue(upgradeKey)
+	}
+	return nil
+}
+
+//reconcileUpgrade records upgrade results reported by agents and moves upgrade forward, it's only called by reconcile worker.
+//The progress is changed on the stored copy with CompareAndSwap, so the resume or rollback requested meanwhile is never overwritten.
+func (cc *ClusterControllerImple) reconcileUpgrade() error {
+	if u := cc.GetUpgrade(); u == nil || u.Status != entities.UpgradeStatus_Running {
+		return nil
+	}
+	return storage.CompareAndSwap(cc.sd, fmt.Sprintf("/lightning-monkey/clusters/%s/upgrade", cc.GetClusterId()), func(value []byte) ([]byte, error) {
+		if value == nil {
+			return nil, nil
+		}
+		u := &entities.ClusterUpgrade{}
+		err := json.Unmarshal(value, u)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to unmarshal cluster upgrade progress, error: %s", err.Error())
+		}
+		changed, err := cc.progressUpgrade(u)
+		if err != nil || !changed {
+			return nil, err
+		}
+		u.UpdateTime = time.Now()
+		return json.Marshal(u)
+	})
+}
+
+//progressUpgrade returns true if any of upgrade progress has been changed.
+func (cc *ClusterControllerImple) progressUpgrade(u *entities.ClusterUpgrade) (bool, error) {
+	changed := false
+	version := u.GetTargetVersion()
+	for u.Status == entities.UpgradeStatus_Running {
+		stage := u.GetCurrentStage()
+		if stage == "" {
+			break
+		}
+		hasCompleted := true
+		for _, id := range getSortedUpgradeNodeIds(u) {
+			n := u.Nodes[id]
+			if !n.IsParticipant(stage) || n.HasCompleted(stage) {
+				continue
+			}
+			agent, err := cc.GetCachedAgent(id)
+			if err != nil {
+				return false, err
+			}
+			if agent != nil && agent.State != nil && n.IsReportedBy(agent.State.Upgrade, version, stage) {
+				s := agent.State.Upgrade
+				if n.Stage != stage || n.Status != s.Status || n.Reason != s.Reason {
+					n.Stage = stage
+					n.Status = s.Status
+					n.Reason = s.Reason
+					n.UpdateTime = time.Now()
+					changed = true
+				}
+				if s.Status == entities.UpgradeNodeStatus_Failed {
+					u.Status = entities.UpgradeStatus_Paused
+					u.Reason = fmt.Sprintf("Node %s failed to upgrade %s to version %s, error: %s", n.Hostname, stage, version, s.Reason)
+					logrus.Warnf("Upgrade of cluster %s has been paused, %s", cc.GetClusterId(), u.Reason)
+				}
+			}
+			if !n.HasCompleted(stage) {
+				hasCompleted = false
+			}
+		}
+		if !hasCompleted {
+			break
+		}
+		logrus.Infof("Cluster %s has completed upgrade stage: %s, version: %s", cc.GetClusterId(), stage, version)
+		u.StageIndex++
+		changed = true
+		if u.GetCurrentStage() == "" {
+			err := cc.updateKubernetesVersion(version)
+			if err != nil {
+				return false, err
+			}
+			u.Status = entities.UpgradeStatus_Completed
+			u.Reason = ""
+		}
+	}
+	return changed, nil
+}
+
+//updateKubernetesVersion makes newly joined agents to use the upgraded version.
+func (cc *ClusterControllerImple) updateKubernetesVersion(version string) error {
+	err := storage.UpdateClusterSettings(cc.sd, cc.GetClusterId(), func(settings *entities.LightningMonkeyClusterSettings) (bool, error) {
+		if settings.KubernetesVersion == version {
+			return false, nil
+		}
+		settings.KubernetesVersion = version
+		return true, nil
+	})
+	if err != nil {
+		return fmt.Errorf("Failed to update Kubernetes version of cluster %s, error: %s", cc.GetClusterId(), err.Error())
+	}
+	return nil
+}
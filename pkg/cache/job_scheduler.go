@@ -3,6 +3,18 @@ package cache
 import (
 	"fmt"
 	"github.com/g0194776/lightningmonkey/pkg/entities"
+	"github.com/sirupsen/logrus"
+	"strings"
+)
+
+var (
+	//roles which are deployed by the jobs, these jobs are gated by agents' preflight checks.
+	deploymentJobRoles = map[string]string{
+		entities.AgentJob_Deploy_ETCD:   entities.AgentRole_ETCD,
+		entities.AgentJob_Deploy_Master: entities.AgentRole_Master,
+		entities.AgentJob_Deploy_Minion: entities.AgentRole_Minion,
+		entities.AgentJob_Deploy_HA:     entities.AgentRole_HA,
+	}
 )
 
 type ClusterJobScheduler interface {
@@ -15,16 +27,10 @@ type ClusterJobSchedulerImple struct {
 }
 
 func (js *ClusterJobSchedulerImple) InitializeStrategies() {
-	js.strategies = []ClusterJobStrategy{
-		&ClusterETCDJobStrategy{},
-		&ClusterKubernetesMasterJobStrategy{},
-		&HAJobStrategy{},
-		&ClusterKubernetesMinionJobStrategy{},
-		&ClusterKubernetesNetworkStackJobStrategy{},
-		&ClusterKubernetesDNSJobStrategy{},
-		&EnableMonitorsJobStrategy{},
-		&ExtensibilityDeploymentJobStrategy{},
-		&MetricsServerAddStaticRouteStrategy{},
+	var err error
+	js.strategies, err = DefaultStrategyRegistry.GetAgentJobStrategies()
+	if err != nil {
+		logrus.Fatalf("Failed to initialize cluster job strategies, error: %s", err.Error())
 	}
 }
 
@@ -53,8 +59,21 @@ func (js *ClusterJobSchedulerImple) GetNextJob(cc ClusterController, agent entit
 			return entities.AgentJob{Name: entities.AgentJob_NOP, Reason: reason}, nil
 		}
 		updateAgentDeploymentPhase(entities.AgentDeploymentPhase_Deploying)
+		if role, isOK := deploymentJobRoles[js.strategies[i].GetStrategyName()]; isOK {
+			if failedChecks := agent.GetFailedPreflightChecks(role); len(failedChecks) > 0 {
+				return entities.AgentJob{Name: entities.AgentJob_NOP, Reason: getPreflightFailureReason(role, failedChecks)}, nil
+			}
+		}
 		return entities.AgentJob{Name: js.strategies[i].GetStrategyName(), Arguments: deployArgs}, nil
 	}
 	updateAgentDeploymentPhase(entities.AgentDeploymentPhase_Deployed)
 	return entities.AgentJob{Name: entities.AgentJob_NOP, Reason: "Waiting, no any operations should perform."}, nil
 }
+
+func getPreflightFailureReason(role string, checks []entities.PreflightCheck) string {
+	reasons := make([]string, 0, len(checks))
+	for i := 0; i < len(checks); i++ {
+		reasons = append(reasons, fmt.Sprintf("%s: %s", checks[i].Name, checks[i].Reason))
+	}
+	return fmt.Sprintf("Refused to deploy role %s, host failed preflight checks: %s", role, strings.Join(reasons, "; "))
+}
@@ -0,0 +1,275 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/g0194776/lightningmonkey/pkg/entities"
+	"github.com/sirupsen/logrus"
+	"go.etcd.io/etcd/clientv3"
+	"golang.org/x/time/rate"
+	"k8s.io/client-go/util/workqueue"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	reconcileInterval       = time.Second * 10
+	reconcileKey            = "cluster-reconcile"
+	upgradeKey              = "cluster-upgrade"
+	snapshotKey             = "cluster-snapshot"
+	bootstrapTokenKeyPrefix = "bootstrap-token/"
+	leaderElectionKey       = "/lightning-monkey/clusters/%s/reconcile-leader"
+	leaderLeaseTTL          = 15 //seconds
+)
+
+//clusterReconcileWorker runs all of cluster-level reconciliations in the background with its own rate-limited queue.
+//agent requests only enqueue works here and read cached results, they never be blocked by Kubernetes API calls.
+//With several API servers, only the one elected by ETCD handles the works, the others read what it saved to ETCD.
+type clusterReconcileWorker struct {
+	cc          *ClusterControllerImple
+	queue       workqueue.RateLimitingInterface
+	reconcilers []StrategyRegistration
+	lockObj     *sync.RWMutex
+	results     map[string]entities.ReconcileResult
+	stopChan    chan struct{}
+	isLeader    uint32
+}
+
+func newClusterReconcileWorker(cc *ClusterControllerImple) (*clusterReconcileWorker, error) {
+	reconcilers, err := DefaultStrategyRegistry.GetClusterReconcilers()
+	if err != nil {
+		return nil, err
+	}
+	rateLimiter := workqueue.NewMaxOfRateLimiter(
+		workqueue.NewItemExponentialFailureRateLimiter(time.Second, time.Minute*2),
+		&workqueue.BucketRateLimiter{Limiter: rate.NewLimiter(rate.Limit(2), 5)},
+	)
+	return &clusterReconcileWorker{
+		cc:          cc,
+		queue:       workqueue.NewNamedRateLimitingQueue(rateLimiter, fmt.Sprintf("cluster-%s", cc.GetClusterId())),
+		reconcilers: reconcilers,
+		lockObj:     &sync.RWMutex{},
+		results:     make(map[string]entities.ReconcileResult),
+		stopChan:    make(chan struct{}),
+	}, nil
+}
+
+func (w *clusterReconcileWorker) Start() {
+	go w.run()
+	go w.lead()
+}
+
+//lead keeps campaigning for the leadership of this cluster until the worker has been stopped.
+func (w *clusterReconcileWorker) lead() {
+	for {
+		err := w.campaign()
+		if err != nil {
+			logrus.Warnf("Failed to campaign for reconciling cluster %s, error: %s", w.cc.GetClusterId(), err.Error())
+		}
+		select {
+		case <-w.stopChan:
+			return
+		case <-time.After(reconcileInterval):
+		}
+	}
+}
+
+//campaign takes the leader key of this cluster with a lease, then it enqueues works periodically as long as the lease is alive.
+//It returns nil immediately if another API server is holding the key.
+func (w *clusterReconcileWorker) campaign() error {
+	ctx, cancel := context.WithTimeout(context.Background(), w.cc.sd.GetRequestTimeoutDuration())
+	defer cancel()
+	lease := w.cc.sd.NewLease()
+	defer lease.Close()
+	grantRsp, err := lease.Grant(ctx, leaderLeaseTTL)
+	if err != nil {
+		return fmt.Errorf("Could not grant a new lease to remote storage driver, error: %s", err.Error())
+	}
+	//revoking lease removes the leader key, the leadership is handed over to another API server immediately.
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), w.cc.sd.GetRequestTimeoutDuration())
+		defer cancel()
+		_, _ = lease.Revoke(ctx, grantRsp.ID)
+	}()
+	hostname, _ := os.Hostname()
+	key := fmt.Sprintf(leaderElectionKey, w.cc.GetClusterId())
+	rsp, err := w.cc.sd.Txn(ctx).
+		If(clientv3.Compare(clientv3.CreateRevision(key), "=", 0)).
+		Then(clientv3.OpPut(key, fmt.Sprintf("%s/%d", hostname, os.Getpid()), clientv3.WithLease(grantRsp.ID))).
+		Commit()
+	if err != nil {
+		return err
+	}
+	if !rsp.Succeeded {
+		return nil
+	}
+	keepAliveCtx, stopKeepAlive := context.WithCancel(context.Background())
+	defer stopKeepAlive()
+	keepAliveChan, err := lease.KeepAlive(keepAliveCtx, grantRsp.ID)
+	if err != nil {
+		return err
+	}
+	logrus.Infof("Elected as the leader of reconciling cluster %s.", w.cc.GetClusterId())
+	atomic.StoreUint32(&w.isLeader, 1)
+	defer atomic.StoreUint32(&w.isLeader, 0)
+	w.enqueueAll()
+	ticker := time.NewTicker(reconcileInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.stopChan:
+			return nil
+		case _, isOK := <-keepAliveChan:
+			if !isOK {
+				return errors.New("Lost the leadership, the lease of leader key has expired")
+			}
+		case <-ticker.C:
+			w.enqueueAll()
+		}
+	}
+}
+
+func (w *clusterReconcileWorker) isLeading() bool {
+	return atomic.LoadUint32(&w.isLeader) == 1
+}
+
+func (w *clusterReconcileWorker) enqueueAll() {
+	w.Enqueue(reconcileKey)
+	w.Enqueue(upgradeKey)
+	w.Enqueue(snapshotKey)
+	//bootstrap tokens requested through other API servers.
+	for _, agentId := range w.cc.getPendingBootstrapTokenAgents() {
+		w.Enqueue(bootstrapTokenKeyPrefix + agentId)
+	}
+	for _, agentId := range w.cc.getUnfinishedDecommissions() {
+		w.Enqueue(decommissionKeyPrefix + agentId)
+	}
+}
+
+func (w *clusterReconcileWorker) Stop() {
+	close(w.stopChan)
+	w.queue.ShutDown()
+}
+
+func (w *clusterReconcileWorker) Enqueue(key string) {
+	//failed item has already been scheduled with its backoff delay.
+	if w.queue.NumRequeues(key) > 0 {
+		return
+	}
+	w.queue.Add(key)
+}
+
+func (w *clusterReconcileWorker) GetResults() []entities.ReconcileResult {
+	w.lockObj.RLock()
+	defer w.lockObj.RUnlock()
+	results := make([]entities.ReconcileResult, 0, len(w.results))
+	for _, v := range w.results {
+		results = append(results, v)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Name < results[j].Name
+	})
+	return results
+}
+
+func (w *clusterReconcileWorker) run() {
+	for w.processNextItem() {
+	}
+}
+
+func (w *clusterReconcileWorker) processNextItem() bool {
+	item, shutdown := w.queue.Get()
+	if shutdown {
+		return false
+	}
+	defer w.queue.Done(item)
+	key := item.(string)
+	err := w.handle(key)
+	if err != nil {
+		logrus.Warnf("Failed to handle reconciliation %s of cluster %s, retries: %d, error: %s", key, w.cc.GetClusterId(), w.queue.NumRequeues(item), err.Error())
+		w.queue.AddRateLimited(item)
+		return true
+	}
+	w.queue.Forget(item)
+	return true
+}
+
+func (w *clusterReconcileWorker) handle(key string) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("Occurred an unhandled exception: %v", r)
+		}
+	}()
+	//works enqueued by events and agent requests are dropped unless this API server is the leader.
+	if w.cc.isDisposedNow() || !w.isLeading() {
+		return nil
+	}
+	if strings.HasPrefix(key, bootstrapTokenKeyPrefix) {
+		return w.cc.createKubeletBootstrapToken(strings.TrimPrefix(key, bootstrapTokenKeyPrefix))
+	}
+	if strings.HasPrefix(key, decommissionKeyPrefix) {
+		return w.cc.reconcileDecommission(strings.TrimPrefix(key, decommissionKeyPrefix))
+	}
+	if key == upgradeKey {
+		return w.cc.reconcileUpgrade()
+	}
+	if key == snapshotKey {
+		return w.cc.reconcileSnapshots()
+	}
+	return w.reconcile()
+}
+
+func (w *clusterReconcileWorker) reconcile() error {
+	//all of cluster-level reconcilers require at least one provisioned Kubernetes master.
+	if w.cc.cache.GetFirstProvisionedKubernetesMasterAgent() == nil {
+		return nil
+	}
+	failed := map[string]bool{}
+	for i := 0; i < len(w.reconcilers); i++ {
+		r := w.reconcilers[i]
+		hasFailedDependency := false
+		for _, dep := range r.DependsOn {
+			if failed[dep] {
+				hasFailedDependency = true
+				break
+			}
+		}
+		if hasFailedDependency {
+			failed[r.Name] = true
+			continue
+		}
+		err := w.record(r.Name, func() error {
+			return r.Reconciler.Reconcile(w.cc, w.cc.cache)
+		})
+		if err != nil {
+			failed[r.Name] = true
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%d reconciler(s) have not been succeed", len(failed))
+	}
+	return nil
+}
+
+func (w *clusterReconcileWorker) record(name string, f func() error) error {
+	startTime := time.Now()
+	err := f()
+	result := entities.ReconcileResult{
+		Name:        name,
+		Succeed:     err == nil,
+		LastRunTime: startTime,
+		CostMs:      int64(time.Since(startTime) / time.Millisecond),
+	}
+	if err != nil {
+		result.Reason = err.Error()
+	}
+	w.lockObj.Lock()
+	w.results[name] = result
+	w.lockObj.Unlock()
+	return err
+}
@@ -1,10 +1,183 @@
 package cache
 
 import (
+	"fmt"
 	"github.com/g0194776/lightningmonkey/pkg/entities"
+	"sync"
 )
 
+type StrategyKind int
+
+const (
+	StrategyKind_AgentJob          StrategyKind = iota //per-agent job, it's evaluated during agent querying its next job.
+	StrategyKind_ClusterReconciler                     //cluster-level reconciler, it's running in the background loop.
+)
+
+//ClusterJobStrategy decides the next job of agent, it declares the strategies which must be evaluated before it.
 type ClusterJobStrategy interface {
 	GetStrategyName() string
+	GetDependencies() []string
 	CanDeploy(cc ClusterController, agent entities.LightningMonkeyAgent, cache *AgentCache) (entities.ConditionCheckedResult, string, map[string]string, error)
 }
+
+//ClusterReconciler converges cluster-level resources, it never be called by agent requests.
+type ClusterReconciler interface {
+	GetStrategyName() string
+	GetDependencies() []string
+	Reconcile(cc ClusterController, cache *AgentCache) error
+}
+
+//StrategyRegistration is built from the registered strategy, its name and dependencies are declared by the implementation.
+type StrategyRegistration struct {
+	Name       string
+	DependsOn  []string
+	Kind       StrategyKind
+	Job        ClusterJobStrategy //required when kind is StrategyKind_AgentJob.
+	Reconciler ClusterReconciler  //required when kind is StrategyKind_ClusterReconciler.
+}
+
+type StrategyRegistry struct {
+	lockObj       *sync.Mutex
+	registrations []StrategyRegistration
+}
+
+var (
+	DefaultStrategyRegistry = NewStrategyRegistry()
+)
+
+func init() {
+	jobs := []ClusterJobStrategy{
+		&ClusterUpgradeJobStrategy{},
+		&ETCDRestoreJobStrategy{},
+		&ETCDMembershipJobStrategy{},
+		&ETCDSnapshotJobStrategy{},
+		&ClusterETCDJobStrategy{},
+		&ClusterKubernetesMasterJobStrategy{},
+		&HAJobStrategy{},
+		&HABackendsJobStrategy{},
+		&ClusterKubernetesMinionJobStrategy{},
+	}
+	for i := 0; i < len(jobs); i++ {
+		if err := DefaultStrategyRegistry.RegisterJob(jobs[i]); err != nil {
+			panic(err)
+		}
+	}
+	reconcilers := []ClusterReconciler{
+		&ClusterKubernetesNetworkStackJobStrategy{},
+		&ClusterKubernetesDNSJobStrategy{},
+		&EnableMonitorsJobStrategy{},
+		&ExtensibilityDeploymentJobStrategy{},
+		&MetricsServerAddStaticRouteStrategy{},
+	}
+	for i := 0; i < len(reconcilers); i++ {
+		if err := DefaultStrategyRegistry.RegisterReconciler(reconcilers[i]); err != nil {
+			panic(err)
+		}
+	}
+}
+
+func NewStrategyRegistry() *StrategyRegistry {
+	return &StrategyRegistry{lockObj: &sync.Mutex{}}
+}
+
+//RegisterJob registers a per-agent job strategy.
+func (sr *StrategyRegistry) RegisterJob(js ClusterJobStrategy) error {
+	if js == nil {
+		return fmt.Errorf("Job strategy cannot be nil!")
+	}
+	return sr.register(StrategyRegistration{Name: js.GetStrategyName(), DependsOn: js.GetDependencies(), Kind: StrategyKind_AgentJob, Job: js})
+}
+
+//RegisterReconciler registers a cluster-level reconciler.
+func (sr *StrategyRegistry) RegisterReconciler(r ClusterReconciler) error {
+	if r == nil {
+		return fmt.Errorf("Cluster reconciler cannot be nil!")
+	}
+	return sr.register(StrategyRegistration{Name: r.GetStrategyName(), DependsOn: r.GetDependencies(), Kind: StrategyKind_ClusterReconciler, Reconciler: r})
+}
+
+func (sr *StrategyRegistry) register(r StrategyRegistration) error {
+	if r.Name == "" {
+		return fmt.Errorf("Strategy name cannot be empty!")
+	}
+	sr.lockObj.Lock()
+	defer sr.lockObj.Unlock()
+	for i := 0; i < len(sr.registrations); i++ {
+		if sr.registrations[i].Name == r.Name {
+			return fmt.Errorf("Duplicated strategy name: %s", r.Name)
+		}
+	}
+	sr.registrations = append(sr.registrations, r)
+	return nil
+}
+
+//Sorted returns all of registered strategies in topological order, registration order is kept among independent strategies.
+func (sr *StrategyRegistry) Sorted() ([]StrategyRegistration, error) {
+	sr.lockObj.Lock()
+	defer sr.lockObj.Unlock()
+	indexes := make(map[string]int, len(sr.registrations))
+	for i := 0; i < len(sr.registrations); i++ {
+		indexes[sr.registrations[i].Name] = i
+	}
+	inDegrees := make([]int, len(sr.registrations))
+	dependents := make([][]int, len(sr.registrations))
+	for i := 0; i < len(sr.registrations); i++ {
+		for _, dep := range sr.registrations[i].DependsOn {
+			j, isOK := indexes[dep]
+			if !isOK {
+				return nil, fmt.Errorf("Strategy: %s depends on an unknown strategy: %s", sr.registrations[i].Name, dep)
+			}
+			inDegrees[i]++
+			dependents[j] = append(dependents[j], i)
+		}
+	}
+	result := make([]StrategyRegistration, 0, len(sr.registrations))
+	visited := make([]bool, len(sr.registrations))
+	for len(result) < len(sr.registrations) {
+		//always pick the earliest registered strategy which has no any pending dependency.
+		picked := -1
+		for i := 0; i < len(sr.registrations); i++ {
+			if !visited[i] && inDegrees[i] == 0 {
+				picked = i
+				break
+			}
+		}
+		if picked == -1 {
+			return nil, fmt.Errorf("Circular strategy dependencies have been detected!")
+		}
+		visited[picked] = true
+		result = append(result, sr.registrations[picked])
+		for _, j := range dependents[picked] {
+			inDegrees[j]--
+		}
+	}
+	return result, nil
+}
+
+func (sr *StrategyRegistry) GetAgentJobStrategies() ([]ClusterJobStrategy, error) {
+	rs, err := sr.Sorted()
+	if err != nil {
+		return nil, err
+	}
+	strategies := []ClusterJobStrategy{}
+	for i := 0; i < len(rs); i++ {
+		if rs[i].Kind == StrategyKind_AgentJob {
+			strategies = append(strategies, rs[i].Job)
+		}
+	}
+	return strategies, nil
+}
+
+func (sr *StrategyRegistry) GetClusterReconcilers() ([]StrategyRegistration, error) {
+	rs, err := sr.Sorted()
+	if err != nil {
+		return nil, err
+	}
+	reconcilers := []StrategyRegistration{}
+	for i := 0; i < len(rs); i++ {
+		if rs[i].Kind == StrategyKind_ClusterReconciler {
+			reconcilers = append(reconcilers, rs[i])
+		}
+	}
+	return reconcilers, nil
+}
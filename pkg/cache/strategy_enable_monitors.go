@@ -1,6 +1,9 @@
 package cache
 
-import "github.com/g0194776/lightningmonkey/pkg/entities"
+import (
+	"errors"
+	"github.com/g0194776/lightningmonkey/pkg/entities"
+)
 
 type EnableMonitorsJobStrategy struct {
 }
@@ -9,7 +12,15 @@ func (js *EnableMonitorsJobStrategy) GetStrategyName() string {
 	return "Enable Cluster Monitors"
 }
 
-func (js *EnableMonitorsJobStrategy) CanDeploy(cc ClusterController, agent entities.LightningMonkeyAgent, cache *AgentCache) (entities.ConditionCheckedResult, string, map[string]string, error) {
+func (js *EnableMonitorsJobStrategy) GetDependencies() []string {
+	return []string{entities.AgentJob_Deploy_Master}
+}
+
+func (js *EnableMonitorsJobStrategy) Reconcile(cc ClusterController, cache *AgentCache) error {
+	err := cc.InitializeKubernetesClient()
+	if err != nil {
+		return errors.New("Failed to initialize Kubernetes client, error: " + err.Error())
+	}
 	cc.EnableMonitors()
-	return entities.ConditionInapplicable, "", nil, nil
+	return nil
 }
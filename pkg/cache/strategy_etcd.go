@@ -13,8 +13,18 @@ func (js *ClusterETCDJobStrategy) GetStrategyName() string {
 	return entities.AgentJob_Deploy_ETCD
 }
 
+func (js *ClusterETCDJobStrategy) GetDependencies() []string {
+	return nil
+}
+
 func (js *ClusterETCDJobStrategy) CanDeploy(cc ClusterController, agent entities.LightningMonkeyAgent, cache *AgentCache) (entities.ConditionCheckedResult, string, map[string]string, error) {
 	clusterSettings := cc.GetSettings()
+	//an existing ETCD cluster only accepts the member which has been added by a healthy member.
+	if agent.HasETCDRole && !agent.State.HasProvisionedETCD {
+		if members := cache.GetETCDMembers(); len(members) > 0 {
+			return joinETCDCluster(clusterSettings.ExpectedETCDCount, agent, cache, members)
+		}
+	}
 	//skipped, when satisfy expected count of ETCD
 	if cache.GetTotalProvisionedCountByRole(entities.AgentRole_ETCD) >= clusterSettings.ExpectedETCDCount {
 		return entities.ConditionInapplicable, "", nil, nil
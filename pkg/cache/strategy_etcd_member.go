@@ -0,0 +1,175 @@
+package cache
+
+import (
+	"fmt"
+	"github.com/g0194776/lightningmonkey/pkg/entities"
+	"github.com/g0194776/lightningmonkey/pkg/utils"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+//ETCDMembershipJobStrategy grows or shrinks an existing ETCD cluster one member at a time,
+//membership changes are performed by one of healthy members.
+type ETCDMembershipJobStrategy struct {
+}
+
+type etcdMembershipChange struct {
+	operation string
+	executor  entities.LightningMonkeyAgent
+	member    entities.ETCDMember
+}
+
+func (js *ETCDMembershipJobStrategy) GetStrategyName() string {
+	return entities.AgentJob_ETCD_Member
+}
+
+func (js *ETCDMembershipJobStrategy) GetDependencies() []string {
+	return nil
+}
+
+func (js *ETCDMembershipJobStrategy) CanDeploy(cc ClusterController, agent entities.LightningMonkeyAgent, cache *AgentCache) (entities.ConditionCheckedResult, string, map[string]string, error) {
+	//nothing to do until the ETCD cluster has been bootstrapped.
+	if !agent.HasETCDRole || !agent.State.HasProvisionedETCD || len(cache.GetETCDMembers()) == 0 {
+		return entities.ConditionInapplicable, "", nil, nil
+	}
+	change, _ := planETCDMembershipChange(cc.GetSettings().ExpectedETCDCount, cache)
+	if change == nil || change.executor.Id != agent.Id {
+		return entities.ConditionInapplicable, "", nil, nil
+	}
+	args := map[string]string{"operation": change.operation}
+	if change.operation == entities.ETCDMemberOperation_Add {
+		args["name"] = change.member.Name
+		args["peer-url"] = change.member.PeerURL
+	} else {
+		args["member-id"] = change.member.Id
+	}
+	return entities.ConditionConfirmed, "", args, nil
+}
+
+//planETCDMembershipChange decides the next membership change, it returns a reason if the change is refused.
+//Members of offline agents are removed only if the cluster exceeds expected size or a new member is waiting for joining,
+//healthy members are removed only after the expected size has been lowered.
+func planETCDMembershipChange(expectedCount int, cache *AgentCache) (*etcdMembershipChange, string) {
+	members := cache.GetETCDMembers()
+	if len(members) == 0 {
+		return nil, ""
+	}
+	agents := cache.GetAgents(entities.AgentRole_ETCD, entities.AgentStatusFlag_Running)
+	agentsByAddress := map[string]entities.LightningMonkeyAgent{}
+	for i := 0; i < len(agents); i++ {
+		agentsByAddress[agents[i].State.LastReportIP] = agents[i]
+	}
+	var executor *entities.LightningMonkeyAgent
+	healthyCount := 0
+	memberAddresses := map[string]bool{}
+	for i := 0; i < len(members); i++ {
+		addr := getETCDMemberAddress(members[i])
+		memberAddresses[addr] = true
+		a, isOK := agentsByAddress[addr]
+		if !isOK || !members[i].IsStarted || !a.State.HasProvisionedETCD {
+			continue
+		}
+		healthyCount++
+		if executor == nil || a.Id < executor.Id {
+			executor = &a
+		}
+	}
+	if executor == nil {
+		return nil, "Waiting, no any healthy ETCD member could perform membership changes."
+	}
+	joiners := []entities.LightningMonkeyAgent{}
+	for i := 0; i < len(agents); i++ {
+		if !agents[i].State.HasProvisionedETCD && !memberAddresses[agents[i].State.LastReportIP] {
+			joiners = append(joiners, agents[i])
+		}
+	}
+	for i := 0; i < len(members); i++ {
+		if _, isOK := agentsByAddress[getETCDMemberAddress(members[i])]; isOK {
+			continue
+		}
+		if len(members) <= expectedCount && len(joiners) == 0 {
+			continue
+		}
+		if !utils.CanRemoveETCDMember(len(members), healthyCount, false) {
+			return nil, fmt.Sprintf("Refused removing ETCD member %s, it would break the quorum(%d/%d).", members[i].Name, healthyCount, len(members))
+		}
+		return &etcdMembershipChange{operation: entities.ETCDMemberOperation_Remove, executor: *executor, member: members[i]}, ""
+	}
+	for i := 0; i < len(members); i++ {
+		if !members[i].IsStarted {
+			return nil, fmt.Sprintf("Waiting, ETCD member %s has not been started yet.", members[i].Name)
+		}
+	}
+	if len(members) > expectedCount {
+		//shrinks the cluster one healthy member at a time, the executor is never removed by itself.
+		var victim *entities.ETCDMember
+		var victimAgent entities.LightningMonkeyAgent
+		for i := 0; i < len(members); i++ {
+			a := agentsByAddress[getETCDMemberAddress(members[i])]
+			if a.Id == executor.Id {
+				continue
+			}
+			//prefers the dedicated ETCD nodes, then the newest agent.
+			if victim == nil || (victimAgent.HasMasterRole && !a.HasMasterRole) || (victimAgent.HasMasterRole == a.HasMasterRole && a.Id > victimAgent.Id) {
+				victim = &members[i]
+				victimAgent = a
+			}
+		}
+		if victim == nil {
+			return nil, ""
+		}
+		if !utils.CanRemoveETCDMember(len(members), healthyCount, true) {
+			return nil, fmt.Sprintf("Refused removing ETCD member %s, it would break the quorum(%d/%d).", victim.Name, healthyCount, len(members))
+		}
+		return &etcdMembershipChange{operation: entities.ETCDMemberOperation_Remove, executor: *executor, member: *victim}, ""
+	}
+	if len(joiners) == 0 || len(members) >= expectedCount {
+		return nil, ""
+	}
+	if !utils.CanAddETCDMember(len(members), healthyCount) {
+		return nil, fmt.Sprintf("Refused adding ETCD member, it would break the quorum(%d/%d).", healthyCount, len(members)+1)
+	}
+	addr := joiners[0].State.LastReportIP
+	return &etcdMembershipChange{
+		operation: entities.ETCDMemberOperation_Add,
+		executor:  *executor,
+		member:    entities.ETCDMember{Name: utils.GetETCDMemberName(addr), PeerURL: fmt.Sprintf("https://%s:2380", addr)},
+	}, ""
+}
+
+//getETCDMemberAddress returns the host address of given member's peer URL.
+func getETCDMemberAddress(m entities.ETCDMember) string {
+	u, err := url.Parse(m.PeerURL)
+	if err != nil {
+		return ""
+	}
+	return u.Hostname()
+}
+
+func getETCDMemberAddresses(members []entities.ETCDMember) []string {
+	addrs := []string{}
+	for i := 0; i < len(members); i++ {
+		addrs = append(addrs, getETCDMemberAddress(members[i]))
+	}
+	sort.Strings(addrs)
+	return addrs
+}
+
+func joinETCDCluster(expectedCount int, agent entities.LightningMonkeyAgent, cache *AgentCache, members []entities.ETCDMember) (entities.ConditionCheckedResult, string, map[string]string, error) {
+	addrs := getETCDMemberAddresses(members)
+	for i := 0; i < len(addrs); i++ {
+		if addrs[i] == agent.State.LastReportIP {
+			//the member has been added, start it with existing cluster state.
+			return entities.ConditionConfirmed, "", map[string]string{"addresses": strings.Join(addrs, ","), "state": "existing"}, nil
+		}
+	}
+	if cache.GetTotalProvisionedCountByRole(entities.AgentRole_ETCD) >= expectedCount {
+		return entities.ConditionInapplicable, "", nil, nil
+	}
+	_, reason := planETCDMembershipChange(expectedCount, cache)
+	if reason == "" {
+		reason = "Waiting, ETCD member of this node is being added."
+	}
+	return entities.ConditionNotConfirmed, reason, nil, nil
+}
@@ -0,0 +1,60 @@
+package cache
+
+import (
+	"fmt"
+	"github.com/g0194776/lightningmonkey/pkg/entities"
+	"sort"
+	"strings"
+)
+
+//ETCDRestoreJobStrategy dispatches restore jobs to all of ETCD members at the same time,
+//members must be restored from the same snapshot before any of them could form a new cluster.
+type ETCDRestoreJobStrategy struct {
+}
+
+func (js *ETCDRestoreJobStrategy) GetStrategyName() string {
+	return entities.AgentJob_ETCD_Restore
+}
+
+func (js *ETCDRestoreJobStrategy) GetDependencies() []string {
+	return nil
+}
+
+func (js *ETCDRestoreJobStrategy) CanDeploy(cc ClusterController, agent entities.LightningMonkeyAgent, cache *AgentCache) (entities.ConditionCheckedResult, string, map[string]string, error) {
+	if !agent.HasETCDRole {
+		return entities.ConditionInapplicable, "", nil, nil
+	}
+	s := cc.GetSnapshots()
+	if s == nil || s.Restore == nil || s.Restore.Status != entities.ETCDRestoreStatus_Running {
+		return entities.ConditionInapplicable, "", nil, nil
+	}
+	r := s.Restore
+	node, isOK := r.Nodes[agent.Id]
+	if !isOK || node.HasFinished() {
+		return entities.ConditionInapplicable, "", nil, nil
+	}
+	if agent.State != nil && agent.State.Restore != nil && agent.State.Restore.RestoreId == r.Id {
+		if agent.State.Restore.Status == entities.ETCDRestoreNodeStatus_Restoring {
+			return entities.ConditionNotConfirmed, fmt.Sprintf("Waiting, restoring ETCD snapshot %s...", r.SnapshotName), nil, nil
+		}
+		return entities.ConditionNotConfirmed, "Waiting, restore result of ETCD is being recorded.", nil, nil
+	}
+	snapshot := s.GetSnapshot(r.SnapshotName)
+	if snapshot == nil {
+		return entities.ConditionNotConfirmed, fmt.Sprintf("Waiting, ETCD snapshot %s not found.", r.SnapshotName), nil, nil
+	}
+	addresses := []string{}
+	for _, n := range r.Nodes {
+		addresses = append(addresses, n.Address)
+	}
+	sort.Strings(addresses)
+	return entities.ConditionConfirmed, "", map[string]string{
+		"restore-id":  r.Id,
+		"name":        snapshot.Name,
+		"destination": snapshot.Destination,
+		"location":    snapshot.Location,
+		"api-server":  snapshot.APIServer,
+		"addresses":   strings.Join(addresses, ","),
+		"token":       r.Token,
+	}, nil
+}
@@ -0,0 +1,69 @@
+package cache
+
+import (
+	"fmt"
+	"github.com/g0194776/lightningmonkey/pkg/entities"
+	"strconv"
+	"time"
+)
+
+//ETCDSnapshotJobStrategy dispatches snapshot jobs to the elected ETCD agent by the backup policy of cluster.
+type ETCDSnapshotJobStrategy struct {
+}
+
+func (js *ETCDSnapshotJobStrategy) GetStrategyName() string {
+	return entities.AgentJob_ETCD_Snapshot
+}
+
+func (js *ETCDSnapshotJobStrategy) GetDependencies() []string {
+	return nil
+}
+
+func (js *ETCDSnapshotJobStrategy) CanDeploy(cc ClusterController, agent entities.LightningMonkeyAgent, cache *AgentCache) (entities.ConditionCheckedResult, string, map[string]string, error) {
+	//member list is only reported by an agent which has observed a healthy ETCD cluster.
+	if !agent.HasETCDRole || agent.State == nil || !agent.State.HasProvisionedETCD || len(agent.State.ETCDMembers) == 0 {
+		return entities.ConditionInapplicable, "", nil, nil
+	}
+	//the first provisioned ETCD agent is elected for taking snapshots.
+	agents := cache.GetAgents(entities.AgentRole_ETCD, entities.AgentStatusFlag_Provisioned)
+	if len(agents) == 0 || agents[0].Id != agent.Id {
+		return entities.ConditionInapplicable, "", nil, nil
+	}
+	bs := cc.GetSettings().BackupSettings
+	if bs == nil || bs.GetInterval() <= 0 {
+		return entities.ConditionInapplicable, "", nil, nil
+	}
+	var lastTime, triggerTime time.Time
+	token := ""
+	if s := cc.GetSnapshots(); s != nil {
+		//never take snapshots while the data of ETCD is being replaced.
+		if s.Restore != nil && s.Restore.Status == entities.ETCDRestoreStatus_Running {
+			return entities.ConditionInapplicable, "", nil, nil
+		}
+		if len(s.Snapshots) > 0 {
+			lastTime = s.Snapshots[len(s.Snapshots)-1].CreateTime
+		}
+		triggerTime = s.TriggerTime
+		if s.Upload != nil && s.Upload.AgentId == agent.Id {
+			token = s.Upload.Token
+		}
+	}
+	//waiting for the upload credential being issued to this agent by the reconcile worker.
+	if bs.Destination == entities.ETCDSnapshotDestination_APIServer && token == "" {
+		return entities.ConditionInapplicable, "", nil, nil
+	}
+	//the latest snapshot might have not been recorded yet.
+	if agent.State.Snapshot != nil && agent.State.Snapshot.CreateTime.After(lastTime) {
+		lastTime = agent.State.Snapshot.CreateTime
+	}
+	if time.Since(lastTime) < bs.GetInterval() && !triggerTime.After(lastTime) {
+		return entities.ConditionInapplicable, "", nil, nil
+	}
+	return entities.ConditionConfirmed, "", map[string]string{
+		"name":        fmt.Sprintf("snapshot-%s", time.Now().UTC().Format("20060102150405")),
+		"destination": bs.Destination,
+		"directory":   bs.Directory,
+		"retention":   strconv.Itoa(bs.Retention),
+		"token":       token,
+	}, nil
+}
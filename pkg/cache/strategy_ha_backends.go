@@ -0,0 +1,55 @@
+package cache
+
+import (
+	"github.com/g0194776/lightningmonkey/pkg/entities"
+	"sort"
+	"strings"
+)
+
+//HABackendsJobStrategy pushes the latest master addresses to provisioned HA nodes,
+//so that HAProxy follows the masters which are added, replaced or have changed their addresses.
+type HABackendsJobStrategy struct {
+}
+
+func (js *HABackendsJobStrategy) GetStrategyName() string {
+	return entities.AgentJob_HA_Backends
+}
+
+func (js *HABackendsJobStrategy) GetDependencies() []string {
+	return []string{entities.AgentJob_Deploy_HA}
+}
+
+func (js *HABackendsJobStrategy) CanDeploy(cc ClusterController, agent entities.LightningMonkeyAgent, cache *AgentCache) (entities.ConditionCheckedResult, string, map[string]string, error) {
+	//agents which never report their backends are not capable of reloading HAProxy.
+	if !agent.HasHARole || agent.State == nil || !agent.State.HasProvisionedHA || len(agent.State.HABackends) == 0 {
+		return entities.ConditionInapplicable, "", nil, nil
+	}
+	if cc.GetSettings().HASettings == nil {
+		return entities.ConditionInapplicable, "", nil, nil
+	}
+	desired := getDesiredHABackends(cache)
+	//never leave HAProxy without any backend.
+	if len(desired) == 0 || isSameHABackends(desired, agent.State.HABackends) {
+		return entities.ConditionInapplicable, "", nil, nil
+	}
+	return entities.ConditionConfirmed, "", map[string]string{"master-addresses": strings.Join(desired, ",")}, nil
+}
+
+//getDesiredHABackends returns sorted addresses of all of Kubernetes masters.
+func getDesiredHABackends(cache *AgentCache) []string {
+	return cache.GetAgentsAddress(entities.AgentRole_Master, entities.AgentStatusFlag_Whatever)
+}
+
+func isSameHABackends(desired, actual []string) bool {
+	if len(desired) != len(actual) {
+		return false
+	}
+	sorted := append([]string{}, actual...)
+	sort.Strings(sorted)
+	for i := 0; i < len(desired); i++ {
+		if desired[i] != sorted[i] {
+			return false
+		}
+	}
+	return true
+}
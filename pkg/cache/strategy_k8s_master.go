@@ -1,6 +1,9 @@
 package cache
 
-import "github.com/g0194776/lightningmonkey/pkg/entities"
+import (
+	"fmt"
+	"github.com/g0194776/lightningmonkey/pkg/entities"
+)
 
 type ClusterKubernetesMasterJobStrategy struct {
 }
@@ -9,12 +12,22 @@ func (js *ClusterKubernetesMasterJobStrategy) GetStrategyName() string {
 	return entities.AgentJob_Deploy_Master
 }
 
+func (js *ClusterKubernetesMasterJobStrategy) GetDependencies() []string {
+	return []string{entities.AgentJob_Deploy_ETCD}
+}
+
 func (js *ClusterKubernetesMasterJobStrategy) CanDeploy(cc ClusterController, agent entities.LightningMonkeyAgent, cache *AgentCache) (entities.ConditionCheckedResult, string, map[string]string, error) {
+	expectedCount := cc.GetSettings().GetExpectedMasterCount()
 	if agent.HasMasterRole && !agent.State.HasProvisionedMasterComponents {
+		//ensures that has enough nodes count of master role before provisioning any of them.
+		if cache.GetTotalCountByRole(entities.AgentRole_Master) < expectedCount {
+			return entities.ConditionNotConfirmed, "Waiting, Not equals required minimum count of Kubernetes master nodes.", nil, nil
+		}
 		return entities.ConditionConfirmed, "", nil, nil
 	}
-	if cache.GetTotalProvisionedCountByRole(entities.AgentRole_Master) <= 0 {
-		return entities.ConditionNotConfirmed, "Waiting, All of agents of Kubernetes master role are not ready yet.", nil, nil
+	//HA & minion jobs are postponed until all of expected masters have been provisioned.
+	if provisionedCount := cache.GetTotalProvisionedCountByRole(entities.AgentRole_Master); provisionedCount < expectedCount {
+		return entities.ConditionNotConfirmed, fmt.Sprintf("Waiting, Kubernetes master nodes are not ready yet(%d/%d).", provisionedCount, expectedCount), nil, nil
 	}
 	return entities.ConditionInapplicable, "", nil, nil
 }
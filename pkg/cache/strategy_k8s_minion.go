@@ -1,6 +1,8 @@
 package cache
 
 import (
+	"encoding/json"
+	"fmt"
 	"github.com/g0194776/lightningmonkey/pkg/entities"
 	"strings"
 )
@@ -12,17 +14,50 @@ func (js *ClusterKubernetesMinionJobStrategy) GetStrategyName() string {
 	return entities.AgentJob_Deploy_Minion
 }
 
+func (js *ClusterKubernetesMinionJobStrategy) GetDependencies() []string {
+	return []string{entities.AgentJob_Deploy_Master, entities.AgentJob_Deploy_HA}
+}
+
 func (js *ClusterKubernetesMinionJobStrategy) CanDeploy(cc ClusterController, agent entities.LightningMonkeyAgent, cache *AgentCache) (entities.ConditionCheckedResult, string, map[string]string, error) {
 	if agent.HasMinionRole && !agent.State.HasProvisionedMinion {
-		vip := ""
-		masterIps := cache.GetAgentsAddress(entities.AgentRole_Master, entities.AgentStatusFlag_Provisioned)
-		if cc.GetSettings().HASettings != nil {
-			vip = cc.GetSettings().HASettings.VIP
+		args, reason := js.getArguments(cc, agent, cache)
+		if args == nil {
+			return entities.ConditionNotConfirmed, reason, nil, nil
 		}
-		return entities.ConditionConfirmed, "", map[string]string{
-			"addresses":  strings.Join(masterIps, ","),
-			"ha_address": vip,
-		}, nil
+		return entities.ConditionConfirmed, "", args, nil
 	}
 	return entities.ConditionInapplicable, "", nil, nil
 }
+
+//getArguments returns nil arguments with the reason when it is not able to join minion into the cluster yet.
+func (js *ClusterKubernetesMinionJobStrategy) getArguments(cc ClusterController, agent entities.LightningMonkeyAgent, cache *AgentCache) (map[string]string, string) {
+	var pool *entities.NodePoolSettings
+	if agent.NodePool != "" {
+		if pool = cc.GetSettings().GetNodePool(agent.NodePool); pool == nil {
+			return nil, fmt.Sprintf("Waiting, node pool %s is not defined in the cluster settings.", agent.NodePool)
+		}
+	}
+	vip := ""
+	masterIps := cache.GetAgentsAddress(entities.AgentRole_Master, entities.AgentStatusFlag_Provisioned)
+	if cc.GetSettings().HASettings != nil {
+		vip = cc.GetSettings().HASettings.VIP
+	}
+	args := map[string]string{
+		"addresses":  strings.Join(masterIps, ","),
+		"ha_address": vip,
+	}
+	//kubelet registers itself with the labels and taints of node pool.
+	if pool != nil {
+		data, _ := json.Marshal(pool)
+		args["node_pool"] = string(data)
+	}
+	//minion-only node joins cluster through kubelet TLS bootstrap, it never holds any CA private key.
+	if !agent.HasMasterRole && !agent.HasETCDRole {
+		token := cc.RequestKubeletBootstrapToken(agent)
+		if token == "" {
+			return nil, "Waiting, kubelet bootstrap token is being created."
+		}
+		args["bootstrap_token"] = token
+	}
+	return args, ""
+}
@@ -14,35 +14,31 @@ func (js *ClusterKubernetesNetworkStackJobStrategy) GetStrategyName() string {
 	return "NETWORK"
 }
 
-func (js *ClusterKubernetesNetworkStackJobStrategy) CanDeploy(cc ClusterController, agent entities.LightningMonkeyAgent, cache *AgentCache) (entities.ConditionCheckedResult, string, map[string]string, error) {
+func (js *ClusterKubernetesNetworkStackJobStrategy) GetDependencies() []string {
+	return []string{entities.AgentJob_Deploy_Master}
+}
+
+func (js *ClusterKubernetesNetworkStackJobStrategy) Reconcile(cc ClusterController, cache *AgentCache) error {
 	//STEP 1, lazy load, can directly skipped with internal initialization status.
 	err := cc.InitializeKubernetesClient()
-	var wrappedErr error
 	if err != nil {
-		wrappedErr = errors.New("Failed to initialize Kubernetes client, error: " + err.Error())
-		logrus.Error(wrappedErr)
-		return entities.ConditionNotConfirmed, "", nil, wrappedErr
+		return errors.New("Failed to initialize Kubernetes client, error: " + err.Error())
 	}
 	//STEP 2, initialize cluster network controller, also can directly skipped with internal initialization status.
 	err = cc.InitializeNetworkController()
 	if err != nil {
-		wrappedErr = fmt.Errorf("Failed to initialize cluster(%s) network controller, error: %s", cc.GetClusterId(), err.Error())
-		logrus.Error(wrappedErr)
-		return entities.ConditionNotConfirmed, "", nil, wrappedErr
+		return fmt.Errorf("Failed to initialize cluster(%s) network controller, error: %s", cc.GetClusterId(), err.Error())
 	}
 	//Kubernetes client has been initialized successfully, try to commit YAML-formatted kube-router resource into cluster.
 	nc := cc.GetNetworkController()
 	if hasInstalled, err := nc.HasInstalled(); err != nil {
-		logrus.Error(err)
-		return entities.ConditionNotConfirmed, "", nil, err
+		return err
 	} else if !hasInstalled {
 		logrus.Infof("Try deploying network stack(%s) to cluster %s ......", nc.GetName(), cc.GetClusterId())
 		err = nc.Install()
 		if err != nil {
-			wrappedErr = fmt.Errorf("Failed to deploy Kubernetes network stack(%s) to cluster %s, error: %s", nc.GetName(), cc.GetClusterId(), err.Error())
-			logrus.Error(wrappedErr)
-			return entities.ConditionNotConfirmed, "", nil, wrappedErr
+			return fmt.Errorf("Failed to deploy Kubernetes network stack(%s) to cluster %s, error: %s", nc.GetName(), cc.GetClusterId(), err.Error())
 		}
 	}
-	return entities.ConditionInapplicable, "", nil, nil
+	return nil
 }
@@ -20,45 +20,46 @@ func (js *MetricsServerAddStaticRouteStrategy) GetStrategyName() string {
 	return "Metrics-Server Static Routing"
 }
 
-func (js *MetricsServerAddStaticRouteStrategy) CanDeploy(cc ClusterController, agent entities.LightningMonkeyAgent, cache *AgentCache) (entities.ConditionCheckedResult, string, map[string]string, error) {
-	if !agent.HasMasterRole || !agent.State.HasProvisionedMasterComponents {
-		return entities.ConditionInapplicable, "", nil, nil
-	}
+func (js *MetricsServerAddStaticRouteStrategy) GetDependencies() []string {
+	return []string{"Extensibility", "Enable Cluster Monitors"}
+}
+
+func (js *MetricsServerAddStaticRouteStrategy) Reconcile(cc ClusterController, cache *AgentCache) error {
 	cs := cc.GetSettings()
 	if cs.ExtensionalDeployments == nil || len(cs.ExtensionalDeployments) == 0 {
-		return entities.ConditionInapplicable, "", nil, nil
+		return nil
 	}
 	if _, isOK := cs.ExtensionalDeployments[entities.EXT_DEPLOYMENT_METRICSERVER]; !isOK {
-		return entities.ConditionInapplicable, "", nil, nil
+		return nil
+	}
+	masters := cache.GetAgents(entities.AgentRole_Master, entities.AgentStatusFlag_Provisioned)
+	if len(masters) == 0 {
+		return nil
 	}
 	wps := cc.GetWachPoints()
 	if !checkMetricsServerHealthy(wps) {
-		wrappedErr := fmt.Errorf("Cluster(%s)'s extensional deployment component: %s is not healthy yet!", cs.Id, entities.EXT_DEPLOYMENT_METRICSERVER)
-		logrus.Error(wrappedErr)
-		return entities.ConditionNotConfirmed, "", nil, wrappedErr
+		return fmt.Errorf("Cluster(%s)'s extensional deployment component: %s is not healthy yet!", cs.Id, entities.EXT_DEPLOYMENT_METRICSERVER)
 	}
 	err := cc.InitializeKubernetesClient()
 	if err != nil {
-		wrappedErr := errors.New("Failed to initialize Kubernetes client, error: " + err.Error())
-		logrus.Error(wrappedErr)
-		return entities.ConditionNotConfirmed, "", nil, wrappedErr
+		return errors.New("Failed to initialize Kubernetes client, error: " + err.Error())
 	}
 	nsi, err := cc.GetNodesInformation()
 	if err != nil {
-		wrappedErr := fmt.Errorf("Failed to list Kubernetes cluster %s nodes, error: %s", cc.GetSettings().Id, err.Error())
-		logrus.Error(wrappedErr)
-		return entities.ConditionNotConfirmed, "", nil, wrappedErr
+		return fmt.Errorf("Failed to list Kubernetes cluster %s nodes, error: %s", cc.GetSettings().Id, err.Error())
 	}
 	if len(nsi) == 0 {
 		logrus.Warnf("Got empty value of Kubernetes cluster %s node list!", cc.GetSettings().Id)
-		return entities.ConditionInapplicable, "", nil, nil
+		return nil
 	}
-	//synchronously call agent's API for injecting all of listed node information.
-	err = generateSystemRoutingRules(agent, nsi)
-	if err != nil {
-		logrus.Errorf("Failed to make a call to the remote Lightning Monkey's agent instance: %s, error: %s", agent.Id, err.Error())
+	//call all of master agents' API for injecting all of listed node information.
+	for i := 0; i < len(masters); i++ {
+		err = generateSystemRoutingRules(masters[i], nsi)
+		if err != nil {
+			logrus.Errorf("Failed to make a call to the remote Lightning Monkey's agent instance: %s, error: %s", masters[i].Id, err.Error())
+		}
 	}
-	return entities.ConditionInapplicable, "", nil, nil
+	return nil
 }
 
 func generateSystemRoutingRules(agent entities.LightningMonkeyAgent, nodes []entities.KubernetesNodeInfo) error {
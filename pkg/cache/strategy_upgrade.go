@@ -0,0 +1,121 @@
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/g0194776/lightningmonkey/pkg/entities"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultKubeletUpgradeBatchSize = 1
+)
+
+//ClusterUpgradeJobStrategy dispatches upgrade jobs stage by stage, it's always evaluated before any of deployment strategies.
+type ClusterUpgradeJobStrategy struct {
+}
+
+func (js *ClusterUpgradeJobStrategy) GetStrategyName() string {
+	return entities.AgentJob_Upgrade
+}
+
+func (js *ClusterUpgradeJobStrategy) GetDependencies() []string {
+	return nil
+}
+
+func (js *ClusterUpgradeJobStrategy) CanDeploy(cc ClusterController, agent entities.LightningMonkeyAgent, cache *AgentCache) (entities.ConditionCheckedResult, string, map[string]string, error) {
+	u := cc.GetUpgrade()
+	if u == nil || u.Status != entities.UpgradeStatus_Running {
+		return entities.ConditionInapplicable, "", nil, nil
+	}
+	stage := u.GetCurrentStage()
+	node, isOK := u.Nodes[agent.Id]
+	//agents joined after the upgrade has started are not the part of it.
+	if stage == "" || !isOK || !node.IsParticipant(stage) || node.HasCompleted(stage) {
+		return entities.ConditionInapplicable, "", nil, nil
+	}
+	version := u.GetTargetVersion()
+	if agent.State != nil && node.IsReportedBy(agent.State.Upgrade, version, stage) {
+		if agent.State.Upgrade.Status == entities.UpgradeNodeStatus_Upgrading {
+			return entities.ConditionNotConfirmed, fmt.Sprintf("Waiting, upgrading %s to version %s...", stage, version), nil, nil
+		}
+		return entities.ConditionNotConfirmed, fmt.Sprintf("Waiting, upgrade result of %s is being recorded.", stage), nil, nil
+	}
+	if batchSize := getUpgradeBatchSize(u, stage); batchSize > 0 {
+		//upgrade nodes in batches, the first participants which have not completed yet take the turn.
+		upgrading := []string{}
+		for _, id := range getSortedUpgradeNodeIds(u) {
+			if id == agent.Id {
+				break
+			}
+			if n := u.Nodes[id]; n.IsParticipant(stage) && !n.HasCompleted(stage) {
+				upgrading = append(upgrading, n.Hostname)
+			}
+		}
+		if len(upgrading) >= batchSize {
+			return entities.ConditionNotConfirmed, fmt.Sprintf("Waiting, node(s) %s are upgrading %s.", strings.Join(upgrading[:batchSize], ", "), stage), nil, nil
+		}
+	}
+	images := u.GetTargetImages()
+	images.HTTPDownloadToken = entities.HTTPDockerImageDownloadToken
+	data, err := json.Marshal(images)
+	if err != nil {
+		return entities.ConditionNotConfirmed, "", nil, err
+	}
+	args := map[string]string{
+		"version": version,
+		"stage":   stage,
+		"attempt": strconv.Itoa(node.Attempt),
+		"images":  string(data),
+	}
+	switch stage {
+	case entities.UpgradeStage_ETCD:
+		args["addresses"] = strings.Join(cache.GetAgentsAddress(entities.AgentRole_ETCD, entities.AgentStatusFlag_Whatever), ",")
+		//membership may have been changed since the cluster was bootstrapped.
+		if members := cache.GetETCDMembers(); len(members) > 0 {
+			args["addresses"] = strings.Join(getETCDMemberAddresses(members), ",")
+			args["state"] = "existing"
+		}
+	case entities.UpgradeStage_HA:
+		for k, v := range (&HAJobStrategy{}).getArguments(cc, agent, cache) {
+			args[k] = v
+		}
+	case entities.UpgradeStage_Kubelet:
+		if agent.HasMinionRole {
+			minionArgs, reason := (&ClusterKubernetesMinionJobStrategy{}).getArguments(cc, agent, cache)
+			if minionArgs == nil {
+				return entities.ConditionNotConfirmed, reason, nil, nil
+			}
+			for k, v := range minionArgs {
+				args[k] = v
+			}
+		}
+	}
+	return entities.ConditionConfirmed, "", args, nil
+}
+
+//getUpgradeBatchSize returns how many nodes are allowed to upgrade given stage at the same time, 0 means no limitation.
+//Upgrading all of control plane nodes or kubelets at the same time would break the availability.
+func getUpgradeBatchSize(u *entities.ClusterUpgrade, stage string) int {
+	switch stage {
+	case entities.UpgradeStage_ETCD, entities.UpgradeStage_Master, entities.UpgradeStage_HA:
+		return 1
+	case entities.UpgradeStage_Kubelet:
+		if u.KubeletBatchSize > 0 {
+			return u.KubeletBatchSize
+		}
+		return defaultKubeletUpgradeBatchSize
+	}
+	return 0
+}
+
+func getSortedUpgradeNodeIds(u *entities.ClusterUpgrade) []string {
+	ids := make([]string, 0, len(u.Nodes))
+	for id := range u.Nodes {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
@@ -6,28 +6,34 @@ import (
 	"encoding/json"
 	"fmt"
 	"github.com/g0194776/lightningmonkey/pkg/entities"
+	"github.com/g0194776/lightningmonkey/pkg/storage"
 	"io/ioutil"
 	"net/http"
 	"time"
 )
 
-//TransferAgentToCluster allowed to transfer an agent to another one cluster.
-func (cm *ClusterManager) TransferAgentToCluster(oldClusterId string, newClusterId string, agent *entities.LightningMonkeyAgent, isETCDRole, isMasterRole, isMinionRole, isHARole bool) error {
+//TransferAgentToCluster allowed to transfer an agent to another one cluster, the node pool is only used by minion role
+//and nil node pool keeps the agent's current one.
+func (cm *ClusterManager) TransferAgentToCluster(oldClusterId string, newClusterId string, agent *entities.LightningMonkeyAgent, isETCDRole, isMasterRole, isMinionRole, isHARole bool, nodePool *string) error {
+	return transferAgentToCluster(cm.storageDriver, oldClusterId, newClusterId, agent, isETCDRole, isMasterRole, isMinionRole, isHARole, nodePool)
+}
+
+func transferAgentToCluster(sd storage.LightningMonkeyStorageDriver, oldClusterId string, newClusterId string, agent *entities.LightningMonkeyAgent, isETCDRole, isMasterRole, isMinionRole, isHARole bool, nodePool *string) error {
 	//STEP 1, entirely remove all of OLD agent's data to remote ETCD,
 	//it'll cause the data changing notification to the all of API Servers for cache cleaning.
-	err := cm.RemoveAgentFromETCD(oldClusterId, agent.Id)
+	err := removeAgentFromETCD(sd, oldClusterId, agent.Id)
 	if err != nil {
 		return fmt.Errorf("Failed to entirely remove given agent(%s) from remote ETCD, error: %s", agent.Id, err.Error())
 	}
 	//STEP 2, make a call to the agent API for re-registering to the new cluster.
-	err = changeAgentCluster(agent, oldClusterId, newClusterId, isETCDRole, isMasterRole, isMinionRole, isHARole)
+	err = changeAgentCluster(agent, oldClusterId, newClusterId, isETCDRole, isMasterRole, isMinionRole, isHARole, nodePool)
 	if err != nil {
 		return fmt.Errorf("Failed to notify agent(%s) API to change the cluster-id and roles, error: %s", agent.Id, err.Error())
 	}
 	return nil
 }
 
-func changeAgentCluster(agent *entities.LightningMonkeyAgent, oldClusterId string, newClusterId string, isETCDRole, isMasterRole, isMinionRole, isHARole bool) error {
+func changeAgentCluster(agent *entities.LightningMonkeyAgent, oldClusterId string, newClusterId string, isETCDRole, isMasterRole, isMinionRole, isHARole bool, nodePool *string) error {
 	gr := entities.ChangeClusterAndRolesRequest{
 		OldClusterId: oldClusterId,
 		NewClusterId: newClusterId,
@@ -35,6 +41,7 @@ func changeAgentCluster(agent *entities.LightningMonkeyAgent, oldClusterId strin
 		IsMasterRole: isMasterRole,
 		IsMinionRole: isMinionRole,
 		IsHARole:     isHARole,
+		NodePool:     nodePool,
 	}
 	data, err := json.Marshal(gr)
 	if err != nil {
@@ -76,20 +83,24 @@ func changeAgentCluster(agent *entities.LightningMonkeyAgent, oldClusterId strin
 }
 
 func (cm *ClusterManager) RemoveAgentFromETCD(clusterId string, agentId string) error {
+	return removeAgentFromETCD(cm.storageDriver, clusterId, agentId)
+}
+
+func removeAgentFromETCD(sd storage.LightningMonkeyStorageDriver, clusterId string, agentId string) error {
 	//STEP 1, immediately delete state node to trigger the agent offline procedure.
 	statePath := fmt.Sprintf("/lightning-monkey/clusters/%s/agents/%s/state", clusterId, agentId)
-	_, err := cm.storageDriver.Delete(context.Background(), statePath)
+	_, err := sd.Delete(context.Background(), statePath)
 	if err != nil {
 		return err
 	}
 	//STEP 2, subsequently delete settings node for permanent remove agent's registration information.
 	statePath = fmt.Sprintf("/lightning-monkey/clusters/%s/agents/%s/settings", clusterId, agentId)
-	_, err = cm.storageDriver.Delete(context.Background(), statePath)
+	_, err = sd.Delete(context.Background(), statePath)
 	if err != nil {
 		return err
 	}
 	//STEP 3, go on to clean-up current agent's data from remote ETCD.
 	statePath = fmt.Sprintf("/lightning-monkey/clusters/%s/agents/%s", clusterId, agentId)
-	_, err = cm.storageDriver.Delete(context.Background(), statePath)
+	_, err = sd.Delete(context.Background(), statePath)
 	return err
 }
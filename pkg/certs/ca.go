@@ -0,0 +1,138 @@
+package certs
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"github.com/g0194776/lightningmonkey/pkg/entities"
+	"time"
+)
+
+//CA certificate & key file names which are used by kubeadm.
+func CACertificateName(caName string) string {
+	return caName + ".crt"
+}
+
+func CAPrivateKeyName(caName string) string {
+	return caName + ".key"
+}
+
+func CASigningRequestName(caName string) string {
+	return caName + ".csr"
+}
+
+//ValidateCAKeyPair checks that the given certificate is a valid CA certificate right now and the private key is belonging to it.
+func ValidateCAKeyPair(certContent, keyContent string, now time.Time) error {
+	cert, err := ParseCertificate(certContent)
+	if err != nil {
+		return err
+	}
+	if err = ValidateCACertificate(cert, now); err != nil {
+		return err
+	}
+	key, err := ParsePrivateKey(keyContent)
+	if err != nil {
+		return err
+	}
+	return validateKeyMatchesCertificate(cert, key)
+}
+
+func ValidateCACertificate(cert *x509.Certificate, now time.Time) error {
+	if !cert.BasicConstraintsValid || !cert.IsCA {
+		return errors.New("Certificate is not a CA certificate, basic constraint CA:TRUE is required!")
+	}
+	if cert.KeyUsage != 0 && cert.KeyUsage&x509.KeyUsageCertSign == 0 {
+		return errors.New("Certificate has no \"cert sign\" key usage!")
+	}
+	if now.Before(cert.NotBefore) {
+		return fmt.Errorf("Certificate is not valid before: %s", cert.NotBefore.Format(time.RFC3339))
+	}
+	if now.After(cert.NotAfter) {
+		return fmt.Errorf("Certificate has been expired at: %s", cert.NotAfter.Format(time.RFC3339))
+	}
+	return nil
+}
+
+func ParseCertificate(content string) (*x509.Certificate, error) {
+	block, _ := pem.Decode([]byte(content))
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, errors.New("Failed to decode certificate, PEM block with type \"CERTIFICATE\" not found!")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse certificate, error: %s", err.Error())
+	}
+	return cert, nil
+}
+
+func ParsePrivateKey(content string) (crypto.Signer, error) {
+	block, _ := pem.Decode([]byte(content))
+	if block == nil {
+		return nil, errors.New("Failed to decode private key, PEM block not found!")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParseECPrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse private key, error: %s", err.Error())
+	}
+	signer, isOK := key.(crypto.Signer)
+	if !isOK {
+		return nil, errors.New("Unsupported private key type!")
+	}
+	return signer, nil
+}
+
+func validateKeyMatchesCertificate(cert *x509.Certificate, key crypto.Signer) error {
+	switch pub := cert.PublicKey.(type) {
+	case *rsa.PublicKey:
+		priv, isOK := key.Public().(*rsa.PublicKey)
+		if !isOK || pub.N.Cmp(priv.N) != 0 || pub.E != priv.E {
+			return errors.New("Private key does not match the certificate!")
+		}
+	case *ecdsa.PublicKey:
+		priv, isOK := key.Public().(*ecdsa.PublicKey)
+		if !isOK || pub.X.Cmp(priv.X) != 0 || pub.Y.Cmp(priv.Y) != 0 {
+			return errors.New("Private key does not match the certificate!")
+		}
+	default:
+		return errors.New("Unsupported certificate public key type!")
+	}
+	return nil
+}
+
+//GenerateCASigningRequest generates a new RSA private key and a PEM encoded CSR for an intermediate CA.
+func GenerateCASigningRequest(commonName string, subject *entities.CASubject) (string, string, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return "", "", fmt.Errorf("Failed to generate private key, error: %s", err.Error())
+	}
+	name := pkix.Name{CommonName: commonName}
+	if subject != nil {
+		if subject.CommonNamePrefix != "" {
+			name.CommonName = subject.CommonNamePrefix + commonName
+		}
+		name.Organization = subject.Organization
+		name.OrganizationalUnit = subject.OrganizationalUnit
+		name.Country = subject.Country
+		name.Province = subject.Province
+		name.Locality = subject.Locality
+	}
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{Subject: name, SignatureAlgorithm: x509.SHA256WithRSA}, key)
+	if err != nil {
+		return "", "", fmt.Errorf("Failed to create certificate signing request, error: %s", err.Error())
+	}
+	keyData := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	csrData := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: csr})
+	return string(keyData), string(csrData), nil
+}
@@ -14,6 +14,7 @@ import (
 	"path/filepath"
 	"regexp"
 	"strings"
+	"time"
 )
 
 type GeneratedCertsMap struct {
@@ -40,7 +41,7 @@ func (gm *GeneratedCertsMap) GetResources() map[string]string {
 type CertificateManager interface {
 	GenerateAdminKubeConfig(advertiseAddr string, basicCertMap entities.LightningMonkeyCertificateCollection) (*GeneratedCertsMap, error)
 	GenerateMasterCertificates(advertiseAddr, serviceCIDR string) (*GeneratedCertsMap, error)
-	GenerateMainCACertificates() (*GeneratedCertsMap, error)
+	GenerateMainCACertificates(caSettings *entities.CertificateAuthoritySettings) (*GeneratedCertsMap, error)
 	GenerateETCDClientCertificatesAndManifest(certPath, etcdConfigContent string) error
 	GenerateMasterCertificatesAndManifest(certPath, address string, settings map[string]string, imageCollection *entities.DockerImageCollection) error
 }
@@ -129,21 +130,28 @@ func (cm *CertificateManagerImple) GenerateMasterCertificates(advertiseAddr, ser
 	return getCertificatesContent(path, "", nil)
 }
 
-func (cm *CertificateManagerImple) GenerateMainCACertificates() (*GeneratedCertsMap, error) {
+func (cm *CertificateManagerImple) GenerateMainCACertificates(caSettings *entities.CertificateAuthoritySettings) (*GeneratedCertsMap, error) {
 	path := fmt.Sprintf("/tmp/kubernetes-certs/%s", uuid.NewV4().String())
 	logrus.Infof("Certificates temporary storage path: %s", path)
 	defer func() {
 		//remove certs path.
 		_ = os.RemoveAll(path)
 	}()
-	subCommands := []string{
-		fmt.Sprintf("kubeadm init phase certs ca --cert-dir=%s", path),
-		fmt.Sprintf("kubeadm init phase certs etcd-ca --cert-dir=%s", path),
-		fmt.Sprintf("kubeadm init phase certs front-proxy-ca --cert-dir=%s", path),
-		fmt.Sprintf("kubeadm init phase certs sa --cert-dir=%s", path),
-	}
 	var err error
 	certMap := &GeneratedCertsMap{res: make(map[string]string), path: path}
+	subCommands := []string{fmt.Sprintf("kubeadm init phase certs sa --cert-dir=%s", path)}
+	if caSettings == nil || caSettings.Mode == "" || caSettings.Mode == entities.CAMode_Generate {
+		subCommands = append([]string{
+			fmt.Sprintf("kubeadm init phase certs ca --cert-dir=%s", path),
+			fmt.Sprintf("kubeadm init phase certs etcd-ca --cert-dir=%s", path),
+			fmt.Sprintf("kubeadm init phase certs front-proxy-ca --cert-dir=%s", path),
+		}, subCommands...)
+	} else {
+		err = prepareSuppliedCACertificates(caSettings, certMap)
+		if err != nil {
+			return nil, err
+		}
+	}
 	for i := 0; i < len(subCommands); i++ {
 		err = executeCommand(subCommands[i], "")
 		if err != nil {
@@ -219,7 +227,7 @@ func (cm *CertificateManagerImple) GenerateMasterCertificatesAndManifest(certPat
 		}
 	}
 	//replace used docker registry.
-	manifestPath := filepath.Join(certPath, "../", "manifests")
+	manifestPath := GetManifestDirectory(certPath)
 	logrus.Infof("Calculated manifest file path: %s", manifestPath)
 	err = filepath.Walk(manifestPath, func(p string, info os.FileInfo, err error) error {
 		if info.IsDir() {
@@ -280,6 +288,69 @@ func (cm *CertificateManagerImple) GenerateMasterCertificatesAndManifest(certPat
 	return err
 }
 
+//GetManifestDirectory returns the directory of static pod manifests which are generated by kubeadm along with the
+//certificates, it is the staticPodPath of kubelet as well.
+func GetManifestDirectory(certPath string) string {
+	return filepath.Join(certPath, "../", "manifests")
+}
+
+//GetETCDConfigurationPaths returns the files which are generated by GenerateETCDClientCertificatesAndManifest.
+func GetETCDConfigurationPaths(certPath string) []string {
+	return []string{filepath.Join(certPath, "etcd_config.yml"), filepath.Join(GetManifestDirectory(certPath), "etcd.yaml")}
+}
+
+//GetMasterManifestPaths returns the static pod manifests which are generated by GenerateMasterCertificatesAndManifest.
+func GetMasterManifestPaths(certPath string) []string {
+	manifestPath := GetManifestDirectory(certPath)
+	return []string{
+		filepath.Join(manifestPath, "kube-apiserver.yaml"),
+		filepath.Join(manifestPath, "kube-controller-manager.yaml"),
+		filepath.Join(manifestPath, "kube-scheduler.yaml"),
+	}
+}
+
+//prepareSuppliedCACertificates puts the supplied CA key pairs(or the generated private keys & CSRs) into the certificates map.
+func prepareSuppliedCACertificates(caSettings *entities.CertificateAuthoritySettings, certMap *GeneratedCertsMap) error {
+	cas := map[string]*entities.CAKeyPair{
+		entities.CAName_Cluster:    caSettings.Cluster,
+		entities.CAName_FrontProxy: caSettings.FrontProxy,
+		entities.CAName_ETCD:       caSettings.ETCD,
+	}
+	for name, pair := range cas {
+		switch caSettings.Mode {
+		case entities.CAMode_Supplied:
+			if pair == nil {
+				return fmt.Errorf("Supplied CA key pair: %s not found!", name)
+			}
+			if err := ValidateCAKeyPair(pair.Certificate, pair.PrivateKey, time.Now()); err != nil {
+				return fmt.Errorf("Failed to validate supplied CA: %s, error: %s", name, err.Error())
+			}
+			certMap.res[CACertificateName(name)] = pair.Certificate
+			certMap.res[CAPrivateKeyName(name)] = pair.PrivateKey
+		case entities.CAMode_CSR:
+			key, csr, err := GenerateCASigningRequest(getCACommonName(name), caSettings.Subject)
+			if err != nil {
+				return fmt.Errorf("Failed to generate certificate signing request for CA: %s, error: %s", name, err.Error())
+			}
+			certMap.res[CAPrivateKeyName(name)] = key
+			certMap.res[CASigningRequestName(name)] = csr
+		default:
+			return fmt.Errorf("Unsupported CA mode: %s", caSettings.Mode)
+		}
+	}
+	return nil
+}
+
+func getCACommonName(name string) string {
+	switch name {
+	case entities.CAName_FrontProxy:
+		return "front-proxy-ca"
+	case entities.CAName_ETCD:
+		return "etcd-ca"
+	}
+	return "kubernetes"
+}
+
 func getExtraSans(settings map[string]string) string {
 	if v, isOK := settings[entities.MasterSettings_APIServerVIP]; isOK {
 		return v
@@ -0,0 +1,373 @@
+package containers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	uuid "github.com/satori/go.uuid"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultContainerdAddress = "/run/containerd/containerd.sock"
+	//kubelet's CRI plugin only sees images and containers in this namespace.
+	containerdNamespace = "k8s.io"
+	//honored by containerd's restart monitor.
+	containerdRestartLabel = "containerd.io/restart.status=running"
+	//stdout & stderr of the containers which are run by agent are written here by containerd's shim.
+	containerdLogDir = "/var/log/lightning-monkey/containers"
+	//kubelet links the log file of each Kubernetes container here, e.g: "<pod>_<namespace>_<container>-<id>.log".
+	kubernetesContainerLogDir = "/var/log/containers"
+)
+
+//containerdRuntime drives containerd by its CLI "ctr", so that the agent has no dependency on the containerd client libraries
+//which are not shipped with the agent. The information of containers is cached since it never changes after creation,
+//listing containers only forks "ctr" for the containers which have not been seen before.
+type containerdRuntime struct {
+	address string
+	lock    *sync.Mutex
+	infos   map[string]containerdContainerInfo
+}
+
+//containerdContainerInfo is the output of "ctr containers info".
+type containerdContainerInfo struct {
+	ID     string            `json:"ID"`
+	Image  string            `json:"Image"`
+	Labels map[string]string `json:"Labels"`
+	Spec   struct {
+		Mounts []struct {
+			Source      string `json:"source"`
+			Destination string `json:"destination"`
+		} `json:"mounts"`
+	} `json:"Spec"`
+}
+
+func newContainerdRuntime(address string) ContainerRuntime {
+	if address == "" {
+		address = defaultContainerdAddress
+	}
+	return &containerdRuntime{address: address, lock: &sync.Mutex{}, infos: map[string]containerdContainerInfo{}}
+}
+
+func (r *containerdRuntime) GetName() string {
+	return Runtime_Containerd
+}
+
+//GetVersion parses the output of "ctr version", the version of server follows the line "Server:".
+func (r *containerdRuntime) GetVersion() (string, error) {
+	out, err := r.ctr(nil, "version")
+	if err != nil {
+		return "", err
+	}
+	lines := strings.Split(out, "\n")
+	isServer := false
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+		if line == "Server:" {
+			isServer = true
+			continue
+		}
+		if isServer && strings.HasPrefix(line, "Version:") {
+			return strings.TrimSpace(strings.TrimPrefix(line, "Version:")), nil
+		}
+	}
+	return "", fmt.Errorf("Failed to parse containerd version: %s", strings.TrimSpace(out))
+}
+
+func (r *containerdRuntime) GetRootDir() string {
+	return "/var/lib/containerd"
+}
+
+func (r *containerdRuntime) ListContainers() ([]Container, error) {
+	out, err := r.ctr(nil, "containers", "list", "-q")
+	if err != nil {
+		return nil, err
+	}
+	tasks, err := r.listTaskStatus()
+	if err != nil {
+		return nil, err
+	}
+	ids := strings.Fields(out)
+	result := make([]Container, 0, len(ids))
+	for i := 0; i < len(ids); i++ {
+		info, isOK, err := r.getContainerInfo(ids[i])
+		if err != nil {
+			return nil, err
+		}
+		if !isOK {
+			//removed during listing.
+			continue
+		}
+		c := Container{
+			Id:        info.ID,
+			Name:      getContainerdContainerName(info),
+			Image:     info.Image,
+			IsRunning: tasks[info.ID] == "RUNNING",
+		}
+		for j := 0; j < len(info.Spec.Mounts); j++ {
+			c.Mounts = append(c.Mounts, Mount{Source: info.Spec.Mounts[j].Source, Destination: info.Spec.Mounts[j].Destination})
+		}
+		result = append(result, c)
+	}
+	r.pruneContainerInfos(ids)
+	return result, nil
+}
+
+//getContainerInfo returns false if the container does not exist.
+func (r *containerdRuntime) getContainerInfo(id string) (containerdContainerInfo, bool, error) {
+	r.lock.Lock()
+	info, isOK := r.infos[id]
+	r.lock.Unlock()
+	if isOK {
+		return info, true, nil
+	}
+	out, err := r.ctr(nil, "containers", "info", id)
+	if err != nil {
+		return info, false, nil
+	}
+	if err = json.Unmarshal([]byte(out), &info); err != nil {
+		return info, false, fmt.Errorf("Failed to parse container %s information, error: %s", id, err.Error())
+	}
+	r.lock.Lock()
+	r.infos[id] = info
+	r.lock.Unlock()
+	return info, true, nil
+}
+
+//pruneContainerInfos drops the cached information of removed containers.
+func (r *containerdRuntime) pruneContainerInfos(ids []string) {
+	existing := make(map[string]bool, len(ids))
+	for i := 0; i < len(ids); i++ {
+		existing[ids[i]] = true
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	for id := range r.infos {
+		if !existing[id] {
+			delete(r.infos, id)
+		}
+	}
+}
+
+func (r *containerdRuntime) LoadImage(reader io.Reader) error {
+	_, err := r.ctr(reader, "images", "import", "-")
+	return err
+}
+
+func (r *containerdRuntime) PullImage(image string) error {
+	_, err := r.ctr(nil, "images", "pull", NormalizeImageReference(image))
+	return err
+}
+
+func (r *containerdRuntime) RunContainer(spec ContainerSpec) (string, error) {
+	args := []string{"run", "-d"}
+	if spec.Privileged {
+		args = append(args, "--privileged")
+	}
+	if spec.HostNetwork {
+		args = append(args, "--net-host")
+	}
+	if spec.HostPID {
+		args = append(args, "--with-ns", "pid:/proc/1/ns/pid")
+	}
+	if spec.AlwaysRestart {
+		args = append(args, "--label", containerdRestartLabel)
+	}
+	for i := 0; i < len(spec.Binds); i++ {
+		src, dst, opts := parseBind(spec.Binds[i])
+		options := []string{"rbind"}
+		isReadonly := false
+		for j := 0; j < len(opts); j++ {
+			if opts[j] == "ro" {
+				isReadonly = true
+			} else if opts[j] != "rw" {
+				options = append(options, opts[j])
+			}
+		}
+		if isReadonly {
+			options = append(options, "ro")
+		} else {
+			options = append(options, "rw")
+		}
+		args = append(args, "--mount", fmt.Sprintf("type=bind,src=%s,dst=%s,options=%s", src, dst, strings.Join(options, ":")))
+	}
+	if err := os.MkdirAll(containerdLogDir, 0755); err != nil {
+		return "", fmt.Errorf("Failed to create log directory of containers, error: %s", err.Error())
+	}
+	args = append(args, "--log-uri", "file://"+filepath.Join(containerdLogDir, spec.Name+".log"))
+	//the name is used as the identity of container.
+	args = append(args, NormalizeImageReference(spec.Image), spec.Name)
+	args = append(args, spec.Cmd...)
+	_, err := r.ctr(nil, args...)
+	if err != nil {
+		return "", fmt.Errorf("Failed to run container %s, error: %s", spec.Name, err.Error())
+	}
+	return spec.Name, nil
+}
+
+func (r *containerdRuntime) RemoveContainer(id string) error {
+	//the task might have exited.
+	_, _ = r.ctr(nil, "tasks", "delete", "--force", id)
+	_, err := r.ctr(nil, "containers", "delete", id)
+	//the name is reused as the identity by the next container.
+	r.lock.Lock()
+	delete(r.infos, id)
+	r.lock.Unlock()
+	return err
+}
+
+func (r *containerdRuntime) WaitContainer(id string, timeout time.Duration) (int, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		tasks, err := r.listTaskStatus()
+		if err != nil {
+			return -1, err
+		}
+		if status, isOK := tasks[id]; !isOK || status == "STOPPED" {
+			break
+		}
+		if time.Now().After(deadline) {
+			return -1, fmt.Errorf("Timed out waiting for container %s to exit", id)
+		}
+		time.Sleep(time.Second)
+	}
+	//"ctr tasks delete" exits with the exit code of the task.
+	_, err := r.ctr(nil, "tasks", "delete", id)
+	if err != nil {
+		if ee, isOK := err.(*ctrError); isOK {
+			return ee.exitCode, nil
+		}
+		return -1, err
+	}
+	return 0, nil
+}
+
+func (r *containerdRuntime) Exec(id string, cmd []string) (string, string, int, error) {
+	args := append([]string{"tasks", "exec", "--exec-id", "lm-" + uuid.NewV4().String()[:8], id}, cmd...)
+	c := exec.Command("ctr", append([]string{"--address", r.address, "--namespace", containerdNamespace}, args...)...)
+	stdout := bytes.Buffer{}
+	stderr := bytes.Buffer{}
+	c.Stdout = &stdout
+	c.Stderr = &stderr
+	err := c.Run()
+	if err != nil {
+		if ee, isOK := err.(*exec.ExitError); isOK {
+			return stdout.String(), stderr.String(), ee.ExitCode(), nil
+		}
+		return "", "", -1, err
+	}
+	return stdout.String(), stderr.String(), 0, nil
+}
+
+//ContainerLogs streams the log file which is written by kubelet for Kubernetes containers, or by containerd's shim for the others.
+func (r *containerdRuntime) ContainerLogs(id string, opts LogOptions) (io.ReadCloser, error) {
+	logFile := filepath.Join(containerdLogDir, id+".log")
+	if matches, _ := filepath.Glob(filepath.Join(kubernetesContainerLogDir, fmt.Sprintf("*-%s.log", id))); len(matches) > 0 {
+		logFile = matches[0]
+	}
+	f, err := os.Open(logFile)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to open log file of container %s, error: %s", id, err.Error())
+	}
+	return StreamLogLines(f, opts), nil
+}
+
+func (r *containerdRuntime) GetKubeletFlags() []string {
+	return []string{
+		"--container-runtime=remote",
+		fmt.Sprintf("--container-runtime-endpoint=unix://%s", r.address),
+	}
+}
+
+func (r *containerdRuntime) GetKubeletBinds() []string {
+	return []string{
+		"/var/lib/containerd:/var/lib/containerd:rw",
+		fmt.Sprintf("%s:%s:rw", filepath.Dir(r.address), filepath.Dir(r.address)),
+	}
+}
+
+//listTaskStatus parses the output of "ctr tasks list", e.g: "kubelet    1234    RUNNING".
+func (r *containerdRuntime) listTaskStatus() (map[string]string, error) {
+	out, err := r.ctr(nil, "tasks", "list")
+	if err != nil {
+		return nil, err
+	}
+	tasks := map[string]string{}
+	lines := strings.Split(out, "\n")
+	for i := 1; i < len(lines); i++ {
+		fields := strings.Fields(lines[i])
+		if len(fields) < 3 {
+			continue
+		}
+		tasks[fields[0]] = fields[2]
+	}
+	return tasks, nil
+}
+
+type ctrError struct {
+	exitCode int
+	stderr   string
+}
+
+func (e *ctrError) Error() string {
+	return fmt.Sprintf("ctr exited with code: %d, error: %s", e.exitCode, e.stderr)
+}
+
+func (r *containerdRuntime) ctr(stdin io.Reader, args ...string) (string, error) {
+	c := exec.Command("ctr", append([]string{"--address", r.address, "--namespace", containerdNamespace}, args...)...)
+	stdout := bytes.Buffer{}
+	stderr := bytes.Buffer{}
+	c.Stdin = stdin
+	c.Stdout = &stdout
+	c.Stderr = &stderr
+	err := c.Run()
+	if err != nil {
+		if ee, isOK := err.(*exec.ExitError); isOK {
+			return "", &ctrError{exitCode: ee.ExitCode(), stderr: strings.TrimSpace(stderr.String())}
+		}
+		return "", err
+	}
+	return stdout.String(), nil
+}
+
+//getContainerdContainerName generates the name in Docker's convention for the containers which are created by kubelet.
+func getContainerdContainerName(info containerdContainerInfo) string {
+	pod := info.Labels["io.kubernetes.pod.name"]
+	if pod == "" {
+		return info.ID
+	}
+	name := info.Labels["io.kubernetes.container.name"]
+	if name == "" {
+		name = "POD"
+	}
+	return fmt.Sprintf("k8s_%s_%s_%s_%s_0", name, pod, info.Labels["io.kubernetes.pod.namespace"], info.Labels["io.kubernetes.pod.uid"])
+}
+
+//NormalizeImageReference returns the fully qualified image reference, e.g: "busybox" -> "docker.io/library/busybox:latest".
+func NormalizeImageReference(image string) string {
+	name := image
+	parts := strings.SplitN(name, "/", 2)
+	if len(parts) == 1 {
+		name = "docker.io/library/" + name
+	} else if !strings.ContainsAny(parts[0], ".:") && parts[0] != "localhost" {
+		name = "docker.io/" + name
+	}
+	if strings.Contains(name, "@") {
+		return name
+	}
+	if !strings.Contains(name[strings.LastIndex(name, "/")+1:], ":") {
+		name += ":latest"
+	}
+	return name
+}
+
+//IsSameImage compares image references regardless of their registry prefixes and default tags.
+func IsSameImage(a, b string) bool {
+	return NormalizeImageReference(a) == NormalizeImageReference(b)
+}
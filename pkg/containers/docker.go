@@ -0,0 +1,196 @@
+package containers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/docker/engine-api/client"
+	"github.com/docker/engine-api/types"
+	"github.com/docker/engine-api/types/container"
+	"github.com/docker/engine-api/types/network"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type dockerRuntime struct {
+	c *client.Client
+}
+
+func newDockerRuntime() (ContainerRuntime, error) {
+	c, err := client.NewEnvClient()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to initialize docker client, error: %s", err.Error())
+	}
+	return &dockerRuntime{c: c}, nil
+}
+
+func (r *dockerRuntime) GetName() string {
+	return Runtime_Docker
+}
+
+func (r *dockerRuntime) GetVersion() (string, error) {
+	v, err := r.c.ServerVersion(context.Background())
+	if err != nil {
+		return "", err
+	}
+	return v.Version, nil
+}
+
+func (r *dockerRuntime) GetRootDir() string {
+	info, err := r.c.Info(context.Background())
+	if err != nil || info.DockerRootDir == "" {
+		return "/var/lib/docker"
+	}
+	return info.DockerRootDir
+}
+
+func (r *dockerRuntime) ListContainers() ([]Container, error) {
+	cs, err := r.c.ContainerList(context.Background(), types.ContainerListOptions{All: true})
+	if err != nil {
+		return nil, err
+	}
+	result := make([]Container, 0, len(cs))
+	for i := 0; i < len(cs); i++ {
+		c := Container{
+			Id:    cs[i].ID,
+			Image: cs[i].Image,
+			//Docker reports the status like "Up 3 minutes" or "Exited (0) 1 second ago".
+			IsRunning: strings.HasPrefix(cs[i].Status, "Up"),
+		}
+		if len(cs[i].Names) > 0 {
+			c.Name = strings.TrimPrefix(cs[i].Names[0], "/")
+		}
+		for j := 0; j < len(cs[i].Mounts); j++ {
+			c.Mounts = append(c.Mounts, Mount{Source: cs[i].Mounts[j].Source, Destination: cs[i].Mounts[j].Destination})
+		}
+		result = append(result, c)
+	}
+	return result, nil
+}
+
+func (r *dockerRuntime) LoadImage(reader io.Reader) error {
+	rsp, err := r.c.ImageLoad(context.Background(), reader, false)
+	if err != nil {
+		return err
+	}
+	defer rsp.Body.Close()
+	_, err = io.Copy(ioutil.Discard, rsp.Body)
+	return err
+}
+
+func (r *dockerRuntime) PullImage(image string) error {
+	reader, err := r.c.ImagePull(context.Background(), image, types.ImagePullOptions{})
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	_, err = io.Copy(ioutil.Discard, reader)
+	return err
+}
+
+func (r *dockerRuntime) RunContainer(spec ContainerSpec) (string, error) {
+	hc := &container.HostConfig{
+		Binds:      spec.Binds,
+		Privileged: spec.Privileged,
+	}
+	if spec.HostNetwork {
+		hc.NetworkMode = "host"
+	}
+	if spec.HostPID {
+		hc.PidMode = "host"
+	}
+	if spec.AlwaysRestart {
+		hc.RestartPolicy = container.RestartPolicy{Name: "unless-stopped"}
+	}
+	resp, err := r.c.ContainerCreate(context.Background(), &container.Config{
+		Hostname: spec.Hostname,
+		Image:    spec.Image,
+		Tty:      false,
+		Cmd:      spec.Cmd,
+		Volumes:  map[string]struct{}{},
+	}, hc, &network.NetworkingConfig{}, spec.Name)
+	if err != nil {
+		return "", fmt.Errorf("Failed to create container %s, error: %s", spec.Name, err.Error())
+	}
+	if err = r.c.ContainerStart(context.Background(), resp.ID, types.ContainerStartOptions{}); err != nil {
+		return "", fmt.Errorf("Failed to start container %s, error: %s", spec.Name, err.Error())
+	}
+	return resp.ID, nil
+}
+
+func (r *dockerRuntime) RemoveContainer(id string) error {
+	return r.c.ContainerRemove(context.Background(), id, types.ContainerRemoveOptions{Force: true})
+}
+
+func (r *dockerRuntime) WaitContainer(id string, timeout time.Duration) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return r.c.ContainerWait(ctx, id)
+}
+
+func (r *dockerRuntime) Exec(id string, cmd []string) (string, string, int, error) {
+	config := types.ExecConfig{
+		AttachStdout: true,
+		AttachStderr: true,
+		Cmd:          cmd,
+	}
+	execID, err := r.c.ContainerExecCreate(context.TODO(), id, config)
+	if err != nil {
+		return "", "", -1, err
+	}
+	res, err := r.c.ContainerExecAttach(context.TODO(), execID.ID, types.ExecConfig{})
+	if err != nil {
+		return "", "", -1, err
+	}
+	defer res.Close()
+	err = r.c.ContainerExecStart(context.TODO(), execID.ID, types.ExecStartCheck{})
+	if err != nil {
+		return "", "", -1, err
+	}
+	//output of non-TTY exec is multiplexed.
+	stdout := bytes.Buffer{}
+	stderr := bytes.Buffer{}
+	_, err = stdcopy.StdCopy(&stdout, &stderr, res.Reader)
+	if err != nil {
+		return "", "", -1, err
+	}
+	ei, err := r.c.ContainerExecInspect(context.TODO(), execID.ID)
+	if err != nil {
+		return "", "", -1, err
+	}
+	return stdout.String(), stderr.String(), ei.ExitCode, nil
+}
+
+func (r *dockerRuntime) ContainerLogs(id string, opts LogOptions) (io.ReadCloser, error) {
+	lo := types.ContainerLogsOptions{ShowStdout: true, ShowStderr: true, Timestamps: true, Tail: "all"}
+	if opts.Tail > 0 {
+		lo.Tail = strconv.Itoa(opts.Tail)
+	}
+	if !opts.Since.IsZero() {
+		lo.Since = strconv.FormatInt(opts.Since.Unix(), 10)
+	}
+	rc, err := r.c.ContainerLogs(context.Background(), id, lo)
+	if err != nil {
+		return nil, err
+	}
+	//output of non-TTY container is multiplexed, it's copied until the reader is closed by caller.
+	pr, pw := io.Pipe()
+	go func() {
+		_, err := stdcopy.StdCopy(pw, pw, rc)
+		_ = rc.Close()
+		_ = pw.CloseWithError(err)
+	}()
+	return pr, nil
+}
+
+func (r *dockerRuntime) GetKubeletFlags() []string {
+	return []string{"--container-runtime=docker"}
+}
+
+func (r *dockerRuntime) GetKubeletBinds() []string {
+	return []string{"/var/lib/docker:/var/lib/docker:rw"}
+}
@@ -0,0 +1,181 @@
+package containers
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+const (
+	Runtime_Docker     = "docker"
+	Runtime_Containerd = "containerd"
+)
+
+//ContainerRuntime hides the differences between container runtimes which are used by Lightning Monkey's agent.
+type ContainerRuntime interface {
+	GetName() string
+	//GetVersion returns the version of runtime's daemon.
+	GetVersion() (string, error)
+	//GetRootDir returns the directory where the runtime stores images and containers.
+	GetRootDir() string
+	//ListContainers returns all of containers including the stopped ones.
+	ListContainers() ([]Container, error)
+	LoadImage(r io.Reader) error
+	PullImage(image string) error
+	//RunContainer creates and starts a detached container, it returns the identity of the container.
+	RunContainer(spec ContainerSpec) (string, error)
+	RemoveContainer(id string) error
+	//WaitContainer blocks until the container exits, it returns the exit code.
+	WaitContainer(id string, timeout time.Duration) (int, error)
+	//Exec runs a command inside of a running container, it returns stdout, stderr and the exit code.
+	Exec(id string, cmd []string) (string, string, int, error)
+	//ContainerLogs returns the merged stdout and stderr of the container, the caller must close the reader.
+	ContainerLogs(id string, opts LogOptions) (io.ReadCloser, error)
+	//GetKubeletFlags returns the flags which make kubelet to use this runtime.
+	GetKubeletFlags() []string
+	//GetKubeletBinds returns the directories of runtime which kubelet container needs to access.
+	GetKubeletBinds() []string
+}
+
+//Container is the runtime-independent summary of a container.
+//Names of the containers which are managed by kubelet follow the Docker's convention: "k8s_<container>_<pod>_<namespace>_<uid>_<attempt>".
+type Container struct {
+	Id        string
+	Name      string
+	Image     string
+	IsRunning bool
+	Mounts    []Mount
+}
+
+type Mount struct {
+	Source      string
+	Destination string
+}
+
+//LogOptions selects the logs of a container, the zero value selects all of them.
+type LogOptions struct {
+	Tail  int       //number of lines from the end of logs, 0 for all.
+	Since time.Time //only returns the logs which are written after it.
+}
+
+type ContainerSpec struct {
+	Name        string
+	Image       string
+	Hostname    string
+	Cmd         []string
+	Binds       []string //Docker's format, "<source>:<destination>[:options]".
+	Privileged  bool
+	HostNetwork bool
+	HostPID     bool
+	//restarts the container unless it's stopped manually.
+	AlwaysRestart bool
+}
+
+//NewContainerRuntime creates the runtime by name, the address is the socket path of runtime's daemon, empty for default.
+func NewContainerRuntime(name, address string) (ContainerRuntime, error) {
+	switch name {
+	case "", Runtime_Docker:
+		return newDockerRuntime()
+	case Runtime_Containerd:
+		return newContainerdRuntime(address), nil
+	default:
+		return nil, fmt.Errorf("Unsupported container runtime: %s", name)
+	}
+}
+
+//FindContainer returns the first container which matches the filter, nil if not found.
+func FindContainer(cs []Container, filter func(c Container) bool) *Container {
+	for i := 0; i < len(cs); i++ {
+		if filter(cs[i]) {
+			return &cs[i]
+		}
+	}
+	return nil
+}
+
+//IsKubernetesContainer returns true if the container is created by kubelet for the given container and namespace.
+func IsKubernetesContainer(c Container, containerName, namespace string) bool {
+	return strings.HasPrefix(c.Name, fmt.Sprintf("k8s_%s_", containerName)) && strings.Contains(c.Name, fmt.Sprintf("_%s_", namespace))
+}
+
+//FilterLogLines applies the options to the logs whose lines start with RFC3339 timestamps,
+//the lines without timestamp are never filtered out by time.
+func FilterLogLines(r io.Reader, opts LogOptions) ([]byte, error) {
+	buf := bytes.Buffer{}
+	err := scanLogLines(r, opts, func(line string) error {
+		buf.WriteString(line)
+		buf.WriteByte('\n')
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if buf.Len() == 0 {
+		return []byte{}, nil
+	}
+	return buf.Bytes(), nil
+}
+
+//StreamLogLines applies the options same as FilterLogLines while the logs are being read, only the lines selected by
+//tail are kept in memory. Closing the returned reader also closes the source.
+func StreamLogLines(r io.ReadCloser, opts LogOptions) io.ReadCloser {
+	pr, pw := io.Pipe()
+	go func() {
+		defer r.Close()
+		err := scanLogLines(r, opts, func(line string) error {
+			_, err := io.WriteString(pw, line+"\n")
+			return err
+		})
+		_ = pw.CloseWithError(err)
+	}()
+	return pr
+}
+
+//scanLogLines emits the selected lines in order, the lines are emitted after reading all of logs if tail is given.
+func scanLogLines(r io.Reader, opts LogOptions, emit func(line string) error) error {
+	lines := []string{}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !opts.Since.IsZero() {
+			if t, err := time.Parse(time.RFC3339Nano, strings.SplitN(line, " ", 2)[0]); err == nil && t.Before(opts.Since) {
+				continue
+			}
+		}
+		if opts.Tail <= 0 {
+			if err := emit(line); err != nil {
+				return err
+			}
+			continue
+		}
+		lines = append(lines, line)
+		if len(lines) > opts.Tail {
+			lines = lines[1:]
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	for i := 0; i < len(lines); i++ {
+		if err := emit(lines[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//parseBind parses Docker's bind format.
+func parseBind(bind string) (string, string, []string) {
+	parts := strings.Split(bind, ":")
+	if len(parts) < 2 {
+		return parts[0], parts[0], nil
+	}
+	if len(parts) == 2 {
+		return parts[0], parts[1], nil
+	}
+	return parts[0], parts[1], strings.Split(parts[2], ",")
+}
@@ -11,6 +11,17 @@ const (
 	AgentJob_Deploy_HA                      = "HA"
 	AgentJob_Deploy_NetworkStack_KubeRouter = "Kube-Router"
 	AgentJob_NOP                            = "NOP"
+	AgentJob_Upgrade                        = "Upgrade"
+	AgentJob_Teardown                       = "Teardown"
+	AgentJob_ETCD_Member                    = "ETCD-Member"
+	AgentJob_ETCD_Snapshot                  = "ETCD-Snapshot"
+	AgentJob_ETCD_Restore                   = "ETCD-Restore"
+	AgentJob_HA_Backends                    = "HA-Backends"
+	AgentJob_Reset                          = "Reset"
+	DiskType_SSD                            = "ssd"
+	DiskType_HDD                            = "hdd"
+	ETCDMemberOperation_Add                 = "add"
+	ETCDMemberOperation_Remove              = "remove"
 	AgentStatus_Registered                  = "New"
 	AgentStatus_Running                     = "Running"
 	AgentStatus_Provisioning                = "Provisioning"
@@ -20,11 +31,41 @@ const (
 	AgentReport_Heartbeat                   = "Heartbeat"
 	MaxAgentReportTimeoutSecs               = 30
 
+	//components whose configuration drift is detected besides the ones which are named after their deployment jobs.
+	ConfigDriftComponent_Kubelet = "kubelet"
+	ConfigDriftComponent_Routes  = "routes"
+
 	AgentDeploymentPhase_Pending   = 0
 	AgentDeploymentPhase_Deploying = 1
 	AgentDeploymentPhase_Deployed  = 2
 )
 
+const (
+	DecommissionStatus_Draining    = "Draining"
+	DecommissionStatus_TearingDown = "TearingDown"
+	DecommissionStatus_Completed   = "Completed"
+	DecommissionStatus_Failed      = "Failed"
+)
+
+//AgentDecommission is the persisted progress of taking an agent out of its cluster, it's driven by the reconcile leader.
+type AgentDecommission struct {
+	AgentId             string    `json:"agent_id"`
+	Hostname            string    `json:"hostname"`
+	NodeName            string    `json:"node_name"` //empty if there is no any provisioned Kubernetes node on the agent.
+	DrainTimeoutSeconds int       `json:"drain_timeout_seconds"`
+	IsDelete            bool      `json:"is_delete"` //marks the agent as deleted instead of returning it to the pool.
+	Status              string    `json:"status"`
+	Reason              string    `json:"reason"`
+	CreateTime          time.Time `json:"create_time"`
+	TeardownTime        time.Time `json:"teardown_time"`
+	UpdateTime          time.Time `json:"update_time"`
+}
+
+//IsFinished returns true if the agent will never be changed by this decommission anymore.
+func (d *AgentDecommission) IsFinished() bool {
+	return d.Status == DecommissionStatus_Completed || d.Status == DecommissionStatus_Failed
+}
+
 type Agent struct {
 	ClusterId                      string    `json:"cluster_id"`
 	MetadataId                     string    `json:"metadata_id"`
@@ -48,37 +89,157 @@ type Agent struct {
 }
 
 type LightningMonkeyAgent struct {
-	Id               string          `json:"id" bson:"_id"`
-	ClusterId        string          `json:"cluster_id" bson:"cluster_id"`
-	AdminCertificate string          `json:"admin_certificate"` //not exist if it has not master role.
-	DeploymentPhase  int             `json:"deployment_phase"`  //0-pending, 1-deploying, 2-deployed
-	Hostname         string          `json:"hostname" bson:"hostname"`
-	IsDelete         bool            `json:"is_delete" bson:"is_delete"`
-	HasETCDRole      bool            `json:"has_etcd_role" bson:"has_etcd_role"`
-	HasMasterRole    bool            `json:"has_master_role" bson:"has_master_role"`
-	HasMinionRole    bool            `json:"has_minion_role" bson:"has_minion_role"`
-	HasHARole        bool            `json:"has_ha_role"`
-	HostInformation  HostInformation `json:"host_information"`
-	ListenPort       int             `json:"listen_port"`
-	State            *AgentState     `json:"-"`
+	Id               string           `json:"id" bson:"_id"`
+	ClusterId        string           `json:"cluster_id" bson:"cluster_id"`
+	AdminCertificate string           `json:"admin_certificate"` //not exist if it has not master role.
+	DeploymentPhase  int              `json:"deployment_phase"`  //0-pending, 1-deploying, 2-deployed
+	Hostname         string           `json:"hostname" bson:"hostname"`
+	IsDelete         bool             `json:"is_delete" bson:"is_delete"`
+	HasETCDRole      bool             `json:"has_etcd_role" bson:"has_etcd_role"`
+	HasMasterRole    bool             `json:"has_master_role" bson:"has_master_role"`
+	HasMinionRole    bool             `json:"has_minion_role" bson:"has_minion_role"`
+	HasHARole        bool             `json:"has_ha_role"`
+	HostInformation  HostInformation  `json:"host_information"`
+	ListenPort       int              `json:"listen_port"`
+	Version          string           `json:"version"`                    //version of the agent itself, empty for the agents of previous versions.
+	IsContainerized  bool             `json:"is_containerized,omitempty"` //containerized agents are upgraded by replacing their images, never by rollout.
+	Preflight        []PreflightCheck `json:"preflight,omitempty"`
+	AccessToken      string           `json:"access_token,omitempty"` //required by the agent's HTTP APIs, e.g: retrieving logs.
+	NodePool         string           `json:"node_pool,omitempty"`    //only used by minion role.
+	State            *AgentState      `json:"-"`
+}
+
+//CachedAgent is an agent in API server's cache, its state is serialized as well for diagnostics.
+type CachedAgent struct {
+	LightningMonkeyAgent
+	State *AgentState `json:"state"`
+}
+
+//PreflightCheck is one of host checks which are performed by agent during registering and periodically afterwards,
+//a failed check blocks deploying the roles which it applies to.
+type PreflightCheck struct {
+	Name     string   `json:"name"`
+	IsPassed bool     `json:"is_passed"`
+	Reason   string   `json:"reason,omitempty"`
+	Roles    []string `json:"roles"`
 }
 
 type HostInformation struct {
-	OS            string  `json:"os"`
-	Kernel        string  `json:"kernel"`
-	CPUCores      int32   `json:"cpu_cores"`
-	CPUMhz        float64 `json:"cpu_mhz"`
-	MemoryTotalMB uint64  `json:"memory_total_mb"`
+	OS                      string            `json:"os"`
+	Kernel                  string            `json:"kernel"`
+	CPUCores                int32             `json:"cpu_cores"`
+	CPUMhz                  float64           `json:"cpu_mhz"`
+	MemoryTotalMB           uint64            `json:"memory_total_mb"`
+	LogicalCPUs             int               `json:"logical_cpus"`
+	Disks                   []DiskInformation `json:"disks,omitempty"`
+	NICs                    []NICInformation  `json:"nics,omitempty"`
+	CgroupVersion           int               `json:"cgroup_version"`
+	ContainerRuntime        string            `json:"container_runtime"`
+	ContainerRuntimeVersion string            `json:"container_runtime_version"`
+	MachineId               string            `json:"machine_id"`
+	UpdateTime              time.Time         `json:"update_time"`
+}
+
+type DiskInformation struct {
+	Name         string   `json:"name"`
+	SizeMB       uint64   `json:"size_mb"`
+	IsRotational bool     `json:"is_rotational"` //false for SSD.
+	MountPoints  []string `json:"mount_points,omitempty"`
+}
+
+type NICInformation struct {
+	Name      string   `json:"name"`
+	MAC       string   `json:"mac"`
+	Addresses []string `json:"addresses,omitempty"`
+	SpeedMbps int      `json:"speed_mbps"` //0 if it's unknown, e.g: virtual NICs.
 }
 
 type AgentState struct {
-	LastReportIP                   string    `json:"last_report_ip"`
-	LastReportTime                 time.Time `json:"last_report_time"`
-	Reason                         string    `json:"reason"`
-	HasProvisionedMasterComponents bool      `json:"provisioned_master_components"`
-	HasProvisionedETCD             bool      `json:"provisioned_etcd"`
-	HasProvisionedMinion           bool      `json:"provisioned_minion"`
-	HasProvisionedHA               bool      `json:"has_provisioned_ha"`
+	LastReportIP                   string              `json:"last_report_ip"`
+	LastReportTime                 time.Time           `json:"last_report_time"`
+	Reason                         string              `json:"reason"`
+	HasProvisionedMasterComponents bool                `json:"provisioned_master_components"`
+	HasProvisionedETCD             bool                `json:"provisioned_etcd"`
+	HasProvisionedMinion           bool                `json:"provisioned_minion"`
+	HasProvisionedHA               bool                `json:"has_provisioned_ha"`
+	Upgrade                        *AgentUpgradeStatus `json:"upgrade,omitempty"`
+	ETCDMembers                    []ETCDMember        `json:"etcd_members,omitempty"`
+	Snapshot                       *ETCDSnapshot       `json:"snapshot,omitempty"`
+	Restore                        *AgentRestoreStatus `json:"restore,omitempty"`
+	HABackends                     []string            `json:"ha_backends,omitempty"` //master addresses which are served by HAProxy.
+	ResetId                        string              `json:"reset_id,omitempty"`    //identity of the latest finished reset job.
+	Probes                         []ComponentProbe    `json:"probes,omitempty"`
+	ConfigDrift                    *ConfigDriftStatus  `json:"config_drift,omitempty"`
+}
+
+//ETCDMember is one of ETCD cluster members which is observed by a provisioned ETCD agent.
+type ETCDMember struct {
+	Id        string `json:"id"`
+	Name      string `json:"name"`
+	PeerURL   string `json:"peer_url"`
+	IsStarted bool   `json:"is_started"`
+}
+
+//ComponentProbe is the latest result of probing the health endpoint of a component,
+//the component turns to unhealthy only after it has failed for the configured times in a row.
+type ComponentProbe struct {
+	Name                string    `json:"name"`
+	IsHealthy           bool      `json:"is_healthy"`
+	LatencyMs           int64     `json:"latency_ms"`
+	ConsecutiveFailures int       `json:"consecutive_failures"`
+	Reason              string    `json:"reason,omitempty"`
+	LastProbeTime       time.Time `json:"last_probe_time"`
+}
+
+//ConfigDrift is a difference between the expected state of an installed component and the actual one.
+type ConfigDrift struct {
+	Component string `json:"component"`
+	Target    string `json:"target"` //path of configuration file, name of container or "routes".
+	Reason    string `json:"reason"`
+}
+
+//ConfigDriftStatus is the result of the latest drift detection on an agent.
+type ConfigDriftStatus struct {
+	Drifts              []ConfigDrift `json:"drifts,omitempty"`
+	LastCheckTime       time.Time     `json:"last_check_time"`
+	LastRemediationTime *time.Time    `json:"last_remediation_time,omitempty"`
+	RemediationError    string        `json:"remediation_error,omitempty"`
+}
+
+//AgentUpgradeStatus is the result of the latest upgrade job which agent has received.
+type AgentUpgradeStatus struct {
+	Version    string    `json:"version"`
+	Stage      string    `json:"stage"`
+	Attempt    int       `json:"attempt"`
+	Status     string    `json:"status"`
+	Reason     string    `json:"reason"`
+	UpdateTime time.Time `json:"update_time"`
+}
+
+//AgentRestoreStatus is the result of the latest ETCD restore job which agent has received.
+type AgentRestoreStatus struct {
+	RestoreId  string    `json:"restore_id"`
+	Status     string    `json:"status"`
+	Reason     string    `json:"reason"`
+	UpdateTime time.Time `json:"update_time"`
+}
+
+//GetFailedPreflightChecks returns the failed checks which apply to the given role,
+//agents of previous versions never report any preflight check.
+func (a *LightningMonkeyAgent) GetFailedPreflightChecks(role string) []PreflightCheck {
+	result := []PreflightCheck{}
+	for i := 0; i < len(a.Preflight); i++ {
+		if a.Preflight[i].IsPassed {
+			continue
+		}
+		for j := 0; j < len(a.Preflight[i].Roles); j++ {
+			if a.Preflight[i].Roles[j] == role {
+				result = append(result, a.Preflight[i])
+				break
+			}
+		}
+	}
+	return result
 }
 
 func (a *LightningMonkeyAgent) HasInitializedRoles() bool {
@@ -119,9 +280,19 @@ type AgentStatus struct {
 }
 
 type LightningMonkeyAgentReportStatus struct {
-	IP      string                                          `json:"ip"`
-	Items   map[string]LightningMonkeyAgentReportStatusItem `json:"items"`
-	LeaseId int64                                           `json:"lease_id"`
+	IP          string                                          `json:"ip"`
+	Items       map[string]LightningMonkeyAgentReportStatusItem `json:"items"`
+	LeaseId     int64                                           `json:"lease_id"`
+	Upgrade     *AgentUpgradeStatus                             `json:"upgrade,omitempty"`
+	ETCDMembers []ETCDMember                                    `json:"etcd_members,omitempty"`
+	Snapshot    *ETCDSnapshot                                   `json:"snapshot,omitempty"`
+	Restore     *AgentRestoreStatus                             `json:"restore,omitempty"`
+	HABackends  []string                                        `json:"ha_backends,omitempty"`
+	ResetId     string                                          `json:"reset_id,omitempty"`
+	Probes      []ComponentProbe                                `json:"probes,omitempty"`
+	Host        *HostInformation                                `json:"host,omitempty"` //only reported when the facts have been refreshed.
+	ConfigDrift *ConfigDriftStatus                              `json:"config_drift,omitempty"`
+	Preflight   []PreflightCheck                                `json:"preflight,omitempty"` //only reported when the checks have been re-run.
 }
 
 type LightningMonkeyAgentReportStatusItem struct {
@@ -139,11 +310,30 @@ type GenerateSystemRoutingRulesRequest struct {
 	Nodes []KubernetesNodeInfo `json:"nodes"`
 }
 
+//SystemRoute is a routing rule which is owned by Lightning Monkey's agent.
+type SystemRoute struct {
+	Destination string `json:"destination"`
+	Gateway     string `json:"gateway"`
+}
+
+type ManagedSystemRoutes struct {
+	Routes     []SystemRoute `json:"routes"`
+	UpdateTime time.Time     `json:"update_time"`
+}
+
+type GetSystemRoutesResponse struct {
+	Response
+	ManagedRoutes *ManagedSystemRoutes `json:"managed_routes"`
+	//routes which are labelled by the agent in the kernel routing table.
+	ActualRoutes []SystemRoute `json:"actual_routes"`
+}
+
 type ChangeClusterAndRolesRequest struct {
-	OldClusterId string `json:"old_cluster_id"`
-	NewClusterId string `json:"new_cluster_id"`
-	IsETCDRole   bool   `json:"is_etcd_role"`
-	IsMasterRole bool   `json:"is_master_role"`
-	IsMinionRole bool   `json:"is_minion_role"`
-	IsHARole     bool   `json:"is_ha_role"`
+	OldClusterId string  `json:"old_cluster_id"`
+	NewClusterId string  `json:"new_cluster_id"`
+	IsETCDRole   bool    `json:"is_etcd_role"`
+	IsMasterRole bool    `json:"is_master_role"`
+	IsMinionRole bool    `json:"is_minion_role"`
+	IsHARole     bool    `json:"is_ha_role"`
+	NodePool     *string `json:"node_pool,omitempty"` //nil keeps the node pool of agent.
 }
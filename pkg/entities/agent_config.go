@@ -0,0 +1,76 @@
+package entities
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	AgentConfigVersion_V1 = "v1"
+)
+
+//AgentConfig is the file-based configuration of agent, the flags which are explicitly given override it.
+type AgentConfig struct {
+	Version string `json:"version"`
+	//endpoints of API servers, e.g: "http://192.168.0.10:8080".
+	Servers   []string          `json:"servers"`
+	ClusterId string            `json:"cluster_id"`
+	AgentId   string            `json:"agent_id,omitempty"` //available only for debugging.
+	Address   string            `json:"address,omitempty"`  //detected automatically if it's empty.
+	Interface string            `json:"interface"`
+	Labels    map[string]string `json:"labels,omitempty"`
+	Roles     []string          `json:"roles,omitempty"`
+	NodePool  string            `json:"node_pool,omitempty"` //must be defined in the cluster settings.
+	//the port used for listening API calls from API servers.
+	Port                   int                `json:"port"`
+	ContainerRuntime       string             `json:"container_runtime"`
+	ContainerdAddress      string             `json:"containerd_address,omitempty"`
+	HealthFailureThreshold int                `json:"health_failure_threshold"`
+	Paths                  AgentPathsConfig   `json:"paths"`
+	TLS                    *AgentTLSConfig    `json:"tls,omitempty"`
+	Token                  string             `json:"token,omitempty"` //sent to API servers as bearer token, e.g: for an authenticating proxy.
+	LogLevel               string             `json:"log_level"`
+	Timeouts               AgentTimeoutConfig `json:"timeouts"`
+}
+
+type AgentPathsConfig struct {
+	Certificates string `json:"certificates"`
+	RecoveryFile string `json:"recovery_file"`
+	//the CNI binaries in this directory are copied to "/opt/cni/bin" at startup.
+	CNIBinaries string `json:"cni_binaries"`
+}
+
+//AgentTLSConfig is used for connecting API servers by HTTPS.
+type AgentTLSConfig struct {
+	CAFile             string `json:"ca_file,omitempty"`
+	CertFile           string `json:"cert_file,omitempty"`
+	KeyFile            string `json:"key_file,omitempty"`
+	InsecureSkipVerify bool   `json:"insecure_skip_verify,omitempty"`
+}
+
+type AgentTimeoutConfig struct {
+	Register Duration `json:"register"`
+	Request  Duration `json:"request"` //for the other calls to API servers.
+}
+
+//Duration is serialized as the string like "1m30s".
+type Duration struct {
+	time.Duration
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	d.Duration = v
+	return nil
+}
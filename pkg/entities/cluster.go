@@ -37,6 +37,7 @@ const (
 	MasterSettings_PortRange                                  = "port_range"
 	MasterSettings_ResourceReservation_Kube                   = "kube_res_reserve"
 	MasterSettings_ResourceReservation_System                 = "system_res_reserve"
+	MasterSettings_KubeletSettings                            = "kubelet_settings"
 	NetworkStack_Flannel                                      = "flannel"
 	NetworkStack_Calico                                       = "calico"
 	NetworkStack_KubeRouter                                   = "kuberouter"
@@ -50,12 +51,47 @@ const (
 	EXT_DEPLOYMENT_FILEBEAT                                   = "filebeat"
 	EXT_DEPLOYMENT_HELM                                       = "helm"
 	EXT_DEPLOYMENT_METRICBEAT                                 = "metricbeat"
+	CAMode_Generate                                           = "generate"
+	CAMode_Supplied                                           = "supplied"
+	CAMode_CSR                                                = "csr"
+	CAName_Cluster                                            = "ca"
+	CAName_FrontProxy                                         = "front-proxy-ca"
+	CAName_ETCD                                               = "etcd/ca"
 	_                                         AgentStatusFlag = iota
 	AgentStatusFlag_Whatever
 	AgentStatusFlag_Running
 	AgentStatusFlag_Provisioned
 )
 
+const (
+	UpgradeStage_Images  = "images"
+	UpgradeStage_ETCD    = "etcd"
+	UpgradeStage_Master  = "master"
+	UpgradeStage_HA      = "ha"
+	UpgradeStage_Kubelet = "kubelet"
+
+	UpgradeStatus_Running   = "Running"
+	UpgradeStatus_Paused    = "Paused"
+	UpgradeStatus_Completed = "Completed"
+
+	UpgradeNodeStatus_Upgrading = "Upgrading"
+	UpgradeNodeStatus_Succeed   = "Succeed"
+	UpgradeNodeStatus_Failed    = "Failed"
+
+	ETCDSnapshotDestination_Local     = "local"
+	ETCDSnapshotDestination_APIServer = "apiserver"
+	ETCDSnapshotStatus_Succeed        = "Succeed"
+	ETCDSnapshotStatus_Failed         = "Failed"
+
+	ETCDRestoreStatus_Running   = "Running"
+	ETCDRestoreStatus_Completed = "Completed"
+	ETCDRestoreStatus_Failed    = "Failed"
+
+	ETCDRestoreNodeStatus_Restoring = "Restoring"
+	ETCDRestoreNodeStatus_Succeed   = "Succeed"
+	ETCDRestoreNodeStatus_Failed    = "Failed"
+)
+
 type Cluster struct {
 	Id                         *bson.ObjectId        `json:"id" bson:"_id"`
 	CreateTime                 time.Time             `json:"create_time" bson:"create_time"`
@@ -78,6 +114,7 @@ type LightningMonkeyClusterSettings struct {
 	CreateTime                    time.Time                                   `json:"create_time"`
 	Name                          string                                      `json:"name"`
 	ExpectedETCDCount             int                                         `json:"expected_etcd_count"`
+	ExpectedMasterCount           int                                         `json:"expected_master_count"`
 	ServiceCIDR                   string                                      `json:"service_cidr"`
 	KubernetesVersion             string                                      `json:"kubernetes_version"`
 	PodNetworkCIDR                string                                      `json:"pod_network_cidr"`
@@ -94,6 +131,76 @@ type LightningMonkeyClusterSettings struct {
 	ResourceReservation           *ResourceReservationSettings                `json:"resource_reservation"`
 	HelmSettings                  *HelmSettings                               `json:"helm_settings"`
 	ImagePullSecrets              []ImagePullSecret                           `json:"image_pull_secrets"`
+	CertificateAuthority          *CertificateAuthoritySettings               `json:"certificate_authority"`
+	BackupSettings                *ETCDBackupSettings                         `json:"backup_settings"`
+	AgentRollout                  *AgentRolloutSettings                       `json:"agent_rollout"`
+	DriftRemediation              *DriftRemediationSettings                   `json:"drift_remediation,omitempty"`
+	Kubelet                       *KubeletSettings                            `json:"kubelet,omitempty"`
+	NodePools                     []NodePoolSettings                          `json:"node_pools,omitempty"`
+}
+
+//GetExpectedMasterCount returns 1 for the clusters which are created before introducing expected master count.
+func (s LightningMonkeyClusterSettings) GetExpectedMasterCount() int {
+	if s.ExpectedMasterCount <= 0 {
+		return 1
+	}
+	return s.ExpectedMasterCount
+}
+
+//GetNodePool returns nil if the node pool is not defined.
+func (s LightningMonkeyClusterSettings) GetNodePool(name string) *NodePoolSettings {
+	for i := 0; i < len(s.NodePools); i++ {
+		if s.NodePools[i].Name == name {
+			return &s.NodePools[i]
+		}
+	}
+	return nil
+}
+
+type MasterQuorum struct {
+	Expected    int  `json:"expected"`
+	Registered  int  `json:"registered"`
+	Provisioned int  `json:"provisioned"`
+	Online      int  `json:"online"` //provisioned and running masters.
+	HasQuorum   bool `json:"has_quorum"`
+	IsCompleted bool `json:"is_completed"`
+}
+
+type ClusterBriefInformation struct {
+	Id                string       `json:"id"`
+	Name              string       `json:"name"`
+	CreateTime        time.Time    `json:"create_time"`
+	KubernetesVersion string       `json:"kubernetes_version"`
+	Status            string       `json:"status"`
+	MasterQuorum      MasterQuorum `json:"master_quorum"`
+}
+
+type CertificateAuthoritySettings struct {
+	Mode       string     `json:"mode"` //generate, supplied, csr...
+	Cluster    *CAKeyPair `json:"cluster"`
+	FrontProxy *CAKeyPair `json:"front_proxy"`
+	ETCD       *CAKeyPair `json:"etcd"`
+	Subject    *CASubject `json:"subject"`
+}
+
+type CAKeyPair struct {
+	Certificate string `json:"certificate"`
+	PrivateKey  string `json:"private_key,omitempty"`
+}
+
+type UploadSignedCARequest struct {
+	ClusterId   string `json:"cluster_id"`
+	Name        string `json:"name"` //ca, front-proxy-ca, etcd/ca
+	Certificate string `json:"certificate"`
+}
+
+type CASubject struct {
+	CommonNamePrefix   string   `json:"common_name_prefix"`
+	Organization       []string `json:"organization"`
+	OrganizationalUnit []string `json:"organizational_unit"`
+	Country            []string `json:"country"`
+	Province           []string `json:"province"`
+	Locality           []string `json:"locality"`
 }
 
 type ImagePullSecret struct {
@@ -149,6 +256,238 @@ type HASettings struct {
 	NodeCount int    `json:"count"`
 }
 
+type ReconcileResult struct {
+	Name        string    `json:"name"`
+	Succeed     bool      `json:"succeed"`
+	Reason      string    `json:"reason"`
+	LastRunTime time.Time `json:"last_run_time"`
+	CostMs      int64     `json:"cost_ms"`
+}
+
+//ClusterUpgrade is the persisted progress of a rolling Kubernetes version upgrade(or its rollback).
+type ClusterUpgrade struct {
+	Id               string                         `json:"id"`
+	FromVersion      string                         `json:"from_version"`
+	ToVersion        string                         `json:"to_version"`
+	FromImages       DockerImageCollection          `json:"from_images"`
+	ToImages         DockerImageCollection          `json:"to_images"`
+	IsRollback       bool                           `json:"is_rollback"`
+	Status           string                         `json:"status"`
+	Stages           []string                       `json:"stages"`
+	StageIndex       int                            `json:"stage_index"`
+	Nodes            map[string]*ClusterUpgradeNode `json:"nodes"` //key: agent id.
+	Reason           string                         `json:"reason"`
+	KubeletBatchSize int                            `json:"kubelet_batch_size"` //how many kubelets are restarted at the same time.
+	CreateTime       time.Time                      `json:"create_time"`
+	UpdateTime       time.Time                      `json:"update_time"`
+}
+
+type ClusterUpgradeNode struct {
+	Hostname      string    `json:"hostname"`
+	HasETCDRole   bool      `json:"has_etcd_role"`
+	HasMasterRole bool      `json:"has_master_role"`
+	HasMinionRole bool      `json:"has_minion_role"`
+	HasHARole     bool      `json:"has_ha_role"`
+	Stage         string    `json:"stage"`
+	Attempt       int       `json:"attempt"` //increased by resuming, the failure of previous attempts will be ignored.
+	Status        string    `json:"status"`
+	Reason        string    `json:"reason"`
+	UpdateTime    time.Time `json:"update_time"`
+}
+
+//GetTargetVersion returns the version which all of nodes are converging to.
+func (u *ClusterUpgrade) GetTargetVersion() string {
+	if u.IsRollback {
+		return u.FromVersion
+	}
+	return u.ToVersion
+}
+
+func (u *ClusterUpgrade) GetTargetImages() DockerImageCollection {
+	if u.IsRollback {
+		return u.FromImages
+	}
+	return u.ToImages
+}
+
+//GetCurrentStage returns empty string when all of stages have been done.
+func (u *ClusterUpgrade) GetCurrentStage() string {
+	if u.StageIndex < 0 || u.StageIndex >= len(u.Stages) {
+		return ""
+	}
+	return u.Stages[u.StageIndex]
+}
+
+//IsParticipant returns true if given stage will change the components on this node.
+func (n *ClusterUpgradeNode) IsParticipant(stage string) bool {
+	switch stage {
+	case UpgradeStage_Images:
+		return true
+	case UpgradeStage_ETCD:
+		return n.HasETCDRole
+	case UpgradeStage_Master:
+		return n.HasMasterRole
+	case UpgradeStage_HA:
+		return n.HasHARole
+	case UpgradeStage_Kubelet:
+		//master node also runs its static pods by a kubelet.
+		return n.HasMasterRole || n.HasMinionRole
+	default:
+		return false
+	}
+}
+
+func (n *ClusterUpgradeNode) HasCompleted(stage string) bool {
+	return n.Stage == stage && n.Status == UpgradeNodeStatus_Succeed
+}
+
+//IsReportedBy returns true if given agent upgrade status is the result of current attempt.
+func (n *ClusterUpgradeNode) IsReportedBy(s *AgentUpgradeStatus, version, stage string) bool {
+	return s != nil && s.Version == version && s.Stage == stage && s.Attempt == n.Attempt
+}
+
+//AgentRolloutSettings publishes the desired version of Lightning Monkey's agent to the agents of a cluster.
+type AgentRolloutSettings struct {
+	Version          string `json:"version"`
+	CanaryPercentage int    `json:"canary_percentage"` //0~100, percentage of agents which are allowed to upgrade themselves.
+}
+
+//DriftRemediationSettings is the policy of configuration drift on agents, the drift is only reported if it's nil.
+type DriftRemediationSettings struct {
+	AutoRemediate bool     `json:"auto_remediate"`
+	Components    []string `json:"components,omitempty"` //empty means all of installed components are restored.
+}
+
+//KubeletSettings overrides the fields of KubeletConfiguration on every minion, nil means the built-in defaults are used.
+type KubeletSettings struct {
+	CgroupDriver                string            `json:"cgroup_driver,omitempty"` //cgroupfs, systemd.
+	EvictionHard                map[string]string `json:"eviction_hard,omitempty"`
+	EvictionSoft                map[string]string `json:"eviction_soft,omitempty"`
+	EvictionSoftGracePeriod     map[string]string `json:"eviction_soft_grace_period,omitempty"` //golang duration format, e.g: "1m30s".
+	ImageGCHighThresholdPercent *int              `json:"image_gc_high_threshold_percent,omitempty"`
+	ImageGCLowThresholdPercent  *int              `json:"image_gc_low_threshold_percent,omitempty"`
+	SerializeImagePulls         *bool             `json:"serialize_image_pulls,omitempty"`
+	ContainerLogMaxSize         string            `json:"container_log_max_size,omitempty"` //e.g: "10Mi".
+	ContainerLogMaxFiles        *int              `json:"container_log_max_files,omitempty"`
+	KubeAPIQPS                  *int              `json:"kube_api_qps,omitempty"`
+	KubeAPIBurst                *int              `json:"kube_api_burst,omitempty"`
+	EventRecordQPS              *int              `json:"event_record_qps,omitempty"`
+	EventBurst                  *int              `json:"event_burst,omitempty"`
+	RegistryPullQPS             *int              `json:"registry_pull_qps,omitempty"`
+	RegistryBurst               *int              `json:"registry_burst,omitempty"`
+	ExtraFlags                  map[string]string `json:"extra_flags,omitempty"` //flag name without leading dashes -> value.
+}
+
+//NodePoolSettings is a named group of minions, its settings are applied when the kubelet of a minion registers itself.
+type NodePoolSettings struct {
+	Name                string                       `json:"name"`
+	Labels              map[string]string            `json:"labels,omitempty"`
+	Taints              []NodeTaint                  `json:"taints,omitempty"`
+	ResourceReservation *ResourceReservationSettings `json:"resource_reservation,omitempty"` //overrides the cluster's one.
+	Kubelet             *KubeletSettings             `json:"kubelet,omitempty"`              //the given fields override the cluster's ones.
+}
+
+type NodeTaint struct {
+	Key    string `json:"key"`
+	Value  string `json:"value,omitempty"`
+	Effect string `json:"effect"` //NoSchedule, PreferNoSchedule, NoExecute.
+}
+
+//ETCDBackupSettings is the backup policy of the provisioned ETCD cluster, nil means never take any snapshot.
+type ETCDBackupSettings struct {
+	Interval    string `json:"interval"`    //golang duration format, e.g: "6h".
+	Retention   int    `json:"retention"`   //maximum count of kept snapshots.
+	Destination string `json:"destination"` //local, apiserver.
+	Directory   string `json:"directory"`   //only used by local destination.
+}
+
+//GetInterval returns zero if the interval is illegal, it has been validated before saving cluster settings.
+func (s *ETCDBackupSettings) GetInterval() time.Duration {
+	d, err := time.ParseDuration(s.Interval)
+	if err != nil {
+		return 0
+	}
+	return d
+}
+
+//ETCDSnapshot is the result of a snapshot which is taken by the elected ETCD agent.
+type ETCDSnapshot struct {
+	Name        string    `json:"name"`
+	AgentId     string    `json:"agent_id"`
+	Hostname    string    `json:"hostname"`
+	Destination string    `json:"destination"`
+	Location    string    `json:"location"`             //file path on the agent or on the API server.
+	APIServer   string    `json:"api_server,omitempty"` //address of API server which holds the uploaded file.
+	Revision    int64     `json:"revision"`
+	Hash        int64     `json:"hash"`
+	TotalKeys   int64     `json:"total_keys"`
+	Size        int64     `json:"size"`
+	Status      string    `json:"status"`
+	Reason      string    `json:"reason"`
+	CreateTime  time.Time `json:"create_time"`
+}
+
+//ETCDRestore is the persisted progress of restoring all of ETCD members from a snapshot.
+type ETCDRestore struct {
+	Id           string                      `json:"id"`
+	SnapshotName string                      `json:"snapshot_name"`
+	Token        string                      `json:"token,omitempty"` //authorizes ETCD agents to download the snapshot.
+	Status       string                      `json:"status"`
+	Nodes        map[string]*ETCDRestoreNode `json:"nodes"` //key: agent id.
+	Reason       string                      `json:"reason"`
+	CreateTime   time.Time                   `json:"create_time"`
+	UpdateTime   time.Time                   `json:"update_time"`
+}
+
+type ETCDRestoreNode struct {
+	Hostname   string    `json:"hostname"`
+	Address    string    `json:"address"`
+	Status     string    `json:"status"`
+	Reason     string    `json:"reason"`
+	UpdateTime time.Time `json:"update_time"`
+}
+
+//ClusterSnapshots is the persisted snapshot history of a cluster, snapshots are sorted by creation time.
+type ClusterSnapshots struct {
+	Snapshots   []ETCDSnapshot                `json:"snapshots"`
+	Restore     *ETCDRestore                  `json:"restore,omitempty"`
+	Upload      *ETCDSnapshotUploadCredential `json:"upload,omitempty"`
+	TriggerTime time.Time                     `json:"trigger_time"` //requests an immediate snapshot.
+}
+
+//ETCDSnapshotUploadCredential authorizes the elected ETCD agent to upload snapshots to API servers,
+//a new token is issued whenever another agent is elected or a snapshot has been uploaded.
+type ETCDSnapshotUploadCredential struct {
+	AgentId string `json:"agent_id"`
+	Token   string `json:"token"`
+}
+
+//WithoutCredentials returns a copy of snapshot history which is safe to be exposed by APIs.
+func (s *ClusterSnapshots) WithoutCredentials() *ClusterSnapshots {
+	c := *s
+	c.Upload = nil
+	if s.Restore != nil {
+		r := *s.Restore
+		r.Token = ""
+		c.Restore = &r
+	}
+	return &c
+}
+
+//GetSnapshot returns nil if given snapshot has not been recorded.
+func (s *ClusterSnapshots) GetSnapshot(name string) *ETCDSnapshot {
+	for i := 0; i < len(s.Snapshots); i++ {
+		if s.Snapshots[i].Name == name {
+			return &s.Snapshots[i]
+		}
+	}
+	return nil
+}
+
+func (n *ETCDRestoreNode) HasFinished() bool {
+	return n.Status == ETCDRestoreNodeStatus_Succeed || n.Status == ETCDRestoreNodeStatus_Failed
+}
+
 type ClusterStatus struct {
 	Status string `json:"status"`
 	Reason string `json:"reason"`
@@ -18,10 +18,14 @@ const (
 	RESPONSEINFO                         = "HTTP_RESPONSE_INFO"
 	DockerImageDownloadType_Registry     = "REGISTRY"
 	DockerImageDownloadType_HTTP         = "HTTP"
+	//AgentTokenHeader carries the access token of agent, API server authenticates the agent by it.
+	AgentTokenHeader = "X-Agent-Token"
 )
 
 var (
 	HTTPDockerImageDownloadToken = ""
+	//ETCDSnapshotStorageDirectory keeps the uploaded ETCD snapshots, it must not be served as the payloads.
+	ETCDSnapshotStorageDirectory = "/var/lib/lightning-monkey/snapshots"
 )
 
 type Response struct {
@@ -38,6 +42,21 @@ type RegisterAgentResponse struct {
 	ClusterId      string                `json:"cluster_id"`
 	MasterSettings map[string]string     `json:"master_settings"`
 	LeaseId        int64                 `json:"lease_id"`
+	AgentSoftware  *AgentSoftware        `json:"agent_software,omitempty"`
+}
+
+//GetServerTimeResponse is used by agents to measure their clock skew against API server.
+type GetServerTimeResponse struct {
+	Response
+	Time time.Time `json:"time"`
+}
+
+//AgentSoftware tells an agent where to download the desired version of itself.
+type AgentSoftware struct {
+	Version  string `json:"version"`
+	Location string `json:"location"`
+	Checksum string `json:"checksum"` //SHA256 hex string.
+	Token    string `json:"token"`
 }
 
 type CreateClusterResponse struct {
@@ -69,12 +88,54 @@ type DockerImage struct {
 
 type AgentReportStatusResponse struct {
 	Response
-	LeaseId int64 `json:"lease_id"`
+	LeaseId          int64                     `json:"lease_id"`
+	AgentSoftware    *AgentSoftware            `json:"agent_software,omitempty"`
+	DriftRemediation *DriftRemediationSettings `json:"drift_remediation,omitempty"`
+}
+
+type SetAgentRolloutRequest struct {
+	ClusterId        string `json:"cluster_id"`
+	Version          string `json:"version"`
+	CanaryPercentage int    `json:"canary_percentage"`
+}
+
+type SetExpectedETCDCountRequest struct {
+	ClusterId         string `json:"cluster_id"`
+	ExpectedETCDCount int    `json:"expected_etcd_count"`
+}
+
+type SetDriftRemediationRequest struct {
+	ClusterId        string                    `json:"cluster_id"`
+	DriftRemediation *DriftRemediationSettings `json:"drift_remediation"` //nil means the drift is only reported.
+}
+
+type GetAgentRolloutResponse struct {
+	Response
+	Rollout       *AgentRolloutSettings `json:"rollout"`
+	Versions      map[string]int        `json:"versions"`      //count of agents by their versions, containerized agents are excluded.
+	Containerized int                   `json:"containerized"` //count of containerized agents, they're not upgradable by the rollout.
 }
 
 type GetClusterComponentStatusResponse struct {
 	Response
-	WatchPoints []WatchPoint `json:"status"`
+	WatchPoints  []WatchPoint      `json:"status"`
+	Reconcilers  []ReconcileResult `json:"reconcilers"`
+	MasterQuorum MasterQuorum      `json:"master_quorum"`
+}
+
+type GetClusterListResponse struct {
+	Response
+	Clusters []ClusterBriefInformation `json:"clusters"`
+}
+
+//AgentListFilter selects agents by their host facts, the zero value of each field matches all of agents.
+type AgentListFilter struct {
+	MinLogicalCPUs   int
+	MinMemoryMB      uint64
+	DiskType         string //"ssd" or "hdd".
+	MinDiskSizeGB    uint64 //at least one disk matches both of disk type and size.
+	CgroupVersion    int
+	ContainerRuntime string
 }
 
 type GetAgentListResponse struct {
@@ -85,14 +146,18 @@ type GetAgentListResponse struct {
 type LightningMonkeyAgentBriefInformation struct {
 	HostInformation
 
-	Id              string      `json:"id"`
-	HasETCDRole     bool        `json:"has_etcd_role"`
-	HasMasterRole   bool        `json:"has_master_role"`
-	HasMinionRole   bool        `json:"has_minion_role"`
-	HasHARole       bool        `json:"has_ha_role"`
-	Hostname        string      `json:"hostname"`
-	State           *AgentState `json:"state,omitempty"`
-	DeploymentPhase int         `json:"deployment_phase"` //0-pending, 1-deploying, 2-deployed
+	Id              string           `json:"id"`
+	HasETCDRole     bool             `json:"has_etcd_role"`
+	HasMasterRole   bool             `json:"has_master_role"`
+	HasMinionRole   bool             `json:"has_minion_role"`
+	HasHARole       bool             `json:"has_ha_role"`
+	Hostname        string           `json:"hostname"`
+	State           *AgentState      `json:"state,omitempty"`
+	DeploymentPhase int              `json:"deployment_phase"` //0-pending, 1-deploying, 2-deployed
+	Version         string           `json:"version"`
+	IsContainerized bool             `json:"is_containerized,omitempty"`
+	Preflight       []PreflightCheck `json:"preflight,omitempty"`
+	NodePool        string           `json:"node_pool,omitempty"`
 }
 
 type WatchPoint struct {
@@ -102,3 +167,42 @@ type WatchPoint struct {
 	Status            string    `json:"status"`
 	LastCheckTime     time.Time `json:"last_check_time"`
 }
+
+type UpgradeClusterRequest struct {
+	ClusterId        string `json:"cluster_id"`
+	Version          string `json:"version"`
+	KubeletBatchSize int    `json:"kubelet_batch_size"` //how many kubelets are upgraded at the same time, 0 means the default one.
+}
+
+type GetClusterUpgradeResponse struct {
+	Response
+	Upgrade *ClusterUpgrade `json:"upgrade"`
+}
+
+type GetAgentDecommissionResponse struct {
+	Response
+	Decommission *AgentDecommission `json:"decommission"`
+}
+
+type GetClusterSnapshotsResponse struct {
+	Response
+	Snapshots *ClusterSnapshots `json:"snapshots"`
+}
+
+//HABackends compares the desired HAProxy backends with the backends which are served by each of HA nodes.
+type HABackends struct {
+	Desired []string        `json:"desired"`
+	Nodes   []HANodeBackend `json:"nodes"`
+}
+
+type HANodeBackend struct {
+	Id             string   `json:"id"`
+	Hostname       string   `json:"hostname"`
+	Backends       []string `json:"backends"`
+	IsSynchronized bool     `json:"is_synchronized"`
+}
+
+type GetHABackendsResponse struct {
+	Response
+	Backends *HABackends `json:"backends"`
+}
@@ -0,0 +1,140 @@
+package k8s
+
+import (
+	"crypto/rand"
+	"fmt"
+	"github.com/g0194776/lightningmonkey/pkg/utils"
+	"io/ioutil"
+	ko "k8s.io/api/core/v1"
+	rbac_v1 "k8s.io/api/rbac/v1"
+	k8s_errors "k8s.io/apimachinery/pkg/api/errors"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"math/big"
+	"path/filepath"
+	"time"
+)
+
+const (
+	BootstrapTokenGroup   = "system:bootstrappers:lightning-monkey"
+	bootstrapTokenCharset = "0123456789abcdefghijklmnopqrstuvwxyz"
+	bootstrapKubeConfig   = `apiVersion: v1
+kind: Config
+clusters:
+- cluster:
+    certificate-authority: {{.CA}}
+    server: https://{{.SERVER}}:6443
+  name: kubernetes
+contexts:
+- context:
+    cluster: kubernetes
+    user: tls-bootstrap-token-user
+  name: tls-bootstrap-token-user@kubernetes
+current-context: tls-bootstrap-token-user@kubernetes
+preferences: {}
+users:
+- name: tls-bootstrap-token-user
+  user:
+    token: {{.TOKEN}}`
+)
+
+//CreateBootstrapToken creates a new bootstrap token secret for the given node and returns the token in "id.secret" format.
+func CreateBootstrapToken(cs *KubernetesClientSet, nodeName string, ttl time.Duration) (string, error) {
+	tokenId, err := randomBootstrapString(6)
+	if err != nil {
+		return "", err
+	}
+	tokenSecret, err := randomBootstrapString(16)
+	if err != nil {
+		return "", err
+	}
+	secret := &ko.Secret{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "bootstrap-token-" + tokenId,
+			Namespace: meta_v1.NamespaceSystem,
+		},
+		Type: ko.SecretType("bootstrap.kubernetes.io/token"),
+		StringData: map[string]string{
+			"description":                    fmt.Sprintf("Kubelet TLS bootstrap token for node: %s, generated by Lightning Monkey.", nodeName),
+			"token-id":                       tokenId,
+			"token-secret":                   tokenSecret,
+			"expiration":                     time.Now().Add(ttl).UTC().Format(time.RFC3339),
+			"usage-bootstrap-authentication": "true",
+			"usage-bootstrap-signing":        "true",
+			"auth-extra-groups":              BootstrapTokenGroup,
+		},
+	}
+	_, err = CreateK8SResource(cs, secret)
+	if err != nil {
+		return "", fmt.Errorf("Failed to create bootstrap token secret, error: %s", err.Error())
+	}
+	return fmt.Sprintf("%s.%s", tokenId, tokenSecret), nil
+}
+
+//EnsureKubeletBootstrapRBAC creates RBAC objects which allow bootstrapping kubelets to create CSRs and get them auto-approved.
+func EnsureKubeletBootstrapRBAC(cs *KubernetesClientSet) error {
+	bindings := []*rbac_v1.ClusterRoleBinding{
+		newGroupClusterRoleBinding("lightning-monkey:kubelet-bootstrap", "system:node-bootstrapper", BootstrapTokenGroup),
+		newGroupClusterRoleBinding("lightning-monkey:node-autoapprove-bootstrap", "system:certificates.k8s.io:certificatesigningrequests:nodeclient", BootstrapTokenGroup),
+		newGroupClusterRoleBinding("lightning-monkey:node-autoapprove-certificate-rotation", "system:certificates.k8s.io:certificatesigningrequests:selfnodeclient", "system:nodes"),
+	}
+	for i := 0; i < len(bindings); i++ {
+		existed, err := IsKubernetesResourceExists(cs, bindings[i])
+		if err != nil && !k8s_errors.IsNotFound(err) {
+			return fmt.Errorf("Failed to check cluster role binding: %s, error: %s", bindings[i].Name, err.Error())
+		}
+		if existed {
+			continue
+		}
+		_, err = CreateK8SResource(cs, bindings[i])
+		if err != nil && !k8s_errors.IsAlreadyExists(err) {
+			return fmt.Errorf("Failed to create cluster role binding: %s, error: %s", bindings[i].Name, err.Error())
+		}
+	}
+	return nil
+}
+
+//GenerateKubeletBootstrapConfig writes bootstrap-kubelet.conf and the kubelet settings without touching any CA private key.
+func GenerateKubeletBootstrapConfig(certPath, masterAPIAddr, token string, replacementSlots map[string]string) error {
+	content, err := utils.TemplateReplace(bootstrapKubeConfig, map[string]string{
+		"CA":     filepath.Join(certPath, "ca.crt"),
+		"SERVER": masterAPIAddr,
+		"TOKEN":  token,
+	})
+	if err != nil {
+		return fmt.Errorf("Failed to replace bootstrap kubeconfig template content, error: %s", err.Error())
+	}
+	err = ioutil.WriteFile(filepath.Join(certPath, "bootstrap-kubelet.conf"), []byte(content), 0600)
+	if err != nil {
+		return fmt.Errorf("Failed to write bootstrap kubeconfig file, error: %s", err.Error())
+	}
+	return writeKubeletSettings(certPath, replacementSlots)
+}
+
+func newGroupClusterRoleBinding(name, role, group string) *rbac_v1.ClusterRoleBinding {
+	return &rbac_v1.ClusterRoleBinding{
+		ObjectMeta: meta_v1.ObjectMeta{Name: name},
+		RoleRef: rbac_v1.RoleRef{
+			APIGroup: "rbac.authorization.k8s.io",
+			Kind:     "ClusterRole",
+			Name:     role,
+		},
+		Subjects: []rbac_v1.Subject{{
+			APIGroup: "rbac.authorization.k8s.io",
+			Kind:     "Group",
+			Name:     group,
+		}},
+	}
+}
+
+func randomBootstrapString(length int) (string, error) {
+	data := make([]byte, length)
+	max := big.NewInt(int64(len(bootstrapTokenCharset)))
+	for i := 0; i < length; i++ {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", fmt.Errorf("Failed to generate random bootstrap token, error: %s", err.Error())
+		}
+		data[i] = bootstrapTokenCharset[n.Int64()]
+	}
+	return string(data), nil
+}
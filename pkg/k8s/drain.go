@@ -0,0 +1,152 @@
+package k8s
+
+import (
+	"fmt"
+	"github.com/sirupsen/logrus"
+	ko "k8s.io/api/core/v1"
+	policy_v1beta "k8s.io/api/policy/v1beta1"
+	k8s_errors "k8s.io/apimachinery/pkg/api/errors"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"time"
+)
+
+const (
+	mirrorPodAnnotation = "kubernetes.io/config.mirror"
+	drainRetryInterval  = time.Second * 5
+)
+
+//CordonNode marks the given node as unschedulable.
+func CordonNode(cs *KubernetesClientSet, nodeName string) error {
+	node, err := cs.CoreClient.CoreV1().Nodes().Get(nodeName, meta_v1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("Failed to retrieve Kubernetes node: %s, error: %s", nodeName, err.Error())
+	}
+	if node.Spec.Unschedulable {
+		return nil
+	}
+	node.Spec.Unschedulable = true
+	_, err = cs.CoreClient.CoreV1().Nodes().Update(node)
+	if err != nil {
+		return fmt.Errorf("Failed to cordon Kubernetes node: %s, error: %s", nodeName, err.Error())
+	}
+	return nil
+}
+
+//UncordonNode marks the given node as schedulable again, it's not an error if the node has already gone.
+func UncordonNode(cs *KubernetesClientSet, nodeName string) error {
+	node, err := cs.CoreClient.CoreV1().Nodes().Get(nodeName, meta_v1.GetOptions{})
+	if err != nil {
+		if k8s_errors.IsNotFound(err) {
+			return nil
+		}
+		return fmt.Errorf("Failed to retrieve Kubernetes node: %s, error: %s", nodeName, err.Error())
+	}
+	if !node.Spec.Unschedulable {
+		return nil
+	}
+	node.Spec.Unschedulable = false
+	_, err = cs.CoreClient.CoreV1().Nodes().Update(node)
+	if err != nil {
+		return fmt.Errorf("Failed to uncordon Kubernetes node: %s, error: %s", nodeName, err.Error())
+	}
+	return nil
+}
+
+//IsNodeExists returns false if the given node has been deleted.
+func IsNodeExists(cs *KubernetesClientSet, nodeName string) (bool, error) {
+	_, err := cs.CoreClient.CoreV1().Nodes().Get(nodeName, meta_v1.GetOptions{})
+	if err == nil {
+		return true, nil
+	}
+	if k8s_errors.IsNotFound(err) {
+		return false, nil
+	}
+	return false, fmt.Errorf("Failed to retrieve Kubernetes node: %s, error: %s", nodeName, err.Error())
+}
+
+//DrainNode evicts all of pods on the given node through the eviction API, so that PodDisruptionBudgets are respected.
+//Pods managed by DaemonSet and mirror pods are ignored.
+func DrainNode(cs *KubernetesClientSet, nodeName string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	pods, err := getDrainablePods(cs, nodeName)
+	if err != nil {
+		return err
+	}
+	pending := map[string]ko.Pod{}
+	for i := 0; i < len(pods); i++ {
+		pending[pods[i].Namespace+"/"+pods[i].Name] = pods[i]
+	}
+	evicted := map[string]bool{}
+	for len(pending) > 0 {
+		if time.Now().After(deadline) {
+			return fmt.Errorf("Timed out draining Kubernetes node: %s, %d pods are still running.", nodeName, len(pending))
+		}
+		for key, pod := range pending {
+			if !evicted[key] {
+				err = cs.CoreClient.PolicyV1beta1().Evictions(pod.Namespace).Evict(&policy_v1beta.Eviction{
+					ObjectMeta: meta_v1.ObjectMeta{Name: pod.Name, Namespace: pod.Namespace},
+				})
+				if err == nil || k8s_errors.IsNotFound(err) {
+					evicted[key] = true
+				} else if k8s_errors.IsTooManyRequests(err) {
+					//disallowed by PodDisruptionBudget, retry it later.
+					logrus.Debugf("Waiting, eviction of pod %s is disallowed by disruption budget.", key)
+					continue
+				} else {
+					return fmt.Errorf("Failed to evict pod %s from Kubernetes node: %s, error: %s", key, nodeName, err.Error())
+				}
+			}
+			p, err := cs.CoreClient.CoreV1().Pods(pod.Namespace).Get(pod.Name, meta_v1.GetOptions{})
+			if err != nil && !k8s_errors.IsNotFound(err) {
+				return fmt.Errorf("Failed to retrieve pod %s, error: %s", key, err.Error())
+			}
+			//a pod re-created with the same name is a different one.
+			if err != nil || p.UID != pod.UID {
+				delete(pending, key)
+			}
+		}
+		if len(pending) > 0 {
+			time.Sleep(drainRetryInterval)
+		}
+	}
+	return nil
+}
+
+//DeleteNode deletes the given node object, it's not an error if the node has already gone.
+func DeleteNode(cs *KubernetesClientSet, nodeName string) error {
+	err := cs.CoreClient.CoreV1().Nodes().Delete(nodeName, &meta_v1.DeleteOptions{})
+	if err != nil && !k8s_errors.IsNotFound(err) {
+		return fmt.Errorf("Failed to delete Kubernetes node: %s, error: %s", nodeName, err.Error())
+	}
+	return nil
+}
+
+func getDrainablePods(cs *KubernetesClientSet, nodeName string) ([]ko.Pod, error) {
+	pl, err := cs.CoreClient.CoreV1().Pods(meta_v1.NamespaceAll).List(meta_v1.ListOptions{FieldSelector: "spec.nodeName=" + nodeName})
+	if err != nil {
+		return nil, fmt.Errorf("Failed to list pods on Kubernetes node: %s, error: %s", nodeName, err.Error())
+	}
+	pods := []ko.Pod{}
+	for i := 0; i < len(pl.Items); i++ {
+		if _, isOK := pl.Items[i].Annotations[mirrorPodAnnotation]; isOK {
+			continue
+		}
+		if pl.Items[i].Status.Phase == ko.PodSucceeded || pl.Items[i].Status.Phase == ko.PodFailed {
+			continue
+		}
+		if isDaemonSetPod(&pl.Items[i]) {
+			continue
+		}
+		pods = append(pods, pl.Items[i])
+	}
+	return pods, nil
+}
+
+func isDaemonSetPod(pod *ko.Pod) bool {
+	for i := 0; i < len(pod.OwnerReferences); i++ {
+		if pod.OwnerReferences[i].Kind == "DaemonSet" {
+			return true
+		}
+	}
+	return false
+}
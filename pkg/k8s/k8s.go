@@ -25,6 +25,7 @@ import (
 	agg_v1beta "k8s.io/kube-aggregator/pkg/client/clientset_generated/clientset/typed/apiregistration/v1beta1"
 	"os/exec"
 	"path/filepath"
+	"sigs.k8s.io/yaml"
 )
 
 const (
@@ -128,13 +129,40 @@ func GenerateKubeletConfig(certPath, masterAPIAddr string, replacementSlots map[
 	if err = cmd.Wait(); err != nil {
 		return err
 	}
+	return writeKubeletSettings(certPath, replacementSlots)
+}
+
+//RenderKubeletSettings returns the content of "kubelet_settings.yml" which is generated by the master settings.
+//The template is kept as it is if the cluster has no kubelet settings.
+func RenderKubeletSettings(replacementSlots map[string]string) (string, error) {
 	tpl, err := utils.TemplateReplace(kubeletSettings, map[string]string{
 		"MAXPODS": replacementSlots[entities.MasterSettings_MaxPodCountPerNode],
 		"DOMAIN":  replacementSlots[entities.MasterSettings_ServiceDNSDomain],
 		"DNSIP":   replacementSlots[entities.MasterSettings_ServiceDNSClusterIP],
 	})
 	if err != nil {
-		return fmt.Errorf("Failed to replace Kubelet configuration template content, error: %s", err.Error())
+		return "", fmt.Errorf("Failed to replace Kubelet configuration template content, error: %s", err.Error())
+	}
+	settings, err := utils.ParseKubeletSettings(replacementSlots)
+	if err != nil || settings == nil {
+		return tpl, err
+	}
+	config := map[string]interface{}{}
+	if err = yaml.Unmarshal([]byte(tpl), &config); err != nil {
+		return "", fmt.Errorf("Failed to parse Kubelet configuration template content, error: %s", err.Error())
+	}
+	utils.ApplyKubeletSettings(config, settings)
+	data, err := yaml.Marshal(config)
+	if err != nil {
+		return "", fmt.Errorf("Failed to generate Kubelet configuration, error: %s", err.Error())
+	}
+	return string(data), nil
+}
+
+func writeKubeletSettings(certPath string, replacementSlots map[string]string) error {
+	tpl, err := RenderKubeletSettings(replacementSlots)
+	if err != nil {
+		return err
 	}
 	err = ioutil.WriteFile(filepath.Join(certPath, "kubelet_settings.yml"), []byte(tpl), 0644)
 	if err != nil {
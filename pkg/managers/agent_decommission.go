@@ -0,0 +1,64 @@
+package managers
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/g0194776/lightningmonkey/pkg/common"
+	"github.com/g0194776/lightningmonkey/pkg/entities"
+	"github.com/g0194776/lightningmonkey/pkg/storage"
+	"time"
+)
+
+//DecommissionAgent takes a minion out of the cluster, it drains & deletes the Kubernetes node,
+//tears down all of local components and finally returns the host to the pool or marks it as deleted.
+//It only saves the request, the progress is moved forward by the reconcile leader and could be queried by GetAgentDecommission.
+func DecommissionAgent(clusterId, agentId string, drainTimeout time.Duration, isDelete bool) (*entities.AgentDecommission, error) {
+	cluster, err := getClusterController(clusterId)
+	if err != nil {
+		return nil, err
+	}
+	agent, err := cluster.GetCachedAgent(agentId)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to retrieve agent information from cache, cluster-id: %s, agent-id: %s, error: %s", clusterId, agentId, err.Error())
+	}
+	if agent == nil || agent.State == nil {
+		return nil, fmt.Errorf("Agent: %s not found or it's offline!", agentId)
+	}
+	if agent.HasETCDRole || agent.HasMasterRole {
+		return nil, fmt.Errorf("Agent: %s has ETCD or Kubernetes master role, only minion and HA nodes could be decommissioned.", agentId)
+	}
+	d := &entities.AgentDecommission{
+		AgentId:             agentId,
+		Hostname:            agent.Hostname,
+		DrainTimeoutSeconds: int(drainTimeout / time.Second),
+		IsDelete:            isDelete,
+		Status:              entities.DecommissionStatus_Draining,
+		CreateTime:          time.Now(),
+		UpdateTime:          time.Now(),
+	}
+	if agent.HasMinionRole && agent.State.HasProvisionedMinion {
+		d.NodeName = agent.State.LastReportIP
+	}
+	err = storage.CompareAndSwap(common.StorageDriver, fmt.Sprintf("/lightning-monkey/clusters/%s/decommissions/%s", clusterId, agentId), func(value []byte) ([]byte, error) {
+		if value != nil {
+			previous := entities.AgentDecommission{}
+			if err := json.Unmarshal(value, &previous); err == nil && !previous.IsFinished() {
+				return nil, fmt.Errorf("Agent: %s is being decommissioned, status: %s", agentId, previous.Status)
+			}
+		}
+		return json.Marshal(d)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+//GetAgentDecommission returns the decommission progress of given agent, nil means it has never been decommissioned.
+func GetAgentDecommission(clusterId, agentId string) (*entities.AgentDecommission, error) {
+	cluster, err := getClusterController(clusterId)
+	if err != nil {
+		return nil, err
+	}
+	return cluster.GetDecommission(agentId), nil
+}
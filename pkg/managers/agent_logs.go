@@ -0,0 +1,90 @@
+package managers
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/g0194776/lightningmonkey/pkg/common"
+	"github.com/g0194776/lightningmonkey/pkg/entities"
+	"github.com/g0194776/lightningmonkey/pkg/utils"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+var (
+	//no overall timeout, logs might be streamed for a long time.
+	agentLogsClient = &http.Client{
+		Transport: &http.Transport{
+			DialContext:           (&net.Dialer{Timeout: time.Second * 5}).DialContext,
+			ResponseHeaderTimeout: time.Second * 30,
+		},
+	}
+)
+
+//OpenAgentLogs proxies the logs of the agent itself or one of components it manages, the caller must close the reader.
+//all of clusters are searched for the agent if the cluster-id is empty.
+func OpenAgentLogs(clusterId, agentId, component string, tail int, since string) (io.ReadCloser, error) {
+	agent, err := findCachedAgent(clusterId, agentId)
+	if err != nil {
+		return nil, err
+	}
+	if agent.State == nil || agent.State.LastReportIP == "" {
+		return nil, fmt.Errorf("Agent: %s is offline!", agentId)
+	}
+	if agent.AccessToken == "" {
+		return nil, fmt.Errorf("Agent: %s has not reported its access token, it might be a previous version.", agentId)
+	}
+	params := url.Values{}
+	params.Set("component", component)
+	params.Set("tail", fmt.Sprintf("%d", tail))
+	params.Set("since", since)
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("http://%s:%d/logs?%s", agent.State.LastReportIP, agent.ListenPort, params.Encode()), nil)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to retrieve logs from agent: %s, error: %s", agentId, err.Error())
+	}
+	utils.SetAgentAccessToken(req.Header, agent.AccessToken)
+	rsp, err := agentLogsClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to retrieve logs from agent: %s, error: %s", agentId, err.Error())
+	}
+	if rsp.StatusCode == http.StatusOK {
+		return rsp.Body, nil
+	}
+	defer rsp.Body.Close()
+	data, _ := ioutil.ReadAll(rsp.Body)
+	genericResponse := entities.Response{}
+	if err = json.Unmarshal(data, &genericResponse); err == nil && genericResponse.Reason != "" {
+		return nil, fmt.Errorf("Failed to retrieve logs from agent: %s, remote agent returned HTTP status code: %d, reason: %s", agentId, rsp.StatusCode, genericResponse.Reason)
+	}
+	return nil, fmt.Errorf("Failed to retrieve logs from agent: %s, remote agent returned HTTP status code: %d, body: %s", agentId, rsp.StatusCode, strings.TrimSpace(string(data)))
+}
+
+func findCachedAgent(clusterId, agentId string) (*entities.LightningMonkeyAgent, error) {
+	if clusterId != "" {
+		cluster, err := getClusterController(clusterId)
+		if err != nil {
+			return nil, err
+		}
+		agent, err := cluster.GetCachedAgent(agentId)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to retrieve agent information from cache, cluster-id: %s, agent-id: %s, error: %s", clusterId, agentId, err.Error())
+		}
+		if agent == nil {
+			return nil, fmt.Errorf("Agent: %s not found!", agentId)
+		}
+		return agent, nil
+	}
+	clusters := common.ClusterManager.GetClusters()
+	for i := 0; i < len(clusters); i++ {
+		//disposed clusters return errors.
+		agent, err := clusters[i].GetCachedAgent(agentId)
+		if err == nil && agent != nil {
+			return agent, nil
+		}
+	}
+	return nil, fmt.Errorf("Agent: %s not found!", agentId)
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/g0194776/lightningmonkey/pkg/entities"
 	uuid "github.com/satori/go.uuid"
 	"github.com/sirupsen/logrus"
+	"reflect"
 	"strings"
 	"time"
 )
@@ -29,6 +30,13 @@ func RegisterAgent(agent *entities.LightningMonkeyAgent) (*entities.LightningMon
 	if cluster.GetStatus() == entities.ClusterBlockedAgentRegistering {
 		return nil, "", "", -1, fmt.Errorf("Target cluster: %s has been blocked agent registering, try it later.", settings.Name)
 	}
+	if settings.CertificateAuthority != nil && settings.CertificateAuthority.Mode == entities.CAMode_CSR && !IsCertificateAuthorityReady(settings, cluster.GetCertificates()) {
+		return nil, "", "", -1, fmt.Errorf("Target cluster: %s is waiting for signed CA certificates, try it later.", settings.Name)
+	}
+	//agents in the resource pool are assigned to the node pool when they are transferred to a cluster.
+	if agent.NodePool != "" && agent.ClusterId != uuid.Nil.String() && settings.GetNodePool(agent.NodePool) == nil {
+		return nil, "", "", -1, fmt.Errorf("Node pool: %s is not defined in cluster: %s.", agent.NodePool, settings.Name)
+	}
 	preAgent, err := common.ClusterManager.GetAgentFromETCD(agent.ClusterId, agent.Id)
 	if err != nil {
 		return nil, "", "", -1, fmt.Errorf("Failed to retrieve agent information from database, error: %s", err.Error())
@@ -48,7 +56,18 @@ func RegisterAgent(agent *entities.LightningMonkeyAgent) (*entities.LightningMon
 		if preAgent.IsDelete {
 			return nil, "", "", -1, errors.New("Target registered agent has been deleted, Please do not reuse it again!")
 		}
-		//duplicated registering.
+		//duplicated registering, the agent might have upgraded itself, been restarted with a new access token or node pool or the host might have been fixed.
+		if preAgent.Version != agent.Version || preAgent.IsContainerized != agent.IsContainerized || preAgent.AccessToken != agent.AccessToken || preAgent.NodePool != agent.NodePool || !reflect.DeepEqual(preAgent.Preflight, agent.Preflight) {
+			preAgent.Version = agent.Version
+			preAgent.IsContainerized = agent.IsContainerized
+			preAgent.AccessToken = agent.AccessToken
+			preAgent.NodePool = agent.NodePool
+			preAgent.Preflight = agent.Preflight
+			err = common.SaveAgentSettingsOnly(preAgent)
+			if err != nil {
+				return nil, "", "", -1, fmt.Errorf("Failed to update agent version, access token, node pool and preflight checks, error: %s", err.Error())
+			}
+		}
 		return &settings, preAgent.Id, preAgent.ClusterId, -1, nil
 	}
 	//generate admin config for master role agent.
@@ -131,9 +150,23 @@ func AgentReportStatus(clusterId, agentId string, status entities.LightningMonke
 		//considered to regenerate it which currently held on client-side.
 		status.LeaseId = -1
 	}
+	if agent.IsDelete {
+		return -1, fmt.Errorf("Agent: %s has been decommissioned!", agentId)
+	}
+	if status.Host != nil || status.Preflight != nil {
+		updateAgentReportedFacts(agent, status.Host, status.Preflight)
+	}
 	state := entities.AgentState{}
 	state.LastReportIP = status.IP
 	state.LastReportTime = time.Now()
+	state.Upgrade = status.Upgrade
+	state.ETCDMembers = status.ETCDMembers
+	state.Snapshot = status.Snapshot
+	state.Restore = status.Restore
+	state.HABackends = status.HABackends
+	state.ResetId = status.ResetId
+	state.Probes = status.Probes
+	state.ConfigDrift = status.ConfigDrift
 	//detect ETCD deployment status.
 	if v, isOK := status.Items[entities.AgentJob_Deploy_ETCD]; isOK {
 		state.HasProvisionedETCD = v.HasProvisioned
@@ -164,3 +197,29 @@ func AgentReportStatus(clusterId, agentId string, status entities.LightningMonke
 	}
 	return common.SaveAgentStateOnly(clusterId, agentId, status.LeaseId, &state)
 }
+
+//updateAgentReportedFacts saves the refreshed host facts and preflight checks along with agent's settings only if
+//they have been changed, nil means it has not been refreshed.
+func updateAgentReportedFacts(agent *entities.LightningMonkeyAgent, host *entities.HostInformation, preflight []entities.PreflightCheck) {
+	newAgent := *agent
+	changed := false
+	if host != nil {
+		old := agent.HostInformation
+		old.UpdateTime = host.UpdateTime
+		if !reflect.DeepEqual(old, *host) {
+			newAgent.HostInformation = *host
+			changed = true
+		}
+	}
+	if preflight != nil && !reflect.DeepEqual(agent.Preflight, preflight) {
+		newAgent.Preflight = preflight
+		changed = true
+	}
+	if !changed {
+		return
+	}
+	err := common.SaveAgentSettingsOnly(&newAgent)
+	if err != nil {
+		logrus.Errorf("Failed to save host information of agent %s, error: %s", agent.Id, err.Error())
+	}
+}
@@ -0,0 +1,79 @@
+package managers
+
+import (
+	"fmt"
+	"github.com/g0194776/lightningmonkey/pkg/cache"
+	"github.com/g0194776/lightningmonkey/pkg/common"
+	"github.com/g0194776/lightningmonkey/pkg/entities"
+	uuid "github.com/satori/go.uuid"
+	"github.com/sirupsen/logrus"
+	"strconv"
+	"time"
+)
+
+const (
+	resetTimeout      = time.Minute * 10
+	resetPollInterval = time.Second * 3
+)
+
+//ResetAgent wipes all of components which were provisioned on the agent's host and returns it to the pool.
+//Resetting an ETCD or Kubernetes master node damages the cluster, it must be forced explicitly.
+func ResetAgent(clusterId, agentId string, wipeData, isForced bool, drainTimeout time.Duration) error {
+	cluster, err := getClusterController(clusterId)
+	if err != nil {
+		return err
+	}
+	agent, err := cluster.GetCachedAgent(agentId)
+	if err != nil {
+		return fmt.Errorf("Failed to retrieve agent information from cache, cluster-id: %s, agent-id: %s, error: %s", clusterId, agentId, err.Error())
+	}
+	if agent == nil || agent.State == nil {
+		return fmt.Errorf("Agent: %s not found or it's offline!", agentId)
+	}
+	if (agent.HasETCDRole || agent.HasMasterRole) && !isForced {
+		return fmt.Errorf("Agent: %s has ETCD or Kubernetes master role, it could only be reset forcibly.", agentId)
+	}
+	if agent.HasMinionRole && agent.State.HasProvisionedMinion {
+		err = cluster.DecommissionKubernetesNode(agent.State.LastReportIP, drainTimeout)
+		if err != nil {
+			return fmt.Errorf("Failed to decommission Kubernetes node of agent: %s, error: %s", agentId, err.Error())
+		}
+	}
+	resetId := uuid.NewV4().String()
+	logrus.Warnf("Resetting agent: %s(%s), wipe data: %t...", agentId, agent.Hostname, wipeData)
+	cluster.EnqueueAgentJob(agentId, entities.AgentJob{Name: entities.AgentJob_Reset, Arguments: map[string]string{
+		"reset-id":  resetId,
+		"wipe-data": strconv.FormatBool(wipeData),
+	}})
+	//stops deploying components to the agent again until it leaves.
+	defer cluster.RemoveAgentJob(agentId)
+	err = waitAgentReset(cluster, agentId, resetId)
+	if err != nil {
+		return err
+	}
+	//the agent leaves its node pool as well.
+	noPool := ""
+	return common.ClusterManager.TransferAgentToCluster(clusterId, uuid.Nil.String(), agent, false, false, false, false, &noPool)
+}
+
+//waitAgentReset waits until the agent reports the identity of the finished reset job.
+func waitAgentReset(cluster cache.ClusterController, agentId, resetId string) error {
+	deadline := time.Now().Add(resetTimeout)
+	for time.Now().Before(deadline) {
+		time.Sleep(resetPollInterval)
+		if cluster.HasPendingAgentJob(agentId) {
+			continue
+		}
+		agent, err := cluster.GetCachedAgent(agentId)
+		if err != nil {
+			return fmt.Errorf("Failed to retrieve agent information from cache, cluster-id: %s, agent-id: %s, error: %s", cluster.GetClusterId(), agentId, err.Error())
+		}
+		if agent == nil || agent.State == nil {
+			return fmt.Errorf("Agent: %s went offline during resetting!", agentId)
+		}
+		if agent.State.ResetId == resetId {
+			return nil
+		}
+	}
+	return fmt.Errorf("Timed out waiting for agent: %s to reset its host.", agentId)
+}
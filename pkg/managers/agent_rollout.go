@@ -0,0 +1,143 @@
+package managers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/g0194776/lightningmonkey/pkg/common"
+	"github.com/g0194776/lightningmonkey/pkg/entities"
+	"github.com/g0194776/lightningmonkey/pkg/storage"
+	"github.com/g0194776/lightningmonkey/pkg/utils"
+	"github.com/sirupsen/logrus"
+	"io"
+	"os"
+	"path"
+	"regexp"
+	"sync"
+	"time"
+)
+
+var (
+	agentVersionRegex = regexp.MustCompile(`^[0-9A-Za-z][0-9A-Za-z._-]*$`)
+	checksumLockObj   = &sync.Mutex{}
+	checksums         = map[string]agentSoftwareChecksum{}
+)
+
+type agentSoftwareChecksum struct {
+	modTime  time.Time
+	checksum string
+}
+
+//GetAgentSoftware returns the desired version of agent's software if the given agent is selected to upgrade itself,
+//agents of previous versions never report their versions and are not capable of upgrading themselves,
+//containerized agents are not upgradable as well because the replaced binary would be lost once the container is recreated.
+func GetAgentSoftware(settings entities.LightningMonkeyClusterSettings, agentId, agentVersion string, isContainerized bool) *entities.AgentSoftware {
+	ar := settings.AgentRollout
+	if ar == nil || ar.Version == "" || agentVersion == "" || agentVersion == ar.Version || isContainerized {
+		return nil
+	}
+	if !utils.IsCanaryAgent(agentId, ar.CanaryPercentage) {
+		return nil
+	}
+	checksum, err := getAgentSoftwareChecksum(ar.Version)
+	if err != nil {
+		logrus.Errorf("Failed to calculate checksum of agent software %s, error: %s", ar.Version, err.Error())
+		return nil
+	}
+	return &entities.AgentSoftware{
+		Version:  ar.Version,
+		Location: utils.GetAgentSoftwareLocation(ar.Version),
+		Checksum: checksum,
+		Token:    entities.HTTPDockerImageDownloadToken,
+	}
+}
+
+//GetAgentSoftwareByAgentId is used by agents' status reporting, they pick up the rollout without registering again.
+func GetAgentSoftwareByAgentId(clusterId, agentId string) *entities.AgentSoftware {
+	cluster, err := getClusterController(clusterId)
+	if err != nil {
+		return nil
+	}
+	agent, err := cluster.GetCachedAgent(agentId)
+	if err != nil || agent == nil {
+		return nil
+	}
+	return GetAgentSoftware(cluster.GetSettings(), agentId, agent.Version, agent.IsContainerized)
+}
+
+//GetAgentRollout counts agents by their versions, containerized agents are counted separately because they never
+//converge to the rollout version, they're upgraded by replacing their images.
+func GetAgentRollout(clusterId string) (*entities.AgentRolloutSettings, map[string]int, int, error) {
+	cluster, err := getClusterController(clusterId)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	agents, err := cluster.GetAgentList(false)
+	if err != nil {
+		return nil, nil, 0, fmt.Errorf("Failed to list agents of cluster: %s, error: %s", clusterId, err.Error())
+	}
+	versions := map[string]int{}
+	containerized := 0
+	for i := 0; i < len(agents); i++ {
+		if agents[i].IsContainerized {
+			containerized++
+			continue
+		}
+		versions[agents[i].Version]++
+	}
+	return cluster.GetSettings().AgentRollout, versions, containerized, nil
+}
+
+//SetAgentRollout publishes the desired agent version to the cluster, an empty version stops the rollout.
+func SetAgentRollout(clusterId, version string, canaryPercentage int) error {
+	if _, err := getClusterController(clusterId); err != nil {
+		return err
+	}
+	var rollout *entities.AgentRolloutSettings
+	if version != "" {
+		if !agentVersionRegex.MatchString(version) {
+			return fmt.Errorf("Illegal agent version: %s", version)
+		}
+		if canaryPercentage < 0 || canaryPercentage > 100 {
+			return fmt.Errorf("Illegal canary percentage: %d, it must be between 0 and 100.", canaryPercentage)
+		}
+		if _, err := getAgentSoftwareChecksum(version); err != nil {
+			return fmt.Errorf("Agent software %s is not available in the registry, error: %s", version, err.Error())
+		}
+		rollout = &entities.AgentRolloutSettings{Version: version, CanaryPercentage: canaryPercentage}
+	}
+	err := storage.UpdateClusterSettings(common.StorageDriver, clusterId, func(settings *entities.LightningMonkeyClusterSettings) (bool, error) {
+		settings.AgentRollout = rollout
+		return true, nil
+	})
+	if err != nil {
+		return fmt.Errorf("Failed to update agent rollout settings of cluster %s, error: %s", clusterId, err.Error())
+	}
+	return nil
+}
+
+//getAgentSoftwareChecksum caches the checksum until the file has been replaced.
+func getAgentSoftwareChecksum(version string) (string, error) {
+	filePath := path.Join(path.Dir(os.Args[0]), utils.GetAgentSoftwareLocation(version))
+	fi, err := os.Stat(filePath)
+	if err != nil {
+		return "", err
+	}
+	checksumLockObj.Lock()
+	defer checksumLockObj.Unlock()
+	if c, isOK := checksums[version]; isOK && c.modTime.Equal(fi.ModTime()) {
+		return c.checksum, nil
+	}
+	f, err := os.Open(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	hasher := sha256.New()
+	if _, err = io.Copy(hasher, f); err != nil {
+		return "", err
+	}
+	checksum := hex.EncodeToString(hasher.Sum(nil))
+	checksums[version] = agentSoftwareChecksum{modTime: fi.ModTime(), checksum: checksum}
+	return checksum, nil
+}
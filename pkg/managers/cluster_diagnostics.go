@@ -0,0 +1,123 @@
+package managers
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/g0194776/lightningmonkey/pkg/entities"
+	"github.com/g0194776/lightningmonkey/pkg/utils"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	agentDiagnosticsTimeout = time.Minute
+)
+
+//CollectClusterDiagnostics builds an archive which contains the redacted archives of all of online agents in the cluster
+//and the view of API server, the agents which failed to respond are listed in "errors.txt".
+func CollectClusterDiagnostics(clusterId string) ([]byte, error) {
+	cluster, err := getClusterController(clusterId)
+	if err != nil {
+		return nil, err
+	}
+	agentCache := cluster.DumpAgentCache()
+	agents := map[string]entities.CachedAgent{}
+	for _, as := range agentCache {
+		for i := 0; i < len(as); i++ {
+			agents[as[i].Id] = as[i]
+		}
+	}
+	//redacts the credentials which are never needed for debugging, the copies above keep the access tokens for fetching.
+	for _, as := range agentCache {
+		for i := 0; i < len(as); i++ {
+			as[i].AccessToken = ""
+			as[i].AdminCertificate = ""
+		}
+	}
+	lock := sync.Mutex{}
+	archives := map[string][]byte{}
+	failures := []string{}
+	wg := sync.WaitGroup{}
+	for _, agent := range agents {
+		wg.Add(1)
+		go func(agent entities.CachedAgent) {
+			defer wg.Done()
+			name := fmt.Sprintf("agents/%s-%s.tar.gz", agent.Hostname, agent.Id)
+			data, err := fetchAgentDiagnostics(agent)
+			lock.Lock()
+			defer lock.Unlock()
+			if err != nil {
+				failures = append(failures, fmt.Sprintf("%s(%s): %s", agent.Id, agent.Hostname, err.Error()))
+				return
+			}
+			archives[name] = data
+		}(agent)
+	}
+	wg.Wait()
+	buffer := bytes.Buffer{}
+	da := utils.NewDiagnosticsArchive(&buffer)
+	if err = da.AddJSON("cluster/settings.json", cluster.GetSettings()); err != nil {
+		return nil, err
+	}
+	if err = da.AddJSON("cluster/agent_cache.json", agentCache); err != nil {
+		return nil, err
+	}
+	if err = da.AddJSON("cluster/watch_points.json", cluster.GetWachPoints()); err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(archives))
+	for name := range archives {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for i := 0; i < len(names); i++ {
+		if err = da.AddArchive(names[i], archives[names[i]]); err != nil {
+			return nil, err
+		}
+	}
+	if len(failures) > 0 {
+		sort.Strings(failures)
+		if err = da.AddFile("errors.txt", []byte(strings.Join(failures, "\n")+"\n")); err != nil {
+			return nil, err
+		}
+	}
+	if err = da.Close(); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+func fetchAgentDiagnostics(agent entities.CachedAgent) ([]byte, error) {
+	if !agent.IsRunning() {
+		return nil, fmt.Errorf("Agent: %s is offline!", agent.Id)
+	}
+	if agent.AccessToken == "" {
+		return nil, fmt.Errorf("Agent: %s has not reported its access token, it might be a previous version.", agent.Id)
+	}
+	client := http.Client{
+		Timeout:   agentDiagnosticsTimeout,
+		Transport: http.DefaultTransport,
+	}
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("http://%s:%d/diagnostics", agent.State.LastReportIP, agent.ListenPort), nil)
+	if err != nil {
+		return nil, err
+	}
+	utils.SetAgentAccessToken(req.Header, agent.AccessToken)
+	rsp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer rsp.Body.Close()
+	data, err := ioutil.ReadAll(rsp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if rsp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("remote agent had returned HTTP status code: %d, body: %s", rsp.StatusCode, strings.TrimSpace(string(data)))
+	}
+	return data, nil
+}
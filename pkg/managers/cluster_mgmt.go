@@ -2,6 +2,7 @@ package managers
 
 import (
 	"context"
+	"crypto/subtle"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -15,6 +16,13 @@ import (
 	"time"
 )
 
+var (
+	//ErrAgentUnauthorized is returned if the access token of agent is not accepted.
+	ErrAgentUnauthorized = errors.New("The access token of agent is not accepted.")
+	//ErrCertificateForbidden is returned if the agent downloads the private key which is not needed by its roles.
+	ErrCertificateForbidden = errors.New("The private key is only given to the agents which have ETCD or master role.")
+)
+
 func NewCluster(cluster *entities.LightningMonkeyClusterSettings) (string, error) {
 	var err error
 	var certsResources *certs.GeneratedCertsMap
@@ -39,10 +47,18 @@ func NewCluster(cluster *entities.LightningMonkeyClusterSettings) (string, error
 			return "", fmt.Errorf("Failed to parse \"cluster.ServiceCIDR\" value as correct CIDR format, error: %s", err.Error())
 		}
 		//generate required certificates.
-		certsResources, err = common.CertManager.GenerateMainCACertificates()
+		certsResources, err = common.CertManager.GenerateMainCACertificates(cluster.CertificateAuthority)
 		if err != nil {
 			return "", fmt.Errorf("Failed to generate Kubernetes required certificates, error: %s", err.Error())
 		}
+		//private keys of supplied CAs had been saved as certificates, do not keep them in the cluster metadata.
+		if cluster.CertificateAuthority != nil {
+			for _, pair := range []*entities.CAKeyPair{cluster.CertificateAuthority.Cluster, cluster.CertificateAuthority.FrontProxy, cluster.CertificateAuthority.ETCD} {
+				if pair != nil {
+					pair.PrivateKey = ""
+				}
+			}
+		}
 	}
 	//considered troubleshooting, set to empty is not an required condition.
 	if cluster.Id == "" {
@@ -68,6 +84,43 @@ func GetClusterCertificates(clusterId string) (entities.LightningMonkeyCertifica
 	return common.ClusterManager.GetClusterCertificates(clusterId)
 }
 
+//GetAgentCertificate returns the certificate which is downloaded by the agent during registering, the agent is
+//authenticated by its access token and the private keys are only given to the agents which have ETCD or master role.
+func GetAgentCertificate(clusterId, agentId, token, certName string) (string, error) {
+	cluster, err := getClusterController(clusterId)
+	if err != nil {
+		return "", err
+	}
+	agent, err := cluster.GetCachedAgent(agentId)
+	if err != nil {
+		return "", fmt.Errorf("Failed to retrieve agent information from cache, cluster-id: %s, agent-id: %s, error: %s", clusterId, agentId, err.Error())
+	}
+	if agent == nil || agent.AccessToken == "" || subtle.ConstantTimeCompare([]byte(agent.AccessToken), []byte(token)) != 1 {
+		return "", ErrAgentUnauthorized
+	}
+	if strings.HasSuffix(strings.ToLower(certName), ".key") && !agent.HasETCDRole && !agent.HasMasterRole {
+		return "", ErrCertificateForbidden
+	}
+	certs, err := common.ClusterManager.GetClusterCertificates(clusterId)
+	if err != nil {
+		return "", err
+	}
+	return certs.GetCertificateContent(certName), nil
+}
+
+//GetHABackends returns the desired HAProxy backends and the synchronization status of each of HA nodes.
+func GetHABackends(clusterId string) (*entities.HABackends, error) {
+	cluster, err := getClusterController(clusterId)
+	if err != nil {
+		return nil, err
+	}
+	if cluster.GetSettings().HASettings == nil {
+		return nil, fmt.Errorf("Cluster: %s has no any HA settings!", clusterId)
+	}
+	backends := cluster.GetHABackends()
+	return &backends, nil
+}
+
 func saveCluster(cluster entities.LightningMonkeyClusterSettings, certsMap *certs.GeneratedCertsMap) error {
 	//STEP 1, add generated cluster certificates.
 	err := saveClusterCertificate(cluster, certsMap)
@@ -106,3 +159,49 @@ func saveClusterCertificate(cluster entities.LightningMonkeyClusterSettings, cer
 	}
 	return nil
 }
+
+//UploadSignedCA saves the out of band signed intermediate CA certificate to the cluster which is using CSR mode.
+func UploadSignedCA(clusterId, caName, certificate string) error {
+	cluster, err := common.ClusterManager.GetClusterById(clusterId)
+	if err != nil {
+		return fmt.Errorf("Failed to retrieve cluster information from cache, error: %s", err.Error())
+	}
+	if cluster == nil {
+		return fmt.Errorf("Cluster: %s not found!", clusterId)
+	}
+	settings := cluster.GetSettings()
+	if settings.CertificateAuthority == nil || settings.CertificateAuthority.Mode != entities.CAMode_CSR {
+		return fmt.Errorf("Cluster: %s is not waiting for any signed CA certificate!", clusterId)
+	}
+	if caName != entities.CAName_Cluster && caName != entities.CAName_FrontProxy && caName != entities.CAName_ETCD {
+		return fmt.Errorf("Unsupported CA name: %s", caName)
+	}
+	certMap := cluster.GetCertificates()
+	if certMap.GetCertificateContent(certs.CACertificateName(caName)) != "" {
+		return fmt.Errorf("Signed CA certificate: %s has been uploaded already!", caName)
+	}
+	key := certMap.GetCertificateContent(certs.CAPrivateKeyName(caName))
+	if key == "" {
+		return fmt.Errorf("Private key of CA: %s not found!", caName)
+	}
+	err = certs.ValidateCAKeyPair(certificate, key, time.Now())
+	if err != nil {
+		return fmt.Errorf("Failed to validate signed CA certificate: %s, error: %s", caName, err.Error())
+	}
+	gcm := &certs.GeneratedCertsMap{}
+	gcm.InitializeData(map[string]string{certs.CACertificateName(caName): certificate})
+	return saveClusterCertificate(settings, gcm)
+}
+
+//IsCertificateAuthorityReady returns false if the cluster is still waiting for any signed CA certificate.
+func IsCertificateAuthorityReady(settings entities.LightningMonkeyClusterSettings, certMap entities.LightningMonkeyCertificateCollection) bool {
+	if settings.CertificateAuthority == nil || settings.CertificateAuthority.Mode != entities.CAMode_CSR {
+		return true
+	}
+	for _, caName := range []string{entities.CAName_Cluster, entities.CAName_FrontProxy, entities.CAName_ETCD} {
+		if certMap.GetCertificateContent(certs.CACertificateName(caName)) == "" {
+			return false
+		}
+	}
+	return true
+}
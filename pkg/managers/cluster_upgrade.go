@@ -0,0 +1,237 @@
+package managers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/g0194776/lightningmonkey/pkg/cache"
+	"github.com/g0194776/lightningmonkey/pkg/common"
+	"github.com/g0194776/lightningmonkey/pkg/entities"
+	"github.com/g0194776/lightningmonkey/pkg/storage"
+	"github.com/g0194776/lightningmonkey/pkg/utils"
+	uuid "github.com/satori/go.uuid"
+	"time"
+)
+
+//UpgradeCluster starts a rolling upgrade to the given Kubernetes version,
+//kubelets are upgraded in batches of given size(the default one is used if it's 0).
+func UpgradeCluster(clusterId, version string, kubeletBatchSize int) (*entities.ClusterUpgrade, error) {
+	cluster, err := getClusterController(clusterId)
+	if err != nil {
+		return nil, err
+	}
+	settings := cluster.GetSettings()
+	toImages, isOK := common.BasicImages[version]
+	if !isOK {
+		return nil, fmt.Errorf("Unsupported Kubernetes version: %s", version)
+	}
+	fromImages, isOK := common.BasicImages[settings.KubernetesVersion]
+	if !isOK {
+		return nil, fmt.Errorf("Unsupported Kubernetes version of current cluster: %s", settings.KubernetesVersion)
+	}
+	err = ValidateVersionSkew(settings.KubernetesVersion, version)
+	if err != nil {
+		return nil, err
+	}
+	if kubeletBatchSize < 0 {
+		return nil, fmt.Errorf("Illegal kubelet batch size: %d, it must not be negative.", kubeletBatchSize)
+	}
+	if q := cluster.GetMasterQuorum(); !q.IsCompleted || !q.HasQuorum {
+		return nil, fmt.Errorf("Cluster: %s is not healthy enough to upgrade, provisioned masters: %d/%d", clusterId, q.Online, q.Expected)
+	}
+	nodes, err := getUpgradeNodes(cluster)
+	if err != nil {
+		return nil, err
+	}
+	//etcd & HA are only upgraded if their images are changed.
+	stages := []string{entities.UpgradeStage_Images}
+	if fromImages.Images["etcd"].ImageName != toImages.Images["etcd"].ImageName {
+		stages = append(stages, entities.UpgradeStage_ETCD)
+	}
+	stages = append(stages, entities.UpgradeStage_Master)
+	if settings.HASettings != nil && fromImages.Images["ha"].ImageName != toImages.Images["ha"].ImageName {
+		stages = append(stages, entities.UpgradeStage_HA)
+	}
+	stages = append(stages, entities.UpgradeStage_Kubelet)
+	return updateClusterUpgrade(clusterId, func(u *entities.ClusterUpgrade) (*entities.ClusterUpgrade, error) {
+		if u != nil && u.Status != entities.UpgradeStatus_Completed {
+			return nil, fmt.Errorf("Cluster: %s has an unfinished upgrade(%s -> %s), resume or rollback it first!", clusterId, u.FromVersion, u.ToVersion)
+		}
+		return &entities.ClusterUpgrade{
+			Id:               uuid.NewV4().String(),
+			FromVersion:      settings.KubernetesVersion,
+			ToVersion:        version,
+			FromImages:       fromImages,
+			ToImages:         toImages,
+			Status:           entities.UpgradeStatus_Running,
+			Stages:           stages,
+			Nodes:            nodes,
+			KubeletBatchSize: kubeletBatchSize,
+			CreateTime:       time.Now(),
+			UpdateTime:       time.Now(),
+		}, nil
+	})
+}
+
+//ResumeClusterUpgrade retries the failed nodes of a paused upgrade.
+func ResumeClusterUpgrade(clusterId string) (*entities.ClusterUpgrade, error) {
+	return updateClusterUpgrade(clusterId, func(u *entities.ClusterUpgrade) (*entities.ClusterUpgrade, error) {
+		if u == nil || u.Status != entities.UpgradeStatus_Paused {
+			return nil, fmt.Errorf("Cluster: %s has no any paused upgrade!", clusterId)
+		}
+		for _, n := range u.Nodes {
+			if n.Status == entities.UpgradeNodeStatus_Failed {
+				n.Attempt++
+				n.Status = ""
+				n.UpdateTime = time.Now()
+			}
+		}
+		u.Status = entities.UpgradeStatus_Running
+		u.Reason = ""
+		u.UpdateTime = time.Now()
+		return u, nil
+	})
+}
+
+//RollbackClusterUpgrade converges all of upgraded components back to the original version in reverse order.
+func RollbackClusterUpgrade(clusterId string) (*entities.ClusterUpgrade, error) {
+	return updateClusterUpgrade(clusterId, func(u *entities.ClusterUpgrade) (*entities.ClusterUpgrade, error) {
+		if u == nil || u.IsRollback {
+			return nil, fmt.Errorf("Cluster: %s has no any upgrade could be rolled back!", clusterId)
+		}
+		err := ValidateUpgradeRollback(u)
+		if err != nil {
+			return nil, err
+		}
+		//images of original version are still there, only the stages which have been started need to be reverted.
+		lastIndex := u.StageIndex
+		if lastIndex >= len(u.Stages) {
+			lastIndex = len(u.Stages) - 1
+		}
+		stages := []string{}
+		for i := lastIndex; i >= 0; i-- {
+			if u.Stages[i] != entities.UpgradeStage_Images {
+				stages = append(stages, u.Stages[i])
+			}
+		}
+		for _, n := range u.Nodes {
+			n.Stage = ""
+			n.Status = ""
+			n.Reason = ""
+			n.Attempt = 0
+			n.UpdateTime = time.Now()
+		}
+		u.IsRollback = true
+		u.Stages = stages
+		u.StageIndex = 0
+		u.Status = entities.UpgradeStatus_Running
+		u.Reason = ""
+		u.UpdateTime = time.Now()
+		return u, nil
+	})
+}
+
+//ValidateUpgradeRollback refuses to roll back an upgrade once its ETCD stage has started,
+//ETCD never supports downgrading the data directory which has been touched by the newer version.
+func ValidateUpgradeRollback(u *entities.ClusterUpgrade) error {
+	for i := 0; i < len(u.Stages) && i <= u.StageIndex; i++ {
+		if u.Stages[i] == entities.UpgradeStage_ETCD {
+			return fmt.Errorf("Upgrade %s -> %s cannot be rolled back, ETCD has been upgraded and it does not support downgrading, resume the upgrade instead!", u.FromVersion, u.ToVersion)
+		}
+	}
+	return nil
+}
+
+func GetClusterUpgrade(clusterId string) (*entities.ClusterUpgrade, error) {
+	cluster, err := getClusterController(clusterId)
+	if err != nil {
+		return nil, err
+	}
+	return cluster.GetUpgrade(), nil
+}
+
+//ValidateVersionSkew only allows upgrading to a newer patch version or the next minor version.
+func ValidateVersionSkew(from, to string) error {
+	fv, err := utils.ParseKubernetesVersion(from)
+	if err != nil {
+		return err
+	}
+	tv, err := utils.ParseKubernetesVersion(to)
+	if err != nil {
+		return err
+	}
+	if fv[0] != tv[0] {
+		return fmt.Errorf("Upgrading across major versions is not supported: %s -> %s", from, to)
+	}
+	if utils.CompareVersions(to, from) <= 0 {
+		return fmt.Errorf("Target version: %s must be newer than current version: %s", to, from)
+	}
+	if tv[1]-fv[1] > 1 {
+		return fmt.Errorf("Skipping minor versions is not supported: %s -> %s", from, to)
+	}
+	return nil
+}
+
+//getUpgradeNodes takes a snapshot of all of online agents, agents joined afterwards are not the part of this upgrade.
+func getUpgradeNodes(cluster cache.ClusterController) (map[string]*entities.ClusterUpgradeNode, error) {
+	agents, err := cluster.GetAgentList(false)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to list agents of cluster: %s, error: %s", cluster.GetClusterId(), err.Error())
+	}
+	nodes := map[string]*entities.ClusterUpgradeNode{}
+	for i := 0; i < len(agents); i++ {
+		if agents[i].State == nil || (!agents[i].HasETCDRole && !agents[i].HasMasterRole && !agents[i].HasMinionRole && !agents[i].HasHARole) {
+			continue
+		}
+		nodes[agents[i].Id] = &entities.ClusterUpgradeNode{
+			Hostname:      agents[i].Hostname,
+			HasETCDRole:   agents[i].HasETCDRole,
+			HasMasterRole: agents[i].HasMasterRole,
+			HasMinionRole: agents[i].HasMinionRole,
+			HasHARole:     agents[i].HasHARole,
+		}
+	}
+	if len(nodes) == 0 {
+		return nil, errors.New("No any online agent could be upgraded!")
+	}
+	return nodes, nil
+}
+
+func getClusterController(clusterId string) (cache.ClusterController, error) {
+	cluster, err := common.ClusterManager.GetClusterById(clusterId)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to retrieve cluster information from cache, error: %s", err.Error())
+	}
+	if cluster == nil {
+		return nil, fmt.Errorf("Cluster: %s not found!", clusterId)
+	}
+	return cluster, nil
+}
+
+//updateClusterUpgrade changes the stored upgrade progress with CompareAndSwap, update receives nil if there is no any upgrade.
+func updateClusterUpgrade(clusterId string, update func(u *entities.ClusterUpgrade) (*entities.ClusterUpgrade, error)) (*entities.ClusterUpgrade, error) {
+	if _, err := getClusterController(clusterId); err != nil {
+		return nil, err
+	}
+	var result *entities.ClusterUpgrade
+	err := storage.CompareAndSwap(common.StorageDriver, fmt.Sprintf("/lightning-monkey/clusters/%s/upgrade", clusterId), func(value []byte) ([]byte, error) {
+		var u *entities.ClusterUpgrade
+		if value != nil {
+			u = &entities.ClusterUpgrade{}
+			err := json.Unmarshal(value, u)
+			if err != nil {
+				return nil, fmt.Errorf("Failed to unmarshal cluster upgrade progress, error: %s", err.Error())
+			}
+		}
+		var err error
+		result, err = update(u)
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(result)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
@@ -1,10 +1,8 @@
 package managers
 
 import (
-	"context"
 	"fmt"
-	"github.com/docker/engine-api/client"
-	"github.com/docker/engine-api/types"
+	"github.com/g0194776/lightningmonkey/pkg/containers"
 	"github.com/g0194776/lightningmonkey/pkg/entities"
 	"github.com/sirupsen/logrus"
 	"io"
@@ -16,7 +14,8 @@ import (
 
 type HTTPDockerImageManager struct {
 	serverAddr              string
-	dockerClient            *client.Client
+	client                  *http.Client
+	runtime                 containers.ContainerRuntime
 	imageCollectionSettings *entities.DockerImageCollection
 }
 
@@ -26,56 +25,38 @@ func (im *HTTPDockerImageManager) Ready() error {
 	}
 	var err error
 	var closer io.ReadCloser
-	var rsp types.ImageLoadResponse
 	for name, v := range im.imageCollectionSettings.Images {
 		downloadUrl := fmt.Sprintf(v.DownloadAddr, im.serverAddr, im.imageCollectionSettings.HTTPDownloadToken)
 		logrus.Infof("Downloading docker image: %s", downloadUrl)
-		closer, err = downloadFile(name, downloadUrl, "/tmp")
+		closer, err = downloadFile(im.client, name, downloadUrl, "/tmp")
 		if err != nil {
 			return fmt.Errorf("Failed to download remote Docker image tarball file, error: %s", err.Error())
 		}
-		rsp, err = im.dockerClient.ImageLoad(context.Background(), closer, false)
-		if err != nil {
-			closer.Close()
-			return fmt.Errorf("Failed to load local tarball docker image file to the Docker daemon, error: %s", err.Error())
-		}
-		_, _ = io.Copy(os.Stdout, rsp.Body)
+		err = im.runtime.LoadImage(closer)
 		//clean resource.
-		rsp.Body.Close()
 		closer.Close()
-		logrus.Infof("Docker image %s had completely loaded into Docker daemon!", v.ImageName)
+		if err != nil {
+			return fmt.Errorf("Failed to load local tarball docker image file to the %s daemon, error: %s", im.runtime.GetName(), err.Error())
+		}
+		logrus.Infof("Docker image %s had completely loaded into %s daemon!", v.ImageName, im.runtime.GetName())
 	}
 	return nil
 }
 
-func downloadFile(name, url string, dest string) (io.ReadCloser, error) {
+func downloadFile(client *http.Client, name, url string, dest string) (io.ReadCloser, error) {
 	start := time.Now()
 	filePath := fmt.Sprintf("%s/%s.tar", dest, name)
 	if _, err := os.Stat(filePath); err != nil {
 		if !os.IsNotExist(err) {
 			return nil, err
 		}
-		out, err := os.Create(filePath)
-		if err != nil {
-			return nil, err
-		}
-		defer out.Close()
-		headResp, err := http.Head(url)
-		if err != nil {
-			return nil, err
-		}
-		defer headResp.Body.Close()
-		_, err = strconv.Atoi(headResp.Header.Get("Content-Length"))
-		if err != nil {
-			return nil, err
-		}
-		resp, err := http.Get(url)
-		if err != nil {
+		//downloads to a temporary file, the failed download is retried from scratch rather than loading a partial file.
+		tmpPath := filePath + ".download"
+		if err := saveRemoteFile(client, url, tmpPath); err != nil {
+			_ = os.Remove(tmpPath)
 			return nil, err
 		}
-		defer resp.Body.Close()
-		_, err = io.Copy(out, resp.Body)
-		if err != nil {
+		if err := os.Rename(tmpPath, filePath); err != nil {
 			return nil, err
 		}
 		elapsed := time.Since(start)
@@ -83,3 +64,30 @@ func downloadFile(name, url string, dest string) (io.ReadCloser, error) {
 	}
 	return os.Open(filePath)
 }
+
+func saveRemoteFile(client *http.Client, url, filePath string) error {
+	out, err := os.Create(filePath)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	headResp, err := client.Head(url)
+	if err != nil {
+		return err
+	}
+	defer headResp.Body.Close()
+	_, err = strconv.Atoi(headResp.Header.Get("Content-Length"))
+	if err != nil {
+		return err
+	}
+	resp, err := client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Failed to download %s, remote API server returned HTTP status code: %d", url, resp.StatusCode)
+	}
+	_, err = io.Copy(out, resp.Body)
+	return err
+}
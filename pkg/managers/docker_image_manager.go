@@ -3,8 +3,9 @@ package managers
 import (
 	"errors"
 	"fmt"
-	"github.com/docker/engine-api/client"
+	"github.com/g0194776/lightningmonkey/pkg/containers"
 	"github.com/g0194776/lightningmonkey/pkg/entities"
+	"net/http"
 )
 
 type DockerImageManager interface {
@@ -15,12 +16,13 @@ var (
 	crashError = errors.New("CRASH ERROR")
 )
 
-func NewDockerImageManager(serverAddr string, dockerClient *client.Client, imageCollectionSettings *entities.DockerImageCollection) (DockerImageManager, error) {
+//NewDockerImageManager creates the image manager, the client is used for downloading images from API server.
+func NewDockerImageManager(serverAddr string, client *http.Client, runtime containers.ContainerRuntime, imageCollectionSettings *entities.DockerImageCollection) (DockerImageManager, error) {
 	if imageCollectionSettings.DownloadType == entities.DockerImageDownloadType_Registry {
-		return &RemoteRegistryDockerImageManager{dockerClient: dockerClient, imageCollectionSettings: imageCollectionSettings}, nil
+		return &RemoteRegistryDockerImageManager{runtime: runtime, imageCollectionSettings: imageCollectionSettings}, nil
 	}
 	if imageCollectionSettings.DownloadType == entities.DockerImageDownloadType_HTTP {
-		return &HTTPDockerImageManager{dockerClient: dockerClient, imageCollectionSettings: imageCollectionSettings, serverAddr: serverAddr}, nil
+		return &HTTPDockerImageManager{runtime: runtime, imageCollectionSettings: imageCollectionSettings, serverAddr: serverAddr, client: client}, nil
 	}
 	return nil, fmt.Errorf("Unsupported download type of remote Docker image: %s", imageCollectionSettings.DownloadType)
 }
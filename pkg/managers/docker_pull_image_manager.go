@@ -1,18 +1,14 @@
 package managers
 
 import (
-	"context"
-	"github.com/docker/engine-api/client"
-	"github.com/docker/engine-api/types"
+	"github.com/g0194776/lightningmonkey/pkg/containers"
 	"github.com/g0194776/lightningmonkey/pkg/entities"
 	"github.com/sirupsen/logrus"
 	"golang.org/x/xerrors"
-	"io"
-	"os"
 )
 
 type RemoteRegistryDockerImageManager struct {
-	dockerClient            *client.Client
+	runtime                 containers.ContainerRuntime
 	imageCollectionSettings *entities.DockerImageCollection
 }
 
@@ -22,11 +18,10 @@ func (im *RemoteRegistryDockerImageManager) Ready() error {
 	}
 	for _, v := range im.imageCollectionSettings.Images {
 		logrus.Infof("Pulling docker image: %s", v.ImageName)
-		reader, err := im.dockerClient.ImagePull(context.Background(), v.ImageName, types.ImagePullOptions{})
+		err := im.runtime.PullImage(v.ImageName)
 		if err != nil {
 			return xerrors.Errorf("Failed to pull docker image, error: %s %w", err.Error(), crashError)
 		}
-		_, _ = io.Copy(os.Stdout, reader)
 	}
 	return nil
 }
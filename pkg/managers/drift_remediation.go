@@ -0,0 +1,37 @@
+package managers
+
+import (
+	"fmt"
+	"github.com/g0194776/lightningmonkey/pkg/common"
+	"github.com/g0194776/lightningmonkey/pkg/entities"
+	"github.com/g0194776/lightningmonkey/pkg/storage"
+	"github.com/g0194776/lightningmonkey/pkg/utils"
+)
+
+//GetDriftRemediation returns the configuration drift remediation policy which is delivered to the agents of cluster.
+func GetDriftRemediation(clusterId string) *entities.DriftRemediationSettings {
+	cluster, err := getClusterController(clusterId)
+	if err != nil {
+		return nil
+	}
+	return cluster.GetSettings().DriftRemediation
+}
+
+//SetDriftRemediation replaces the remediation policy of cluster, nil policy makes agents only report the drift.
+func SetDriftRemediation(clusterId string, s *entities.DriftRemediationSettings) error {
+	_, err := getClusterController(clusterId)
+	if err != nil {
+		return err
+	}
+	if err = utils.ValidateDriftRemediation(s); err != nil {
+		return err
+	}
+	err = storage.UpdateClusterSettings(common.StorageDriver, clusterId, func(settings *entities.LightningMonkeyClusterSettings) (bool, error) {
+		settings.DriftRemediation = s
+		return true, nil
+	})
+	if err != nil {
+		return fmt.Errorf("Failed to update drift remediation settings of cluster %s, error: %s", clusterId, err.Error())
+	}
+	return nil
+}
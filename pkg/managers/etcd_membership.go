@@ -0,0 +1,30 @@
+package managers
+
+import (
+	"fmt"
+	"github.com/g0194776/lightningmonkey/pkg/common"
+	"github.com/g0194776/lightningmonkey/pkg/entities"
+	"github.com/g0194776/lightningmonkey/pkg/storage"
+)
+
+//SetExpectedETCDCount changes the expected size of cluster's ETCD, the members are added or removed one at a time
+//by the ETCD membership job afterwards.
+func SetExpectedETCDCount(clusterId string, count int) error {
+	if count <= 0 || count%2 == 0 {
+		return fmt.Errorf("Expected ETCD count must be a positive odd number, got %d", count)
+	}
+	if _, err := getClusterController(clusterId); err != nil {
+		return err
+	}
+	err := storage.UpdateClusterSettings(common.StorageDriver, clusterId, func(settings *entities.LightningMonkeyClusterSettings) (bool, error) {
+		if settings.ExpectedETCDCount == count {
+			return false, nil
+		}
+		settings.ExpectedETCDCount = count
+		return true, nil
+	})
+	if err != nil {
+		return fmt.Errorf("Failed to update expected ETCD count of cluster %s, error: %s", clusterId, err.Error())
+	}
+	return nil
+}
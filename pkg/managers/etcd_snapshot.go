@@ -0,0 +1,219 @@
+package managers
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/g0194776/lightningmonkey/pkg/common"
+	"github.com/g0194776/lightningmonkey/pkg/entities"
+	"github.com/g0194776/lightningmonkey/pkg/storage"
+	"github.com/g0194776/lightningmonkey/pkg/utils"
+	uuid "github.com/satori/go.uuid"
+	"io"
+	"os"
+	"path"
+	"regexp"
+	"time"
+)
+
+var (
+	snapshotNameRegex = regexp.MustCompile(`^snapshot-[0-9]{14}$`)
+	//ErrSnapshotUnauthorized is returned if the token of uploading or downloading snapshot is not accepted.
+	ErrSnapshotUnauthorized = errors.New("The token is not accepted for accessing ETCD snapshots.")
+)
+
+//GetETCDSnapshots returns the snapshot history of cluster without any credential.
+func GetETCDSnapshots(clusterId string) (*entities.ClusterSnapshots, error) {
+	s, err := getETCDSnapshots(clusterId)
+	if err != nil {
+		return nil, err
+	}
+	return s.WithoutCredentials(), nil
+}
+
+func getETCDSnapshots(clusterId string) (*entities.ClusterSnapshots, error) {
+	cluster, err := getClusterController(clusterId)
+	if err != nil {
+		return nil, err
+	}
+	s := cluster.GetSnapshots()
+	if s == nil {
+		s = &entities.ClusterSnapshots{Snapshots: []entities.ETCDSnapshot{}}
+	}
+	return s, nil
+}
+
+//TriggerETCDSnapshot requests the elected ETCD agent to take a snapshot immediately.
+func TriggerETCDSnapshot(clusterId string) (*entities.ClusterSnapshots, error) {
+	cluster, err := getClusterController(clusterId)
+	if err != nil {
+		return nil, err
+	}
+	if cluster.GetSettings().BackupSettings == nil {
+		return nil, fmt.Errorf("Cluster: %s has no any backup policy, \"backup_settings\" is required!", clusterId)
+	}
+	s, err := updateClusterSnapshots(clusterId, func(s *entities.ClusterSnapshots) error {
+		if s.Restore != nil && s.Restore.Status == entities.ETCDRestoreStatus_Running {
+			return fmt.Errorf("Cluster: %s is restoring ETCD snapshot %s!", clusterId, s.Restore.SnapshotName)
+		}
+		s.TriggerTime = time.Now()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.WithoutCredentials(), nil
+}
+
+//RestoreETCDSnapshot restores all of ETCD members from the given snapshot, all of ETCD agents must be online.
+func RestoreETCDSnapshot(clusterId, name string) (*entities.ClusterSnapshots, error) {
+	cluster, err := getClusterController(clusterId)
+	if err != nil {
+		return nil, err
+	}
+	if u := cluster.GetUpgrade(); u != nil && u.Status != entities.UpgradeStatus_Completed {
+		return nil, fmt.Errorf("Cluster: %s has an unfinished upgrade(%s -> %s)!", clusterId, u.FromVersion, u.ToVersion)
+	}
+	agents, err := cluster.GetAgentList(false)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to list agents of cluster: %s, error: %s", clusterId, err.Error())
+	}
+	nodes := map[string]*entities.ETCDRestoreNode{}
+	for i := 0; i < len(agents); i++ {
+		if !agents[i].HasETCDRole {
+			continue
+		}
+		if agents[i].State == nil {
+			return nil, fmt.Errorf("ETCD agent: %s is offline, all of ETCD agents are required for restoring!", agents[i].Hostname)
+		}
+		nodes[agents[i].Id] = &entities.ETCDRestoreNode{
+			Hostname:   agents[i].Hostname,
+			Address:    agents[i].State.LastReportIP,
+			UpdateTime: time.Now(),
+		}
+	}
+	if len(nodes) == 0 {
+		return nil, errors.New("No any ETCD agent could be restored!")
+	}
+	s, err := updateClusterSnapshots(clusterId, func(s *entities.ClusterSnapshots) error {
+		if s.Restore != nil && s.Restore.Status == entities.ETCDRestoreStatus_Running {
+			return fmt.Errorf("Cluster: %s is restoring ETCD snapshot %s!", clusterId, s.Restore.SnapshotName)
+		}
+		snapshot := s.GetSnapshot(name)
+		if snapshot == nil || snapshot.Status != entities.ETCDSnapshotStatus_Succeed {
+			return fmt.Errorf("ETCD snapshot: %s not found in cluster: %s", name, clusterId)
+		}
+		//local snapshot file only exists on the agent which took it.
+		if snapshot.Destination == entities.ETCDSnapshotDestination_Local {
+			if _, isOK := nodes[snapshot.AgentId]; !isOK || len(nodes) != 1 {
+				return fmt.Errorf("ETCD snapshot: %s is saved on agent %s locally, it can only be restored to a single-member ETCD cluster on that agent!", name, snapshot.Hostname)
+			}
+		}
+		s.Restore = &entities.ETCDRestore{
+			Id:           uuid.NewV4().String(),
+			SnapshotName: name,
+			Token:        uuid.NewV4().String(),
+			Status:       entities.ETCDRestoreStatus_Running,
+			Nodes:        nodes,
+			CreateTime:   time.Now(),
+			UpdateTime:   time.Now(),
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.WithoutCredentials(), nil
+}
+
+//SaveETCDSnapshotFile saves the snapshot which is uploaded by the elected ETCD agent into the snapshot storage directory
+//of this API server, existing snapshots are never overwritten.
+func SaveETCDSnapshotFile(clusterId, name, token string, r io.Reader) error {
+	filePath, err := getETCDSnapshotFilePath(clusterId, name)
+	if err != nil {
+		return err
+	}
+	s, err := getETCDSnapshots(clusterId)
+	if err != nil {
+		return err
+	}
+	if s.Upload == nil || subtle.ConstantTimeCompare([]byte(s.Upload.Token), []byte(token)) != 1 {
+		return ErrSnapshotUnauthorized
+	}
+	if s.GetSnapshot(name) != nil {
+		return fmt.Errorf("ETCD snapshot: %s has been recorded in cluster: %s", name, clusterId)
+	}
+	err = os.MkdirAll(path.Dir(filePath), 0700)
+	if err != nil {
+		return fmt.Errorf("Failed to create snapshot storage directory, error: %s", err.Error())
+	}
+	//write to a temporary file first for avoiding serving partial snapshot.
+	tmpPath := filePath + ".tmp"
+	f, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("Failed to create snapshot file, error: %s", err.Error())
+	}
+	_, err = io.Copy(f, r)
+	closeErr := f.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(tmpPath)
+		return fmt.Errorf("Failed to save snapshot file, error: %s", err.Error())
+	}
+	return os.Rename(tmpPath, filePath)
+}
+
+//OpenETCDSnapshotFile opens the snapshot held by this API server for the ETCD agents which are restoring it.
+func OpenETCDSnapshotFile(clusterId, name, token string) (*os.File, error) {
+	filePath, err := getETCDSnapshotFilePath(clusterId, name)
+	if err != nil {
+		return nil, err
+	}
+	s, err := getETCDSnapshots(clusterId)
+	if err != nil {
+		return nil, err
+	}
+	r := s.Restore
+	if r == nil || r.Status != entities.ETCDRestoreStatus_Running || r.SnapshotName != name || subtle.ConstantTimeCompare([]byte(r.Token), []byte(token)) != 1 {
+		return nil, ErrSnapshotUnauthorized
+	}
+	return os.Open(filePath)
+}
+
+func getETCDSnapshotFilePath(clusterId, name string) (string, error) {
+	if _, err := uuid.FromString(clusterId); err != nil {
+		return "", fmt.Errorf("Illegal cluster id: %s", clusterId)
+	}
+	if !snapshotNameRegex.MatchString(name) {
+		return "", fmt.Errorf("Illegal ETCD snapshot name: %s", name)
+	}
+	return path.Join(entities.ETCDSnapshotStorageDirectory, utils.GetETCDSnapshotLocation(clusterId, name)), nil
+}
+
+//updateClusterSnapshots changes the stored snapshot history with CompareAndSwap, update receives an empty history
+//if there is no any snapshot.
+func updateClusterSnapshots(clusterId string, update func(s *entities.ClusterSnapshots) error) (*entities.ClusterSnapshots, error) {
+	var result *entities.ClusterSnapshots
+	err := storage.CompareAndSwap(common.StorageDriver, fmt.Sprintf("/lightning-monkey/clusters/%s/snapshots", clusterId), func(value []byte) ([]byte, error) {
+		s := &entities.ClusterSnapshots{Snapshots: []entities.ETCDSnapshot{}}
+		if value != nil {
+			err := json.Unmarshal(value, s)
+			if err != nil {
+				return nil, fmt.Errorf("Failed to unmarshal snapshots of cluster %s, error: %s", clusterId, err.Error())
+			}
+		}
+		err := update(s)
+		if err != nil {
+			return nil, err
+		}
+		result = s
+		return json.Marshal(s)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
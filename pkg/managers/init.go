@@ -2,8 +2,14 @@ package managers
 
 import (
 	"errors"
+	"fmt"
+	"github.com/g0194776/lightningmonkey/pkg/certs"
 	"github.com/g0194776/lightningmonkey/pkg/entities"
+	"github.com/g0194776/lightningmonkey/pkg/utils"
 	"github.com/sirupsen/logrus"
+	"path"
+	"strings"
+	"time"
 )
 
 func init() {
@@ -13,6 +19,13 @@ func init() {
 }
 
 func setDefaultValueProcessors() {
+	//set default expected master count.
+	def_processors = append(def_processors, func(cluster *entities.LightningMonkeyClusterSettings) error {
+		if cluster.ExpectedMasterCount <= 0 {
+			cluster.ExpectedMasterCount = 1
+		}
+		return nil
+	})
 	//set default node port range.
 	def_processors = append(def_processors, func(cluster *entities.LightningMonkeyClusterSettings) error {
 		if cluster.PortRangeSettings == nil {
@@ -23,9 +36,39 @@ func setDefaultValueProcessors() {
 		}
 		return nil
 	})
+	//set default ETCD backup policy values.
+	def_processors = append(def_processors, func(cluster *entities.LightningMonkeyClusterSettings) error {
+		bs := cluster.BackupSettings
+		if bs == nil {
+			return nil
+		}
+		if bs.Retention <= 0 {
+			bs.Retention = 7
+		}
+		if bs.Destination == "" {
+			bs.Destination = entities.ETCDSnapshotDestination_APIServer
+		}
+		if bs.Destination == entities.ETCDSnapshotDestination_Local && bs.Directory == "" {
+			bs.Directory = "/data/etcd-snapshots"
+		}
+		return nil
+	})
 }
 
 func setBizCheckProcessor() {
+	//expected master count check.
+	check_processors = append(check_processors, func(cluster entities.LightningMonkeyClusterSettings) error {
+		if cluster.ExpectedMasterCount <= 1 {
+			return nil
+		}
+		if cluster.ExpectedMasterCount%2 == 0 {
+			return errors.New("\"expected_master_count\" must be an odd number for keeping quorum of Kubernetes masters!")
+		}
+		if cluster.HASettings == nil {
+			return errors.New("\"ha_settings\" is required when there are more than one Kubernetes master!")
+		}
+		return nil
+	})
 	//HA settings check.
 	check_processors = append(check_processors, func(cluster entities.LightningMonkeyClusterSettings) error {
 		if cluster.HASettings != nil {
@@ -51,6 +94,83 @@ func setBizCheckProcessor() {
 		}
 		return nil
 	})
+	//certificate authority settings check.
+	check_processors = append(check_processors, func(cluster entities.LightningMonkeyClusterSettings) error {
+		ca := cluster.CertificateAuthority
+		if ca == nil {
+			return nil
+		}
+		switch ca.Mode {
+		case "", entities.CAMode_Generate, entities.CAMode_CSR:
+		case entities.CAMode_Supplied:
+			pairs := map[string]*entities.CAKeyPair{"cluster": ca.Cluster, "front_proxy": ca.FrontProxy, "etcd": ca.ETCD}
+			for name, pair := range pairs {
+				if pair == nil || pair.Certificate == "" || pair.PrivateKey == "" {
+					return fmt.Errorf("\"certificate_authority.%s\" certificate & private key are required in supplied mode!", name)
+				}
+				if err := certs.ValidateCAKeyPair(pair.Certificate, pair.PrivateKey, time.Now()); err != nil {
+					return fmt.Errorf("Illegal CA key pair \"certificate_authority.%s\", error: %s", name, err.Error())
+				}
+			}
+		default:
+			return fmt.Errorf("Unsupported \"certificate_authority.mode\": %s", ca.Mode)
+		}
+		return nil
+	})
+	//ETCD backup settings check.
+	check_processors = append(check_processors, func(cluster entities.LightningMonkeyClusterSettings) error {
+		bs := cluster.BackupSettings
+		if bs == nil {
+			return nil
+		}
+		d, err := time.ParseDuration(bs.Interval)
+		if err != nil {
+			return fmt.Errorf("Illegal \"backup_settings.interval\": %s, error: %s", bs.Interval, err.Error())
+		}
+		if d < time.Minute*10 {
+			return errors.New("\"backup_settings.interval\" must not be less than 10 minutes!")
+		}
+		switch bs.Destination {
+		case entities.ETCDSnapshotDestination_APIServer:
+		case entities.ETCDSnapshotDestination_Local:
+			if !path.IsAbs(bs.Directory) {
+				return errors.New("\"backup_settings.directory\" must be an absolute path!")
+			}
+			//the data directory of ETCD is replaced during restoring.
+			if bs.Directory == "/data/etcd" || strings.HasPrefix(bs.Directory, "/data/etcd/") {
+				return errors.New("\"backup_settings.directory\" cannot be the data directory of ETCD!")
+			}
+		default:
+			return fmt.Errorf("Unsupported \"backup_settings.destination\": %s", bs.Destination)
+		}
+		return nil
+	})
+	//agent rollout settings check.
+	check_processors = append(check_processors, func(cluster entities.LightningMonkeyClusterSettings) error {
+		ar := cluster.AgentRollout
+		if ar == nil {
+			return nil
+		}
+		if !agentVersionRegex.MatchString(ar.Version) {
+			return fmt.Errorf("Illegal \"agent_rollout.version\": %s", ar.Version)
+		}
+		if ar.CanaryPercentage < 0 || ar.CanaryPercentage > 100 {
+			return errors.New("\"agent_rollout.canary_percentage\" must be between 0 and 100!")
+		}
+		return nil
+	})
+	//configuration drift remediation policy check.
+	check_processors = append(check_processors, func(cluster entities.LightningMonkeyClusterSettings) error {
+		return utils.ValidateDriftRemediation(cluster.DriftRemediation)
+	})
+	//kubelet settings check.
+	check_processors = append(check_processors, func(cluster entities.LightningMonkeyClusterSettings) error {
+		return utils.ValidateKubeletSettings(cluster.Kubelet)
+	})
+	//node pools check.
+	check_processors = append(check_processors, func(cluster entities.LightningMonkeyClusterSettings) error {
+		return utils.ValidateNodePools(cluster)
+	})
 	//image pulling secrets check.
 	check_processors = append(check_processors, func(cluster entities.LightningMonkeyClusterSettings) error {
 		if cluster.ImagePullSecrets != nil && len(cluster.ImagePullSecrets) > 0 {
@@ -0,0 +1,74 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/g0194776/lightningmonkey/pkg/entities"
+	"go.etcd.io/etcd/clientv3"
+)
+
+const (
+	maxCompareAndSwapAttempts = 5
+)
+
+//CompareAndSwap does a read-modify-write on given key which never overwrites the changes made by others in the meantime.
+//update receives the current value(nil if the key does not exist), returning nil value means nothing needs to be saved.
+//It's retried with the newest value if the key has been changed before the new value is written.
+func CompareAndSwap(sd LightningMonkeyStorageDriver, key string, update func(value []byte) ([]byte, error)) error {
+	for i := 0; i < maxCompareAndSwapAttempts; i++ {
+		succeed, err := compareAndSwap(sd, key, update)
+		if err != nil || succeed {
+			return err
+		}
+	}
+	return fmt.Errorf("Failed to update %s, it has been changed concurrently for %d times.", key, maxCompareAndSwapAttempts)
+}
+
+//UpdateClusterSettings changes the stored settings of cluster with CompareAndSwap,
+//update returns false if nothing needs to be changed.
+func UpdateClusterSettings(sd LightningMonkeyStorageDriver, clusterId string, update func(settings *entities.LightningMonkeyClusterSettings) (bool, error)) error {
+	return CompareAndSwap(sd, fmt.Sprintf("/lightning-monkey/clusters/%s/metadata", clusterId), func(value []byte) ([]byte, error) {
+		if value == nil {
+			return nil, fmt.Errorf("Cluster: %s not found!", clusterId)
+		}
+		settings := entities.LightningMonkeyClusterSettings{}
+		err := json.Unmarshal(value, &settings)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to unmarshal settings of cluster %s, error: %s", clusterId, err.Error())
+		}
+		changed, err := update(&settings)
+		if err != nil || !changed {
+			return nil, err
+		}
+		return json.Marshal(&settings)
+	})
+}
+
+func compareAndSwap(sd LightningMonkeyStorageDriver, key string, update func(value []byte) ([]byte, error)) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), sd.GetRequestTimeoutDuration())
+	defer cancel()
+	rsp, err := sd.Get(ctx, key)
+	if err != nil {
+		return false, err
+	}
+	var value []byte
+	//the revision of a nonexistent key is 0.
+	var revision int64
+	if len(rsp.Kvs) > 0 {
+		value = rsp.Kvs[0].Value
+		revision = rsp.Kvs[0].ModRevision
+	}
+	newValue, err := update(value)
+	if err != nil || newValue == nil {
+		return true, err
+	}
+	txnRsp, err := sd.Txn(ctx).
+		If(clientv3.Compare(clientv3.ModRevision(key), "=", revision)).
+		Then(clientv3.OpPut(key, string(newValue))).
+		Commit()
+	if err != nil {
+		return false, err
+	}
+	return txnRsp.Succeeded, nil
+}
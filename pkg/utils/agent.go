@@ -0,0 +1,44 @@
+package utils
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"strings"
+)
+
+const bearerTokenPrefix = "Bearer "
+
+//GetAgentSoftwareLocation returns the relative path of the agent's binary file of given version in API server's registry.
+func GetAgentSoftwareLocation(version string) string {
+	return fmt.Sprintf("registry/software/lightning-monkey-agent/%s/lightning-monkey-agent", version)
+}
+
+//IsCanaryAgent returns a stable result for each of agents, the agents which are selected by the smaller percentage
+//are always selected by the larger one.
+func IsCanaryAgent(agentId string, percentage int) bool {
+	if percentage <= 0 {
+		return false
+	}
+	if percentage >= 100 {
+		return true
+	}
+	hasher := fnv.New32a()
+	_, _ = hasher.Write([]byte(agentId))
+	return int(hasher.Sum32()%100) < percentage
+}
+
+//SetAgentAccessToken authenticates API server to the agent's HTTP APIs by the "Authorization" header,
+//tokens in URLs are easily leaked by access logs and proxies.
+func SetAgentAccessToken(header http.Header, token string) {
+	header.Set("Authorization", bearerTokenPrefix+token)
+}
+
+//IsAgentAccessTokenMatched compares the bearer token of the "Authorization" header in constant time, an empty token never matches.
+func IsAgentAccessTokenMatched(authorization, token string) bool {
+	if token == "" || !strings.HasPrefix(authorization, bearerTokenPrefix) {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(strings.TrimPrefix(authorization, bearerTokenPrefix)), []byte(token)) == 1
+}
@@ -0,0 +1,178 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"github.com/g0194776/lightningmonkey/pkg/containers"
+	"github.com/g0194776/lightningmonkey/pkg/entities"
+	uuid "github.com/satori/go.uuid"
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sigs.k8s.io/yaml"
+	"sort"
+	"strings"
+	"time"
+)
+
+//NewDefaultAgentConfig returns the configuration which is used if neither the configuration file nor the flag is given.
+func NewDefaultAgentConfig() *entities.AgentConfig {
+	return &entities.AgentConfig{
+		Version:                entities.AgentConfigVersion_V1,
+		ClusterId:              uuid.Nil.String(), //resource pool.
+		Port:                   6060,
+		ContainerRuntime:       containers.Runtime_Docker,
+		HealthFailureThreshold: 3,
+		Paths: entities.AgentPathsConfig{
+			Certificates: "/etc/kubernetes",
+			RecoveryFile: "/opt/lightning-monkey/recovery",
+			CNIBinaries:  "/tmp/cni",
+		},
+		LogLevel: logrus.InfoLevel.String(),
+		Timeouts: entities.AgentTimeoutConfig{
+			Register: entities.Duration{Duration: time.Second * 120},
+			Request:  entities.Duration{Duration: time.Second * 5},
+		},
+	}
+}
+
+//LoadAgentConfig parses the YAML configuration file over the defaults, unknown fields are rejected.
+func LoadAgentConfig(data []byte) (*entities.AgentConfig, error) {
+	c := NewDefaultAgentConfig()
+	if err := yaml.UnmarshalStrict(data, c); err != nil {
+		return nil, fmt.Errorf("Failed to parse agent configuration, error: %s", err.Error())
+	}
+	return c, nil
+}
+
+//ValidateAgentConfig returns all of problems of the configuration in one error.
+func ValidateAgentConfig(c *entities.AgentConfig) error {
+	problems := []string{}
+	addProblem := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+	if c.Version != entities.AgentConfigVersion_V1 {
+		addProblem("\"version\" must be %q, got %q", entities.AgentConfigVersion_V1, c.Version)
+	}
+	if len(c.Servers) == 0 {
+		addProblem("at least one of \"servers\" is required")
+	}
+	for i := 0; i < len(c.Servers); i++ {
+		u, err := url.Parse(c.Servers[i])
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			addProblem("\"servers[%d]\" must be a HTTP or HTTPS URL, got %q", i, c.Servers[i])
+		}
+	}
+	if c.ClusterId == "" {
+		addProblem("\"cluster_id\" is required")
+	}
+	if c.Interface == "" {
+		addProblem("\"interface\" is required")
+	}
+	for k, v := range c.Labels {
+		if k == "" || strings.ContainsAny(k, "=,") || strings.ContainsAny(v, "=,") {
+			addProblem("label %q=%q must not be empty or contain \"=\" and \",\"", k, v)
+		}
+	}
+	if msgs := validation.IsDNS1123Label(c.NodePool); c.NodePool != "" && len(msgs) > 0 {
+		addProblem("\"node_pool\" %q is illegal: %s", c.NodePool, strings.Join(msgs, ", "))
+	}
+	roles := map[string]bool{}
+	for i := 0; i < len(c.Roles); i++ {
+		switch c.Roles[i] {
+		case entities.AgentRole_ETCD, entities.AgentRole_Master, entities.AgentRole_Minion, entities.AgentRole_HA:
+		default:
+			addProblem("unsupported role %q, it must be one of etcd, master, minion and ha", c.Roles[i])
+		}
+		if roles[c.Roles[i]] {
+			addProblem("duplicated role %q", c.Roles[i])
+		}
+		roles[c.Roles[i]] = true
+	}
+	if c.Port <= 0 || c.Port > 65535 {
+		addProblem("\"port\" must be in range 1-65535, got %d", c.Port)
+	}
+	if c.ContainerRuntime != containers.Runtime_Docker && c.ContainerRuntime != containers.Runtime_Containerd {
+		addProblem("\"container_runtime\" must be docker or containerd, got %q", c.ContainerRuntime)
+	}
+	if c.HealthFailureThreshold < 1 {
+		addProblem("\"health_failure_threshold\" must be at least 1, got %d", c.HealthFailureThreshold)
+	}
+	paths := map[string]string{
+		"paths.certificates":  c.Paths.Certificates,
+		"paths.recovery_file": c.Paths.RecoveryFile,
+		"paths.cni_binaries":  c.Paths.CNIBinaries,
+	}
+	for name, path := range paths {
+		if !filepath.IsAbs(path) {
+			addProblem("%q must be an absolute path, got %q", name, path)
+		}
+	}
+	if c.TLS != nil {
+		if (c.TLS.CertFile == "") != (c.TLS.KeyFile == "") {
+			addProblem("\"tls.cert_file\" and \"tls.key_file\" must be given together")
+		}
+		files := map[string]string{"tls.ca_file": c.TLS.CAFile, "tls.cert_file": c.TLS.CertFile, "tls.key_file": c.TLS.KeyFile}
+		for name, path := range files {
+			if path == "" {
+				continue
+			}
+			if _, err := os.Stat(path); err != nil {
+				addProblem("%q is not accessible, error: %s", name, err.Error())
+			}
+		}
+	}
+	if _, err := logrus.ParseLevel(c.LogLevel); err != nil {
+		addProblem("\"log_level\" is illegal, error: %s", err.Error())
+	}
+	if c.Timeouts.Register.Duration <= 0 {
+		addProblem("\"timeouts.register\" must be positive, got %s", c.Timeouts.Register.String())
+	}
+	if c.Timeouts.Request.Duration <= 0 {
+		addProblem("\"timeouts.request\" must be positive, got %s", c.Timeouts.Request.String())
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	sort.Strings(problems)
+	return errors.New("Illegal agent configuration: " + strings.Join(problems, "; "))
+}
+
+//HasAgentRole returns true if the role is in the configuration.
+func HasAgentRole(c *entities.AgentConfig, role string) bool {
+	for i := 0; i < len(c.Roles); i++ {
+		if c.Roles[i] == role {
+			return true
+		}
+	}
+	return false
+}
+
+//FormatAgentLabels converts labels to the kubelet's format, e.g: "k1=v1,k2=v2".
+func FormatAgentLabels(labels map[string]string) string {
+	pairs := make([]string, 0, len(labels))
+	for k, v := range labels {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+//ParseAgentLabels parses the kubelet's format of labels.
+func ParseAgentLabels(s string) (map[string]string, error) {
+	labels := map[string]string{}
+	if s == "" {
+		return labels, nil
+	}
+	pairs := strings.Split(s, ",")
+	for i := 0; i < len(pairs); i++ {
+		kv := strings.SplitN(pairs[i], "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return nil, fmt.Errorf("Illegal label: %q, it must be a key=value pair", pairs[i])
+		}
+		labels[kv[0]] = kv[1]
+	}
+	return labels, nil
+}
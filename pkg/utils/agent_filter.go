@@ -0,0 +1,47 @@
+package utils
+
+import (
+	"github.com/g0194776/lightningmonkey/pkg/entities"
+)
+
+//FilterAgents returns the agents whose host facts match the filter.
+func FilterAgents(agents []entities.LightningMonkeyAgentBriefInformation, filter entities.AgentListFilter) []entities.LightningMonkeyAgentBriefInformation {
+	result := []entities.LightningMonkeyAgentBriefInformation{}
+	for i := 0; i < len(agents); i++ {
+		if IsHostMatched(agents[i].HostInformation, filter) {
+			result = append(result, agents[i])
+		}
+	}
+	return result
+}
+
+func IsHostMatched(host entities.HostInformation, filter entities.AgentListFilter) bool {
+	if filter.MinLogicalCPUs > 0 && host.LogicalCPUs < filter.MinLogicalCPUs {
+		return false
+	}
+	if filter.MinMemoryMB > 0 && host.MemoryTotalMB < filter.MinMemoryMB {
+		return false
+	}
+	if filter.CgroupVersion > 0 && host.CgroupVersion != filter.CgroupVersion {
+		return false
+	}
+	if filter.ContainerRuntime != "" && host.ContainerRuntime != filter.ContainerRuntime {
+		return false
+	}
+	if filter.DiskType == "" && filter.MinDiskSizeGB == 0 {
+		return true
+	}
+	for i := 0; i < len(host.Disks); i++ {
+		if filter.DiskType == entities.DiskType_SSD && host.Disks[i].IsRotational {
+			continue
+		}
+		if filter.DiskType == entities.DiskType_HDD && !host.Disks[i].IsRotational {
+			continue
+		}
+		if host.Disks[i].SizeMB < filter.MinDiskSizeGB*1024 {
+			continue
+		}
+		return true
+	}
+	return false
+}
@@ -0,0 +1,42 @@
+package utils
+
+import (
+	"math/rand"
+	"time"
+)
+
+//Backoff computes exponentially growing delays with random jitter for retrying transient failures.
+type Backoff struct {
+	Base     time.Duration
+	Max      time.Duration
+	attempts uint
+}
+
+//Next returns the delay before the next retry, it's picked randomly between the half and the full of the exponential delay.
+func (b *Backoff) Next() time.Duration {
+	d := b.Max
+	if b.attempts < 32 && b.Base<<b.attempts > 0 && b.Base<<b.attempts < b.Max {
+		d = b.Base << b.attempts
+	}
+	b.attempts++
+	if d <= 1 {
+		return d
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+//Reset starts over from the base delay, it's called after a successful attempt.
+func (b *Backoff) Reset() {
+	b.attempts = 0
+}
+
+//SelectServerEndpoint returns the index of the first healthy endpoint, the earlier one in the list is preferred.
+//The current one is kept when none of endpoints is healthy.
+func SelectServerEndpoint(healthy []bool, current int) int {
+	for i := 0; i < len(healthy); i++ {
+		if healthy[i] {
+			return i
+		}
+	}
+	return current
+}
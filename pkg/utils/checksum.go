@@ -0,0 +1,48 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"sort"
+)
+
+//FileChecksum returns the hex encoded SHA256 checksum of file content.
+func FileChecksum(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	hasher := sha256.New()
+	if _, err = io.Copy(hasher, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+//ChecksumFiles returns the checksums keyed by file path, the files which cannot be read are skipped.
+func ChecksumFiles(paths []string) map[string]string {
+	checksums := make(map[string]string, len(paths))
+	for i := 0; i < len(paths); i++ {
+		checksum, err := FileChecksum(paths[i])
+		if err != nil {
+			continue
+		}
+		checksums[paths[i]] = checksum
+	}
+	return checksums
+}
+
+//ChangedFiles returns the sorted paths of files which are missing or different from the recorded checksums.
+func ChangedFiles(checksums map[string]string) []string {
+	changed := []string{}
+	for p, expected := range checksums {
+		if checksum, err := FileChecksum(p); err != nil || checksum != expected {
+			changed = append(changed, p)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
@@ -0,0 +1,73 @@
+package utils
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"regexp"
+	"time"
+)
+
+const (
+	redactedValue = "<redacted>"
+)
+
+var (
+	//names of the fields which hold credentials, e.g: "client-key-data" of kubeconfig, "auth_pass" of keepalived and "password" of image pulling secrets.
+	secretFieldNames = `[\w-]*(?:password|token|private[_-]?key|key-data|auth_pass|admin_certificate)[\w-]*`
+	privateKeyRegex  = regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`)
+	jsonSecretRegex  = regexp.MustCompile(`(?i)("` + secretFieldNames + `"\s*:\s*)"(?:[^"\\]|\\.)*"`)
+	textSecretRegex  = regexp.MustCompile(`(?im)^(\s*` + secretFieldNames + `\s*[:=]?[ \t]+)\S.*$`)
+)
+
+//RedactSecrets strips private keys and the values of credential fields from JSON, YAML and configuration files.
+func RedactSecrets(data []byte) []byte {
+	data = privateKeyRegex.ReplaceAll(data, []byte(redactedValue))
+	data = jsonSecretRegex.ReplaceAll(data, []byte(`${1}"`+redactedValue+`"`))
+	return textSecretRegex.ReplaceAll(data, []byte("${1}"+redactedValue))
+}
+
+//DiagnosticsArchive writes a tar.gz archive whose files are redacted except the nested archives.
+type DiagnosticsArchive struct {
+	gw *gzip.Writer
+	tw *tar.Writer
+}
+
+func NewDiagnosticsArchive(w io.Writer) *DiagnosticsArchive {
+	gw := gzip.NewWriter(w)
+	return &DiagnosticsArchive{gw: gw, tw: tar.NewWriter(gw)}
+}
+
+func (da *DiagnosticsArchive) AddFile(name string, data []byte) error {
+	return da.addRawFile(name, RedactSecrets(data))
+}
+
+func (da *DiagnosticsArchive) AddJSON(name string, obj interface{}) error {
+	data, err := json.MarshalIndent(obj, "", "  ")
+	if err != nil {
+		return err
+	}
+	return da.AddFile(name, data)
+}
+
+//AddArchive adds an archive which has been redacted by the agent which built it.
+func (da *DiagnosticsArchive) AddArchive(name string, data []byte) error {
+	return da.addRawFile(name, data)
+}
+
+func (da *DiagnosticsArchive) Close() error {
+	if err := da.tw.Close(); err != nil {
+		return err
+	}
+	return da.gw.Close()
+}
+
+func (da *DiagnosticsArchive) addRawFile(name string, data []byte) error {
+	err := da.tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(data)), ModTime: time.Now()})
+	if err != nil {
+		return err
+	}
+	_, err = da.tw.Write(data)
+	return err
+}
@@ -0,0 +1,76 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/g0194776/lightningmonkey/pkg/entities"
+	"os"
+)
+
+var (
+	driftComponents = []string{
+		entities.AgentJob_Deploy_ETCD,
+		entities.AgentJob_Deploy_Master,
+		entities.AgentJob_Deploy_HA,
+		entities.ConfigDriftComponent_Kubelet,
+		entities.ConfigDriftComponent_Routes,
+	}
+)
+
+//ContentChecksum returns the hex encoded SHA256 checksum of the rendered content.
+func ContentChecksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+//DetectFileDrifts compares the configuration files on disk with the expected checksums keyed by file path.
+func DetectFileDrifts(component string, expected map[string]string) []entities.ConfigDrift {
+	drifts := []entities.ConfigDrift{}
+	for _, p := range ChangedFiles(expected) {
+		reason := "modified"
+		if _, err := os.Stat(p); os.IsNotExist(err) {
+			reason = "missing"
+		}
+		drifts = append(drifts, entities.ConfigDrift{Component: component, Target: p, Reason: reason})
+	}
+	return drifts
+}
+
+//ValidateDriftRemediation checks the components of remediation policy.
+func ValidateDriftRemediation(s *entities.DriftRemediationSettings) error {
+	if s == nil {
+		return nil
+	}
+	for i := 0; i < len(s.Components); i++ {
+		if !isDriftComponent(s.Components[i]) {
+			return fmt.Errorf("Unsupported component of \"drift_remediation.components\": %s, supported components: %v", s.Components[i], driftComponents)
+		}
+	}
+	return nil
+}
+
+//ShouldRemediateDrift returns true when the drift of given component is allowed to be restored automatically.
+func ShouldRemediateDrift(s *entities.DriftRemediationSettings, component string) bool {
+	if s == nil || !s.AutoRemediate {
+		return false
+	}
+	if len(s.Components) == 0 {
+		return true
+	}
+	for i := 0; i < len(s.Components); i++ {
+		if s.Components[i] == component {
+			return true
+		}
+	}
+	return false
+}
+
+func isDriftComponent(component string) bool {
+	for i := 0; i < len(driftComponents); i++ {
+		if driftComponents[i] == component {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,46 @@
+package utils
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"path"
+)
+
+//GetETCDMemberName generates a stable ETCD member name by node address.
+func GetETCDMemberName(addr string) string {
+	hasher := md5.New()
+	hasher.Write([]byte(addr))
+	return hex.EncodeToString(hasher.Sum(nil))
+}
+
+//GetETCDQuorum returns the minimum count of members to keep an ETCD cluster available.
+func GetETCDQuorum(memberCount int) int {
+	return memberCount/2 + 1
+}
+
+//CanAddETCDMember returns true if healthy members still satisfy the quorum of the enlarged cluster,
+//the newly added member counts but it's not started yet.
+//A single member cluster is an exception, it's the only way to grow it.
+func CanAddETCDMember(memberCount, healthyCount int) bool {
+	if memberCount == 1 {
+		return healthyCount == 1
+	}
+	return healthyCount >= GetETCDQuorum(memberCount+1)
+}
+
+//CanRemoveETCDMember returns true if the remaining healthy members satisfy the quorum of the shrunk cluster.
+func CanRemoveETCDMember(memberCount, healthyCount int, isHealthy bool) bool {
+	if memberCount <= 1 {
+		return false
+	}
+	if isHealthy {
+		healthyCount--
+	}
+	return healthyCount >= GetETCDQuorum(memberCount-1)
+}
+
+//GetETCDSnapshotLocation returns the relative path of a snapshot which is uploaded to API server,
+//it's served under "/apis/v1/snapshots/" of API server and saved under the snapshot storage directory.
+func GetETCDSnapshotLocation(clusterId, name string) string {
+	return path.Join(clusterId, name+".db")
+}
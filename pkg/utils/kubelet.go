@@ -0,0 +1,232 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/g0194776/lightningmonkey/pkg/entities"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	kubeletFlagNameRegex = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+	//evictionSignals are the signals supported by kubelet's eviction manager.
+	evictionSignals = map[string]bool{
+		"memory.available":            true,
+		"allocatableMemory.available": true,
+		"nodefs.available":            true,
+		"nodefs.inodesFree":           true,
+		"imagefs.available":           true,
+		"imagefs.inodesFree":          true,
+		"pid.available":               true,
+	}
+	//reservedKubeletFlags are managed by the agent, overriding them breaks the node registration.
+	reservedKubeletFlags = map[string]bool{
+		"config":               true,
+		"kubeconfig":           true,
+		"bootstrap-kubeconfig": true,
+		"hostname-override":    true,
+		"register-node":        true,
+		"node-labels":          true,
+		"register-with-taints": true,
+	}
+)
+
+//ValidateKubeletSettings returns all of problems of the kubelet settings in one error.
+func ValidateKubeletSettings(s *entities.KubeletSettings) error {
+	problems := kubeletSettingsProblems("kubelet", s)
+	if len(problems) == 0 {
+		return nil
+	}
+	sort.Strings(problems)
+	return errors.New("Illegal kubelet settings: " + strings.Join(problems, "; "))
+}
+
+//kubeletSettingsProblems returns the problems of kubelet settings, the field names are prefixed by given path.
+func kubeletSettingsProblems(path string, s *entities.KubeletSettings) []string {
+	problems := []string{}
+	if s == nil {
+		return problems
+	}
+	addProblem := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+	field := func(name string) string {
+		return strconv.Quote(path + "." + name)
+	}
+	if s.CgroupDriver != "" && s.CgroupDriver != "cgroupfs" && s.CgroupDriver != "systemd" {
+		addProblem("%s must be cgroupfs or systemd, got %q", field("cgroup_driver"), s.CgroupDriver)
+	}
+	evictions := map[string]map[string]string{field("eviction_hard"): s.EvictionHard, field("eviction_soft"): s.EvictionSoft}
+	for name, thresholds := range evictions {
+		for signal, v := range thresholds {
+			if !evictionSignals[signal] {
+				addProblem("unsupported eviction signal %q of %s", signal, name)
+			}
+			if !isEvictionThreshold(v) {
+				addProblem("eviction threshold %q=%q of %s must be a quantity or a percentage", signal, v, name)
+			}
+		}
+	}
+	for signal := range s.EvictionSoft {
+		if _, isOK := s.EvictionSoftGracePeriod[signal]; !isOK {
+			addProblem("grace period of soft eviction signal %q is required by %s", signal, field("eviction_soft_grace_period"))
+		}
+	}
+	for signal, v := range s.EvictionSoftGracePeriod {
+		if _, isOK := s.EvictionSoft[signal]; !isOK {
+			addProblem("grace period of signal %q is given without a threshold in %s", signal, field("eviction_soft"))
+		}
+		if d, err := time.ParseDuration(v); err != nil || d <= 0 {
+			addProblem("grace period %q=%q of %s must be a positive duration", signal, v, field("eviction_soft_grace_period"))
+		}
+	}
+	percents := map[string]*int{
+		field("image_gc_high_threshold_percent"): s.ImageGCHighThresholdPercent,
+		field("image_gc_low_threshold_percent"):  s.ImageGCLowThresholdPercent,
+	}
+	for name, v := range percents {
+		if v != nil && (*v < 0 || *v > 100) {
+			addProblem("%s must be between 0 and 100, got %d", name, *v)
+		}
+	}
+	high, low := 85, 80
+	if s.ImageGCHighThresholdPercent != nil {
+		high = *s.ImageGCHighThresholdPercent
+	}
+	if s.ImageGCLowThresholdPercent != nil {
+		low = *s.ImageGCLowThresholdPercent
+	}
+	if low >= high {
+		addProblem("%s (%d) must be less than %s (%d)", field("image_gc_low_threshold_percent"), low, field("image_gc_high_threshold_percent"), high)
+	}
+	if s.ContainerLogMaxSize != "" {
+		if q, err := resource.ParseQuantity(s.ContainerLogMaxSize); err != nil || q.Sign() <= 0 {
+			addProblem("%s must be a positive quantity, got %q", field("container_log_max_size"), s.ContainerLogMaxSize)
+		}
+	}
+	if s.ContainerLogMaxFiles != nil && *s.ContainerLogMaxFiles < 2 {
+		addProblem("%s must be at least 2, got %d", field("container_log_max_files"), *s.ContainerLogMaxFiles)
+	}
+	rates := map[string]*int{
+		field("kube_api_qps"):      s.KubeAPIQPS,
+		field("kube_api_burst"):    s.KubeAPIBurst,
+		field("event_record_qps"):  s.EventRecordQPS,
+		field("event_burst"):       s.EventBurst,
+		field("registry_pull_qps"): s.RegistryPullQPS,
+		field("registry_burst"):    s.RegistryBurst,
+	}
+	for name, v := range rates {
+		if v != nil && *v < 0 {
+			addProblem("%s must not be negative, got %d", name, *v)
+		}
+	}
+	for name := range s.ExtraFlags {
+		if !kubeletFlagNameRegex.MatchString(name) {
+			addProblem("illegal flag name %q of %s, it must be given without leading dashes", name, field("extra_flags"))
+		} else if reservedKubeletFlags[name] {
+			addProblem("flag %q of %s is managed by Lightning Monkey", name, field("extra_flags"))
+		}
+	}
+	return problems
+}
+
+//ParseKubeletSettings returns the kubelet settings delivered by master settings, nil means the defaults are used.
+func ParseKubeletSettings(masterSettings map[string]string) (*entities.KubeletSettings, error) {
+	v := masterSettings[entities.MasterSettings_KubeletSettings]
+	if v == "" {
+		return nil, nil
+	}
+	s := entities.KubeletSettings{}
+	if err := json.Unmarshal([]byte(v), &s); err != nil {
+		return nil, fmt.Errorf("Failed to parse kubelet settings, error: %s", err.Error())
+	}
+	return &s, nil
+}
+
+//ApplyKubeletSettings overrides the fields of KubeletConfiguration with the given settings, the eviction thresholds are
+//replaced as a whole same as kubelet does.
+func ApplyKubeletSettings(config map[string]interface{}, s *entities.KubeletSettings) {
+	if s == nil {
+		return
+	}
+	if s.CgroupDriver != "" {
+		config["cgroupDriver"] = s.CgroupDriver
+	}
+	thresholds := map[string]map[string]string{
+		"evictionHard":            s.EvictionHard,
+		"evictionSoft":            s.EvictionSoft,
+		"evictionSoftGracePeriod": s.EvictionSoftGracePeriod,
+	}
+	for key, v := range thresholds {
+		if len(v) > 0 {
+			config[key] = v
+		}
+	}
+	if s.ContainerLogMaxSize != "" {
+		config["containerLogMaxSize"] = s.ContainerLogMaxSize
+	}
+	if s.SerializeImagePulls != nil {
+		config["serializeImagePulls"] = *s.SerializeImagePulls
+	}
+	numbers := map[string]*int{
+		"imageGCHighThresholdPercent": s.ImageGCHighThresholdPercent,
+		"imageGCLowThresholdPercent":  s.ImageGCLowThresholdPercent,
+		"containerLogMaxFiles":        s.ContainerLogMaxFiles,
+		"kubeAPIQPS":                  s.KubeAPIQPS,
+		"kubeAPIBurst":                s.KubeAPIBurst,
+		"eventRecordQPS":              s.EventRecordQPS,
+		"eventBurst":                  s.EventBurst,
+		"registryPullQPS":             s.RegistryPullQPS,
+		"registryBurst":               s.RegistryBurst,
+	}
+	for key, v := range numbers {
+		if v != nil {
+			config[key] = *v
+		}
+	}
+}
+
+//MergeKubeletFlags replaces the flags of kubelet command which are also given by extra flags,
+//the rest of extra flags are appended in order of name.
+func MergeKubeletFlags(cmd []string, extra map[string]string) []string {
+	if len(extra) == 0 {
+		return cmd
+	}
+	merged := make([]string, 0, len(cmd)+len(extra))
+	used := map[string]bool{}
+	for i := 0; i < len(cmd); i++ {
+		name := strings.SplitN(strings.TrimLeft(cmd[i], "-"), "=", 2)[0]
+		if v, isOK := extra[name]; isOK && strings.HasPrefix(cmd[i], "--") {
+			merged = append(merged, fmt.Sprintf("--%s=%s", name, v))
+			used[name] = true
+			continue
+		}
+		merged = append(merged, cmd[i])
+	}
+	names := make([]string, 0, len(extra))
+	for name := range extra {
+		if !used[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for i := 0; i < len(names); i++ {
+		merged = append(merged, fmt.Sprintf("--%s=%s", names[i], extra[names[i]]))
+	}
+	return merged
+}
+
+func isEvictionThreshold(v string) bool {
+	if strings.HasSuffix(v, "%") {
+		p, err := strconv.ParseFloat(strings.TrimSuffix(v, "%"), 64)
+		return err == nil && p >= 0 && p <= 100
+	}
+	q, err := resource.ParseQuantity(v)
+	return err == nil && q.Sign() >= 0
+}
@@ -0,0 +1,24 @@
+package utils
+
+import (
+	"fmt"
+	"time"
+)
+
+//ParseLogSince accepts either a relative duration like "10m" or a RFC3339 timestamp, empty string returns the zero time.
+func ParseLogSince(since string, now time.Time) (time.Time, error) {
+	if since == "" {
+		return time.Time{}, nil
+	}
+	if d, err := time.ParseDuration(since); err == nil {
+		if d < 0 {
+			return time.Time{}, fmt.Errorf("Illegal log since: %s, duration must not be negative", since)
+		}
+		return now.Add(-d), nil
+	}
+	t, err := time.Parse(time.RFC3339, since)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("Illegal log since: %s, it must be a duration like \"10m\" or a RFC3339 timestamp", since)
+	}
+	return t, nil
+}
@@ -0,0 +1,214 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/g0194776/lightningmonkey/pkg/entities"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sort"
+	"strings"
+)
+
+var (
+	taintEffects = map[string]bool{"NoSchedule": true, "PreferNoSchedule": true, "NoExecute": true}
+)
+
+//ValidateNodePools returns all of problems of the node pools in one error,
+//the kubelet overrides are validated along with the cluster's kubelet settings.
+func ValidateNodePools(cluster entities.LightningMonkeyClusterSettings) error {
+	problems := []string{}
+	addProblem := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+	names := map[string]bool{}
+	for i := 0; i < len(cluster.NodePools); i++ {
+		pool := cluster.NodePools[i]
+		path := fmt.Sprintf("node_pools[%d]", i)
+		if msgs := validation.IsDNS1123Label(pool.Name); len(msgs) > 0 {
+			addProblem("\"%s.name\" %q is illegal: %s", path, pool.Name, strings.Join(msgs, ", "))
+		}
+		if names[pool.Name] {
+			addProblem("duplicated node pool %q", pool.Name)
+		}
+		names[pool.Name] = true
+		for k, v := range pool.Labels {
+			msgs := append(validation.IsQualifiedName(k), validation.IsValidLabelValue(v)...)
+			if len(msgs) > 0 {
+				addProblem("label %q=%q of \"%s.labels\" is illegal: %s", k, v, path, strings.Join(msgs, ", "))
+			}
+		}
+		for j := 0; j < len(pool.Taints); j++ {
+			t := pool.Taints[j]
+			msgs := append(validation.IsQualifiedName(t.Key), validation.IsValidLabelValue(t.Value)...)
+			if len(msgs) > 0 {
+				addProblem("\"%s.taints[%d]\" is illegal: %s", path, j, strings.Join(msgs, ", "))
+			}
+			if !taintEffects[t.Effect] {
+				addProblem("\"%s.taints[%d].effect\" must be NoSchedule, PreferNoSchedule or NoExecute, got %q", path, j, t.Effect)
+			}
+		}
+		if rr := pool.ResourceReservation; rr != nil {
+			reservations := map[string]string{path + ".resource_reservation.kube": rr.Kube, path + ".resource_reservation.system": rr.System}
+			for name, v := range reservations {
+				if err := validateResourceList(v); err != nil {
+					addProblem("%q is illegal: %s", name, err.Error())
+				}
+			}
+		}
+		if pool.Kubelet != nil {
+			problems = append(problems, kubeletSettingsProblems(path+".kubelet", MergeKubeletSettings(cluster.Kubelet, pool.Kubelet))...)
+		}
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	sort.Strings(problems)
+	return errors.New("Illegal node pools: " + strings.Join(problems, "; "))
+}
+
+//MergeKubeletSettings returns the cluster's kubelet settings overridden by the given fields of node pool,
+//the extra flags are merged by name.
+func MergeKubeletSettings(cluster, pool *entities.KubeletSettings) *entities.KubeletSettings {
+	if pool == nil {
+		return cluster
+	}
+	merged := entities.KubeletSettings{}
+	if cluster != nil {
+		merged = *cluster
+	}
+	if pool.CgroupDriver != "" {
+		merged.CgroupDriver = pool.CgroupDriver
+	}
+	if pool.EvictionHard != nil {
+		merged.EvictionHard = pool.EvictionHard
+	}
+	if pool.EvictionSoft != nil {
+		merged.EvictionSoft = pool.EvictionSoft
+		merged.EvictionSoftGracePeriod = pool.EvictionSoftGracePeriod
+	}
+	if pool.ContainerLogMaxSize != "" {
+		merged.ContainerLogMaxSize = pool.ContainerLogMaxSize
+	}
+	if pool.SerializeImagePulls != nil {
+		merged.SerializeImagePulls = pool.SerializeImagePulls
+	}
+	overrideInt(&merged.ImageGCHighThresholdPercent, pool.ImageGCHighThresholdPercent)
+	overrideInt(&merged.ImageGCLowThresholdPercent, pool.ImageGCLowThresholdPercent)
+	overrideInt(&merged.ContainerLogMaxFiles, pool.ContainerLogMaxFiles)
+	overrideInt(&merged.KubeAPIQPS, pool.KubeAPIQPS)
+	overrideInt(&merged.KubeAPIBurst, pool.KubeAPIBurst)
+	overrideInt(&merged.EventRecordQPS, pool.EventRecordQPS)
+	overrideInt(&merged.EventBurst, pool.EventBurst)
+	overrideInt(&merged.RegistryPullQPS, pool.RegistryPullQPS)
+	overrideInt(&merged.RegistryBurst, pool.RegistryBurst)
+	if len(pool.ExtraFlags) > 0 {
+		flags := make(map[string]string, len(merged.ExtraFlags)+len(pool.ExtraFlags))
+		for k, v := range merged.ExtraFlags {
+			flags[k] = v
+		}
+		for k, v := range pool.ExtraFlags {
+			flags[k] = v
+		}
+		merged.ExtraFlags = flags
+	}
+	return &merged
+}
+
+//ParseNodePool returns nil if the minion does not belong to any node pool.
+func ParseNodePool(data string) (*entities.NodePoolSettings, error) {
+	if data == "" {
+		return nil, nil
+	}
+	pool := entities.NodePoolSettings{}
+	if err := json.Unmarshal([]byte(data), &pool); err != nil {
+		return nil, fmt.Errorf("Failed to parse node pool settings, error: %s", err.Error())
+	}
+	return &pool, nil
+}
+
+//ApplyNodePool returns a copy of master settings in which the resource reservation and kubelet settings are
+//overridden by the node pool, the empty reservation of node pool keeps the cluster's one.
+func ApplyNodePool(masterSettings map[string]string, pool *entities.NodePoolSettings) (map[string]string, error) {
+	settings := make(map[string]string, len(masterSettings))
+	for k, v := range masterSettings {
+		settings[k] = v
+	}
+	if pool == nil {
+		return settings, nil
+	}
+	if rr := pool.ResourceReservation; rr != nil {
+		if rr.Kube != "" {
+			settings[entities.MasterSettings_ResourceReservation_Kube] = rr.Kube
+		}
+		if rr.System != "" {
+			settings[entities.MasterSettings_ResourceReservation_System] = rr.System
+		}
+	}
+	if pool.Kubelet != nil {
+		cluster, err := ParseKubeletSettings(masterSettings)
+		if err != nil {
+			return nil, err
+		}
+		data, err := json.Marshal(MergeKubeletSettings(cluster, pool.Kubelet))
+		if err != nil {
+			return nil, err
+		}
+		settings[entities.MasterSettings_KubeletSettings] = string(data)
+	}
+	return settings, nil
+}
+
+//MergeNodeLabels returns the labels of node pool with the agent's own ones in kubelet's format, the latter wins.
+func MergeNodeLabels(pool *entities.NodePoolSettings, agentLabels string) (string, error) {
+	labels, err := ParseAgentLabels(agentLabels)
+	if err != nil {
+		return "", err
+	}
+	if pool != nil {
+		for k, v := range pool.Labels {
+			if _, isOK := labels[k]; !isOK {
+				labels[k] = v
+			}
+		}
+	}
+	return FormatAgentLabels(labels), nil
+}
+
+//FormatNodeTaints returns the taints in kubelet's format, e.g: "dedicated=gpu:NoSchedule,spot:NoExecute".
+func FormatNodeTaints(taints []entities.NodeTaint) string {
+	pairs := make([]string, 0, len(taints))
+	for i := 0; i < len(taints); i++ {
+		if taints[i].Value == "" {
+			pairs = append(pairs, fmt.Sprintf("%s:%s", taints[i].Key, taints[i].Effect))
+		} else {
+			pairs = append(pairs, fmt.Sprintf("%s=%s:%s", taints[i].Key, taints[i].Value, taints[i].Effect))
+		}
+	}
+	return strings.Join(pairs, ",")
+}
+
+func overrideInt(field **int, v *int) {
+	if v != nil {
+		*field = v
+	}
+}
+
+//validateResourceList checks the kubelet's format of reserved resources, e.g: "cpu=200m,memory=400Mi".
+func validateResourceList(s string) error {
+	if s == "" {
+		return nil
+	}
+	pairs := strings.Split(s, ",")
+	for i := 0; i < len(pairs); i++ {
+		kv := strings.SplitN(pairs[i], "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return fmt.Errorf("%q must be a name=quantity pair", pairs[i])
+		}
+		if _, err := resource.ParseQuantity(kv[1]); err != nil {
+			return fmt.Errorf("%q has an illegal quantity", pairs[i])
+		}
+	}
+	return nil
+}
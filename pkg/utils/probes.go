@@ -0,0 +1,80 @@
+package utils
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"github.com/g0194776/lightningmonkey/pkg/entities"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"time"
+)
+
+//ProbeCertificates are the files which are used to verify a component and to authenticate the prober,
+//an empty CA file means the component is probed over plain HTTP.
+type ProbeCertificates struct {
+	CAFile   string
+	CertFile string
+	KeyFile  string
+}
+
+//GetAPIServerProbeCertificates returns the cluster CA which is located in the certificates directory of agent, e.g: /etc/kubernetes/pki.
+func GetAPIServerProbeCertificates(certPath string) ProbeCertificates {
+	return ProbeCertificates{CAFile: filepath.Join(certPath, "ca.crt")}
+}
+
+//GetETCDProbeCertificates returns the ETCD CA and the health check client certificate which are generated by kubeadm.
+func GetETCDProbeCertificates(certPath string) ProbeCertificates {
+	return ProbeCertificates{
+		CAFile:   filepath.Join(certPath, "etcd", "ca.crt"),
+		CertFile: filepath.Join(certPath, "etcd", "healthcheck-client.crt"),
+		KeyFile:  filepath.Join(certPath, "etcd", "healthcheck-client.key"),
+	}
+}
+
+//NewProbeClient creates a HTTP client which verifies the server by the given CA and authenticates itself by the optional client certificate.
+func NewProbeClient(c ProbeCertificates, timeout time.Duration) (*http.Client, error) {
+	transport := &http.Transport{DisableKeepAlives: true}
+	if c.CAFile != "" {
+		ca, err := ioutil.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to read CA file, error: %s", err.Error())
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, errors.New("Failed to parse CA file: " + c.CAFile)
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+		if c.CertFile != "" {
+			cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+			if err != nil {
+				return nil, fmt.Errorf("Failed to load client certificate, error: %s", err.Error())
+			}
+			transport.TLSClientConfig.Certificates = []tls.Certificate{cert}
+		}
+	}
+	return &http.Client{Timeout: timeout, Transport: transport}, nil
+}
+
+//UpdateComponentProbe records the result of a probe, the component stays in its previous status until it has failed
+//for "failureThreshold" times in a row, a component which has never succeeded is unhealthy.
+func UpdateComponentProbe(probe *entities.ComponentProbe, err error, latency time.Duration, failureThreshold int) {
+	if failureThreshold < 1 {
+		failureThreshold = 1
+	}
+	probe.LatencyMs = int64(latency / time.Millisecond)
+	probe.LastProbeTime = time.Now()
+	if err == nil {
+		probe.IsHealthy = true
+		probe.ConsecutiveFailures = 0
+		probe.Reason = ""
+		return
+	}
+	probe.ConsecutiveFailures++
+	probe.Reason = err.Error()
+	if probe.ConsecutiveFailures >= failureThreshold {
+		probe.IsHealthy = false
+	}
+}
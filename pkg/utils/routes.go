@@ -0,0 +1,43 @@
+package utils
+
+import (
+	"github.com/g0194776/lightningmonkey/pkg/entities"
+	"sort"
+)
+
+//DiffSystemRoutes compares routes by their destinations, it returns routes which need to be added,
+//routes which need to be replaced because of the changed gateway and routes which need to be deleted.
+func DiffSystemRoutes(desired, actual []entities.SystemRoute) ([]entities.SystemRoute, []entities.SystemRoute, []entities.SystemRoute) {
+	added := []entities.SystemRoute{}
+	changed := []entities.SystemRoute{}
+	removed := []entities.SystemRoute{}
+	actualRoutes := make(map[string]entities.SystemRoute, len(actual))
+	for i := 0; i < len(actual); i++ {
+		actualRoutes[actual[i].Destination] = actual[i]
+	}
+	desiredRoutes := make(map[string]entities.SystemRoute, len(desired))
+	for i := 0; i < len(desired); i++ {
+		desiredRoutes[desired[i].Destination] = desired[i]
+		r, isOK := actualRoutes[desired[i].Destination]
+		if !isOK {
+			added = append(added, desired[i])
+		} else if r.Gateway != desired[i].Gateway {
+			changed = append(changed, desired[i])
+		}
+	}
+	for i := 0; i < len(actual); i++ {
+		if _, isOK := desiredRoutes[actual[i].Destination]; !isOK {
+			removed = append(removed, actual[i])
+		}
+	}
+	sortSystemRoutes(added)
+	sortSystemRoutes(changed)
+	sortSystemRoutes(removed)
+	return added, changed, removed
+}
+
+func sortSystemRoutes(routes []entities.SystemRoute) {
+	sort.Slice(routes, func(i, j int) bool {
+		return routes[i].Destination < routes[j].Destination
+	})
+}
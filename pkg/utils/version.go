@@ -0,0 +1,58 @@
+package utils
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+//CompareVersions compares dotted numeric versions, e.g: "v1.2.13", "18.09.7-ce" and "1.13.1".
+//it returns -1, 0 or 1, non-numeric suffixes are ignored.
+func CompareVersions(a, b string) int {
+	pa := parseVersion(a)
+	pb := parseVersion(b)
+	for i := 0; i < len(pa) || i < len(pb); i++ {
+		var va, vb int
+		if i < len(pa) {
+			va = pa[i]
+		}
+		if i < len(pb) {
+			vb = pb[i]
+		}
+		if va < vb {
+			return -1
+		}
+		if va > vb {
+			return 1
+		}
+	}
+	return 0
+}
+
+//ParseKubernetesVersion returns the major, minor and patch numbers of a Kubernetes version, e.g: "v1.13.5".
+func ParseKubernetesVersion(version string) ([3]int, error) {
+	var v [3]int
+	parts := parseVersion(version)
+	if len(parts) != 3 || len(strings.Split(strings.TrimPrefix(version, "v"), ".")) != 3 {
+		return v, fmt.Errorf("Illegal Kubernetes version: %s", version)
+	}
+	copy(v[:], parts)
+	return v, nil
+}
+
+func parseVersion(v string) []int {
+	v = strings.TrimPrefix(strings.TrimSpace(v), "v")
+	if idx := strings.IndexAny(v, "-+ "); idx >= 0 {
+		v = v[:idx]
+	}
+	parts := strings.Split(v, ".")
+	result := make([]int, 0, len(parts))
+	for i := 0; i < len(parts); i++ {
+		n, err := strconv.Atoi(parts[i])
+		if err != nil {
+			break
+		}
+		result = append(result, n)
+	}
+	return result
+}
@@ -0,0 +1,65 @@
+package test
+
+import (
+	"github.com/g0194776/lightningmonkey/pkg/entities"
+	"github.com/g0194776/lightningmonkey/pkg/utils"
+	assert "github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_LoadAgentConfig(t *testing.T) {
+	c, err := utils.LoadAgentConfig([]byte(`
+version: v1
+servers: ["https://10.0.0.1:8443", "https://10.0.0.2:8443"]
+interface: eth1
+labels:
+  zone: a
+  rack: r1
+roles: [etcd, minion]
+timeouts:
+  request: 10s
+`))
+	assert.Nil(t, err)
+	assert.Nil(t, utils.ValidateAgentConfig(c))
+	assert.Equal(t, 2, len(c.Servers))
+	assert.Equal(t, "rack=r1,zone=a", utils.FormatAgentLabels(c.Labels))
+	assert.True(t, utils.HasAgentRole(c, entities.AgentRole_ETCD))
+	assert.False(t, utils.HasAgentRole(c, entities.AgentRole_Master))
+	assert.Equal(t, time.Second*10, c.Timeouts.Request.Duration)
+	//defaults are kept for the missing fields.
+	assert.Equal(t, time.Second*120, c.Timeouts.Register.Duration)
+	assert.Equal(t, 6060, c.Port)
+	assert.Equal(t, "/etc/kubernetes", c.Paths.Certificates)
+
+	_, err = utils.LoadAgentConfig([]byte("version: v1\nserver: http://10.0.0.1:8080\n"))
+	assert.NotNil(t, err)
+	assert.True(t, strings.Contains(err.Error(), "unknown field"))
+	_, err = utils.LoadAgentConfig([]byte("version: v1\ntimeouts:\n  request: soon\n"))
+	assert.NotNil(t, err)
+}
+
+func Test_ValidateAgentConfig(t *testing.T) {
+	c := utils.NewDefaultAgentConfig()
+	c.Servers = []string{"10.0.0.1:8080"}
+	c.Roles = []string{"minion", "worker", "minion"}
+	c.Port = 70000
+	c.Paths.RecoveryFile = "recovery"
+	c.TLS = &entities.AgentTLSConfig{CertFile: "/tmp/client.crt"}
+	c.LogLevel = "verbose"
+	c.Timeouts.Request.Duration = 0
+	err := utils.ValidateAgentConfig(c)
+	assert.NotNil(t, err)
+	for _, problem := range []string{"\"interface\" is required", "servers[0]", "unsupported role \"worker\"", "duplicated role \"minion\"", "\"port\"", "paths.recovery_file", "given together", "tls.cert_file", "log_level", "timeouts.request"} {
+		assert.True(t, strings.Contains(err.Error(), problem), problem)
+	}
+}
+
+func Test_ParseAgentLabels(t *testing.T) {
+	labels, err := utils.ParseAgentLabels("zone=a,node.kubernetes.io/pool=gpu")
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"zone": "a", "node.kubernetes.io/pool": "gpu"}, labels)
+	_, err = utils.ParseAgentLabels("zone")
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,52 @@
+package test
+
+import (
+	"github.com/g0194776/lightningmonkey/pkg/entities"
+	"github.com/g0194776/lightningmonkey/pkg/utils"
+	assert "github.com/stretchr/testify/require"
+	"testing"
+)
+
+func Test_FilterAgents(t *testing.T) {
+	agents := []entities.LightningMonkeyAgentBriefInformation{
+		{
+			Id: "ssd-node",
+			HostInformation: entities.HostInformation{
+				LogicalCPUs:      16,
+				MemoryTotalMB:    32 * 1024,
+				CgroupVersion:    1,
+				ContainerRuntime: "docker",
+				Disks: []entities.DiskInformation{
+					{Name: "sda", SizeMB: 100 * 1024, IsRotational: true},
+					{Name: "nvme0n1", SizeMB: 500 * 1024},
+				},
+			},
+		},
+		{
+			Id: "hdd-node",
+			HostInformation: entities.HostInformation{
+				LogicalCPUs:      4,
+				MemoryTotalMB:    8 * 1024,
+				CgroupVersion:    2,
+				ContainerRuntime: "containerd",
+				Disks:            []entities.DiskInformation{{Name: "sda", SizeMB: 2048 * 1024, IsRotational: true}},
+			},
+		},
+	}
+	assert.Equal(t, 2, len(utils.FilterAgents(agents, entities.AgentListFilter{})))
+
+	result := utils.FilterAgents(agents, entities.AgentListFilter{MinLogicalCPUs: 8, MinMemoryMB: 16 * 1024})
+	assert.Equal(t, 1, len(result))
+	assert.Equal(t, "ssd-node", result[0].Id)
+
+	result = utils.FilterAgents(agents, entities.AgentListFilter{CgroupVersion: 2, ContainerRuntime: "containerd"})
+	assert.Equal(t, 1, len(result))
+	assert.Equal(t, "hdd-node", result[0].Id)
+
+	//the SSD of "ssd-node" is too small, the large disk of "hdd-node" is rotational.
+	assert.Equal(t, 0, len(utils.FilterAgents(agents, entities.AgentListFilter{DiskType: entities.DiskType_SSD, MinDiskSizeGB: 1024})))
+
+	result = utils.FilterAgents(agents, entities.AgentListFilter{DiskType: entities.DiskType_HDD, MinDiskSizeGB: 1024})
+	assert.Equal(t, 1, len(result))
+	assert.Equal(t, "hdd-node", result[0].Id)
+}
@@ -0,0 +1,71 @@
+package test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/g0194776/lightningmonkey/pkg/entities"
+	"github.com/g0194776/lightningmonkey/pkg/managers"
+	"github.com/g0194776/lightningmonkey/pkg/utils"
+	assert "github.com/stretchr/testify/require"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"testing"
+)
+
+func Test_CanaryAgentSelection(t *testing.T) {
+	selected := 0
+	for i := 0; i < 1000; i++ {
+		agentId := fmt.Sprintf("agent-%d", i)
+		assert.False(t, utils.IsCanaryAgent(agentId, 0))
+		assert.True(t, utils.IsCanaryAgent(agentId, 100))
+		if utils.IsCanaryAgent(agentId, 10) {
+			selected++
+			//the agents which are selected by the smaller percentage are always selected by the larger one.
+			assert.True(t, utils.IsCanaryAgent(agentId, 50))
+		}
+	}
+	assert.True(t, selected > 50 && selected < 150)
+}
+
+func Test_GetAgentSoftware(t *testing.T) {
+	filePath := path.Join(path.Dir(os.Args[0]), utils.GetAgentSoftwareLocation("1.1.0"))
+	assert.Nil(t, os.MkdirAll(path.Dir(filePath), 0755))
+	defer os.RemoveAll(path.Join(path.Dir(os.Args[0]), "registry"))
+	data := []byte("agent binary")
+	assert.Nil(t, ioutil.WriteFile(filePath, data, 0755))
+	hasher := sha256.New()
+	hasher.Write(data)
+
+	settings := entities.LightningMonkeyClusterSettings{AgentRollout: &entities.AgentRolloutSettings{Version: "1.1.0", CanaryPercentage: 100}}
+	s := managers.GetAgentSoftware(settings, "agent-1", "1.0.0", false)
+	assert.NotNil(t, s)
+	assert.True(t, s.Version == "1.1.0")
+	assert.True(t, s.Location == utils.GetAgentSoftwareLocation("1.1.0"))
+	assert.True(t, s.Checksum == hex.EncodeToString(hasher.Sum(nil)))
+	//already upgraded.
+	assert.Nil(t, managers.GetAgentSoftware(settings, "agent-1", "1.1.0", false))
+	//agents of previous versions.
+	assert.Nil(t, managers.GetAgentSoftware(settings, "agent-1", "", false))
+	//containerized agents are upgraded by replacing their images.
+	assert.Nil(t, managers.GetAgentSoftware(settings, "agent-1", "1.0.0", true))
+	//not selected.
+	settings.AgentRollout.CanaryPercentage = 0
+	assert.Nil(t, managers.GetAgentSoftware(settings, "agent-1", "1.0.0", false))
+	//artifact not found.
+	settings.AgentRollout = &entities.AgentRolloutSettings{Version: "1.2.0", CanaryPercentage: 100}
+	assert.Nil(t, managers.GetAgentSoftware(settings, "agent-1", "1.0.0", false))
+}
+
+func Test_IsAgentAccessTokenMatched(t *testing.T) {
+	header := http.Header{}
+	utils.SetAgentAccessToken(header, "agent-token")
+	assert.True(t, utils.IsAgentAccessTokenMatched(header.Get("Authorization"), "agent-token"))
+	assert.False(t, utils.IsAgentAccessTokenMatched(header.Get("Authorization"), "another-token"))
+	//the token must be given as a bearer token.
+	assert.False(t, utils.IsAgentAccessTokenMatched("agent-token", "agent-token"))
+	assert.False(t, utils.IsAgentAccessTokenMatched("", ""))
+	assert.False(t, utils.IsAgentAccessTokenMatched("Bearer ", ""))
+}
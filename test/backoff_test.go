@@ -0,0 +1,34 @@
+package test
+
+import (
+	"github.com/g0194776/lightningmonkey/pkg/utils"
+	assert "github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func Test_Backoff(t *testing.T) {
+	bo := utils.Backoff{Base: time.Second, Max: time.Second * 10}
+	expected := []time.Duration{time.Second, time.Second * 2, time.Second * 4, time.Second * 8, time.Second * 10, time.Second * 10}
+	for i := 0; i < len(expected); i++ {
+		d := bo.Next()
+		assert.True(t, d >= expected[i]/2 && d <= expected[i], "attempt: %d, delay: %s", i, d)
+	}
+	//never overflows after lots of attempts.
+	for i := 0; i < 100; i++ {
+		d := bo.Next()
+		assert.True(t, d >= time.Second*5 && d <= time.Second*10)
+	}
+	bo.Reset()
+	d := bo.Next()
+	assert.True(t, d >= time.Millisecond*500 && d <= time.Second)
+}
+
+func Test_SelectServerEndpoint(t *testing.T) {
+	//the preferred one is picked when it has recovered.
+	assert.Equal(t, 0, utils.SelectServerEndpoint([]bool{true, true, true}, 2))
+	assert.Equal(t, 1, utils.SelectServerEndpoint([]bool{false, true, true}, 2))
+	assert.Equal(t, 2, utils.SelectServerEndpoint([]bool{false, false, true}, 0))
+	//keeps the current one when none of them is healthy.
+	assert.Equal(t, 1, utils.SelectServerEndpoint([]bool{false, false, false}, 1))
+}
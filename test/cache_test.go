@@ -38,6 +38,7 @@ func Test_WithoutAnyLiveNodes(t *testing.T) {
 	gc := gomock.NewController(t)
 	defer gc.Finish()
 	cc := mock_lm.NewMockClusterController(gc)
+	cc.EXPECT().GetUpgrade().Return(nil)
 	cc.EXPECT().GetSettings().Return(cs)
 
 	var currentAgent entities.LightningMonkeyAgent
@@ -72,6 +73,7 @@ func Test_WithoutAnyExpectedETCDNodes(t *testing.T) {
 	gc := gomock.NewController(t)
 	defer gc.Finish()
 	cc := mock_lm.NewMockClusterController(gc)
+	cc.EXPECT().GetUpgrade().Return(nil)
 	cc.EXPECT().GetSettings().Return(cs)
 
 	var currentAgent entities.LightningMonkeyAgent
@@ -118,6 +120,7 @@ func Test_LessThanExpectedETCDNodes(t *testing.T) {
 	gc := gomock.NewController(t)
 	defer gc.Finish()
 	cc := mock_lm.NewMockClusterController(gc)
+	cc.EXPECT().GetUpgrade().Return(nil)
 	cc.EXPECT().GetSettings().Return(cs)
 
 	var currentAgent entities.LightningMonkeyAgent
@@ -162,6 +165,7 @@ func Test_LessThanExpectedETCDNodes2(t *testing.T) {
 	gc := gomock.NewController(t)
 	defer gc.Finish()
 	cc := mock_lm.NewMockClusterController(gc)
+	cc.EXPECT().GetUpgrade().Return(nil)
 	cc.EXPECT().GetSettings().Return(cs)
 
 	var currentAgent entities.LightningMonkeyAgent
@@ -264,6 +268,8 @@ func Test_ProvisionedCountThanLessExpectedETCDNodeCount(t *testing.T) {
 	gc := gomock.NewController(t)
 	defer gc.Finish()
 	cc := mock_lm.NewMockClusterController(gc)
+	cc.EXPECT().GetUpgrade().Return(nil)
+	cc.EXPECT().GetSnapshots().Return(nil)
 	cc.EXPECT().GetSettings().Return(cs)
 
 	currentAgent := entities.LightningMonkeyAgent{
@@ -331,6 +337,8 @@ func Test_ProvisionedCountThanLessExpectedETCDNodeCount2(t *testing.T) {
 	gc := gomock.NewController(t)
 	defer gc.Finish()
 	cc := mock_lm.NewMockClusterController(gc)
+	cc.EXPECT().GetUpgrade().Return(nil)
+	cc.EXPECT().GetSnapshots().Return(nil)
 	cc.EXPECT().GetSettings().Return(cs)
 
 	currentAgent := entities.LightningMonkeyAgent{
@@ -403,6 +411,8 @@ func Test_ProvisionedCountThanLessExpectedETCDNodeCount3(t *testing.T) {
 	gc := gomock.NewController(t)
 	defer gc.Finish()
 	cc := mock_lm.NewMockClusterController(gc)
+	cc.EXPECT().GetUpgrade().Return(nil)
+	cc.EXPECT().GetSnapshots().Return(nil)
 	cc.EXPECT().GetSettings().Return(cs)
 
 	currentAgent := entities.LightningMonkeyAgent{
@@ -508,6 +518,8 @@ func Test_GetETCDDeploymentJob(t *testing.T) {
 	defer gc.Finish()
 	cc := mock_lm.NewMockClusterController(gc)
 	cc.EXPECT().GetSettings().Return(cs)
+	cc.EXPECT().GetUpgrade().Return(nil)
+	cc.EXPECT().GetSnapshots().Return(nil)
 
 	agent1 := entities.LightningMonkeyAgent{
 		Id:        uuid.NewV4().String(),
@@ -592,7 +604,9 @@ func Test_WithoutAnyK8sMasterNodes(t *testing.T) {
 	gc := gomock.NewController(t)
 	defer gc.Finish()
 	cc := mock_lm.NewMockClusterController(gc)
-	cc.EXPECT().GetSettings().Return(cs)
+	cc.EXPECT().GetUpgrade().Return(nil)
+	cc.EXPECT().GetSnapshots().Return(nil)
+	cc.EXPECT().GetSettings().Return(cs).Times(2)
 
 	agent1 := entities.LightningMonkeyAgent{
 		Id:        uuid.NewV4().String(),
@@ -672,7 +686,8 @@ func Test_GetK8sMasterDeploymentJob(t *testing.T) {
 	gc := gomock.NewController(t)
 	defer gc.Finish()
 	cc := mock_lm.NewMockClusterController(gc)
-	cc.EXPECT().GetSettings().Return(cs)
+	cc.EXPECT().GetUpgrade().Return(nil)
+	cc.EXPECT().GetSettings().Return(cs).Times(2)
 
 	agent1 := entities.LightningMonkeyAgent{
 		Id:        uuid.NewV4().String(),
@@ -769,7 +784,8 @@ func Test_WaitingAtLeastOneLiveK8sMaster(t *testing.T) {
 	gc := gomock.NewController(t)
 	defer gc.Finish()
 	cc := mock_lm.NewMockClusterController(gc)
-	cc.EXPECT().GetSettings().Return(cs)
+	cc.EXPECT().GetUpgrade().Return(nil)
+	cc.EXPECT().GetSettings().Return(cs).Times(2)
 
 	agent1 := entities.LightningMonkeyAgent{
 		Id:            uuid.NewV4().String(),
@@ -860,7 +876,9 @@ func Test_GetK8sMinionDeploymentJob(t *testing.T) {
 	gc := gomock.NewController(t)
 	defer gc.Finish()
 	cc := mock_lm.NewMockClusterController(gc)
+	cc.EXPECT().GetUpgrade().Return(nil)
 	cc.EXPECT().GetSettings().Return(cs).AnyTimes()
+	cc.EXPECT().RequestKubeletBootstrapToken(gomock.Any()).Return("abcdef.0123456789abcdef").AnyTimes()
 
 	agent1 := entities.LightningMonkeyAgent{
 		Id:        uuid.NewV4().String(),
@@ -950,6 +968,7 @@ func Test_GetK8sMinionDeploymentJob(t *testing.T) {
 	assert.Nil(t, err)
 	fmt.Printf("%#v\n", job)
 	assert.True(t, job.Name == entities.AgentJob_Deploy_Minion)
+	assert.True(t, job.Arguments["bootstrap_token"] == "abcdef.0123456789abcdef")
 }
 
 func Test_GetK8sMinionDeploymentJob2(t *testing.T) {
@@ -977,7 +996,9 @@ func Test_GetK8sMinionDeploymentJob2(t *testing.T) {
 	gc := gomock.NewController(t)
 	defer gc.Finish()
 	cc := mock_lm.NewMockClusterController(gc)
+	cc.EXPECT().GetUpgrade().Return(nil)
 	cc.EXPECT().GetSettings().Return(cs).AnyTimes()
+	cc.EXPECT().RequestKubeletBootstrapToken(gomock.Any()).Return("abcdef.0123456789abcdef").AnyTimes()
 
 	agent1 := entities.LightningMonkeyAgent{
 		Id:        uuid.NewV4().String(),
@@ -1433,6 +1454,8 @@ func Test_CheckDeploymentPhase(t *testing.T) {
 	defer gc.Finish()
 	cc := mock_lm.NewMockClusterController(gc)
 	cc.EXPECT().GetSettings().Return(cs)
+	cc.EXPECT().GetUpgrade().Return(nil)
+	cc.EXPECT().GetSnapshots().Return(nil)
 
 	agent1 := entities.LightningMonkeyAgent{
 		Id:        uuid.NewV4().String(),
@@ -1500,3 +1523,92 @@ func Test_CheckDeploymentPhase(t *testing.T) {
 	assert.True(t, compiler.StringArrayContainsValue(strings.Split(job.Arguments["addresses"], ","), agent2.State.LastReportIP))
 	assert.True(t, compiler.StringArrayContainsValue(strings.Split(job.Arguments["addresses"], ","), agent3.State.LastReportIP))
 }
+
+func Test_WaitingExpectedK8sMasterCount(t *testing.T) {
+	js := cache.ClusterJobSchedulerImple{}
+	js.InitializeStrategies()
+
+	cs := entities.LightningMonkeyClusterSettings{
+		Name:                "demo_cluster",
+		ExpectedETCDCount:   1,
+		ExpectedMasterCount: 3,
+		ServiceCIDR:         "10.254.0.0/16",
+		KubernetesVersion:   "1.12.5",
+		PodNetworkCIDR:      "172.1.0.0/16",
+		SecurityToken:       "",
+		ServiceDNSDomain:    ".cluster.local",
+		NetworkStack: &entities.NetworkStackSettings{
+			Type: entities.NetworkStack_KubeRouter,
+		},
+	}
+
+	gc := gomock.NewController(t)
+	defer gc.Finish()
+	cc := mock_lm.NewMockClusterController(gc)
+	cc.EXPECT().GetSettings().Return(cs).AnyTimes()
+	cc.EXPECT().GetUpgrade().Return(nil).AnyTimes()
+	cc.EXPECT().GetSnapshots().Return(nil).AnyTimes()
+
+	etcd := entities.LightningMonkeyAgent{
+		Id:          uuid.NewV4().String(),
+		Hostname:    "keepers-1",
+		HasETCDRole: true,
+		State: &entities.AgentState{
+			LastReportIP:       "127.0.0.1",
+			HasProvisionedETCD: true,
+			LastReportTime:     time.Now(),
+		},
+	}
+	master1 := entities.LightningMonkeyAgent{
+		Id:            uuid.NewV4().String(),
+		Hostname:      "keepers-2",
+		HasMasterRole: true,
+		State: &entities.AgentState{
+			LastReportIP:   "192.168.1.1",
+			LastReportTime: time.Now(),
+		},
+	}
+	master2 := entities.LightningMonkeyAgent{
+		Id:            uuid.NewV4().String(),
+		Hostname:      "keepers-3",
+		HasMasterRole: true,
+		State: &entities.AgentState{
+			LastReportIP:   "192.168.1.2",
+			LastReportTime: time.Now(),
+		},
+	}
+	masters := map[string]*entities.LightningMonkeyAgent{master1.Id: &master1, master2.Id: &master2}
+	ac := cache.AgentCache{}
+	ac.InitializeWithValues(map[string]*entities.LightningMonkeyAgent{etcd.Id: &etcd}, masters, map[string]*entities.LightningMonkeyAgent{}, map[string]*entities.LightningMonkeyAgent{})
+	job, err := js.GetNextJob(cc, master1, &ac, func(i int) {})
+	assert.Nil(t, err)
+	assert.True(t, job.Name == entities.AgentJob_NOP)
+	assert.True(t, strings.Contains(job.Reason, "Kubernetes master"))
+
+	master3 := entities.LightningMonkeyAgent{
+		Id:            uuid.NewV4().String(),
+		Hostname:      "keepers-4",
+		HasMasterRole: true,
+		State: &entities.AgentState{
+			LastReportIP:                   "192.168.1.3",
+			HasProvisionedMasterComponents: true,
+			LastReportTime:                 time.Now(),
+		},
+	}
+	masters[master3.Id] = &master3
+	ac.InitializeWithValues(map[string]*entities.LightningMonkeyAgent{etcd.Id: &etcd}, masters, map[string]*entities.LightningMonkeyAgent{}, map[string]*entities.LightningMonkeyAgent{})
+	job, err = js.GetNextJob(cc, master1, &ac, func(i int) {})
+	assert.Nil(t, err)
+	assert.True(t, job.Name == entities.AgentJob_Deploy_Master)
+	//HA & minion jobs must wait until all of expected masters have been provisioned.
+	job, err = js.GetNextJob(cc, master3, &ac, func(i int) {})
+	assert.Nil(t, err)
+	assert.True(t, job.Name == entities.AgentJob_NOP)
+	assert.True(t, strings.Contains(job.Reason, "(1/3)"))
+
+	q := ac.GetMasterQuorum(cs.GetExpectedMasterCount())
+	assert.True(t, q.Registered == 3)
+	assert.True(t, q.Provisioned == 1)
+	assert.False(t, q.HasQuorum)
+	assert.False(t, q.IsCompleted)
+}
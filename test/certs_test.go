@@ -0,0 +1,153 @@
+package test
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	mock_lm "github.com/g0194776/lightningmonkey/mocks"
+	"github.com/g0194776/lightningmonkey/pkg/certs"
+	"github.com/g0194776/lightningmonkey/pkg/common"
+	"github.com/g0194776/lightningmonkey/pkg/entities"
+	"github.com/g0194776/lightningmonkey/pkg/managers"
+	"github.com/g0194776/lightningmonkey/pkg/utils"
+	"github.com/golang/mock/gomock"
+	uuid "github.com/satori/go.uuid"
+	assert "github.com/stretchr/testify/require"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func generateTestCertificate(t *testing.T, isCA bool, notBefore, notAfter time.Time) (string, string) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.Nil(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test-ca"},
+		NotBefore:             notBefore,
+		NotAfter:              notAfter,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  isCA,
+	}
+	data, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.Nil(t, err)
+	cert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: data})
+	keyData := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	return string(cert), string(keyData)
+}
+
+func Test_ValidateCAKeyPair_Succeed(t *testing.T) {
+	cert, key := generateTestCertificate(t, true, time.Now().Add(-time.Hour), time.Now().Add(time.Hour))
+	assert.Nil(t, certs.ValidateCAKeyPair(cert, key, time.Now()))
+}
+
+func Test_ValidateCAKeyPair_KeyMismatch(t *testing.T) {
+	cert, _ := generateTestCertificate(t, true, time.Now().Add(-time.Hour), time.Now().Add(time.Hour))
+	_, otherKey := generateTestCertificate(t, true, time.Now().Add(-time.Hour), time.Now().Add(time.Hour))
+	assert.NotNil(t, certs.ValidateCAKeyPair(cert, otherKey, time.Now()))
+}
+
+func Test_ValidateCAKeyPair_NotCA(t *testing.T) {
+	cert, key := generateTestCertificate(t, false, time.Now().Add(-time.Hour), time.Now().Add(time.Hour))
+	assert.NotNil(t, certs.ValidateCAKeyPair(cert, key, time.Now()))
+}
+
+func Test_ValidateCAKeyPair_Expired(t *testing.T) {
+	cert, key := generateTestCertificate(t, true, time.Now().Add(-2*time.Hour), time.Now().Add(-time.Hour))
+	assert.NotNil(t, certs.ValidateCAKeyPair(cert, key, time.Now()))
+}
+
+func Test_GenerateCASigningRequest(t *testing.T) {
+	key, csr, err := certs.GenerateCASigningRequest("kubernetes", &entities.CASubject{CommonNamePrefix: "corp-", Organization: []string{"corp"}})
+	assert.Nil(t, err)
+	block, _ := pem.Decode([]byte(csr))
+	assert.NotNil(t, block)
+	req, err := x509.ParseCertificateRequest(block.Bytes)
+	assert.Nil(t, err)
+	assert.Nil(t, req.CheckSignature())
+	assert.Equal(t, "corp-kubernetes", req.Subject.CommonName)
+	_, err = certs.ParsePrivateKey(key)
+	assert.Nil(t, err)
+}
+
+func Test_SecurityCheck_SuppliedCA_MissingKeyPair(t *testing.T) {
+	cert, key := generateTestCertificate(t, true, time.Now().Add(-time.Hour), time.Now().Add(time.Hour))
+	settings := entities.LightningMonkeyClusterSettings{
+		PortRangeSettings: &entities.NodePortRangeSettings{Begin: 30000, End: 32767},
+		CertificateAuthority: &entities.CertificateAuthoritySettings{
+			Mode:       entities.CAMode_Supplied,
+			Cluster:    &entities.CAKeyPair{Certificate: cert, PrivateKey: key},
+			FrontProxy: &entities.CAKeyPair{Certificate: cert, PrivateKey: key},
+		},
+	}
+	assert.NotNil(t, managers.SecurityCheck(settings))
+	settings.CertificateAuthority.ETCD = &entities.CAKeyPair{Certificate: cert, PrivateKey: key}
+	assert.Nil(t, managers.SecurityCheck(settings))
+}
+
+func Test_GetAgentCertificate(t *testing.T) {
+	gc := gomock.NewController(t)
+	defer gc.Finish()
+
+	clusterId := uuid.NewV4().String()
+	etcd := entities.LightningMonkeyAgent{Id: "etcd", AccessToken: "etcd-token", HasETCDRole: true}
+	minion := entities.LightningMonkeyAgent{Id: "minion", AccessToken: "minion-token", HasMinionRole: true}
+	agents := map[string]*entities.LightningMonkeyAgent{etcd.Id: &etcd, minion.Id: &minion}
+	cc := mock_lm.NewMockClusterController(gc)
+	cc.EXPECT().GetCachedAgent(gomock.Any()).DoAndReturn(func(agentId string) (*entities.LightningMonkeyAgent, error) { return agents[agentId], nil }).AnyTimes()
+	cm := mock_lm.NewMockClusterManagerInterface(gc)
+	cm.EXPECT().GetClusterById(clusterId).Return(cc, nil).AnyTimes()
+	cm.EXPECT().GetClusterCertificates(clusterId).Return(entities.LightningMonkeyCertificateCollection{{Name: "ca.crt", Value: "CA"}, {Name: "ca.key", Value: "KEY"}}, nil).AnyTimes()
+	common.ClusterManager = cm
+
+	content, err := managers.GetAgentCertificate(clusterId, etcd.Id, "etcd-token", "ca.key")
+	assert.Nil(t, err)
+	assert.Equal(t, "KEY", content)
+	content, err = managers.GetAgentCertificate(clusterId, minion.Id, "minion-token", "ca.crt")
+	assert.Nil(t, err)
+	assert.Equal(t, "CA", content)
+	//the private keys are never given to the minion-only agent.
+	_, err = managers.GetAgentCertificate(clusterId, minion.Id, "minion-token", "CA.KEY")
+	assert.Equal(t, managers.ErrCertificateForbidden, err)
+	//unknown agent or illegal access token.
+	_, err = managers.GetAgentCertificate(clusterId, etcd.Id, "minion-token", "ca.crt")
+	assert.Equal(t, managers.ErrAgentUnauthorized, err)
+	_, err = managers.GetAgentCertificate(clusterId, "unknown", "", "ca.crt")
+	assert.Equal(t, managers.ErrAgentUnauthorized, err)
+}
+
+func Test_GetManifestDirectory(t *testing.T) {
+	//the agent is started with "--cert-dir=/etc/kubernetes/pki" and kubelet reads the static pods from "/etc/kubernetes/manifests".
+	assert.Equal(t, "/etc/kubernetes/manifests", certs.GetManifestDirectory("/etc/kubernetes/pki"))
+}
+
+func Test_ComponentConfigurationChecksums(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kubernetes")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	//same layout as "/etc/kubernetes/pki" and "/etc/kubernetes/manifests".
+	certPath := filepath.Join(dir, "pki")
+	assert.Nil(t, os.MkdirAll(certPath, 0755))
+	assert.Nil(t, os.MkdirAll(filepath.Join(dir, "manifests"), 0755))
+	for _, name := range []string{"etcd.yaml", "kube-apiserver.yaml", "kube-controller-manager.yaml", "kube-scheduler.yaml"} {
+		assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "manifests", name), []byte("kind: Pod\nmetadata:\n  name: "+name), 0600))
+	}
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(certPath, "etcd_config.yml"), []byte("kind: ClusterConfiguration"), 0644))
+	etcdChecksums := utils.ChecksumFiles(certs.GetETCDConfigurationPaths(certPath))
+	masterChecksums := utils.ChecksumFiles(certs.GetMasterManifestPaths(certPath))
+	assert.Equal(t, 2, len(etcdChecksums))
+	assert.Equal(t, 3, len(masterChecksums))
+	assert.Equal(t, 0, len(utils.ChangedFiles(etcdChecksums)))
+	assert.Equal(t, 0, len(utils.ChangedFiles(masterChecksums)))
+	//edits the manifest which is read by kubelet.
+	apiServerManifest := filepath.Join(dir, "manifests", "kube-apiserver.yaml")
+	assert.Nil(t, ioutil.WriteFile(apiServerManifest, []byte("kind: Pod\nmetadata:\n  name: kube-apiserver\n  labels: {}"), 0600))
+	assert.Equal(t, []string{apiServerManifest}, utils.ChangedFiles(masterChecksums))
+	assert.Equal(t, 0, len(utils.ChangedFiles(etcdChecksums)))
+}
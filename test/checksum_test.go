@@ -0,0 +1,33 @@
+package test
+
+import (
+	"github.com/g0194776/lightningmonkey/pkg/utils"
+	assert "github.com/stretchr/testify/require"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_ChangedFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "checksum")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	haproxy := filepath.Join(dir, "haproxy.cfg")
+	keepalived := filepath.Join(dir, "keepalived.conf")
+	assert.Nil(t, ioutil.WriteFile(haproxy, []byte("backend kubernetes-apiserver"), 0644))
+	assert.Nil(t, ioutil.WriteFile(keepalived, []byte("vrrp_instance VI_1"), 0644))
+	checksum, err := utils.FileChecksum(haproxy)
+	assert.Nil(t, err)
+	assert.Equal(t, 64, len(checksum))
+	//the missing files are never recorded.
+	checksums := utils.ChecksumFiles([]string{haproxy, keepalived, filepath.Join(dir, "missing.conf")})
+	assert.Equal(t, 2, len(checksums))
+	assert.Equal(t, checksum, checksums[haproxy])
+	assert.Equal(t, 0, len(utils.ChangedFiles(checksums)))
+	//changed and lost files.
+	assert.Nil(t, ioutil.WriteFile(haproxy, []byte("backend kubernetes-apiserver\n"), 0644))
+	assert.Nil(t, os.Remove(keepalived))
+	assert.Equal(t, []string{haproxy, keepalived}, utils.ChangedFiles(checksums))
+	assert.Equal(t, 0, len(utils.ChangedFiles(nil)))
+}
@@ -1,17 +1,24 @@
 package test
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	etcdserverpb2 "github.com/coreos/etcd/etcdserver/etcdserverpb"
+	"github.com/coreos/etcd/mvcc/mvccpb"
 	mock_lm "github.com/g0194776/lightningmonkey/mocks"
 	"github.com/g0194776/lightningmonkey/pkg/cache"
 	"github.com/g0194776/lightningmonkey/pkg/entities"
+	"github.com/g0194776/lightningmonkey/pkg/storage"
 	"github.com/golang/mock/gomock"
 	uuid "github.com/satori/go.uuid"
 	assert "github.com/stretchr/testify/require"
 	"go.etcd.io/etcd/clientv3"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 	"unsafe"
 )
 
@@ -24,6 +31,7 @@ func Test_AgentOnline(t *testing.T) {
 	sd.EXPECT().Get(gomock.Any(), fmt.Sprintf("/lightning-monkey/clusters/%s/", clusterId), gomock.Any()).Return(&clientv3.GetResponse{
 		Header: &etcdserverpb2.ResponseHeader{Revision: 0},
 	}, nil)
+	expectFollower(sd)
 
 	cc := cache.ClusterControllerImple{}
 	cc.UpdateClusterSettings(entities.LightningMonkeyClusterSettings{
@@ -59,6 +67,7 @@ func Test_AgentOnlineWithoutStateObject(t *testing.T) {
 	sd.EXPECT().Get(gomock.Any(), fmt.Sprintf("/lightning-monkey/clusters/%s/", clusterId), gomock.Any()).Return(&clientv3.GetResponse{
 		Header: &etcdserverpb2.ResponseHeader{Revision: 0},
 	}, nil)
+	expectFollower(sd)
 
 	cc := cache.ClusterControllerImple{}
 	cc.UpdateClusterSettings(entities.LightningMonkeyClusterSettings{
@@ -91,6 +100,7 @@ func Test_AgentOffline(t *testing.T) {
 	sd.EXPECT().Get(gomock.Any(), fmt.Sprintf("/lightning-monkey/clusters/%s/", clusterId), gomock.Any()).Return(&clientv3.GetResponse{
 		Header: &etcdserverpb2.ResponseHeader{Revision: 0},
 	}, nil)
+	expectFollower(sd)
 
 	cc := cache.ClusterControllerImple{}
 	cc.UpdateClusterSettings(entities.LightningMonkeyClusterSettings{
@@ -131,6 +141,7 @@ func Test_DisposedCall(t *testing.T) {
 	sd.EXPECT().Get(gomock.Any(), fmt.Sprintf("/lightning-monkey/clusters/%s/", clusterId), gomock.Any()).Return(&clientv3.GetResponse{
 		Header: &etcdserverpb2.ResponseHeader{Revision: 0},
 	}, nil)
+	expectFollower(sd)
 
 	cc := cache.ClusterControllerImple{}
 	cc.UpdateClusterSettings(entities.LightningMonkeyClusterSettings{
@@ -167,3 +178,257 @@ func Test_DisposedCall(t *testing.T) {
 	assert.NotNil(t, err)
 	assert.True(t, isDisposed == 1)
 }
+
+func Test_EnqueuedAgentJob(t *testing.T) {
+	gc := gomock.NewController(t)
+	defer gc.Finish()
+	clusterId := uuid.NewV4().String()
+	sd := mock_lm.NewMockLightningMonkeyStorageDriver(gc)
+	//full-sync logic
+	sd.EXPECT().Get(gomock.Any(), fmt.Sprintf("/lightning-monkey/clusters/%s/", clusterId), gomock.Any()).Return(&clientv3.GetResponse{
+		Header: &etcdserverpb2.ResponseHeader{Revision: 0},
+	}, nil)
+	expectFollower(sd)
+
+	cc := cache.ClusterControllerImple{}
+	cc.UpdateClusterSettings(entities.LightningMonkeyClusterSettings{
+		Id: clusterId,
+	})
+	cc.Initialize(sd)
+	agent1 := entities.LightningMonkeyAgent{
+		Id:            uuid.NewV4().String(),
+		ClusterId:     clusterId,
+		Hostname:      "keepers-1",
+		HasMinionRole: true,
+		State: &entities.AgentState{
+			HasProvisionedMinion: true,
+		},
+	}
+	cc.EnqueueAgentJob(agent1.Id, entities.AgentJob{Name: entities.AgentJob_Teardown})
+	assert.True(t, cc.HasPendingAgentJob(agent1.Id))
+	job, err := cc.GetNextJob(agent1, func(i int) {})
+	assert.Nil(t, err)
+	assert.True(t, job.Name == entities.AgentJob_Teardown)
+	assert.False(t, cc.HasPendingAgentJob(agent1.Id))
+	//never schedules anything else before the job is removed.
+	job, err = cc.GetNextJob(agent1, func(i int) {})
+	assert.Nil(t, err)
+	assert.True(t, job.Name == entities.AgentJob_NOP)
+	cc.RemoveAgentJob(agent1.Id)
+	assert.False(t, cc.HasPendingAgentJob(agent1.Id))
+}
+
+func Test_DeletedAgentOffline(t *testing.T) {
+	gc := gomock.NewController(t)
+	defer gc.Finish()
+	clusterId := uuid.NewV4().String()
+	sd := mock_lm.NewMockLightningMonkeyStorageDriver(gc)
+	//full-sync logic
+	sd.EXPECT().Get(gomock.Any(), fmt.Sprintf("/lightning-monkey/clusters/%s/", clusterId), gomock.Any()).Return(&clientv3.GetResponse{
+		Header: &etcdserverpb2.ResponseHeader{Revision: 0},
+	}, nil)
+	expectFollower(sd)
+
+	cc := cache.ClusterControllerImple{}
+	cc.UpdateClusterSettings(entities.LightningMonkeyClusterSettings{
+		Id: clusterId,
+	})
+	cc.Initialize(sd)
+	agent1 := entities.LightningMonkeyAgent{
+		Id:          uuid.NewV4().String(),
+		ClusterId:   clusterId,
+		Hostname:    "keepers-1",
+		HasETCDRole: true,
+		State: &entities.AgentState{
+			HasProvisionedETCD: true,
+		},
+	}
+	assert.Nil(t, cc.OnAgentChanged(agent1, false))
+	agent1.IsDelete = true
+	assert.Nil(t, cc.OnAgentChanged(agent1, false))
+
+	v := reflect.Indirect(reflect.ValueOf(cc)).FieldByName("cache")
+	ac := ((*cache.AgentCache)(unsafe.Pointer(v.Pointer())))
+	assert.True(t, ac.GetETCDCount() == 0)
+}
+
+func Test_ReconcileLeaderElection(t *testing.T) {
+	gc := gomock.NewController(t)
+	defer gc.Finish()
+	clusterId := uuid.NewV4().String()
+	sd := mock_lm.NewMockLightningMonkeyStorageDriver(gc)
+	//full-sync logic
+	sd.EXPECT().Get(gomock.Any(), fmt.Sprintf("/lightning-monkey/clusters/%s/", clusterId), gomock.Any()).Return(&clientv3.GetResponse{
+		Header: &etcdserverpb2.ResponseHeader{Revision: 0},
+	}, nil)
+	lease := &fakeLease{revoked: make(chan clientv3.LeaseID, 1)}
+	txn := &fakeTxn{succeeded: true, committed: make(chan struct{}, 1)}
+	sd.EXPECT().GetRequestTimeoutDuration().Return(time.Second).AnyTimes()
+	sd.EXPECT().NewLease().Return(lease).AnyTimes()
+	sd.EXPECT().Txn(gomock.Any()).Return(txn).AnyTimes()
+
+	cc := cache.ClusterControllerImple{}
+	cc.UpdateClusterSettings(entities.LightningMonkeyClusterSettings{
+		Id: clusterId,
+	})
+	cc.Initialize(sd)
+	select {
+	case <-txn.committed:
+	case <-time.After(time.Second * 5):
+		t.Fatal("Leader key has never been taken.")
+	}
+	//the leader holds its lease until the cluster controller has been disposed.
+	select {
+	case <-lease.revoked:
+		t.Fatal("Leader lease has been revoked before disposing.")
+	case <-time.After(time.Millisecond * 200):
+	}
+	cc.Dispose()
+	select {
+	case <-lease.revoked:
+	case <-time.After(time.Second * 5):
+		t.Fatal("Leader lease has not been revoked after disposing.")
+	}
+}
+
+//expectFollower makes the reconcile worker of cluster controller never be elected as the leader.
+func expectFollower(sd *mock_lm.MockLightningMonkeyStorageDriver) {
+	sd.EXPECT().GetRequestTimeoutDuration().Return(time.Second).AnyTimes()
+	sd.EXPECT().NewLease().Return(&fakeLease{isUnreachable: true}).AnyTimes()
+}
+
+type fakeLease struct {
+	clientv3.Lease
+	isUnreachable bool
+	revoked       chan clientv3.LeaseID
+}
+
+func (l *fakeLease) Grant(ctx context.Context, ttl int64) (*clientv3.LeaseGrantResponse, error) {
+	if l.isUnreachable {
+		return nil, errors.New("ETCD is unreachable")
+	}
+	return &clientv3.LeaseGrantResponse{ID: clientv3.LeaseID(1), TTL: ttl}, nil
+}
+
+func (l *fakeLease) Revoke(ctx context.Context, id clientv3.LeaseID) (*clientv3.LeaseRevokeResponse, error) {
+	l.revoked <- id
+	return &clientv3.LeaseRevokeResponse{}, nil
+}
+
+func (l *fakeLease) KeepAlive(ctx context.Context, id clientv3.LeaseID) (<-chan *clientv3.LeaseKeepAliveResponse, error) {
+	ch := make(chan *clientv3.LeaseKeepAliveResponse)
+	go func() {
+		<-ctx.Done()
+		close(ch)
+	}()
+	return ch, nil
+}
+
+func (l *fakeLease) Close() error {
+	return nil
+}
+
+type fakeTxn struct {
+	succeeded bool
+	committed chan struct{}
+}
+
+func (txn *fakeTxn) If(cs ...clientv3.Cmp) clientv3.Txn {
+	return txn
+}
+
+func (txn *fakeTxn) Then(ops ...clientv3.Op) clientv3.Txn {
+	return txn
+}
+
+func (txn *fakeTxn) Else(ops ...clientv3.Op) clientv3.Txn {
+	return txn
+}
+
+func (txn *fakeTxn) Commit() (*clientv3.TxnResponse, error) {
+	txn.committed <- struct{}{}
+	return &clientv3.TxnResponse{Succeeded: txn.succeeded}, nil
+}
+
+func Test_CompareAndSwap(t *testing.T) {
+	gc := gomock.NewController(t)
+	defer gc.Finish()
+	sd := mock_lm.NewMockLightningMonkeyStorageDriver(gc)
+	key := "/lightning-monkey/clusters/abc/upgrade"
+	sd.EXPECT().GetRequestTimeoutDuration().Return(time.Second).AnyTimes()
+	gomock.InOrder(
+		sd.EXPECT().Get(gomock.Any(), key).Return(&clientv3.GetResponse{Kvs: []*mvccpb.KeyValue{{Value: []byte("1"), ModRevision: 10}}}, nil),
+		//changed by others before writing.
+		sd.EXPECT().Txn(gomock.Any()).Return(&fakeTxn{succeeded: false, committed: make(chan struct{}, 1)}),
+		sd.EXPECT().Get(gomock.Any(), key).Return(&clientv3.GetResponse{Kvs: []*mvccpb.KeyValue{{Value: []byte("2"), ModRevision: 11}}}, nil),
+		sd.EXPECT().Txn(gomock.Any()).Return(&fakeTxn{succeeded: true, committed: make(chan struct{}, 1)}),
+	)
+	values := []string{}
+	err := storage.CompareAndSwap(sd, key, func(value []byte) ([]byte, error) {
+		values = append(values, string(value))
+		return []byte("3"), nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"1", "2"}, values)
+	//nothing is written if the value has not been changed.
+	sd.EXPECT().Get(gomock.Any(), key).Return(&clientv3.GetResponse{}, nil)
+	err = storage.CompareAndSwap(sd, key, func(value []byte) ([]byte, error) {
+		assert.Nil(t, value)
+		return nil, nil
+	})
+	assert.Nil(t, err)
+}
+
+func Test_DecommissionJobIsServedByEveryAPIServer(t *testing.T) {
+	gc := gomock.NewController(t)
+	defer gc.Finish()
+	clusterId := uuid.NewV4().String()
+	sd := mock_lm.NewMockLightningMonkeyStorageDriver(gc)
+	//full-sync logic
+	sd.EXPECT().Get(gomock.Any(), fmt.Sprintf("/lightning-monkey/clusters/%s/", clusterId), gomock.Any()).Return(&clientv3.GetResponse{
+		Header: &etcdserverpb2.ResponseHeader{Revision: 0},
+	}, nil)
+	expectFollower(sd)
+
+	cc := cache.ClusterControllerImple{}
+	cc.UpdateClusterSettings(entities.LightningMonkeyClusterSettings{
+		Id: clusterId,
+	})
+	cc.Initialize(sd)
+	agent1 := entities.LightningMonkeyAgent{
+		Id:            uuid.NewV4().String(),
+		ClusterId:     clusterId,
+		Hostname:      "workers-1",
+		HasMinionRole: true,
+		State:         &entities.AgentState{LastReportIP: "192.168.1.2", HasProvisionedMinion: true, LastReportTime: time.Now()},
+	}
+	assert.Nil(t, cc.OnAgentChanged(agent1, false))
+	d := entities.AgentDecommission{AgentId: agent1.Id, Hostname: agent1.Hostname, NodeName: "192.168.1.2", Status: entities.DecommissionStatus_Draining}
+	data, err := json.Marshal(&d)
+	assert.Nil(t, err)
+	//the progress is saved by the reconcile leader and synchronized from ETCD.
+	assert.Nil(t, cc.OnDecommissionChanged(agent1.Id, data, false))
+	job, err := cc.GetNextJob(agent1, func(i int) {})
+	assert.Nil(t, err)
+	assert.Equal(t, entities.AgentJob_NOP, job.Name)
+	assert.True(t, strings.Contains(job.Reason, "drained"))
+
+	d.Status = entities.DecommissionStatus_TearingDown
+	data, err = json.Marshal(&d)
+	assert.Nil(t, err)
+	assert.Nil(t, cc.OnDecommissionChanged(agent1.Id, data, false))
+	job, err = cc.GetNextJob(agent1, func(i int) {})
+	assert.Nil(t, err)
+	assert.Equal(t, entities.AgentJob_Teardown, job.Name)
+	//components have been torn down.
+	agent1.State.HasProvisionedMinion = false
+	job, err = cc.GetNextJob(agent1, func(i int) {})
+	assert.Nil(t, err)
+	assert.Equal(t, entities.AgentJob_NOP, job.Name)
+
+	d.Status = entities.DecommissionStatus_Failed
+	data, err = json.Marshal(&d)
+	assert.Nil(t, err)
+	assert.Nil(t, cc.OnDecommissionChanged(agent1.Id, data, false))
+	assert.Equal(t, entities.DecommissionStatus_Failed, cc.GetDecommission(agent1.Id).Status)
+}
@@ -0,0 +1,78 @@
+package test
+
+import (
+	"github.com/g0194776/lightningmonkey/pkg/containers"
+	"github.com/g0194776/lightningmonkey/pkg/utils"
+	assert "github.com/stretchr/testify/require"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_NormalizeImageReference(t *testing.T) {
+	assert.Equal(t, "docker.io/library/busybox:latest", containers.NormalizeImageReference("busybox"))
+	assert.Equal(t, "docker.io/g0194776/lightning-monkey-ha:v1", containers.NormalizeImageReference("g0194776/lightning-monkey-ha:v1"))
+	assert.Equal(t, "k8s.gcr.io/etcd:3.3.10", containers.NormalizeImageReference("k8s.gcr.io/etcd:3.3.10"))
+	assert.Equal(t, "localhost:5000/pause:latest", containers.NormalizeImageReference("localhost:5000/pause"))
+	assert.True(t, containers.IsSameImage("docker.io/library/busybox:latest", "busybox"))
+	assert.False(t, containers.IsSameImage("busybox:1.31", "busybox"))
+}
+
+func Test_IsKubernetesContainer(t *testing.T) {
+	c := containers.Container{Name: "k8s_etcd_etcd-192.168.0.11_kube-system_0b9d3bfb1c3f_0"}
+	assert.True(t, containers.IsKubernetesContainer(c, "etcd", "kube-system"))
+	assert.False(t, containers.IsKubernetesContainer(c, "etcd", "default"))
+	assert.False(t, containers.IsKubernetesContainer(containers.Container{Name: "k8s_POD_etcd-192.168.0.11_kube-system_0b9d3bfb1c3f_0"}, "etcd", "kube-system"))
+	assert.False(t, containers.IsKubernetesContainer(containers.Container{Name: "kubelet"}, "kubelet", "kube-system"))
+}
+
+func Test_FilterLogLines(t *testing.T) {
+	logs := strings.Join([]string{
+		"2020-03-01T10:00:00.000000000Z stdout F starting etcd",
+		"2020-03-01T10:05:00.000000000Z stderr F lost leader",
+		"raw line without timestamp",
+		"2020-03-01T10:10:00.000000000Z stdout F elected leader",
+	}, "\n")
+	data, err := containers.FilterLogLines(strings.NewReader(logs), containers.LogOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, logs+"\n", string(data))
+
+	data, err = containers.FilterLogLines(strings.NewReader(logs), containers.LogOptions{Tail: 2})
+	assert.Nil(t, err)
+	assert.Equal(t, "raw line without timestamp\n2020-03-01T10:10:00.000000000Z stdout F elected leader\n", string(data))
+
+	since, _ := time.Parse(time.RFC3339, "2020-03-01T10:03:00Z")
+	data, err = containers.FilterLogLines(strings.NewReader(logs), containers.LogOptions{Since: since})
+	assert.Nil(t, err)
+	assert.Equal(t, 3, strings.Count(string(data), "\n"))
+	assert.False(t, strings.Contains(string(data), "starting etcd"))
+
+	//streamed logs are filtered in the same way.
+	for _, opts := range []containers.LogOptions{{}, {Tail: 2}, {Since: since}} {
+		expected, err := containers.FilterLogLines(strings.NewReader(logs), opts)
+		assert.Nil(t, err)
+		rc := containers.StreamLogLines(ioutil.NopCloser(strings.NewReader(logs)), opts)
+		data, err = ioutil.ReadAll(rc)
+		assert.Nil(t, err)
+		assert.Nil(t, rc.Close())
+		assert.Equal(t, string(expected), string(data))
+	}
+}
+
+func Test_ParseLogSince(t *testing.T) {
+	now := time.Now()
+	since, err := utils.ParseLogSince("", now)
+	assert.Nil(t, err)
+	assert.True(t, since.IsZero())
+	since, err = utils.ParseLogSince("10m", now)
+	assert.Nil(t, err)
+	assert.Equal(t, now.Add(-10*time.Minute), since)
+	since, err = utils.ParseLogSince("2020-03-01T10:03:00Z", now)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1583056980), since.Unix())
+	_, err = utils.ParseLogSince("yesterday", now)
+	assert.NotNil(t, err)
+	_, err = utils.ParseLogSince("-5m", now)
+	assert.NotNil(t, err)
+}
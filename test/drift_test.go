@@ -0,0 +1,46 @@
+package test
+
+import (
+	"github.com/g0194776/lightningmonkey/pkg/entities"
+	"github.com/g0194776/lightningmonkey/pkg/utils"
+	assert "github.com/stretchr/testify/require"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_DetectFileDrifts(t *testing.T) {
+	dir, err := ioutil.TempDir("", "drift")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	haproxy := filepath.Join(dir, "haproxy.cfg")
+	keepalived := filepath.Join(dir, "keepalived.conf")
+	assert.Nil(t, ioutil.WriteFile(haproxy, []byte("backend kubernetes-apiserver"), 0644))
+	expected := map[string]string{
+		haproxy:    utils.ContentChecksum([]byte("backend kubernetes-apiserver")),
+		keepalived: utils.ContentChecksum([]byte("vrrp_instance VI_1")),
+	}
+	drifts := utils.DetectFileDrifts(entities.AgentJob_Deploy_HA, expected)
+	assert.Equal(t, []entities.ConfigDrift{{Component: entities.AgentJob_Deploy_HA, Target: keepalived, Reason: "missing"}}, drifts)
+	//edited manually.
+	assert.Nil(t, ioutil.WriteFile(haproxy, []byte("backend kubernetes-apiserver\n    server master-9 10.0.0.9:6443"), 0644))
+	assert.Nil(t, ioutil.WriteFile(keepalived, []byte("vrrp_instance VI_1"), 0644))
+	drifts = utils.DetectFileDrifts(entities.AgentJob_Deploy_HA, expected)
+	assert.Equal(t, []entities.ConfigDrift{{Component: entities.AgentJob_Deploy_HA, Target: haproxy, Reason: "modified"}}, drifts)
+}
+
+func Test_DriftRemediationPolicy(t *testing.T) {
+	//only reported.
+	assert.False(t, utils.ShouldRemediateDrift(nil, entities.ConfigDriftComponent_Kubelet))
+	assert.False(t, utils.ShouldRemediateDrift(&entities.DriftRemediationSettings{}, entities.ConfigDriftComponent_Kubelet))
+	all := &entities.DriftRemediationSettings{AutoRemediate: true}
+	assert.True(t, utils.ShouldRemediateDrift(all, entities.AgentJob_Deploy_Master))
+	assert.True(t, utils.ShouldRemediateDrift(all, entities.ConfigDriftComponent_Routes))
+	some := &entities.DriftRemediationSettings{AutoRemediate: true, Components: []string{entities.AgentJob_Deploy_HA, entities.ConfigDriftComponent_Kubelet}}
+	assert.True(t, utils.ShouldRemediateDrift(some, entities.ConfigDriftComponent_Kubelet))
+	assert.False(t, utils.ShouldRemediateDrift(some, entities.AgentJob_Deploy_ETCD))
+	assert.Nil(t, utils.ValidateDriftRemediation(nil))
+	assert.Nil(t, utils.ValidateDriftRemediation(some))
+	assert.NotNil(t, utils.ValidateDriftRemediation(&entities.DriftRemediationSettings{AutoRemediate: true, Components: []string{"kube-proxy"}}))
+}
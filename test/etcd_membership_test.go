@@ -0,0 +1,190 @@
+package test
+
+import (
+	"fmt"
+	mock_lm "github.com/g0194776/lightningmonkey/mocks"
+	"github.com/g0194776/lightningmonkey/pkg/cache"
+	"github.com/g0194776/lightningmonkey/pkg/entities"
+	"github.com/g0194776/lightningmonkey/pkg/utils"
+	"github.com/golang/mock/gomock"
+	assert "github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_ETCDQuorum(t *testing.T) {
+	assert.True(t, utils.GetETCDQuorum(1) == 1)
+	assert.True(t, utils.GetETCDQuorum(3) == 2)
+	assert.True(t, utils.GetETCDQuorum(4) == 3)
+	assert.True(t, utils.GetETCDQuorum(5) == 3)
+	assert.True(t, utils.CanAddETCDMember(3, 3))
+	assert.False(t, utils.CanAddETCDMember(3, 2))
+	assert.True(t, utils.CanAddETCDMember(1, 1))
+	assert.True(t, utils.CanRemoveETCDMember(3, 2, false))
+	assert.False(t, utils.CanRemoveETCDMember(3, 1, false))
+	assert.True(t, utils.CanRemoveETCDMember(3, 3, true))
+	assert.False(t, utils.CanRemoveETCDMember(1, 1, true))
+}
+
+func newETCDMemberAgent(id, ip string, isProvisioned bool, members []entities.ETCDMember) *entities.LightningMonkeyAgent {
+	return &entities.LightningMonkeyAgent{
+		Id:          id,
+		Hostname:    id,
+		HasETCDRole: true,
+		State: &entities.AgentState{
+			LastReportIP:       ip,
+			LastReportTime:     time.Now(),
+			HasProvisionedETCD: isProvisioned,
+			ETCDMembers:        members,
+		},
+	}
+}
+
+func newETCDMember(id, ip string, isStarted bool) entities.ETCDMember {
+	return entities.ETCDMember{Id: id, Name: utils.GetETCDMemberName(ip), PeerURL: fmt.Sprintf("https://%s:2380", ip), IsStarted: isStarted}
+}
+
+func Test_ETCDScaleOut(t *testing.T) {
+	js := cache.ClusterJobSchedulerImple{}
+	js.InitializeStrategies()
+
+	gc := gomock.NewController(t)
+	defer gc.Finish()
+	cc := mock_lm.NewMockClusterController(gc)
+	cc.EXPECT().GetUpgrade().Return(nil).AnyTimes()
+	cc.EXPECT().GetSnapshots().Return(nil).AnyTimes()
+	cc.EXPECT().GetSettings().Return(entities.LightningMonkeyClusterSettings{ExpectedETCDCount: 4}).AnyTimes()
+
+	members := []entities.ETCDMember{
+		newETCDMember("m1", "10.0.0.1", true),
+		newETCDMember("m2", "10.0.0.2", true),
+		newETCDMember("m3", "10.0.0.3", true),
+	}
+	etcd1 := newETCDMemberAgent("a-etcd", "10.0.0.1", true, members)
+	etcd2 := newETCDMemberAgent("b-etcd", "10.0.0.2", true, members)
+	etcd3 := newETCDMemberAgent("c-etcd", "10.0.0.3", true, members)
+	joiner := newETCDMemberAgent("d-etcd", "10.0.0.4", false, nil)
+	agents := map[string]*entities.LightningMonkeyAgent{etcd1.Id: etcd1, etcd2.Id: etcd2, etcd3.Id: etcd3, joiner.Id: joiner}
+	ac := cache.AgentCache{}
+	ac.InitializeWithValues(agents, map[string]*entities.LightningMonkeyAgent{}, map[string]*entities.LightningMonkeyAgent{}, map[string]*entities.LightningMonkeyAgent{})
+
+	//the new node must wait until it has been added by a healthy member.
+	job, err := js.GetNextJob(cc, *joiner, &ac, func(i int) {})
+	assert.Nil(t, err)
+	assert.True(t, job.Name == entities.AgentJob_NOP)
+	job, err = js.GetNextJob(cc, *etcd2, &ac, func(i int) {})
+	assert.Nil(t, err)
+	assert.True(t, job.Name != entities.AgentJob_ETCD_Member)
+	job, err = js.GetNextJob(cc, *etcd1, &ac, func(i int) {})
+	assert.Nil(t, err)
+	assert.True(t, job.Name == entities.AgentJob_ETCD_Member)
+	assert.True(t, job.Arguments["operation"] == entities.ETCDMemberOperation_Add)
+	assert.True(t, job.Arguments["peer-url"] == "https://10.0.0.4:2380")
+	assert.True(t, job.Arguments["name"] == utils.GetETCDMemberName("10.0.0.4"))
+
+	//member has been added, the new node joins the existing cluster.
+	members = append(members, entities.ETCDMember{Id: "m4", PeerURL: "https://10.0.0.4:2380"})
+	etcd1.State.ETCDMembers = members
+	etcd1.State.LastReportTime = time.Now()
+	job, err = js.GetNextJob(cc, *etcd1, &ac, func(i int) {})
+	assert.Nil(t, err)
+	assert.True(t, job.Name != entities.AgentJob_ETCD_Member)
+	job, err = js.GetNextJob(cc, *joiner, &ac, func(i int) {})
+	assert.Nil(t, err)
+	assert.True(t, job.Name == entities.AgentJob_Deploy_ETCD)
+	assert.True(t, job.Arguments["state"] == "existing")
+	assert.True(t, job.Arguments["addresses"] == "10.0.0.1,10.0.0.2,10.0.0.3,10.0.0.4")
+}
+
+func Test_ETCDMemberReplacement(t *testing.T) {
+	js := cache.ClusterJobSchedulerImple{}
+	js.InitializeStrategies()
+
+	gc := gomock.NewController(t)
+	defer gc.Finish()
+	cc := mock_lm.NewMockClusterController(gc)
+	cc.EXPECT().GetUpgrade().Return(nil).AnyTimes()
+	cc.EXPECT().GetSnapshots().Return(nil).AnyTimes()
+	cc.EXPECT().GetSettings().Return(entities.LightningMonkeyClusterSettings{ExpectedETCDCount: 3}).AnyTimes()
+
+	members := []entities.ETCDMember{
+		newETCDMember("m1", "10.0.0.1", true),
+		newETCDMember("m2", "10.0.0.2", true),
+		newETCDMember("m3", "10.0.0.3", true),
+	}
+	etcd1 := newETCDMemberAgent("a-etcd", "10.0.0.1", true, members)
+	etcd2 := newETCDMemberAgent("b-etcd", "10.0.0.2", true, members)
+	joiner := newETCDMemberAgent("d-etcd", "10.0.0.4", false, nil)
+	agents := map[string]*entities.LightningMonkeyAgent{etcd1.Id: etcd1, etcd2.Id: etcd2, joiner.Id: joiner}
+	ac := cache.AgentCache{}
+	ac.InitializeWithValues(agents, map[string]*entities.LightningMonkeyAgent{}, map[string]*entities.LightningMonkeyAgent{}, map[string]*entities.LightningMonkeyAgent{})
+
+	//the dead member must be removed before adding the new one.
+	job, err := js.GetNextJob(cc, *etcd1, &ac, func(i int) {})
+	assert.Nil(t, err)
+	assert.True(t, job.Name == entities.AgentJob_ETCD_Member)
+	assert.True(t, job.Arguments["operation"] == entities.ETCDMemberOperation_Remove)
+	assert.True(t, job.Arguments["member-id"] == "m3")
+
+	//removing another dead member breaks the quorum.
+	etcd2.State.LastReportTime = time.Now().Add(-time.Hour)
+	job, err = js.GetNextJob(cc, *etcd1, &ac, func(i int) {})
+	assert.Nil(t, err)
+	assert.True(t, job.Name != entities.AgentJob_ETCD_Member)
+	job, err = js.GetNextJob(cc, *joiner, &ac, func(i int) {})
+	assert.Nil(t, err)
+	assert.True(t, job.Name == entities.AgentJob_NOP)
+	assert.True(t, strings.Contains(job.Reason, "quorum"))
+}
+
+func Test_ETCDScaleIn(t *testing.T) {
+	js := cache.ClusterJobSchedulerImple{}
+	js.InitializeStrategies()
+
+	settings := entities.LightningMonkeyClusterSettings{ExpectedETCDCount: 5}
+	gc := gomock.NewController(t)
+	defer gc.Finish()
+	cc := mock_lm.NewMockClusterController(gc)
+	cc.EXPECT().GetUpgrade().Return(nil).AnyTimes()
+	cc.EXPECT().GetSnapshots().Return(nil).AnyTimes()
+	cc.EXPECT().GetSettings().DoAndReturn(func() entities.LightningMonkeyClusterSettings { return settings }).AnyTimes()
+
+	members := []entities.ETCDMember{
+		newETCDMember("m1", "10.0.0.1", true),
+		newETCDMember("m2", "10.0.0.2", true),
+		newETCDMember("m3", "10.0.0.3", true),
+		newETCDMember("m4", "10.0.0.4", true),
+		newETCDMember("m5", "10.0.0.5", true),
+	}
+	etcd1 := newETCDMemberAgent("a-etcd", "10.0.0.1", true, members)
+	etcd2 := newETCDMemberAgent("b-etcd", "10.0.0.2", true, members)
+	etcd3 := newETCDMemberAgent("c-etcd", "10.0.0.3", true, members)
+	etcd4 := newETCDMemberAgent("d-etcd", "10.0.0.4", true, members)
+	etcd5 := newETCDMemberAgent("e-etcd", "10.0.0.5", true, members)
+	etcd5.HasMasterRole = true
+	agents := map[string]*entities.LightningMonkeyAgent{etcd1.Id: etcd1, etcd2.Id: etcd2, etcd3.Id: etcd3, etcd4.Id: etcd4, etcd5.Id: etcd5}
+	ac := cache.AgentCache{}
+	ac.InitializeWithValues(agents, map[string]*entities.LightningMonkeyAgent{}, map[string]*entities.LightningMonkeyAgent{}, map[string]*entities.LightningMonkeyAgent{})
+
+	//healthy members are kept as long as the expected count is reached.
+	job, err := js.GetNextJob(cc, *etcd1, &ac, func(i int) {})
+	assert.Nil(t, err)
+	assert.True(t, job.Name != entities.AgentJob_ETCD_Member)
+
+	//the newest dedicated ETCD node is removed first.
+	settings.ExpectedETCDCount = 3
+	job, err = js.GetNextJob(cc, *etcd1, &ac, func(i int) {})
+	assert.Nil(t, err)
+	assert.True(t, job.Name == entities.AgentJob_ETCD_Member)
+	assert.True(t, job.Arguments["operation"] == entities.ETCDMemberOperation_Remove)
+	assert.True(t, job.Arguments["member-id"] == "m4")
+
+	//removing a healthy member is refused while another one is down.
+	etcd2.State.LastReportTime = time.Now().Add(-time.Hour)
+	etcd3.State.LastReportTime = time.Now().Add(-time.Hour)
+	job, err = js.GetNextJob(cc, *etcd1, &ac, func(i int) {})
+	assert.Nil(t, err)
+	assert.True(t, job.Name == entities.AgentJob_ETCD_Member)
+	assert.True(t, job.Arguments["member-id"] == "m2")
+}
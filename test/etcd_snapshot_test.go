@@ -0,0 +1,190 @@
+package test
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/coreos/etcd/mvcc/mvccpb"
+	mock_lm "github.com/g0194776/lightningmonkey/mocks"
+	"github.com/g0194776/lightningmonkey/pkg/cache"
+	"github.com/g0194776/lightningmonkey/pkg/common"
+	"github.com/g0194776/lightningmonkey/pkg/entities"
+	"github.com/g0194776/lightningmonkey/pkg/managers"
+	"github.com/golang/mock/gomock"
+	uuid "github.com/satori/go.uuid"
+	assert "github.com/stretchr/testify/require"
+	"go.etcd.io/etcd/clientv3"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_ETCDBackupSettingsCheck(t *testing.T) {
+	cs := entities.LightningMonkeyClusterSettings{BackupSettings: &entities.ETCDBackupSettings{Interval: "6h"}}
+	assert.Nil(t, managers.SetDefaultValue(&cs))
+	assert.True(t, cs.BackupSettings.Retention == 7)
+	assert.True(t, cs.BackupSettings.Destination == entities.ETCDSnapshotDestination_APIServer)
+	assert.Nil(t, managers.SecurityCheck(cs))
+	cs.BackupSettings.Interval = "1m"
+	assert.NotNil(t, managers.SecurityCheck(cs))
+	cs.BackupSettings.Interval = "6x"
+	assert.NotNil(t, managers.SecurityCheck(cs))
+	cs.BackupSettings.Interval = "6h"
+	cs.BackupSettings.Destination = entities.ETCDSnapshotDestination_Local
+	cs.BackupSettings.Directory = "/data/etcd/snapshots"
+	assert.NotNil(t, managers.SecurityCheck(cs))
+	cs.BackupSettings.Directory = "/data/etcd-snapshots"
+	assert.Nil(t, managers.SecurityCheck(cs))
+}
+
+func Test_ETCDSnapshotSchedule(t *testing.T) {
+	js := cache.ClusterJobSchedulerImple{}
+	js.InitializeStrategies()
+
+	members := []entities.ETCDMember{
+		newETCDMember("m1", "10.0.0.1", true),
+		newETCDMember("m2", "10.0.0.2", true),
+		newETCDMember("m3", "10.0.0.3", true),
+	}
+	etcd1 := newETCDMemberAgent("a-etcd", "10.0.0.1", true, members)
+	etcd2 := newETCDMemberAgent("b-etcd", "10.0.0.2", true, members)
+	etcd3 := newETCDMemberAgent("c-etcd", "10.0.0.3", true, members)
+	agents := map[string]*entities.LightningMonkeyAgent{etcd1.Id: etcd1, etcd2.Id: etcd2, etcd3.Id: etcd3}
+	ac := cache.AgentCache{}
+	ac.InitializeWithValues(agents, map[string]*entities.LightningMonkeyAgent{}, map[string]*entities.LightningMonkeyAgent{}, map[string]*entities.LightningMonkeyAgent{})
+
+	s := &entities.ClusterSnapshots{}
+	gc := gomock.NewController(t)
+	defer gc.Finish()
+	cc := mock_lm.NewMockClusterController(gc)
+	cc.EXPECT().GetUpgrade().Return(nil).AnyTimes()
+	cc.EXPECT().GetSnapshots().Return(s).AnyTimes()
+	cc.EXPECT().GetSettings().Return(entities.LightningMonkeyClusterSettings{
+		ExpectedETCDCount: 3,
+		BackupSettings:    &entities.ETCDBackupSettings{Interval: "6h", Retention: 3, Destination: entities.ETCDSnapshotDestination_APIServer},
+	}).AnyTimes()
+
+	//waiting for the upload credential.
+	job, err := js.GetNextJob(cc, *etcd1, &ac, func(i int) {})
+	assert.Nil(t, err)
+	assert.True(t, job.Name != entities.AgentJob_ETCD_Snapshot)
+	s.Upload = &entities.ETCDSnapshotUploadCredential{AgentId: etcd1.Id, Token: "upload-token"}
+	//only the elected ETCD agent takes snapshots.
+	job, err = js.GetNextJob(cc, *etcd2, &ac, func(i int) {})
+	assert.Nil(t, err)
+	assert.True(t, job.Name != entities.AgentJob_ETCD_Snapshot)
+	job, err = js.GetNextJob(cc, *etcd1, &ac, func(i int) {})
+	assert.Nil(t, err)
+	assert.True(t, job.Name == entities.AgentJob_ETCD_Snapshot)
+	assert.True(t, job.Arguments["destination"] == entities.ETCDSnapshotDestination_APIServer)
+	assert.True(t, job.Arguments["retention"] == "3")
+	assert.True(t, job.Arguments["token"] == "upload-token")
+	assert.True(t, job.Arguments["token"] != entities.HTTPDockerImageDownloadToken)
+
+	//the reported snapshot has not been recorded yet.
+	etcd1.State.Snapshot = &entities.ETCDSnapshot{Name: job.Arguments["name"], Status: entities.ETCDSnapshotStatus_Succeed, CreateTime: time.Now()}
+	job, err = js.GetNextJob(cc, *etcd1, &ac, func(i int) {})
+	assert.Nil(t, err)
+	assert.True(t, job.Name != entities.AgentJob_ETCD_Snapshot)
+	s.Snapshots = append(s.Snapshots, *etcd1.State.Snapshot)
+	job, err = js.GetNextJob(cc, *etcd1, &ac, func(i int) {})
+	assert.Nil(t, err)
+	assert.True(t, job.Name != entities.AgentJob_ETCD_Snapshot)
+
+	//manually triggered.
+	s.TriggerTime = time.Now().Add(time.Second)
+	job, err = js.GetNextJob(cc, *etcd1, &ac, func(i int) {})
+	assert.Nil(t, err)
+	assert.True(t, job.Name == entities.AgentJob_ETCD_Snapshot)
+
+	//never take snapshots during restoring.
+	s.Restore = &entities.ETCDRestore{Id: "r1", Status: entities.ETCDRestoreStatus_Running}
+	job, err = js.GetNextJob(cc, *etcd1, &ac, func(i int) {})
+	assert.Nil(t, err)
+	assert.True(t, job.Name != entities.AgentJob_ETCD_Snapshot)
+}
+
+func Test_ETCDRestoreAllMembers(t *testing.T) {
+	js := cache.ClusterJobSchedulerImple{}
+	js.InitializeStrategies()
+
+	etcd1 := newETCDMemberAgent("a-etcd", "10.0.0.1", true, nil)
+	etcd2 := newETCDMemberAgent("b-etcd", "10.0.0.2", true, nil)
+	agents := map[string]*entities.LightningMonkeyAgent{etcd1.Id: etcd1, etcd2.Id: etcd2}
+	ac := cache.AgentCache{}
+	ac.InitializeWithValues(agents, map[string]*entities.LightningMonkeyAgent{}, map[string]*entities.LightningMonkeyAgent{}, map[string]*entities.LightningMonkeyAgent{})
+
+	s := &entities.ClusterSnapshots{
+		Snapshots: []entities.ETCDSnapshot{{Name: "snapshot-20261019000000", Destination: entities.ETCDSnapshotDestination_APIServer, Location: "c/snapshot-20261019000000.db", APIServer: "http://10.0.0.100:8080", Status: entities.ETCDSnapshotStatus_Succeed}},
+		Restore: &entities.ETCDRestore{
+			Id:           "r1",
+			SnapshotName: "snapshot-20261019000000",
+			Token:        "download-token",
+			Status:       entities.ETCDRestoreStatus_Running,
+			Nodes: map[string]*entities.ETCDRestoreNode{
+				etcd1.Id: {Hostname: etcd1.Hostname, Address: "10.0.0.1"},
+				etcd2.Id: {Hostname: etcd2.Hostname, Address: "10.0.0.2"},
+			},
+		},
+	}
+	gc := gomock.NewController(t)
+	defer gc.Finish()
+	cc := mock_lm.NewMockClusterController(gc)
+	cc.EXPECT().GetUpgrade().Return(nil).AnyTimes()
+	cc.EXPECT().GetSnapshots().Return(s).AnyTimes()
+	cc.EXPECT().GetSettings().Return(entities.LightningMonkeyClusterSettings{ExpectedETCDCount: 2}).AnyTimes()
+
+	for _, agent := range []*entities.LightningMonkeyAgent{etcd1, etcd2} {
+		job, err := js.GetNextJob(cc, *agent, &ac, func(i int) {})
+		assert.Nil(t, err)
+		assert.True(t, job.Name == entities.AgentJob_ETCD_Restore)
+		assert.True(t, job.Arguments["restore-id"] == "r1")
+		assert.True(t, job.Arguments["addresses"] == "10.0.0.1,10.0.0.2")
+		assert.True(t, job.Arguments["location"] == s.Snapshots[0].Location)
+		assert.True(t, job.Arguments["api-server"] == "http://10.0.0.100:8080")
+		assert.True(t, job.Arguments["token"] == "download-token")
+	}
+	//credentials are never exposed by APIs.
+	exposed := s.WithoutCredentials()
+	assert.True(t, exposed.Restore.Token == "" && exposed.Upload == nil)
+	assert.True(t, s.Restore.Token == "download-token")
+	//restoring node must not receive any other job.
+	etcd1.State.Restore = &entities.AgentRestoreStatus{RestoreId: "r1", Status: entities.ETCDRestoreNodeStatus_Restoring}
+	job, err := js.GetNextJob(cc, *etcd1, &ac, func(i int) {})
+	assert.Nil(t, err)
+	assert.True(t, job.Name == entities.AgentJob_NOP)
+	//restored node goes back to normal deployment strategies.
+	s.Restore.Nodes[etcd1.Id].Status = entities.ETCDRestoreNodeStatus_Succeed
+	job, err = js.GetNextJob(cc, *etcd1, &ac, func(i int) {})
+	assert.Nil(t, err)
+	assert.True(t, job.Name != entities.AgentJob_ETCD_Restore)
+}
+
+func Test_TriggerETCDSnapshotKeepsStoredRestore(t *testing.T) {
+	gc := gomock.NewController(t)
+	defer gc.Finish()
+
+	clusterId := uuid.NewV4().String()
+	key := fmt.Sprintf("/lightning-monkey/clusters/%s/snapshots", clusterId)
+	cc := mock_lm.NewMockClusterController(gc)
+	cc.EXPECT().GetSettings().Return(entities.LightningMonkeyClusterSettings{BackupSettings: &entities.ETCDBackupSettings{}}).AnyTimes()
+	cm := mock_lm.NewMockClusterManagerInterface(gc)
+	cm.EXPECT().GetClusterById(clusterId).Return(cc, nil).AnyTimes()
+	common.ClusterManager = cm
+	sd := mock_lm.NewMockLightningMonkeyStorageDriver(gc)
+	sd.EXPECT().GetRequestTimeoutDuration().Return(time.Second).AnyTimes()
+	common.StorageDriver = sd
+
+	//the restore requested by another API server has not been synchronized into the cache yet.
+	data, err := json.Marshal(&entities.ClusterSnapshots{Restore: &entities.ETCDRestore{SnapshotName: "snapshot-20261019000000", Status: entities.ETCDRestoreStatus_Running}})
+	assert.Nil(t, err)
+	sd.EXPECT().Get(gomock.Any(), key).Return(&clientv3.GetResponse{Kvs: []*mvccpb.KeyValue{{Value: data, ModRevision: 10}}}, nil)
+	_, err = managers.TriggerETCDSnapshot(clusterId)
+	assert.NotNil(t, err)
+	assert.True(t, strings.Contains(err.Error(), "restoring"))
+
+	sd.EXPECT().Get(gomock.Any(), key).Return(&clientv3.GetResponse{}, nil)
+	sd.EXPECT().Txn(gomock.Any()).Return(&fakeTxn{succeeded: true, committed: make(chan struct{}, 1)})
+	s, err := managers.TriggerETCDSnapshot(clusterId)
+	assert.Nil(t, err)
+	assert.False(t, s.TriggerTime.IsZero())
+}
@@ -0,0 +1,63 @@
+package test
+
+import (
+	mock_lm "github.com/g0194776/lightningmonkey/mocks"
+	"github.com/g0194776/lightningmonkey/pkg/cache"
+	"github.com/g0194776/lightningmonkey/pkg/entities"
+	"github.com/golang/mock/gomock"
+	assert "github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func newHABackendsTestAgent(id, ip string, hasMasterRole bool, backends []string) *entities.LightningMonkeyAgent {
+	return &entities.LightningMonkeyAgent{
+		Id:            id,
+		Hostname:      id,
+		HasMasterRole: hasMasterRole,
+		HasHARole:     !hasMasterRole,
+		State: &entities.AgentState{
+			LastReportIP:                   ip,
+			LastReportTime:                 time.Now(),
+			HasProvisionedMasterComponents: hasMasterRole,
+			HasProvisionedHA:               !hasMasterRole,
+			HABackends:                     backends,
+		},
+	}
+}
+
+func Test_HABackendsStrategy(t *testing.T) {
+	master1 := newHABackendsTestAgent("master-1", "10.0.0.2", true, nil)
+	master2 := newHABackendsTestAgent("master-2", "10.0.0.1", true, nil)
+	ha := newHABackendsTestAgent("ha-1", "10.0.1.1", false, []string{"10.0.0.1"})
+	ac := cache.AgentCache{}
+	ac.InitializeWithValues(map[string]*entities.LightningMonkeyAgent{},
+		map[string]*entities.LightningMonkeyAgent{master1.Id: master1, master2.Id: master2},
+		map[string]*entities.LightningMonkeyAgent{},
+		map[string]*entities.LightningMonkeyAgent{ha.Id: ha})
+
+	gc := gomock.NewController(t)
+	defer gc.Finish()
+	cc := mock_lm.NewMockClusterController(gc)
+	cc.EXPECT().GetSettings().Return(entities.LightningMonkeyClusterSettings{HASettings: &entities.HASettings{NodeCount: 1, VIP: "10.0.1.100"}}).AnyTimes()
+
+	js := cache.HABackendsJobStrategy{}
+	result, _, args, err := js.CanDeploy(cc, *ha, &ac)
+	assert.Nil(t, err)
+	assert.True(t, result == entities.ConditionConfirmed)
+	assert.True(t, args["master-addresses"] == "10.0.0.1,10.0.0.2")
+	//reported backends are not sorted.
+	ha.State.HABackends = []string{"10.0.0.2", "10.0.0.1"}
+	result, _, _, err = js.CanDeploy(cc, *ha, &ac)
+	assert.Nil(t, err)
+	assert.True(t, result == entities.ConditionInapplicable)
+	//agents of previous versions never report backends.
+	ha.State.HABackends = nil
+	result, _, _, err = js.CanDeploy(cc, *ha, &ac)
+	assert.Nil(t, err)
+	assert.True(t, result == entities.ConditionInapplicable)
+	//non HA agents.
+	result, _, _, err = js.CanDeploy(cc, *master1, &ac)
+	assert.Nil(t, err)
+	assert.True(t, result == entities.ConditionInapplicable)
+}
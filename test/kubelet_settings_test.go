@@ -0,0 +1,93 @@
+package test
+
+import (
+	"encoding/json"
+	"github.com/g0194776/lightningmonkey/pkg/entities"
+	"github.com/g0194776/lightningmonkey/pkg/k8s"
+	"github.com/g0194776/lightningmonkey/pkg/utils"
+	assert "github.com/stretchr/testify/require"
+	"sigs.k8s.io/yaml"
+	"strings"
+	"testing"
+)
+
+func Test_ValidateKubeletSettings(t *testing.T) {
+	assert.Nil(t, utils.ValidateKubeletSettings(nil))
+	high, low, files := 90, 70, 3
+	s := &entities.KubeletSettings{
+		CgroupDriver:                "systemd",
+		EvictionHard:                map[string]string{"memory.available": "500Mi", "nodefs.available": "10%"},
+		EvictionSoft:                map[string]string{"memory.available": "1Gi"},
+		EvictionSoftGracePeriod:     map[string]string{"memory.available": "1m30s"},
+		ImageGCHighThresholdPercent: &high,
+		ImageGCLowThresholdPercent:  &low,
+		ContainerLogMaxSize:         "50Mi",
+		ContainerLogMaxFiles:        &files,
+		ExtraFlags:                  map[string]string{"max-open-files": "2000000"},
+	}
+	assert.Nil(t, utils.ValidateKubeletSettings(s))
+	negative, one := -1, 1
+	err := utils.ValidateKubeletSettings(&entities.KubeletSettings{
+		CgroupDriver:               "cgroupv2",
+		EvictionHard:               map[string]string{"memory.free": "1Gi", "nodefs.available": "110%"},
+		EvictionSoft:               map[string]string{"memory.available": "1Gi"},
+		ImageGCLowThresholdPercent: &high,
+		ContainerLogMaxSize:        "big",
+		ContainerLogMaxFiles:       &one,
+		KubeAPIQPS:                 &negative,
+		ExtraFlags:                 map[string]string{"--v": "2", "kubeconfig": "/tmp/kubelet.conf"},
+	})
+	assert.NotNil(t, err)
+	for _, field := range []string{"cgroup_driver", "memory.free", "110%", "grace period of soft eviction signal", "image_gc_low_threshold_percent", "container_log_max_size", "container_log_max_files", "kube_api_qps", "--v", "kubeconfig"} {
+		assert.True(t, strings.Contains(err.Error(), field), field)
+	}
+}
+
+func Test_RenderKubeletSettings(t *testing.T) {
+	slots := map[string]string{
+		entities.MasterSettings_MaxPodCountPerNode:  "110",
+		entities.MasterSettings_ServiceDNSDomain:    "cluster.local",
+		entities.MasterSettings_ServiceDNSClusterIP: "10.254.0.10",
+	}
+	//the built-in template is kept as it is.
+	tpl, err := k8s.RenderKubeletSettings(slots)
+	assert.Nil(t, err)
+	assert.True(t, strings.Contains(tpl, "cgroupDriver: cgroupfs\nclusterDNS:\n- 10.254.0.10"))
+	serialize, burst := true, 20
+	data, err := json.Marshal(&entities.KubeletSettings{
+		CgroupDriver:        "systemd",
+		EvictionHard:        map[string]string{"memory.available": "500Mi"},
+		SerializeImagePulls: &serialize,
+		KubeAPIBurst:        &burst,
+		ExtraFlags:          map[string]string{"v": "4"},
+	})
+	assert.Nil(t, err)
+	slots[entities.MasterSettings_KubeletSettings] = string(data)
+	tpl, err = k8s.RenderKubeletSettings(slots)
+	assert.Nil(t, err)
+	config := map[string]interface{}{}
+	assert.Nil(t, yaml.Unmarshal([]byte(tpl), &config))
+	assert.Equal(t, "systemd", config["cgroupDriver"])
+	assert.Equal(t, map[string]interface{}{"memory.available": "500Mi"}, config["evictionHard"])
+	assert.Equal(t, true, config["serializeImagePulls"])
+	assert.Equal(t, float64(20), config["kubeAPIBurst"])
+	//untouched fields.
+	assert.Equal(t, float64(110), config["maxPods"])
+	assert.Equal(t, "cluster.local", config["clusterDomain"])
+	assert.Equal(t, "10Mi", config["containerLogMaxSize"])
+	assert.Equal(t, "KubeletConfiguration", config["kind"])
+}
+
+func Test_MergeKubeletFlags(t *testing.T) {
+	cmd := []string{"kubelet", "--config=/etc/kubernetes/kubelet_settings.yml", "--network-plugin=cni", "--kube-reserved=cpu=1,memory=1Gi"}
+	assert.Equal(t, cmd, utils.MergeKubeletFlags(cmd, nil))
+	merged := utils.MergeKubeletFlags(cmd, map[string]string{"v": "2", "kube-reserved": "cpu=500m", "feature-gates": "RotateKubeletServerCertificate=true"})
+	assert.Equal(t, []string{
+		"kubelet",
+		"--config=/etc/kubernetes/kubelet_settings.yml",
+		"--network-plugin=cni",
+		"--kube-reserved=cpu=500m",
+		"--feature-gates=RotateKubeletServerCertificate=true",
+		"--v=2",
+	}, merged)
+}
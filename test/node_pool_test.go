@@ -0,0 +1,151 @@
+package test
+
+import (
+	"encoding/json"
+	mock_lm "github.com/g0194776/lightningmonkey/mocks"
+	"github.com/g0194776/lightningmonkey/pkg/cache"
+	"github.com/g0194776/lightningmonkey/pkg/entities"
+	"github.com/g0194776/lightningmonkey/pkg/utils"
+	"github.com/golang/mock/gomock"
+	assert "github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_ValidateNodePools(t *testing.T) {
+	high := 90
+	cluster := entities.LightningMonkeyClusterSettings{
+		Kubelet: &entities.KubeletSettings{ImageGCHighThresholdPercent: &high},
+		NodePools: []entities.NodePoolSettings{
+			{
+				Name:                "gpu",
+				Labels:              map[string]string{"accelerator": "nvidia-tesla-v100"},
+				Taints:              []entities.NodeTaint{{Key: "nvidia.com/gpu", Value: "present", Effect: "NoSchedule"}},
+				ResourceReservation: &entities.ResourceReservationSettings{Kube: "cpu=500m,memory=1Gi", System: "cpu=200m"},
+				Kubelet:             &entities.KubeletSettings{ExtraFlags: map[string]string{"feature-gates": "DevicePlugins=true"}},
+			},
+			{Name: "spot", Taints: []entities.NodeTaint{{Key: "spot", Effect: "NoExecute"}}},
+		},
+	}
+	assert.Nil(t, utils.ValidateNodePools(cluster))
+	assert.Nil(t, utils.ValidateNodePools(entities.LightningMonkeyClusterSettings{}))
+	low := 95
+	cluster.NodePools = []entities.NodePoolSettings{
+		{Name: "GPU_Pool", Labels: map[string]string{"accelerator": "nvidia tesla"}},
+		{Name: "spot", Taints: []entities.NodeTaint{{Key: "spot", Effect: "NoRun"}}},
+		{Name: "spot", ResourceReservation: &entities.ResourceReservationSettings{Kube: "cpu=lots"}},
+		//conflicts with the threshold of cluster's kubelet settings.
+		{Name: "batch", Kubelet: &entities.KubeletSettings{ImageGCLowThresholdPercent: &low}},
+	}
+	err := utils.ValidateNodePools(cluster)
+	assert.NotNil(t, err)
+	for _, problem := range []string{"node_pools[0].name", "node_pools[0].labels", "node_pools[1].taints[0].effect", "duplicated node pool \"spot\"", "node_pools[2].resource_reservation.kube", "node_pools[3].kubelet.image_gc_low_threshold_percent"} {
+		assert.True(t, strings.Contains(err.Error(), problem), problem)
+	}
+}
+
+func Test_ApplyNodePool(t *testing.T) {
+	qps, burst, files := 10, 20, 10
+	cluster := &entities.KubeletSettings{
+		CgroupDriver: "systemd",
+		KubeAPIQPS:   &qps,
+		ExtraFlags:   map[string]string{"v": "2", "feature-gates": "RotateKubeletServerCertificate=true"},
+	}
+	data, err := json.Marshal(cluster)
+	assert.Nil(t, err)
+	masterSettings := map[string]string{
+		entities.MasterSettings_ResourceReservation_Kube:   "cpu=1,memory=1Gi",
+		entities.MasterSettings_ResourceReservation_System: "cpu=1,memory=1Gi",
+		entities.MasterSettings_KubeletSettings:            string(data),
+	}
+	settings, err := utils.ApplyNodePool(masterSettings, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, masterSettings, settings)
+	pool := &entities.NodePoolSettings{
+		Name:                "edge",
+		ResourceReservation: &entities.ResourceReservationSettings{Kube: "cpu=200m", System: "cpu=100m"},
+		Kubelet:             &entities.KubeletSettings{KubeAPIBurst: &burst, ContainerLogMaxFiles: &files, ExtraFlags: map[string]string{"v": "4"}},
+	}
+	settings, err = utils.ApplyNodePool(masterSettings, pool)
+	assert.Nil(t, err)
+	assert.Equal(t, "cpu=200m", settings[entities.MasterSettings_ResourceReservation_Kube])
+	assert.Equal(t, "cpu=100m", settings[entities.MasterSettings_ResourceReservation_System])
+	//the cluster's master settings are never changed.
+	assert.Equal(t, "cpu=1,memory=1Gi", masterSettings[entities.MasterSettings_ResourceReservation_Kube])
+	merged, err := utils.ParseKubeletSettings(settings)
+	assert.Nil(t, err)
+	assert.Equal(t, "systemd", merged.CgroupDriver)
+	assert.Equal(t, qps, *merged.KubeAPIQPS)
+	assert.Equal(t, burst, *merged.KubeAPIBurst)
+	assert.Equal(t, files, *merged.ContainerLogMaxFiles)
+	assert.Equal(t, map[string]string{"v": "4", "feature-gates": "RotateKubeletServerCertificate=true"}, merged.ExtraFlags)
+	assert.Equal(t, map[string]string{"v": "2", "feature-gates": "RotateKubeletServerCertificate=true"}, cluster.ExtraFlags)
+	//only the given reservation is overridden.
+	pool = &entities.NodePoolSettings{Name: "edge", ResourceReservation: &entities.ResourceReservationSettings{Kube: "cpu=200m"}}
+	settings, err = utils.ApplyNodePool(masterSettings, pool)
+	assert.Nil(t, err)
+	assert.Equal(t, "cpu=200m", settings[entities.MasterSettings_ResourceReservation_Kube])
+	assert.Equal(t, "cpu=1,memory=1Gi", settings[entities.MasterSettings_ResourceReservation_System])
+}
+
+func Test_NodePoolLabelsAndTaints(t *testing.T) {
+	pool := &entities.NodePoolSettings{
+		Name:   "gpu",
+		Labels: map[string]string{"accelerator": "nvidia", "zone": "a"},
+		Taints: []entities.NodeTaint{{Key: "nvidia.com/gpu", Value: "present", Effect: "NoSchedule"}, {Key: "spot", Effect: "NoExecute"}},
+	}
+	labels, err := utils.MergeNodeLabels(pool, "zone=b,rack=r1")
+	assert.Nil(t, err)
+	assert.Equal(t, "accelerator=nvidia,rack=r1,zone=b", labels)
+	labels, err = utils.MergeNodeLabels(nil, "")
+	assert.Nil(t, err)
+	assert.Equal(t, "", labels)
+	assert.Equal(t, "nvidia.com/gpu=present:NoSchedule,spot:NoExecute", utils.FormatNodeTaints(pool.Taints))
+}
+
+func Test_MinionJobCarriesNodePool(t *testing.T) {
+	master := entities.LightningMonkeyAgent{
+		Id:            "master",
+		Hostname:      "keepers-1",
+		HasMasterRole: true,
+		State:         &entities.AgentState{LastReportIP: "192.168.1.1", HasProvisionedMasterComponents: true, LastReportTime: time.Now()},
+	}
+	minion := entities.LightningMonkeyAgent{
+		Id:            "minion",
+		Hostname:      "workers-1",
+		HasMinionRole: true,
+		NodePool:      "gpu",
+		State:         &entities.AgentState{LastReportIP: "192.168.1.2", LastReportTime: time.Now()},
+	}
+	pool := entities.NodePoolSettings{Name: "gpu", Labels: map[string]string{"accelerator": "nvidia"}}
+	settings := entities.LightningMonkeyClusterSettings{}
+
+	gc := gomock.NewController(t)
+	defer gc.Finish()
+	cc := mock_lm.NewMockClusterController(gc)
+	cc.EXPECT().GetSettings().DoAndReturn(func() entities.LightningMonkeyClusterSettings { return settings }).AnyTimes()
+	cc.EXPECT().RequestKubeletBootstrapToken(gomock.Any()).Return("abcdef.0123456789abcdef").AnyTimes()
+
+	ac := cache.AgentCache{}
+	ac.InitializeWithValues(map[string]*entities.LightningMonkeyAgent{}, map[string]*entities.LightningMonkeyAgent{master.Id: &master}, map[string]*entities.LightningMonkeyAgent{minion.Id: &minion}, map[string]*entities.LightningMonkeyAgent{})
+	js := &cache.ClusterKubernetesMinionJobStrategy{}
+	//waiting for the node pool being defined.
+	result, reason, _, err := js.CanDeploy(cc, minion, &ac)
+	assert.Nil(t, err)
+	assert.Equal(t, entities.ConditionNotConfirmed, result)
+	assert.True(t, strings.Contains(reason, "gpu"))
+	settings.NodePools = []entities.NodePoolSettings{pool}
+	result, _, args, err := js.CanDeploy(cc, minion, &ac)
+	assert.Nil(t, err)
+	assert.Equal(t, entities.ConditionConfirmed, result)
+	p, err := utils.ParseNodePool(args["node_pool"])
+	assert.Nil(t, err)
+	assert.Equal(t, pool, *p)
+	//the minion which does not belong to any node pool.
+	minion.NodePool = ""
+	_, _, args, err = js.CanDeploy(cc, minion, &ac)
+	assert.Nil(t, err)
+	_, isOK := args["node_pool"]
+	assert.False(t, isOK)
+}
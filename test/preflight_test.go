@@ -0,0 +1,69 @@
+package test
+
+import (
+	mock_lm "github.com/g0194776/lightningmonkey/mocks"
+	"github.com/g0194776/lightningmonkey/pkg/cache"
+	"github.com/g0194776/lightningmonkey/pkg/entities"
+	"github.com/g0194776/lightningmonkey/pkg/utils"
+	"github.com/golang/mock/gomock"
+	assert "github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newPreflightTestAgent(id, ip string, hasProvisionedETCD bool) *entities.LightningMonkeyAgent {
+	return &entities.LightningMonkeyAgent{
+		Id:          id,
+		Hostname:    id,
+		HasETCDRole: true,
+		State: &entities.AgentState{
+			LastReportIP:       ip,
+			HasProvisionedETCD: hasProvisionedETCD,
+			LastReportTime:     time.Now(),
+		},
+	}
+}
+
+func Test_PreflightChecksGateScheduling(t *testing.T) {
+	js := cache.ClusterJobSchedulerImple{}
+	js.InitializeStrategies()
+	gc := gomock.NewController(t)
+	defer gc.Finish()
+	cc := mock_lm.NewMockClusterController(gc)
+	cc.EXPECT().GetSettings().Return(entities.LightningMonkeyClusterSettings{ExpectedETCDCount: 3}).AnyTimes()
+	cc.EXPECT().GetUpgrade().Return(nil).AnyTimes()
+	cc.EXPECT().GetSnapshots().Return(nil).AnyTimes()
+
+	agent1 := newPreflightTestAgent("etcd-1", "127.0.0.1", false)
+	agent2 := newPreflightTestAgent("etcd-2", "127.0.0.2", true)
+	agent3 := newPreflightTestAgent("etcd-3", "127.0.0.3", true)
+	ac := cache.AgentCache{}
+	ac.InitializeWithValues(map[string]*entities.LightningMonkeyAgent{agent1.Id: agent1, agent2.Id: agent2, agent3.Id: agent3},
+		map[string]*entities.LightningMonkeyAgent{}, map[string]*entities.LightningMonkeyAgent{}, map[string]*entities.LightningMonkeyAgent{})
+	agent1.Preflight = []entities.PreflightCheck{
+		{Name: "swap", IsPassed: false, Reason: "Swap is enabled", Roles: []string{entities.AgentRole_ETCD, entities.AgentRole_Minion}},
+		{Name: "disk", IsPassed: true, Roles: []string{entities.AgentRole_ETCD}},
+	}
+	job, err := js.GetNextJob(cc, *agent1, &ac, func(i int) {})
+	assert.Nil(t, err)
+	assert.True(t, job.Name == entities.AgentJob_NOP)
+	assert.True(t, strings.Contains(job.Reason, "swap"))
+	//failed checks of the other roles never block it.
+	agent1.Preflight[0].Roles = []string{entities.AgentRole_Minion}
+	job, err = js.GetNextJob(cc, *agent1, &ac, func(i int) {})
+	assert.Nil(t, err)
+	assert.True(t, job.Name == entities.AgentJob_Deploy_ETCD)
+	//agents of previous versions never report any preflight check.
+	agent1.Preflight = nil
+	job, err = js.GetNextJob(cc, *agent1, &ac, func(i int) {})
+	assert.Nil(t, err)
+	assert.True(t, job.Name == entities.AgentJob_Deploy_ETCD)
+}
+
+func Test_CompareVersions(t *testing.T) {
+	assert.Equal(t, 1, utils.CompareVersions("18.09.7", "1.13.1"))
+	assert.Equal(t, 0, utils.CompareVersions("v1.2.0", "1.2"))
+	assert.Equal(t, -1, utils.CompareVersions("1.12.6-ce", "1.13.1"))
+	assert.Equal(t, 1, utils.CompareVersions("v1.3.3+unknown", "1.2.0"))
+}
@@ -0,0 +1,130 @@
+package test
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"github.com/g0194776/lightningmonkey/pkg/entities"
+	"github.com/g0194776/lightningmonkey/pkg/utils"
+	assert "github.com/stretchr/testify/require"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func Test_UpdateComponentProbe(t *testing.T) {
+	p := entities.ComponentProbe{Name: "kubelet"}
+	probeErr := errors.New("connection refused")
+	//never succeeded.
+	utils.UpdateComponentProbe(&p, probeErr, time.Millisecond, 3)
+	assert.False(t, p.IsHealthy)
+	utils.UpdateComponentProbe(&p, nil, time.Millisecond*15, 3)
+	assert.True(t, p.IsHealthy)
+	assert.Equal(t, int64(15), p.LatencyMs)
+	assert.Equal(t, 0, p.ConsecutiveFailures)
+	//no flapping until it fails 3 times in a row.
+	utils.UpdateComponentProbe(&p, probeErr, time.Millisecond, 3)
+	utils.UpdateComponentProbe(&p, probeErr, time.Millisecond, 3)
+	assert.True(t, p.IsHealthy)
+	assert.Equal(t, 2, p.ConsecutiveFailures)
+	assert.Equal(t, probeErr.Error(), p.Reason)
+	utils.UpdateComponentProbe(&p, probeErr, time.Millisecond, 3)
+	assert.False(t, p.IsHealthy)
+	utils.UpdateComponentProbe(&p, nil, time.Millisecond, 3)
+	assert.True(t, p.IsHealthy)
+	assert.Equal(t, "", p.Reason)
+}
+
+//issueTestCertificate signs a certificate by the given CA, a nil CA means the certificate is a self-signed CA.
+func issueTestCertificate(t *testing.T, commonName string, ca *x509.Certificate, caKey *rsa.PrivateKey) (*x509.Certificate, *rsa.PrivateKey, []byte, []byte) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.Nil(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: commonName},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		BasicConstraintsValid: true,
+	}
+	if ca == nil {
+		template.IsCA = true
+		template.KeyUsage |= x509.KeyUsageCertSign
+		ca, caKey = template, key
+	}
+	data, err := x509.CreateCertificate(rand.Reader, template, ca, &key.PublicKey, caKey)
+	assert.Nil(t, err)
+	cert, err := x509.ParseCertificate(data)
+	assert.Nil(t, err)
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: data})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	return cert, key, certPEM, keyPEM
+}
+
+func Test_ProbeCertificates_CertificatesDirectoryLayout(t *testing.T) {
+	//same layout as the agent's "--cert-dir=/etc/kubernetes/pki".
+	certPath, err := ioutil.TempDir("", "pki")
+	assert.Nil(t, err)
+	defer os.RemoveAll(certPath)
+	assert.Nil(t, os.MkdirAll(filepath.Join(certPath, "etcd"), 0755))
+	writeFile := func(name string, data []byte) {
+		assert.Nil(t, ioutil.WriteFile(filepath.Join(certPath, name), data, 0600))
+	}
+	newServer := func(ca *x509.Certificate, caKey *rsa.PrivateKey, clientCA *x509.Certificate) *httptest.Server {
+		_, _, certPEM, keyPEM := issueTestCertificate(t, "server", ca, caKey)
+		cert, err := tls.X509KeyPair(certPEM, keyPEM)
+		assert.Nil(t, err)
+		server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte("ok"))
+		}))
+		server.TLS = &tls.Config{Certificates: []tls.Certificate{cert}}
+		if clientCA != nil {
+			pool := x509.NewCertPool()
+			pool.AddCert(clientCA)
+			server.TLS.ClientCAs = pool
+			server.TLS.ClientAuth = tls.RequireAndVerifyClientCert
+		}
+		server.StartTLS()
+		return server
+	}
+	probe := func(c utils.ProbeCertificates, url string) error {
+		client, err := utils.NewProbeClient(c, time.Second*5)
+		if err != nil {
+			return err
+		}
+		rsp, err := client.Get(url)
+		if err != nil {
+			return err
+		}
+		return rsp.Body.Close()
+	}
+
+	ca, caKey, caPEM, _ := issueTestCertificate(t, "kubernetes", nil, nil)
+	writeFile("ca.crt", caPEM)
+	apiServer := newServer(ca, caKey, nil)
+	defer apiServer.Close()
+	assert.Nil(t, probe(utils.GetAPIServerProbeCertificates(certPath), apiServer.URL))
+
+	etcdCA, etcdCAKey, etcdCAPEM, _ := issueTestCertificate(t, "etcd-ca", nil, nil)
+	_, _, clientPEM, clientKeyPEM := issueTestCertificate(t, "kube-etcd-healthcheck-client", etcdCA, etcdCAKey)
+	writeFile("etcd/ca.crt", etcdCAPEM)
+	writeFile("etcd/healthcheck-client.crt", clientPEM)
+	writeFile("etcd/healthcheck-client.key", clientKeyPEM)
+	etcd := newServer(etcdCA, etcdCAKey, etcdCA)
+	defer etcd.Close()
+	assert.Nil(t, probe(utils.GetETCDProbeCertificates(certPath), etcd.URL))
+	//ETCD is signed by its own CA rather than the cluster CA.
+	assert.NotNil(t, probe(utils.GetAPIServerProbeCertificates(certPath), etcd.URL))
+}
@@ -0,0 +1,29 @@
+package test
+
+import (
+	"github.com/g0194776/lightningmonkey/pkg/entities"
+	"github.com/g0194776/lightningmonkey/pkg/utils"
+	assert "github.com/stretchr/testify/require"
+	"testing"
+)
+
+func Test_DiffSystemRoutes(t *testing.T) {
+	desired := []entities.SystemRoute{
+		{Destination: "10.244.1.0/24", Gateway: "192.168.0.11"},
+		{Destination: "10.244.2.0/24", Gateway: "192.168.0.22"},
+		{Destination: "10.244.3.0/24", Gateway: "192.168.0.13"},
+	}
+	actual := []entities.SystemRoute{
+		{Destination: "10.244.4.0/24", Gateway: "192.168.0.14"},
+		{Destination: "10.244.2.0/24", Gateway: "192.168.0.12"},
+		{Destination: "10.244.1.0/24", Gateway: "192.168.0.11"},
+	}
+	added, changed, removed := utils.DiffSystemRoutes(desired, actual)
+	assert.True(t, len(added) == 1 && added[0].Destination == "10.244.3.0/24")
+	assert.True(t, len(changed) == 1 && changed[0].Gateway == "192.168.0.22")
+	assert.True(t, len(removed) == 1 && removed[0].Destination == "10.244.4.0/24")
+	added, changed, removed = utils.DiffSystemRoutes(desired, desired)
+	assert.True(t, len(added) == 0 && len(changed) == 0 && len(removed) == 0)
+	added, changed, removed = utils.DiffSystemRoutes(nil, actual)
+	assert.True(t, len(added) == 0 && len(changed) == 0 && len(removed) == 3)
+}
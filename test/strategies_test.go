@@ -0,0 +1,82 @@
+package test
+
+import (
+	"github.com/g0194776/lightningmonkey/pkg/cache"
+	"github.com/g0194776/lightningmonkey/pkg/entities"
+	assert "github.com/stretchr/testify/require"
+	"testing"
+)
+
+type fakeReconciler struct {
+	name      string
+	dependsOn []string
+}
+
+func (r *fakeReconciler) GetStrategyName() string {
+	return r.name
+}
+
+func (r *fakeReconciler) GetDependencies() []string {
+	return r.dependsOn
+}
+
+func (r *fakeReconciler) Reconcile(cc cache.ClusterController, cache *cache.AgentCache) error {
+	return nil
+}
+
+func Test_DefaultStrategyRegistry_Order(t *testing.T) {
+	rs, err := cache.DefaultStrategyRegistry.Sorted()
+	assert.Nil(t, err)
+	positions := map[string]int{}
+	for i := 0; i < len(rs); i++ {
+		positions[rs[i].Name] = i
+	}
+	for i := 0; i < len(rs); i++ {
+		for _, dep := range rs[i].DependsOn {
+			assert.True(t, positions[dep] < i)
+		}
+	}
+	jobs, err := cache.DefaultStrategyRegistry.GetAgentJobStrategies()
+	assert.Nil(t, err)
+	assert.True(t, len(jobs) == 9)
+	assert.True(t, jobs[0].GetStrategyName() == entities.AgentJob_Upgrade)
+	assert.True(t, jobs[1].GetStrategyName() == entities.AgentJob_ETCD_Restore)
+	assert.True(t, jobs[2].GetStrategyName() == entities.AgentJob_ETCD_Member)
+	assert.True(t, jobs[3].GetStrategyName() == entities.AgentJob_ETCD_Snapshot)
+	assert.True(t, jobs[4].GetStrategyName() == entities.AgentJob_Deploy_ETCD)
+	assert.True(t, jobs[5].GetStrategyName() == entities.AgentJob_Deploy_Master)
+	assert.True(t, jobs[6].GetStrategyName() == entities.AgentJob_Deploy_HA)
+	assert.True(t, jobs[7].GetStrategyName() == entities.AgentJob_HA_Backends)
+	assert.True(t, jobs[8].GetStrategyName() == entities.AgentJob_Deploy_Minion)
+	reconcilers, err := cache.DefaultStrategyRegistry.GetClusterReconcilers()
+	assert.Nil(t, err)
+	assert.True(t, len(reconcilers) == 5)
+}
+
+func Test_StrategyRegistry_TopologicalSort(t *testing.T) {
+	sr := cache.NewStrategyRegistry()
+	assert.Nil(t, sr.RegisterReconciler(&fakeReconciler{name: "C", dependsOn: []string{"B"}}))
+	assert.Nil(t, sr.RegisterReconciler(&fakeReconciler{name: "B", dependsOn: []string{"A"}}))
+	assert.Nil(t, sr.RegisterReconciler(&fakeReconciler{name: "A"}))
+	assert.NotNil(t, sr.RegisterReconciler(&fakeReconciler{name: "A"}))
+	rs, err := sr.Sorted()
+	assert.Nil(t, err)
+	assert.True(t, rs[0].Name == "A")
+	assert.True(t, rs[1].Name == "B")
+	assert.True(t, rs[2].Name == "C")
+}
+
+func Test_StrategyRegistry_CircularDependencies(t *testing.T) {
+	sr := cache.NewStrategyRegistry()
+	assert.Nil(t, sr.RegisterReconciler(&fakeReconciler{name: "A", dependsOn: []string{"B"}}))
+	assert.Nil(t, sr.RegisterReconciler(&fakeReconciler{name: "B", dependsOn: []string{"A"}}))
+	_, err := sr.Sorted()
+	assert.NotNil(t, err)
+}
+
+func Test_StrategyRegistry_UnknownDependency(t *testing.T) {
+	sr := cache.NewStrategyRegistry()
+	assert.Nil(t, sr.RegisterReconciler(&fakeReconciler{name: "A", dependsOn: []string{"NOT-EXISTED"}}))
+	_, err := sr.Sorted()
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,167 @@
+package test
+
+import (
+	mock_lm "github.com/g0194776/lightningmonkey/mocks"
+	"github.com/g0194776/lightningmonkey/pkg/cache"
+	"github.com/g0194776/lightningmonkey/pkg/entities"
+	"github.com/g0194776/lightningmonkey/pkg/managers"
+	"github.com/golang/mock/gomock"
+	assert "github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_ValidateVersionSkew(t *testing.T) {
+	assert.Nil(t, managers.ValidateVersionSkew("1.12.5", "1.12.6"))
+	assert.Nil(t, managers.ValidateVersionSkew("1.12.5", "1.13.0"))
+	assert.Nil(t, managers.ValidateVersionSkew("v1.12.5", "v1.13.1"))
+	assert.NotNil(t, managers.ValidateVersionSkew("1.12.5", "1.12.5"))
+	assert.NotNil(t, managers.ValidateVersionSkew("1.13.0", "1.12.5"))
+	assert.NotNil(t, managers.ValidateVersionSkew("1.12.5", "1.14.0"))
+	assert.NotNil(t, managers.ValidateVersionSkew("1.12.5", "2.0.0"))
+	assert.NotNil(t, managers.ValidateVersionSkew("1.12", "1.13.0"))
+}
+
+func Test_UpgradeMastersOneByOne(t *testing.T) {
+	js := cache.ClusterJobSchedulerImple{}
+	js.InitializeStrategies()
+
+	master1 := entities.LightningMonkeyAgent{
+		Id:            "a-master",
+		Hostname:      "keepers-1",
+		HasMasterRole: true,
+		State: &entities.AgentState{
+			LastReportIP:                   "192.168.1.1",
+			HasProvisionedMasterComponents: true,
+			LastReportTime:                 time.Now(),
+		},
+	}
+	master2 := entities.LightningMonkeyAgent{
+		Id:            "b-master",
+		Hostname:      "keepers-2",
+		HasMasterRole: true,
+		State: &entities.AgentState{
+			LastReportIP:                   "192.168.1.2",
+			HasProvisionedMasterComponents: true,
+			LastReportTime:                 time.Now(),
+		},
+	}
+	u := &entities.ClusterUpgrade{
+		FromVersion: "1.12.5",
+		ToVersion:   "1.13.5",
+		Status:      entities.UpgradeStatus_Running,
+		Stages:      []string{entities.UpgradeStage_Images, entities.UpgradeStage_Master},
+		StageIndex:  1,
+		Nodes: map[string]*entities.ClusterUpgradeNode{
+			master1.Id: {Hostname: master1.Hostname, HasMasterRole: true},
+			master2.Id: {Hostname: master2.Hostname, HasMasterRole: true},
+		},
+	}
+
+	gc := gomock.NewController(t)
+	defer gc.Finish()
+	cc := mock_lm.NewMockClusterController(gc)
+	cc.EXPECT().GetUpgrade().Return(u).AnyTimes()
+	cc.EXPECT().GetSnapshots().Return(nil).AnyTimes()
+	cc.EXPECT().GetSettings().Return(entities.LightningMonkeyClusterSettings{KubernetesVersion: "1.12.5", ExpectedETCDCount: 1}).AnyTimes()
+
+	ac := cache.AgentCache{}
+	ac.InitializeWithValues(map[string]*entities.LightningMonkeyAgent{}, map[string]*entities.LightningMonkeyAgent{master1.Id: &master1, master2.Id: &master2}, map[string]*entities.LightningMonkeyAgent{}, map[string]*entities.LightningMonkeyAgent{})
+	job, err := js.GetNextJob(cc, master1, &ac, func(i int) {})
+	assert.Nil(t, err)
+	assert.True(t, job.Name == entities.AgentJob_Upgrade)
+	assert.True(t, job.Arguments["stage"] == entities.UpgradeStage_Master)
+	assert.True(t, job.Arguments["version"] == "1.13.5")
+	assert.True(t, job.Arguments["attempt"] == "0")
+	//the second master must wait until the first one has been upgraded.
+	job, err = js.GetNextJob(cc, master2, &ac, func(i int) {})
+	assert.Nil(t, err)
+	assert.True(t, job.Name == entities.AgentJob_NOP)
+	assert.True(t, strings.Contains(job.Reason, master1.Hostname))
+
+	u.Nodes[master1.Id].Stage = entities.UpgradeStage_Master
+	u.Nodes[master1.Id].Status = entities.UpgradeNodeStatus_Succeed
+	job, err = js.GetNextJob(cc, master2, &ac, func(i int) {})
+	assert.Nil(t, err)
+	assert.True(t, job.Name == entities.AgentJob_Upgrade)
+	//a completed node never receives the same stage again.
+	job, err = js.GetNextJob(cc, master1, &ac, func(i int) {})
+	assert.Nil(t, err)
+	assert.True(t, job.Name != entities.AgentJob_Upgrade)
+}
+
+func Test_UpgradeKubeletsInBatches(t *testing.T) {
+	js := cache.ClusterJobSchedulerImple{}
+	js.InitializeStrategies()
+
+	minions := map[string]*entities.LightningMonkeyAgent{}
+	u := &entities.ClusterUpgrade{
+		FromVersion:      "1.12.5",
+		ToVersion:        "1.13.5",
+		Status:           entities.UpgradeStatus_Running,
+		Stages:           []string{entities.UpgradeStage_Images, entities.UpgradeStage_Master, entities.UpgradeStage_Kubelet},
+		StageIndex:       2,
+		KubeletBatchSize: 2,
+		Nodes:            map[string]*entities.ClusterUpgradeNode{},
+	}
+	for _, name := range []string{"a", "b", "c"} {
+		minions[name] = &entities.LightningMonkeyAgent{
+			Id:            name,
+			Hostname:      "workers-" + name,
+			HasMinionRole: true,
+			State:         &entities.AgentState{LastReportIP: "192.168.1.2", HasProvisionedMinion: true, LastReportTime: time.Now()},
+		}
+		u.Nodes[name] = &entities.ClusterUpgradeNode{Hostname: "workers-" + name, HasMinionRole: true}
+	}
+	master := entities.LightningMonkeyAgent{
+		Id:            "master",
+		Hostname:      "keepers-1",
+		HasMasterRole: true,
+		State:         &entities.AgentState{LastReportIP: "192.168.1.1", HasProvisionedMasterComponents: true, LastReportTime: time.Now()},
+	}
+
+	gc := gomock.NewController(t)
+	defer gc.Finish()
+	cc := mock_lm.NewMockClusterController(gc)
+	cc.EXPECT().GetUpgrade().Return(u).AnyTimes()
+	cc.EXPECT().GetSnapshots().Return(nil).AnyTimes()
+	cc.EXPECT().GetSettings().Return(entities.LightningMonkeyClusterSettings{KubernetesVersion: "1.12.5", ExpectedETCDCount: 1}).AnyTimes()
+	cc.EXPECT().RequestKubeletBootstrapToken(gomock.Any()).Return("abcdef.0123456789abcdef").AnyTimes()
+
+	ac := cache.AgentCache{}
+	ac.InitializeWithValues(map[string]*entities.LightningMonkeyAgent{}, map[string]*entities.LightningMonkeyAgent{master.Id: &master}, minions, map[string]*entities.LightningMonkeyAgent{})
+	for _, name := range []string{"a", "b"} {
+		job, err := js.GetNextJob(cc, *minions[name], &ac, func(i int) {})
+		assert.Nil(t, err)
+		assert.True(t, job.Name == entities.AgentJob_Upgrade)
+		assert.True(t, job.Arguments["stage"] == entities.UpgradeStage_Kubelet)
+	}
+	//the third kubelet must wait until one of the first batch has been upgraded.
+	job, err := js.GetNextJob(cc, *minions["c"], &ac, func(i int) {})
+	assert.Nil(t, err)
+	assert.True(t, job.Name == entities.AgentJob_NOP)
+	assert.True(t, strings.Contains(job.Reason, "workers-a, workers-b"))
+
+	u.Nodes["a"].Stage = entities.UpgradeStage_Kubelet
+	u.Nodes["a"].Status = entities.UpgradeNodeStatus_Succeed
+	job, err = js.GetNextJob(cc, *minions["c"], &ac, func(i int) {})
+	assert.Nil(t, err)
+	assert.True(t, job.Name == entities.AgentJob_Upgrade)
+}
+
+func Test_ValidateUpgradeRollback(t *testing.T) {
+	u := &entities.ClusterUpgrade{
+		FromVersion: "1.12.5",
+		ToVersion:   "1.13.5",
+		Stages:      []string{entities.UpgradeStage_Images, entities.UpgradeStage_ETCD, entities.UpgradeStage_Master, entities.UpgradeStage_Kubelet},
+	}
+	assert.Nil(t, managers.ValidateUpgradeRollback(u))
+	//ETCD never supports downgrading once it has been started to upgrade.
+	u.StageIndex = 1
+	assert.NotNil(t, managers.ValidateUpgradeRollback(u))
+	u.StageIndex = 3
+	assert.NotNil(t, managers.ValidateUpgradeRollback(u))
+	u.Stages = []string{entities.UpgradeStage_Images, entities.UpgradeStage_Master, entities.UpgradeStage_Kubelet}
+	assert.Nil(t, managers.ValidateUpgradeRollback(u))
+}